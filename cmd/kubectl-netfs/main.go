@@ -0,0 +1,15 @@
+package main
+
+import (
+	"os"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/plugin"
+)
+
+func main() {
+	if err := plugin.NewApp().Run(os.Args); err != nil {
+		logrus.Fatal(err)
+	}
+}
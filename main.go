@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	lhclientset "github.com/longhorn/longhorn-manager/k8s/pkg/client/clientset/versioned"
 	corev1 "github.com/rancher/wrangler/v3/pkg/generated/controllers/core"
@@ -19,14 +20,33 @@ import (
 	"github.com/rancher/wrangler/v3/pkg/start"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
+	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/backend"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/backend/ganesha"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/backend/longhorn"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/command"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/controller/drain"
 	"github.com/Vicente-Cheng/networkfs-manager/pkg/controller/endpoint"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/controller/export"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/controller/mount"
 	"github.com/Vicente-Cheng/networkfs-manager/pkg/controller/networkfilesystem"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/controller/schedule"
 	"github.com/Vicente-Cheng/networkfs-manager/pkg/controller/sharemanager"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/controller/volume"
 	ntefsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/generated/controllers/harvesterhci.io"
 	ctrllonghorn "github.com/Vicente-Cheng/networkfs-manager/pkg/generated/controllers/longhorn.io"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/idle"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/metrics"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/mounter"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/placement"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/prober"
 	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/webhook"
 )
 
 func main() {
@@ -61,8 +81,126 @@ func main() {
 			EnvVars:     []string{"HARVESTER_NAMESPACE"},
 			Destination: &opt.Namespace,
 		},
+		&cli.StringFlag{
+			Name:        "ganesha-image",
+			Value:       "longhornio/longhorn-share-manager:v1.7.0",
+			DefaultText: "longhornio/longhorn-share-manager:v1.7.0",
+			EnvVars:     []string{"GANESHA_IMAGE"},
+			Usage:       "Image which provides ganesha.nfsd for the Ganesha export backend",
+			Destination: &opt.GaneshaImage,
+		},
+		&cli.StringFlag{
+			Name:        "samba-image",
+			Value:       "quay.io/samba.org/samba-server:v0.5",
+			DefaultText: "quay.io/samba.org/samba-server:v0.5",
+			EnvVars:     []string{"SAMBA_IMAGE"},
+			Usage:       "Image which provides smbd for the SMB protocol of the Ganesha export backend",
+			Destination: &opt.SambaImage,
+		},
+		&cli.IntFlag{
+			Name:        "recovery-retry-budget",
+			Value:       5,
+			DefaultText: "5",
+			EnvVars:     []string{"RECOVERY_RETRY_BUDGET"},
+			Usage:       "Number of the recovery attempts of a failed export before giving up",
+			Destination: &opt.RecoveryRetryBudget,
+		},
+		&cli.DurationFlag{
+			Name:        "teardown-timeout",
+			Value:       5 * time.Minute,
+			DefaultText: "5m",
+			EnvVars:     []string{"TEARDOWN_TIMEOUT"},
+			Usage:       "Timeout of waiting for the export of a deleting network filesystem to stop before it is removed anyway",
+			Destination: &opt.TeardownTimeout,
+		},
+		&cli.DurationFlag{
+			Name:        "probe-interval",
+			Value:       time.Minute,
+			DefaultText: "1m",
+			EnvVars:     []string{"PROBE_INTERVAL"},
+			Usage:       "Interval of the active health probe against the enabled endpoints, 0 to disable the probe",
+			Destination: &opt.ProbeInterval,
+		},
+		&cli.DurationFlag{
+			Name:        "probe-timeout",
+			Value:       10 * time.Second,
+			DefaultText: "10s",
+			EnvVars:     []string{"PROBE_TIMEOUT"},
+			Usage:       "Timeout of a single health probe",
+			Destination: &opt.ProbeTimeout,
+		},
+		&cli.DurationFlag{
+			Name:        "idle-check-interval",
+			Value:       time.Minute,
+			DefaultText: "1m",
+			EnvVars:     []string{"IDLE_CHECK_INTERVAL"},
+			Usage:       "Interval of counting the clients of the network filesystems with the idleTimeout, 0 to disable the idle check",
+			Destination: &opt.IdleCheckInterval,
+		},
+		&cli.IntFlag{
+			Name:        "drain-max-migrations",
+			Value:       1,
+			DefaultText: "1",
+			EnvVars:     []string{"DRAIN_MAX_MIGRATIONS"},
+			Usage:       "Number of the exports migrated off the cordoned or maintained nodes at once, 0 to let them fail over when the node is cordoned",
+			Destination: &opt.DrainMaxMigrations,
+		},
+		&cli.IntFlag{
+			Name:        "metrics-port",
+			Value:       9190,
+			DefaultText: "9190",
+			EnvVars:     []string{"METRICS_PORT"},
+			Usage:       "Port of the Prometheus metrics endpoint, 0 to disable the metrics",
+			Destination: &opt.MetricsPort,
+		},
+		&cli.StringFlag{
+			Name:        "node-name",
+			EnvVars:     []string{"NODE_NAME"},
+			Usage:       "Name of the node on which the manager runs, required by the mount agent",
+			Destination: &opt.NodeName,
+		},
+		&cli.BoolFlag{
+			Name:        "mount-agent",
+			EnvVars:     []string{"MOUNT_AGENT"},
+			Usage:       "Run the node agent which mounts the NetworkFilesystemMount onto the host",
+			Destination: &opt.MountAgent,
+		},
+		&cli.StringFlag{
+			Name:        "mount-host-path-prefix",
+			Value:       utils.DefaultMountHostPathPrefix,
+			DefaultText: utils.DefaultMountHostPathPrefix,
+			EnvVars:     []string{"MOUNT_HOST_PATH_PREFIX"},
+			Usage:       "Directory below which the NetworkFilesystemMount may mount the network filesystems on the host",
+			Destination: &opt.MountHostPathPrefix,
+		},
+		&cli.IntFlag{
+			Name:        "webhook-port",
+			Value:       8443,
+			DefaultText: "8443",
+			EnvVars:     []string{"WEBHOOK_PORT"},
+			Usage:       "Port of the admission webhook server, 0 to disable the webhook",
+			Destination: &opt.WebhookPort,
+		},
+		&cli.StringFlag{
+			Name:        "webhook-name",
+			Value:       "harvester-network-fs-manager-webhook",
+			DefaultText: "harvester-network-fs-manager-webhook",
+			EnvVars:     []string{"WEBHOOK_NAME"},
+			Usage:       "Name of the ValidatingWebhookConfiguration",
+			Destination: &opt.WebhookName,
+		},
+		&cli.StringFlag{
+			Name:        "webhook-service",
+			Value:       "harvester-network-fs-manager-webhook",
+			DefaultText: "harvester-network-fs-manager-webhook",
+			EnvVars:     []string{"WEBHOOK_SERVICE_NAME"},
+			Usage:       "Name of the Service in front of the admission webhook server",
+			Destination: &opt.WebhookServiceName,
+		},
 	}
 
+	app.Commands = command.Commands(&opt)
+
 	app.Action = func(_ *cli.Context) error {
 		initLogs(&opt)
 		return run(&opt)
@@ -117,23 +255,99 @@ func run(opt *utils.Option) error {
 		return fmt.Errorf("failed to create longhorn controller: %v", err)
 	}
 
+	// every replica serves the metrics, the state of the network filesystems is only exported by the leader
+	if opt.MetricsPort > 0 {
+		go func() {
+			if err := metrics.ListenAndServe(ctx, opt.MetricsPort); err != nil {
+				logrus.Fatalf("failed to run metrics server: %v", err)
+			}
+		}()
+	}
+
+	// webhook is stateless, so every replica serves it regardless of the leadership
+	if opt.WebhookPort > 0 {
+		crdClient, err := apiextensionsclient.NewForConfig(config)
+		if err != nil {
+			return fmt.Errorf("failed to create apiextensions client: %v", err)
+		}
+		webhookServer := webhook.NewServer(client, crdClient, lhClient, opt)
+		go func() {
+			if err := webhookServer.ListenAndServe(ctx); err != nil {
+				logrus.Fatalf("failed to run webhook server: %v", err)
+			}
+		}()
+	}
+
+	recorder := utils.NewEventRecorder(ctx, client, opt.NodeName)
+
+	// the mount agent runs on every node, its controllers are started regardless of the leadership
+	if opt.MountAgent {
+		if err := runMountAgent(ctx, config, recorder, opt); err != nil {
+			return err
+		}
+	}
+
 	endpoints := clientv1.Core().V1().Endpoints()
 	networkFilsystems := clientNetfs.Harvesterhci().V1beta1().NetworkFilesystem()
+	networkFSExports := clientNetfs.Harvesterhci().V1beta1().NetworkFilesystemExport()
 	sharemanagers := lhCtrlClient.Longhorn().V1beta2().ShareManager()
+	volumes := lhCtrlClient.Longhorn().V1beta2().Volume()
+	pvcs := clientv1.Core().V1().PersistentVolumeClaim()
+	pvs := clientv1.Core().V1().PersistentVolume()
+	pods := clientv1.Core().V1().Pod()
+	configmaps := clientv1.Core().V1().ConfigMap()
+	services := clientv1.Core().V1().Service()
+	secrets := clientv1.Core().V1().Secret()
+	nodes := clientv1.Core().V1().Node()
+	lhNodes := lhCtrlClient.Longhorn().V1beta2().Node()
 
 	cb := func(ctx context.Context) {
-		if err := endpoint.Register(ctx, endpoints, networkFilsystems, opt); err != nil {
+		if err := endpoint.Register(ctx, endpoints, networkFilsystems, recorder, opt); err != nil {
 			logrus.Errorf("failed to register endpoint controller: %v", err)
 		}
 
-		if err := networkfilesystem.Register(ctx, clientv1.Core().V1(), lhClient, endpoints, networkFilsystems, opt); err != nil {
+		backends := backend.Backends{
+			networkfsv1.ExportBackendLonghorn: longhorn.New(client, lhClient, endpoints, pvs, sharemanagers, nodes),
+			networkfsv1.ExportBackendGanesha:  ganesha.New(pods, configmaps, secrets, opt.GaneshaImage, opt.SambaImage),
+		}
+		placer := placement.New(nodes, lhNodes, opt)
+		if err := drain.Register(ctx, backends, placer, nodes, pods, networkFilsystems, recorder, opt); err != nil {
+			logrus.Errorf("failed to register drain controller: %v", err)
+		}
+		if err := networkfilesystem.Register(ctx, backends, placer, networkFilsystems, services, endpoints, secrets, recorder, opt); err != nil {
 			logrus.Errorf("failed to register networkfilesystem controller: %v", err)
 		}
 
-		if err := sharemanager.Register(ctx, sharemanagers, networkFilsystems, opt); err != nil {
+		if opt.MetricsPort > 0 {
+			if err := metrics.RegisterNetworkFSCollector(networkFilsystems.Cache()); err != nil {
+				logrus.Errorf("failed to register networkfilesystem metrics: %v", err)
+			}
+		}
+
+		if err := schedule.Register(ctx, networkFilsystems, recorder, opt); err != nil {
+			logrus.Errorf("failed to register schedule controller: %v", err)
+		}
+
+		if err := prober.Register(ctx, networkFilsystems, opt); err != nil {
+			logrus.Errorf("failed to register endpoint prober: %v", err)
+		}
+
+		if err := idle.Register(ctx, backends, networkFilsystems, idle.NewClientCounter(config, client), recorder, opt); err != nil {
+			logrus.Errorf("failed to register idle checker: %v", err)
+		}
+
+		if err := sharemanager.Register(ctx, sharemanagers, networkFilsystems, recorder, opt); err != nil {
 			logrus.Errorf("failed to register sharemanager controller: %v", err)
 		}
 
+		if err := volume.Register(ctx, volumes, sharemanagers, pvcs, networkFilsystems, opt); err != nil {
+			logrus.Errorf("failed to register volume discovery controller: %v", err)
+		}
+
+		if err := export.Register(ctx, networkFSExports, networkFilsystems, secrets, recorder, opt); err != nil {
+			logrus.Errorf("failed to register networkfilesystem export controller: %v", err)
+		}
+
 		if err := start.All(ctx, opt.Threadiness, clientNetfs, clientv1, lhCtrlClient); err != nil {
 			logrus.Errorf("failed to start controller: %v", err)
 		}
@@ -146,3 +360,22 @@ func run(opt *utils.Option) error {
 	logrus.Infof("NetworkFS manager is shutting down")
 	return nil
 }
+
+func runMountAgent(ctx context.Context, config *rest.Config, recorder record.EventRecorder, opt *utils.Option) error {
+	agentNetfs, err := ntefsv1.NewFactoryFromConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed to create mount agent networkFS controller: %v", err)
+	}
+	agentv1, err := corev1.NewFactoryFromConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed to create mount agent node controller: %v", err)
+	}
+
+	mounts := agentNetfs.Harvesterhci().V1beta1().NetworkFilesystemMount()
+	networkFilsystems := agentNetfs.Harvesterhci().V1beta1().NetworkFilesystem()
+	nodes := agentv1.Core().V1().Node()
+	if err := mount.Register(ctx, mounts, networkFilsystems, nodes, mounter.NewHostMounter(mounter.DefaultHostProcPath), recorder, opt); err != nil {
+		return fmt.Errorf("failed to register mount agent: %v", err)
+	}
+	return start.All(ctx, opt.Threadiness, agentNetfs, agentv1)
+}
@@ -2,12 +2,16 @@ package v1beta1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type NetworkFSState string
 type EndpointStatus string
 type ConditionType string
+type ExportBackendType string
+type AccessType string
+type SquashType string
 
 const (
 	// NetworkFSStateEnabled indicates the networkFS endpoint is enabled
@@ -38,9 +42,34 @@ const (
 	ConditionTypeReconciling ConditionType = "Reconciling"
 	// ConditionTypeEndpointChanged indicates the networkFS endpoint is changed
 	ConditionTypeEndpointChanged ConditionType = "EndpointChanged"
+	// ConditionTypeHealthy indicates the result of the active probe against the endpoint
+	ConditionTypeHealthy ConditionType = "Healthy"
+	// ConditionTypeIdle indicates the networkFS is disabled because it had no client for the idle timeout
+	ConditionTypeIdle ConditionType = "Idle"
+	// ConditionTypeEndpointChanging indicates the export is migrating to another node, the endpoint is unavailable until it is ready there
+	ConditionTypeEndpointChanging ConditionType = "EndpointChanging"
 
 	// NetworkFSTypeNFS indicates the networkFS endpoint is NFS
 	NetworkFSTypeNFS string = "NFS"
+	// NetworkFSTypeSMB indicates the networkFS endpoint is SMB/CIFS
+	NetworkFSTypeSMB string = "SMB"
+
+	// ExportBackendLonghorn exports the Longhorn RWX volume through the Longhorn share manager
+	ExportBackendLonghorn ExportBackendType = "Longhorn"
+	// ExportBackendGanesha exports any PVC through a managed NFS-Ganesha server pod
+	ExportBackendGanesha ExportBackendType = "Ganesha"
+
+	// AccessReadWrite allows the clients to read and write the export
+	AccessReadWrite AccessType = "ReadWrite"
+	// AccessReadOnly allows the clients to only read the export
+	AccessReadOnly AccessType = "ReadOnly"
+
+	// SquashNone keeps the uid/gid of the clients
+	SquashNone SquashType = "None"
+	// SquashRoot maps the root user of the clients to the anonymous user
+	SquashRoot SquashType = "RootSquash"
+	// SquashAll maps all users of the clients to the anonymous user
+	SquashAll SquashType = "AllSquash"
 )
 
 // +genclient
@@ -52,6 +81,7 @@ const (
 // +kubebuilder:printcolumn:name="State",type="string",JSONPath=`.status.state`
 // +kubebuilder:printcolumn:name="Type",type="string",JSONPath=`.status.type`
 // +kubebuilder:subresource:status
+// +kubebuilder:storageversion
 
 type NetworkFilesystem struct {
 	metav1.TypeMeta   `json:",inline"`
@@ -61,27 +91,86 @@ type NetworkFilesystem struct {
 }
 
 type NetworkFSSpec struct {
-	// name of the networkFS to which the endpoint is exported
+	// name of the networkFS to which the endpoint is exported,
+	// it is the Longhorn volume name for the Longhorn backend or the PVC name (in the same namespace) for the Ganesha backend
 	// +kubebuilder:validation:Required
 	NetworkFSName string `json:"networkFSName"`
 
+	// backend which exports the networkFS, options are "Longhorn" or "Ganesha"
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum:=Longhorn;Ganesha
+	// +kubebuilder:default:=Longhorn
+	ExportBackend ExportBackendType `json:"exportBackend,omitempty"`
+
+	// protocol of the export, options are "NFS" or "SMB", SMB is only supported by the Ganesha backend (served by Samba)
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum:=NFS;SMB
+	// +kubebuilder:default:=NFS
+	Protocol string `json:"protocol,omitempty"`
+
+	// secret which contains the "username" and "password" of the SMB share, required by the SMB protocol
+	// +kubebuilder:validation:Optional
+	SMBCredentialsSecretRef *corev1.LocalObjectReference `json:"smbCredentialsSecretRef,omitempty"`
+
+	// access rules of the export, the export is open to all clients when it is empty,
+	// otherwise only the clients matched by the rules are allowed to mount it
+	// +kubebuilder:validation:Optional
+	AccessRules []AccessRule `json:"accessRules,omitempty"`
+
+	// the managed service which provides the stable address of the export
+	// +kubebuilder:validation:Optional
+	Service ExportService `json:"service,omitempty"`
+
+	// secret to which the connection details (server, port, path, protocol, mountOptions and uri) of the
+	// ready export are written, the secret is created and owned by the networkFS
+	// +kubebuilder:validation:Optional
+	ConnectionSecretRef *corev1.LocalObjectReference `json:"connectionSecretRef,omitempty"`
+
+	// the windows in which the networkFS is enabled, the desiredState is flipped by the manager at the scheduled times
+	// +kubebuilder:validation:Optional
+	Schedule *ExportSchedule `json:"schedule,omitempty"`
+
+	// the networkFS is disabled after it had no client for the duration, e.g. "2h", it is never disabled for idleness when it is empty
+	// +kubebuilder:validation:Optional
+	IdleTimeout *metav1.Duration `json:"idleTimeout,omitempty"`
+
 	// desired state of the networkFS endpoint, options are "Disabled", "Enabling", "Enabled", "Disabling", or "Unknown"
 	// +kubebuilder:validation:Required:Enum:=Disabled;Enabling;Enabled;Disabling;Unknown
 	DesiredState NetworkFSState `json:"desiredState"`
 
-	// perferred nodes to which the networkFS endpoint is exported
+	// perferred nodes to which the networkFS endpoint is exported, it is deprecated by the candidateNodes
+	// and only used when the candidateNodes is empty
 	// +kubebuilder:validation:Optional
 	PreferredNode string `json:"perferredNodes,omitempty"`
+
+	// ordered nodes to which the networkFS endpoint is exported, the first available one is chosen and the export is
+	// moved to the next available one when its node goes NotReady or is cordoned
+	// +kubebuilder:validation:Optional
+	CandidateNodes []string `json:"candidateNodes,omitempty"`
+
+	// labels of the nodes to which the networkFS endpoint is exported, the matched nodes are the candidates
+	// (ordered by name) when the candidateNodes is empty, otherwise the candidateNodes are filtered by it
+	// +kubebuilder:validation:Optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// node to which the running export is migrated, changing it moves the export there in a planned way,
+	// the node is preferred over the candidates while it is eligible
+	// +kubebuilder:validation:Optional
+	TargetNode string `json:"targetNode,omitempty"`
 }
 
 type NetworkFSStatus struct {
 
+	// the generation of the spec which is handled by the controller
+	// +kubebuilder:validation:Optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
 	// the conditions of the networkFS
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:default:={}
 	NetworkFSConds []NetworkFSCondition `json:"conditions,omitempty"`
 
-	// the current Endpoint of the networkFS
+	// the current Endpoint of the networkFS, it is the stable address of the managed service
 	// +kubebuilder:validation:
 	// +kubebuilder:default:=""
 	Endpoint string `json:"endpoint"`
@@ -91,8 +180,8 @@ type NetworkFSStatus struct {
 	// +kubebuilder:default:=Disabled
 	State NetworkFSState `json:"state"`
 
-	// the type of the networkFS endpoint, options are "NFS", or "Unknown"
-	// +kubebuilder:validation:Enum:=NFS;Unknown
+	// the type of the networkFS endpoint, options are "NFS", "SMB", or "Unknown"
+	// +kubebuilder:validation:Enum:=NFS;SMB;Unknown
 	// +kubebuilder:default:=NFS
 	Type string `json:"type"`
 
@@ -103,6 +192,199 @@ type NetworkFSStatus struct {
 
 	// the recommend mount options for the networkFS endpoint
 	MountOpts string `json:"mountOpts,omitempty"`
+
+	// the UNC path of the SMB share, e.g. \\10.0.0.1\share
+	UNCPath string `json:"uncPath,omitempty"`
+
+	// the access rules which are enforced by the export backend
+	AccessRules []AccessRule `json:"accessRules,omitempty"`
+
+	// the address of the server behind the managed service, it changes when the server is rescheduled
+	ServerAddress string `json:"serverAddress,omitempty"`
+
+	// the endpoints and server addresses the export was served from before, the latest is the last one.
+	// The mount agents only remount the stale mounts of these addresses.
+	PreviousAddresses []string `json:"previousAddresses,omitempty"`
+
+	// the number of the recovery attempts since the export failed, it is reset once the export is ready
+	RecoveryAttempts int32 `json:"recoveryAttempts,omitempty"`
+
+	// the last time the export was recovered
+	LastRecoveryTime *metav1.Time `json:"lastRecoveryTime,omitempty"`
+
+	// the path of the export on the server, e.g. the NFS pseudo path
+	ExportPath string `json:"exportPath,omitempty"`
+
+	// the result of the active probe against the endpoint
+	Health *HealthStatus `json:"health,omitempty"`
+
+	// the remount results of the stale mounts, reported by the mount agent of each node
+	Remounts []NodeRemountStatus `json:"remounts,omitempty"`
+
+	// the transitions of the desiredState planned and applied by the schedule
+	Schedule *ScheduleStatus `json:"schedule,omitempty"`
+
+	// the clients observed on the server, it is only reported when the idleTimeout is set
+	Activity *ActivityStatus `json:"activity,omitempty"`
+
+	// the node which is chosen from the candidates to host the export, it is empty when the backend picks the node
+	Node string `json:"node,omitempty"`
+
+	// the last planned migration of the export to the targetNode
+	Migration *MigrationStatus `json:"migration,omitempty"`
+}
+
+type ExportSchedule struct {
+	// cron expression (minute hour day-of-month month day-of-week) at which the networkFS is enabled
+	// +kubebuilder:validation:Optional
+	Enable string `json:"enable,omitempty"`
+
+	// cron expression (minute hour day-of-month month day-of-week) at which the networkFS is disabled
+	// +kubebuilder:validation:Optional
+	Disable string `json:"disable,omitempty"`
+
+	// IANA time zone of the cron expressions, e.g. "Asia/Taipei", UTC is used when it is empty
+	// +kubebuilder:validation:Optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+type ScheduleStatus struct {
+	// the desiredState which is planned by the schedule
+	NextState NetworkFSState `json:"nextState,omitempty"`
+
+	// the time of the planned transition
+	NextTime *metav1.Time `json:"nextTime,omitempty"`
+
+	// the desiredState which was applied by the schedule last time
+	LastState NetworkFSState `json:"lastState,omitempty"`
+
+	// the time of the last applied transition
+	LastTime *metav1.Time `json:"lastTime,omitempty"`
+
+	// the schedule is suspended by the manual override annotation, the planned transitions are skipped
+	Overridden bool `json:"overridden,omitempty"`
+}
+
+type RemountResult string
+
+const (
+	// RemountResultRemounted indicates the stale mounts are remounted from the current endpoint
+	RemountResultRemounted RemountResult = "Remounted"
+	// RemountResultFailed indicates the stale mounts could not be remounted
+	RemountResultFailed RemountResult = "Failed"
+)
+
+type NodeRemountStatus struct {
+	// name of the node
+	NodeName string `json:"nodeName"`
+
+	// result of the last remount on the node, options are "Remounted" or "Failed"
+	// +kubebuilder:validation:Enum:=Remounted;Failed
+	Result RemountResult `json:"result"`
+
+	// the mount points which were mounted from the stale address
+	MountPoints []string `json:"mountPoints,omitempty"`
+
+	// the stale server address of the mounts
+	StaleAddress string `json:"staleAddress,omitempty"`
+
+	// the details of the result
+	Message string `json:"message,omitempty"`
+
+	// the last time the stale mounts were handled
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+type MigrationPhase string
+
+const (
+	// MigrationPhasePending indicates the target node is not eligible, the migration starts once it is
+	MigrationPhasePending MigrationPhase = "Pending"
+	// MigrationPhaseDetaching indicates the server on the source node is being stopped
+	MigrationPhaseDetaching MigrationPhase = "Detaching"
+	// MigrationPhaseAttaching indicates the export is brought up on the target node, it waits for the new endpoint
+	MigrationPhaseAttaching MigrationPhase = "Attaching"
+	// MigrationPhaseCompleted indicates the export is served from the target node
+	MigrationPhaseCompleted MigrationPhase = "Completed"
+	// MigrationPhaseFailed indicates the export could not be moved to the target node
+	MigrationPhaseFailed MigrationPhase = "Failed"
+)
+
+type MigrationStatus struct {
+	// the node from which the export is migrated
+	SourceNode string `json:"sourceNode,omitempty"`
+
+	// the node to which the export is migrated
+	TargetNode string `json:"targetNode"`
+
+	// phase of the migration, options are "Pending", "Detaching", "Attaching", "Completed" or "Failed"
+	// +kubebuilder:validation:Enum:=Pending;Detaching;Attaching;Completed;Failed
+	Phase MigrationPhase `json:"phase"`
+
+	// the details of the phase
+	Message string `json:"message,omitempty"`
+
+	// the time the migration was announced by the EndpointChanging condition
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// the time the server on the source node was stopped
+	DetachedTime *metav1.Time `json:"detachedTime,omitempty"`
+
+	// the time the endpoint on the target node became ready
+	EndpointReadyTime *metav1.Time `json:"endpointReadyTime,omitempty"`
+
+	// the time the managed service and secrets were updated with the new endpoint
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+type ActivityStatus struct {
+	// the number of the clients connected to the server at the last check
+	Clients int32 `json:"clients,omitempty"`
+
+	// the time since which the server has had no client, it is cleared when a client connects
+	IdleSince *metav1.Time `json:"idleSince,omitempty"`
+}
+
+type HealthStatus struct {
+	// the latency of the last successful probe in milliseconds
+	LatencyMilliseconds int64 `json:"latencyMilliseconds,omitempty"`
+
+	// the last time the probe succeeded
+	LastSuccessTime *metav1.Time `json:"lastSuccessTime,omitempty"`
+}
+
+type ExportService struct {
+	// type of the service, options are "ClusterIP" or "LoadBalancer"
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum:=ClusterIP;LoadBalancer
+	// +kubebuilder:default:=ClusterIP
+	Type corev1.ServiceType `json:"type,omitempty"`
+
+	// requested address of the LoadBalancer service, it is set to the kube-vip annotation "kube-vip.io/loadbalancerIPs"
+	// +kubebuilder:validation:Optional
+	LoadBalancerIP string `json:"loadBalancerIP,omitempty"`
+
+	// extra annotations of the service, e.g. the IPAM annotation of the load balancer
+	// +kubebuilder:validation:Optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type AccessRule struct {
+	// client addresses matched by the rule, in CIDR notation or a single IP
+	// +kubebuilder:validation:MinItems:=1
+	Clients []string `json:"clients"`
+
+	// access of the matched clients, options are "ReadWrite" or "ReadOnly"
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum:=ReadWrite;ReadOnly
+	// +kubebuilder:default:=ReadWrite
+	Access AccessType `json:"access,omitempty"`
+
+	// squash of the matched clients, options are "None", "RootSquash" or "AllSquash"
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum:=None;RootSquash;AllSquash
+	// +kubebuilder:default:=None
+	Squash SquashType `json:"squash,omitempty"`
 }
 
 type NetworkFSCondition struct {
@@ -112,3 +394,189 @@ type NetworkFSCondition struct {
 	Reason             string                 `json:"reason,omitempty"`
 	Message            string                 `json:"message,omitempty"`
 }
+
+type MountState string
+
+const (
+	// MountStatePending indicates the networkFS is not ready to be mounted on the node
+	MountStatePending MountState = "Pending"
+	// MountStateMounted indicates the networkFS is mounted on the node
+	MountStateMounted MountState = "Mounted"
+	// MountStateUnmounted indicates the networkFS is unmounted from the node (e.g. it is disabled)
+	MountStateUnmounted MountState = "Unmounted"
+	// MountStateFailed indicates the networkFS could not be mounted on the node
+	MountStateFailed MountState = "Failed"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:shortName=netfsmount;netfsmounts,scope=Namespaced
+// +kubebuilder:printcolumn:name="NetworkFS",type="string",JSONPath=`.spec.networkFilesystemName`
+// +kubebuilder:printcolumn:name="HostPath",type="string",JSONPath=`.spec.hostPath`
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=`.metadata.creationTimestamp`
+// +kubebuilder:subresource:status
+
+type NetworkFilesystemMount struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              NetworkFSMountSpec   `json:"spec"`
+	Status            NetworkFSMountStatus `json:"status,omitempty"`
+}
+
+type NetworkFSMountSpec struct {
+	// name of the NetworkFilesystem (in the same namespace) to mount
+	// +kubebuilder:validation:Required
+	NetworkFilesystemName string `json:"networkFilesystemName"`
+
+	// absolute path on the host to which the networkFS is mounted, it is created if it does not exist. It must be below
+	// the host path prefix of the mount agent (/mnt by default), must not contain "..", and can not be changed.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern:=`^/.+`
+	HostPath string `json:"hostPath"`
+
+	// labels of the nodes on which the networkFS is mounted, all nodes are selected when it is empty
+	// +kubebuilder:validation:Optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// mount options, the recommended mount options of the NetworkFilesystem are used when it is empty. Only the NFS
+	// options are allowed (e.g. suid and dev are not), nosuid and nodev are always set.
+	// +kubebuilder:validation:Optional
+	MountOptions string `json:"mountOptions,omitempty"`
+
+	// mount the networkFS read-only
+	// +kubebuilder:validation:Optional
+	ReadOnly bool `json:"readOnly,omitempty"`
+}
+
+type NetworkFSMountStatus struct {
+	// the mount status reported by the agent of each selected node
+	// +kubebuilder:validation:Optional
+	Nodes []NodeMountStatus `json:"nodes,omitempty"`
+}
+
+type NodeMountStatus struct {
+	// name of the node
+	NodeName string `json:"nodeName"`
+
+	// state of the mount on the node, options are "Pending", "Mounted", "Unmounted" or "Failed"
+	// +kubebuilder:validation:Enum:=Pending;Mounted;Unmounted;Failed
+	State MountState `json:"state"`
+
+	// the mounted source, e.g. 10.53.0.10:/pvc-xxx
+	Source string `json:"source,omitempty"`
+
+	// the details of the state
+	Message string `json:"message,omitempty"`
+
+	// the last time the state or the source changed
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+type GuestVolumeState string
+
+const (
+	// GuestVolumeStatePending indicates the networkFS is not ready to be provisioned to the guest cluster
+	GuestVolumeStatePending GuestVolumeState = "Pending"
+	// GuestVolumeStateSynced indicates the PV (and PVC) in the guest cluster point at the current endpoint
+	GuestVolumeStateSynced GuestVolumeState = "Synced"
+	// GuestVolumeStateFailed indicates the PV (and PVC) could not be provisioned to the guest cluster
+	GuestVolumeStateFailed GuestVolumeState = "Failed"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:shortName=netfsexport;netfsexports,scope=Namespaced
+// +kubebuilder:printcolumn:name="NetworkFS",type="string",JSONPath=`.spec.networkFilesystemName`
+// +kubebuilder:printcolumn:name="PV",type="string",JSONPath=`.spec.persistentVolume.name`
+// +kubebuilder:printcolumn:name="State",type="string",JSONPath=`.status.state`
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=`.metadata.creationTimestamp`
+// +kubebuilder:subresource:status
+
+type NetworkFilesystemExport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              NetworkFSExportSpec   `json:"spec"`
+	Status            NetworkFSExportStatus `json:"status,omitempty"`
+}
+
+type NetworkFSExportSpec struct {
+	// name of the NetworkFilesystem (in the same namespace) to provision to the guest cluster,
+	// it must be exported by the LoadBalancer service to be reachable from the guest cluster
+	// +kubebuilder:validation:Required
+	NetworkFilesystemName string `json:"networkFilesystemName"`
+
+	// secret (in the same namespace) which contains the kubeconfig of the guest cluster, it may only contain the server,
+	// the CA data and the inline token or client certificate data. The user needs to manage PVs and PVCs and to list pods.
+	// +kubebuilder:validation:Required
+	KubeconfigSecretRef corev1.LocalObjectReference `json:"kubeconfigSecretRef"`
+
+	// key of the kubeconfig in the secret
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=kubeconfig
+	KubeconfigKey string `json:"kubeconfigKey,omitempty"`
+
+	// the static NFS PersistentVolume which is created in the guest cluster
+	// +kubebuilder:validation:Required
+	PersistentVolume GuestPersistentVolume `json:"persistentVolume"`
+
+	// the PersistentVolumeClaim which is created in the guest cluster and bound to the PV, no PVC is created when it is empty
+	// +kubebuilder:validation:Optional
+	PersistentVolumeClaim *GuestPersistentVolumeClaim `json:"persistentVolumeClaim,omitempty"`
+}
+
+type GuestPersistentVolume struct {
+	// name of the PV in the guest cluster
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// capacity of the PV, it is informational because the NFS export is not limited by it
+	// +kubebuilder:validation:Required
+	Capacity resource.Quantity `json:"capacity"`
+
+	// access modes of the PV
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:={ReadWriteMany}
+	AccessModes []corev1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+
+	// storage class name of the PV, the PVC requests the same class
+	// +kubebuilder:validation:Optional
+	StorageClassName string `json:"storageClassName,omitempty"`
+
+	// mount options of the PV, the recommended mount options of the NetworkFilesystem are used when it is empty. Only the
+	// NFS options are allowed, e.g. suid and dev are not.
+	// +kubebuilder:validation:Optional
+	MountOptions string `json:"mountOptions,omitempty"`
+}
+
+type GuestPersistentVolumeClaim struct {
+	// namespace of the PVC in the guest cluster
+	// +kubebuilder:validation:Required
+	Namespace string `json:"namespace"`
+
+	// name of the PVC in the guest cluster
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+}
+
+type NetworkFSExportStatus struct {
+	// the state of the PV in the guest cluster, options are "Pending", "Synced" or "Failed"
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum:=Pending;Synced;Failed
+	State GuestVolumeState `json:"state,omitempty"`
+
+	// the NFS server of the PV in the guest cluster
+	// +kubebuilder:validation:Optional
+	Server string `json:"server,omitempty"`
+
+	// the NFS path of the PV in the guest cluster
+	// +kubebuilder:validation:Optional
+	Path string `json:"path,omitempty"`
+
+	// the details of the state
+	// +kubebuilder:validation:Optional
+	Message string `json:"message,omitempty"`
+
+	// the last time the state, the server or the path changed
+	// +kubebuilder:validation:Optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
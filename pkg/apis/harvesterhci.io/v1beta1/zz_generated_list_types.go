@@ -40,3 +40,37 @@ func NewNetworkFilesystem(namespace, name string, obj NetworkFilesystem) *Networ
 	obj.Namespace = namespace
 	return &obj
 }
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// NetworkFilesystemMountList is a list of NetworkFilesystemMount resources
+type NetworkFilesystemMountList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []NetworkFilesystemMount `json:"items"`
+}
+
+func NewNetworkFilesystemMount(namespace, name string, obj NetworkFilesystemMount) *NetworkFilesystemMount {
+	obj.APIVersion, obj.Kind = SchemeGroupVersion.WithKind("NetworkFilesystemMount").ToAPIVersionAndKind()
+	obj.Name = name
+	obj.Namespace = namespace
+	return &obj
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// NetworkFilesystemExportList is a list of NetworkFilesystemExport resources
+type NetworkFilesystemExportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []NetworkFilesystemExport `json:"items"`
+}
+
+func NewNetworkFilesystemExport(namespace, name string, obj NetworkFilesystemExport) *NetworkFilesystemExport {
+	obj.APIVersion, obj.Kind = SchemeGroupVersion.WithKind("NetworkFilesystemExport").ToAPIVersionAndKind()
+	obj.Name = name
+	obj.Namespace = namespace
+	return &obj
+}
@@ -28,7 +28,9 @@ import (
 )
 
 var (
-	NetworkFilesystemResourceName = "networkfilesystems"
+	NetworkFilesystemResourceName       = "networkfilesystems"
+	NetworkFilesystemExportResourceName = "networkfilesystemexports"
+	NetworkFilesystemMountResourceName  = "networkfilesystemmounts"
 )
 
 // SchemeGroupVersion is group version used to register these objects
@@ -54,6 +56,10 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
 		&NetworkFilesystem{},
 		&NetworkFilesystemList{},
+		&NetworkFilesystemExport{},
+		&NetworkFilesystemExportList{},
+		&NetworkFilesystemMount{},
+		&NetworkFilesystemMountList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
@@ -0,0 +1,370 @@
+package v1beta2
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+)
+
+// AnnotationPreferredNodes keeps the weights of the v1beta2 preferred nodes which can not be represented by the
+// order of the v1beta1 candidate nodes, so the conversion does not lose data.
+const AnnotationPreferredNodes = "networkfs.harvesterhci.io/v1beta2-preferred-nodes"
+
+// AnnotationV1beta1PreferredNode keeps the deprecated v1beta1 perferredNodes on the v1beta2 object, so the v1beta1
+// spec is written back as it was while the v1beta2 preferred nodes are not changed.
+const AnnotationV1beta1PreferredNode = "networkfs.harvesterhci.io/v1beta1-perferred-node"
+
+const networkFSKind = "NetworkFilesystem"
+
+// ConvertFromV1beta1 converts the v1beta1 (storage version) NetworkFilesystem to v1beta2
+func ConvertFromV1beta1(src *v1beta1.NetworkFilesystem) *NetworkFilesystem {
+	dst := &NetworkFilesystem{
+		TypeMeta:   src.TypeMeta,
+		ObjectMeta: *src.ObjectMeta.DeepCopy(),
+	}
+	dst.APIVersion = SchemeGroupVersion.String()
+	dst.Kind = networkFSKind
+
+	dst.Spec.NetworkFSName = src.Spec.NetworkFSName
+	dst.Spec.DesiredState = NetworkFSState(src.Spec.DesiredState)
+	dst.Spec.ExportBackend = ExportBackendType(src.Spec.ExportBackend)
+	dst.Spec.Protocol = NetworkFSProtocol(src.Spec.Protocol)
+	dst.Spec.SMBCredentialsSecretRef = src.Spec.SMBCredentialsSecretRef.DeepCopy()
+	dst.Spec.ConnectionSecretRef = src.Spec.ConnectionSecretRef.DeepCopy()
+	if src.Spec.Schedule != nil {
+		dst.Spec.Schedule = &ExportSchedule{
+			Enable:   src.Spec.Schedule.Enable,
+			Disable:  src.Spec.Schedule.Disable,
+			TimeZone: src.Spec.Schedule.TimeZone,
+		}
+	}
+	dst.Spec.IdleTimeout = src.Spec.IdleTimeout.DeepCopy()
+	dst.Spec.AccessRules = accessRulesFromV1beta1(src.Spec.AccessRules)
+	dst.Spec.Service = ExportService{
+		Type:           src.Spec.Service.Type,
+		LoadBalancerIP: src.Spec.Service.LoadBalancerIP,
+		Annotations:    copyStringMap(src.Spec.Service.Annotations),
+	}
+	dst.Spec.PreferredNodes = preferredNodesFromV1beta1(src)
+	dst.Spec.NodeSelector = copyStringMap(src.Spec.NodeSelector)
+	dst.Spec.TargetNode = src.Spec.TargetNode
+	removeAnnotation(&dst.ObjectMeta, AnnotationPreferredNodes)
+	removeAnnotation(&dst.ObjectMeta, AnnotationV1beta1PreferredNode)
+	if len(src.Spec.CandidateNodes) == 0 && src.Spec.PreferredNode != "" {
+		setAnnotation(&dst.ObjectMeta, AnnotationV1beta1PreferredNode, src.Spec.PreferredNode)
+	}
+
+	dst.Status.ObservedGeneration = src.Status.ObservedGeneration
+	for _, cond := range src.Status.NetworkFSConds {
+		dst.Status.NetworkFSConds = append(dst.Status.NetworkFSConds, NetworkFSCondition{
+			Type:               ConditionType(cond.Type),
+			Status:             cond.Status,
+			LastTransitionTime: cond.LastTransitionTime,
+			Reason:             cond.Reason,
+			Message:            cond.Message,
+		})
+	}
+	dst.Status.Endpoint = src.Status.Endpoint
+	dst.Status.State = NetworkFSState(src.Status.State)
+	dst.Status.Type = NetworkFSProtocol(src.Status.Type)
+	dst.Status.Status = EndpointStatus(src.Status.Status)
+	dst.Status.MountOpts = src.Status.MountOpts
+	dst.Status.UNCPath = src.Status.UNCPath
+	dst.Status.AccessRules = accessRulesFromV1beta1(src.Status.AccessRules)
+	dst.Status.ServerAddress = src.Status.ServerAddress
+	dst.Status.PreviousAddresses = append([]string(nil), src.Status.PreviousAddresses...)
+	dst.Status.RecoveryAttempts = src.Status.RecoveryAttempts
+	dst.Status.LastRecoveryTime = src.Status.LastRecoveryTime.DeepCopy()
+	dst.Status.ExportPath = src.Status.ExportPath
+	if src.Status.Health != nil {
+		dst.Status.Health = &HealthStatus{
+			LatencyMilliseconds: src.Status.Health.LatencyMilliseconds,
+			LastSuccessTime:     src.Status.Health.LastSuccessTime.DeepCopy(),
+		}
+	}
+	for _, remount := range src.Status.Remounts {
+		dst.Status.Remounts = append(dst.Status.Remounts, NodeRemountStatus{
+			NodeName:           remount.NodeName,
+			Result:             RemountResult(remount.Result),
+			MountPoints:        append([]string(nil), remount.MountPoints...),
+			StaleAddress:       remount.StaleAddress,
+			Message:            remount.Message,
+			LastTransitionTime: remount.LastTransitionTime,
+		})
+	}
+	if src.Status.Schedule != nil {
+		dst.Status.Schedule = &ScheduleStatus{
+			NextState:  NetworkFSState(src.Status.Schedule.NextState),
+			NextTime:   src.Status.Schedule.NextTime.DeepCopy(),
+			LastState:  NetworkFSState(src.Status.Schedule.LastState),
+			LastTime:   src.Status.Schedule.LastTime.DeepCopy(),
+			Overridden: src.Status.Schedule.Overridden,
+		}
+	}
+	if src.Status.Activity != nil {
+		dst.Status.Activity = &ActivityStatus{
+			Clients:   src.Status.Activity.Clients,
+			IdleSince: src.Status.Activity.IdleSince.DeepCopy(),
+		}
+	}
+	dst.Status.Node = src.Status.Node
+	if src.Status.Migration != nil {
+		dst.Status.Migration = &MigrationStatus{
+			SourceNode:        src.Status.Migration.SourceNode,
+			TargetNode:        src.Status.Migration.TargetNode,
+			Phase:             MigrationPhase(src.Status.Migration.Phase),
+			Message:           src.Status.Migration.Message,
+			StartTime:         src.Status.Migration.StartTime.DeepCopy(),
+			DetachedTime:      src.Status.Migration.DetachedTime.DeepCopy(),
+			EndpointReadyTime: src.Status.Migration.EndpointReadyTime.DeepCopy(),
+			CompletionTime:    src.Status.Migration.CompletionTime.DeepCopy(),
+		}
+	}
+	return dst
+}
+
+// ConvertToV1beta1 converts the v1beta2 NetworkFilesystem to v1beta1 (storage version)
+func ConvertToV1beta1(src *NetworkFilesystem) *v1beta1.NetworkFilesystem {
+	dst := &v1beta1.NetworkFilesystem{
+		TypeMeta:   src.TypeMeta,
+		ObjectMeta: *src.ObjectMeta.DeepCopy(),
+	}
+	dst.APIVersion = v1beta1.SchemeGroupVersion.String()
+	dst.Kind = networkFSKind
+
+	dst.Spec.NetworkFSName = src.Spec.NetworkFSName
+	dst.Spec.DesiredState = v1beta1.NetworkFSState(src.Spec.DesiredState)
+	dst.Spec.ExportBackend = v1beta1.ExportBackendType(src.Spec.ExportBackend)
+	dst.Spec.Protocol = string(src.Spec.Protocol)
+	dst.Spec.SMBCredentialsSecretRef = src.Spec.SMBCredentialsSecretRef.DeepCopy()
+	dst.Spec.ConnectionSecretRef = src.Spec.ConnectionSecretRef.DeepCopy()
+	if src.Spec.Schedule != nil {
+		dst.Spec.Schedule = &v1beta1.ExportSchedule{
+			Enable:   src.Spec.Schedule.Enable,
+			Disable:  src.Spec.Schedule.Disable,
+			TimeZone: src.Spec.Schedule.TimeZone,
+		}
+	}
+	dst.Spec.IdleTimeout = src.Spec.IdleTimeout.DeepCopy()
+	dst.Spec.AccessRules = accessRulesToV1beta1(src.Spec.AccessRules)
+	dst.Spec.Service = v1beta1.ExportService{
+		Type:           src.Spec.Service.Type,
+		LoadBalancerIP: src.Spec.Service.LoadBalancerIP,
+		Annotations:    copyStringMap(src.Spec.Service.Annotations),
+	}
+	preferred := sortedPreferredNodes(src.Spec.PreferredNodes)
+	names := preferredNodeNames(preferred)
+	removeAnnotation(&dst.ObjectMeta, AnnotationV1beta1PreferredNode)
+	if node, found := src.Annotations[AnnotationV1beta1PreferredNode]; found && reflect.DeepEqual(names, []string{node}) {
+		// the node still comes from the deprecated field, it is not moved to the candidate nodes
+		dst.Spec.PreferredNode = node
+	} else {
+		dst.Spec.CandidateNodes = names
+	}
+	dst.Spec.NodeSelector = copyStringMap(src.Spec.NodeSelector)
+	dst.Spec.TargetNode = src.Spec.TargetNode
+	// keep the whole list when the order of the candidates can not describe the weights
+	if !hasCandidateWeights(preferred) {
+		if raw, err := json.Marshal(src.Spec.PreferredNodes); err == nil {
+			setAnnotation(&dst.ObjectMeta, AnnotationPreferredNodes, string(raw))
+		}
+	} else {
+		removeAnnotation(&dst.ObjectMeta, AnnotationPreferredNodes)
+	}
+
+	dst.Status.ObservedGeneration = src.Status.ObservedGeneration
+	for _, cond := range src.Status.NetworkFSConds {
+		dst.Status.NetworkFSConds = append(dst.Status.NetworkFSConds, v1beta1.NetworkFSCondition{
+			Type:               v1beta1.ConditionType(cond.Type),
+			Status:             cond.Status,
+			LastTransitionTime: cond.LastTransitionTime,
+			Reason:             cond.Reason,
+			Message:            cond.Message,
+		})
+	}
+	dst.Status.Endpoint = src.Status.Endpoint
+	dst.Status.State = v1beta1.NetworkFSState(src.Status.State)
+	dst.Status.Type = string(src.Status.Type)
+	dst.Status.Status = v1beta1.EndpointStatus(src.Status.Status)
+	dst.Status.MountOpts = src.Status.MountOpts
+	dst.Status.UNCPath = src.Status.UNCPath
+	dst.Status.AccessRules = accessRulesToV1beta1(src.Status.AccessRules)
+	dst.Status.ServerAddress = src.Status.ServerAddress
+	dst.Status.PreviousAddresses = append([]string(nil), src.Status.PreviousAddresses...)
+	dst.Status.RecoveryAttempts = src.Status.RecoveryAttempts
+	dst.Status.LastRecoveryTime = src.Status.LastRecoveryTime.DeepCopy()
+	dst.Status.ExportPath = src.Status.ExportPath
+	if src.Status.Health != nil {
+		dst.Status.Health = &v1beta1.HealthStatus{
+			LatencyMilliseconds: src.Status.Health.LatencyMilliseconds,
+			LastSuccessTime:     src.Status.Health.LastSuccessTime.DeepCopy(),
+		}
+	}
+	for _, remount := range src.Status.Remounts {
+		dst.Status.Remounts = append(dst.Status.Remounts, v1beta1.NodeRemountStatus{
+			NodeName:           remount.NodeName,
+			Result:             v1beta1.RemountResult(remount.Result),
+			MountPoints:        append([]string(nil), remount.MountPoints...),
+			StaleAddress:       remount.StaleAddress,
+			Message:            remount.Message,
+			LastTransitionTime: remount.LastTransitionTime,
+		})
+	}
+	if src.Status.Schedule != nil {
+		dst.Status.Schedule = &v1beta1.ScheduleStatus{
+			NextState:  v1beta1.NetworkFSState(src.Status.Schedule.NextState),
+			NextTime:   src.Status.Schedule.NextTime.DeepCopy(),
+			LastState:  v1beta1.NetworkFSState(src.Status.Schedule.LastState),
+			LastTime:   src.Status.Schedule.LastTime.DeepCopy(),
+			Overridden: src.Status.Schedule.Overridden,
+		}
+	}
+	if src.Status.Activity != nil {
+		dst.Status.Activity = &v1beta1.ActivityStatus{
+			Clients:   src.Status.Activity.Clients,
+			IdleSince: src.Status.Activity.IdleSince.DeepCopy(),
+		}
+	}
+	dst.Status.Node = src.Status.Node
+	if src.Status.Migration != nil {
+		dst.Status.Migration = &v1beta1.MigrationStatus{
+			SourceNode:        src.Status.Migration.SourceNode,
+			TargetNode:        src.Status.Migration.TargetNode,
+			Phase:             v1beta1.MigrationPhase(src.Status.Migration.Phase),
+			Message:           src.Status.Migration.Message,
+			StartTime:         src.Status.Migration.StartTime.DeepCopy(),
+			DetachedTime:      src.Status.Migration.DetachedTime.DeepCopy(),
+			EndpointReadyTime: src.Status.Migration.EndpointReadyTime.DeepCopy(),
+			CompletionTime:    src.Status.Migration.CompletionTime.DeepCopy(),
+		}
+	}
+	return dst
+}
+
+func accessRulesFromV1beta1(rules []v1beta1.AccessRule) []AccessRule {
+	if rules == nil {
+		return nil
+	}
+	dst := make([]AccessRule, 0, len(rules))
+	for _, rule := range rules {
+		dst = append(dst, AccessRule{
+			Clients: append([]string(nil), rule.Clients...),
+			Access:  AccessType(rule.Access),
+			Squash:  SquashType(rule.Squash),
+		})
+	}
+	return dst
+}
+
+func accessRulesToV1beta1(rules []AccessRule) []v1beta1.AccessRule {
+	if rules == nil {
+		return nil
+	}
+	dst := make([]v1beta1.AccessRule, 0, len(rules))
+	for _, rule := range rules {
+		dst = append(dst, v1beta1.AccessRule{
+			Clients: append([]string(nil), rule.Clients...),
+			Access:  v1beta1.AccessType(rule.Access),
+			Squash:  v1beta1.SquashType(rule.Squash),
+		})
+	}
+	return dst
+}
+
+func copyStringMap(src map[string]string) map[string]string {
+	if src == nil {
+		return nil
+	}
+	dst := make(map[string]string, len(src))
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+// preferredNodesFromV1beta1 restores the preferred nodes from the annotation if it still matches
+// the v1beta1 candidate nodes, otherwise the v1beta1 fields win because they were edited afterwards.
+func preferredNodesFromV1beta1(src *v1beta1.NetworkFilesystem) []PreferredNode {
+	candidates := src.Spec.CandidateNodes
+	if len(candidates) == 0 && src.Spec.PreferredNode != "" {
+		candidates = []string{src.Spec.PreferredNode}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	if raw, found := src.Annotations[AnnotationPreferredNodes]; found {
+		var nodes []PreferredNode
+		if err := json.Unmarshal([]byte(raw), &nodes); err == nil && reflect.DeepEqual(preferredNodeNames(sortedPreferredNodes(nodes)), candidates) {
+			return nodes
+		}
+	}
+
+	nodes := make([]PreferredNode, 0, len(candidates))
+	for i, name := range candidates {
+		nodes = append(nodes, PreferredNode{
+			Name:   name,
+			Weight: candidateWeight(i),
+		})
+	}
+	return nodes
+}
+
+// candidateWeight returns the weight of the i-th v1beta1 candidate node, the weight decreases with the order
+func candidateWeight(i int) int32 {
+	return max(DefaultPreferredNodeWeight-int32(min(i, int(DefaultPreferredNodeWeight))), 1)
+}
+
+// hasCandidateWeights returns whether the sorted preferred nodes have the weights converted from the candidate nodes
+func hasCandidateWeights(sorted []PreferredNode) bool {
+	for i, node := range sorted {
+		if node.Weight != candidateWeight(i) {
+			return false
+		}
+	}
+	return true
+}
+
+func preferredNodeNames(nodes []PreferredNode) []string {
+	if len(nodes) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		names = append(names, node.Name)
+	}
+	return names
+}
+
+func sortedPreferredNodes(nodes []PreferredNode) []PreferredNode {
+	if len(nodes) == 0 {
+		return nil
+	}
+	sorted := make([]PreferredNode, len(nodes))
+	copy(sorted, nodes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Weight > sorted[j].Weight
+	})
+	return sorted
+}
+
+func removeAnnotation(meta *metav1.ObjectMeta, key string) {
+	if _, found := meta.Annotations[key]; !found {
+		return
+	}
+	delete(meta.Annotations, key)
+	if len(meta.Annotations) == 0 {
+		meta.Annotations = nil
+	}
+}
+
+func setAnnotation(meta *metav1.ObjectMeta, key, value string) {
+	if meta.Annotations == nil {
+		meta.Annotations = map[string]string{}
+	}
+	meta.Annotations[key] = value
+}
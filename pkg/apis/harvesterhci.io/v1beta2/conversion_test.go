@@ -0,0 +1,190 @@
+package v1beta2
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+)
+
+var testTime = metav1.NewTime(time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC))
+
+func newV1beta1(spec v1beta1.NetworkFSSpec) *v1beta1.NetworkFilesystem {
+	return &v1beta1.NetworkFilesystem{
+		TypeMeta:   metav1.TypeMeta{APIVersion: v1beta1.SchemeGroupVersion.String(), Kind: networkFSKind},
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc-1234", Namespace: "harvester-system", Labels: map[string]string{"app": "test"}},
+		Spec:       spec,
+	}
+}
+
+func newV1beta2(spec NetworkFSSpec) *NetworkFilesystem {
+	return &NetworkFilesystem{
+		TypeMeta:   metav1.TypeMeta{APIVersion: SchemeGroupVersion.String(), Kind: networkFSKind},
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc-1234", Namespace: "harvester-system"},
+		Spec:       spec,
+	}
+}
+
+// fullV1beta1 returns the networkFS with every field set, so a field missed by the conversion fails the round trip
+func fullV1beta1() *v1beta1.NetworkFilesystem {
+	networkFS := newV1beta1(v1beta1.NetworkFSSpec{
+		NetworkFSName:           "pvc-1234",
+		DesiredState:            v1beta1.NetworkFSStateEnabled,
+		ExportBackend:           v1beta1.ExportBackendGanesha,
+		Protocol:                v1beta1.NetworkFSTypeSMB,
+		SMBCredentialsSecretRef: &corev1.LocalObjectReference{Name: "smb-credentials"},
+		ConnectionSecretRef:     &corev1.LocalObjectReference{Name: "connection"},
+		AccessRules: []v1beta1.AccessRule{
+			{Clients: []string{"10.0.0.0/24"}, Access: v1beta1.AccessReadOnly, Squash: v1beta1.SquashRoot},
+		},
+		Service: v1beta1.ExportService{
+			Type:           corev1.ServiceTypeLoadBalancer,
+			LoadBalancerIP: "192.168.1.10",
+			Annotations:    map[string]string{"lb": "pool-1"},
+		},
+		Schedule:       &v1beta1.ExportSchedule{Enable: "0 8 * * 1-5", Disable: "0 20 * * 1-5", TimeZone: "UTC"},
+		IdleTimeout:    &metav1.Duration{Duration: time.Hour},
+		CandidateNodes: []string{"node-1", "node-2", "node-3"},
+		NodeSelector:   map[string]string{"zone": "a"},
+		TargetNode:     "node-2",
+	})
+	networkFS.Status = v1beta1.NetworkFSStatus{
+		ObservedGeneration: 3,
+		NetworkFSConds: []v1beta1.NetworkFSCondition{
+			{Type: v1beta1.ConditionTypeReady, Status: corev1.ConditionTrue, LastTransitionTime: testTime, Reason: "Endpoint is ready"},
+		},
+		Endpoint:          "10.53.0.10",
+		State:             v1beta1.NetworkFSStateEnabled,
+		Type:              v1beta1.NetworkFSTypeSMB,
+		Status:            v1beta1.EndpointStatusReady,
+		MountOpts:         "vers=3.0",
+		UNCPath:           `\\10.53.0.10\pvc-1234`,
+		AccessRules:       []v1beta1.AccessRule{{Clients: []string{"10.0.0.0/24"}}},
+		ServerAddress:     "10.52.0.20",
+		PreviousAddresses: []string{"10.52.0.19"},
+		RecoveryAttempts:  1,
+		LastRecoveryTime:  &testTime,
+		ExportPath:        "/pvc-1234",
+		Health:            &v1beta1.HealthStatus{LatencyMilliseconds: 3, LastSuccessTime: &testTime},
+		Remounts: []v1beta1.NodeRemountStatus{
+			{NodeName: "node-1", Result: v1beta1.RemountResult("Succeeded"), MountPoints: []string{"/mnt/a"}, StaleAddress: "10.52.0.19", LastTransitionTime: testTime},
+		},
+		Schedule: &v1beta1.ScheduleStatus{NextState: v1beta1.NetworkFSStateDisabled, NextTime: &testTime, LastState: v1beta1.NetworkFSStateEnabled, LastTime: &testTime},
+		Activity: &v1beta1.ActivityStatus{Clients: 2, IdleSince: &testTime},
+		Node:     "node-1",
+		Migration: &v1beta1.MigrationStatus{
+			SourceNode: "node-1", TargetNode: "node-2", Phase: v1beta1.MigrationPhaseDetaching, Message: "Stopping", StartTime: &testTime,
+		},
+	}
+	return networkFS
+}
+
+func TestV1beta1RoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		networkFS *v1beta1.NetworkFilesystem
+	}{
+		{
+			name:      "every field",
+			networkFS: fullV1beta1(),
+		},
+		{
+			name:      "only the deprecated perferredNodes",
+			networkFS: newV1beta1(v1beta1.NetworkFSSpec{NetworkFSName: "pvc-1234", DesiredState: v1beta1.NetworkFSStateDisabled, PreferredNode: "node-1"}),
+		},
+		{
+			name:      "a single candidate node",
+			networkFS: newV1beta1(v1beta1.NetworkFSSpec{NetworkFSName: "pvc-1234", DesiredState: v1beta1.NetworkFSStateDisabled, CandidateNodes: []string{"node-1"}}),
+		},
+		{
+			name:      "no placement rule",
+			networkFS: newV1beta1(v1beta1.NetworkFSSpec{NetworkFSName: "pvc-1234", DesiredState: v1beta1.NetworkFSStateDisabled}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ConvertToV1beta1(ConvertFromV1beta1(tt.networkFS))
+			if !reflect.DeepEqual(got, tt.networkFS) {
+				t.Errorf("round trip changed the object\ngot:  %+v\nwant: %+v", got, tt.networkFS)
+			}
+		})
+	}
+}
+
+func TestV1beta2RoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		networkFS *NetworkFilesystem
+	}{
+		{
+			name: "preferred nodes with the candidate weights",
+			networkFS: newV1beta2(NetworkFSSpec{NetworkFSName: "pvc-1234", DesiredState: NetworkFSStateEnabled, PreferredNodes: []PreferredNode{
+				{Name: "node-1", Weight: 100}, {Name: "node-2", Weight: 99},
+			}}),
+		},
+		{
+			name: "preferred nodes with arbitrary weights",
+			networkFS: newV1beta2(NetworkFSSpec{NetworkFSName: "pvc-1234", DesiredState: NetworkFSStateEnabled, PreferredNodes: []PreferredNode{
+				{Name: "node-2", Weight: 10}, {Name: "node-1", Weight: 50},
+			}}),
+		},
+		{
+			name: "a single preferred node with an arbitrary weight",
+			networkFS: newV1beta2(NetworkFSSpec{NetworkFSName: "pvc-1234", DesiredState: NetworkFSStateEnabled, PreferredNodes: []PreferredNode{
+				{Name: "node-1", Weight: 7},
+			}}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ConvertFromV1beta1(ConvertToV1beta1(tt.networkFS))
+			if !reflect.DeepEqual(got, tt.networkFS) {
+				t.Errorf("round trip changed the object\ngot:  %+v\nwant: %+v", got, tt.networkFS)
+			}
+		})
+	}
+}
+
+func TestV1beta2WriteKeepsDeprecatedPreferredNode(t *testing.T) {
+	stored := newV1beta1(v1beta1.NetworkFSSpec{NetworkFSName: "pvc-1234", DesiredState: v1beta1.NetworkFSStateDisabled, PreferredNode: "node-1"})
+
+	// a v1beta2 client updates an unrelated field
+	networkFS := ConvertFromV1beta1(stored)
+	networkFS.Spec.DesiredState = NetworkFSStateEnabled
+	got := ConvertToV1beta1(networkFS)
+	if got.Spec.PreferredNode != "node-1" || len(got.Spec.CandidateNodes) != 0 {
+		t.Errorf("expected the perferredNodes to be kept, got perferredNodes %q and candidateNodes %v", got.Spec.PreferredNode, got.Spec.CandidateNodes)
+	}
+	if _, found := got.Annotations[AnnotationV1beta1PreferredNode]; found {
+		t.Errorf("annotation %s is leaked to the stored object", AnnotationV1beta1PreferredNode)
+	}
+
+	// a v1beta2 client changes the preferred nodes, they become the candidates
+	networkFS = ConvertFromV1beta1(stored)
+	networkFS.Spec.PreferredNodes = append(networkFS.Spec.PreferredNodes, PreferredNode{Name: "node-2", Weight: 1})
+	got = ConvertToV1beta1(networkFS)
+	if got.Spec.PreferredNode != "" || !reflect.DeepEqual(got.Spec.CandidateNodes, []string{"node-1", "node-2"}) {
+		t.Errorf("expected the candidateNodes to replace perferredNodes, got perferredNodes %q and candidateNodes %v", got.Spec.PreferredNode, got.Spec.CandidateNodes)
+	}
+}
+
+func TestV1beta1EditWinsOverWeights(t *testing.T) {
+	networkFS := newV1beta2(NetworkFSSpec{NetworkFSName: "pvc-1234", DesiredState: NetworkFSStateEnabled, PreferredNodes: []PreferredNode{
+		{Name: "node-1", Weight: 50}, {Name: "node-2", Weight: 10},
+	}})
+
+	// a v1beta1 client reorders the candidates, the weights in the annotation no longer apply
+	stored := ConvertToV1beta1(networkFS)
+	stored.Spec.CandidateNodes = []string{"node-2", "node-1"}
+	got := ConvertFromV1beta1(stored)
+	want := []PreferredNode{{Name: "node-2", Weight: candidateWeight(0)}, {Name: "node-1", Weight: candidateWeight(1)}}
+	if !reflect.DeepEqual(got.Spec.PreferredNodes, want) {
+		t.Errorf("expected preferred nodes %v, got %v", want, got.Spec.PreferredNodes)
+	}
+}
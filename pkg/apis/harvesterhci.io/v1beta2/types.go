@@ -0,0 +1,408 @@
+package v1beta2
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type NetworkFSState string
+type EndpointStatus string
+type ConditionType string
+type ExportBackendType string
+type AccessType string
+type SquashType string
+type NetworkFSProtocol string
+
+const (
+	// NetworkFSStateEnabled indicates the networkFS endpoint is enabled
+	NetworkFSStateEnabled NetworkFSState = "Enabled"
+	// NetworkFSStateEnabling indicates the networkFS endpoint is enabling
+	NetworkFSStateEnabling NetworkFSState = "Enabling"
+	// NetworkFSStateDisabling indicates the networkFS endpoint is disabling
+	NetworkFSStateDisabling NetworkFSState = "Disabling"
+	// NetworkFSStateDisabled indicates the networkFS endpoint is disabled
+	NetworkFSStateDisabled NetworkFSState = "Disabled"
+	// NetworkFSStateUnknown indicates the networkFS endpoint state is unknown (initial state)
+	NetworkFSStateUnknown NetworkFSState = "Unknown"
+
+	// EndpointStatusReady indicates the endpoint is ready
+	EndpointStatusReady EndpointStatus = "Ready"
+	// EndpointStatusNotReady indicates the endpoint is not ready
+	EndpointStatusNotReady EndpointStatus = "NotReady"
+	// EndpointStatusReconciling indicates the endpoint is reconciling
+	EndpointStatusReconciling EndpointStatus = "Reconciling"
+	// EndpointStatusUnknown indicates the endpoint status is unknown
+	EndpointStatusUnknown EndpointStatus = "Unknown"
+
+	// ConditionTypeReady indicates the networkFS is ready
+	ConditionTypeReady ConditionType = "Ready"
+	// ConditionTypeNotReady indicates the networkFS is not ready
+	ConditionTypeNotReady ConditionType = "NotReady"
+	// ConditionTypeReconciling indicates the networkFS is reconciling (maybe wait for the backend NFS service)
+	ConditionTypeReconciling ConditionType = "Reconciling"
+	// ConditionTypeEndpointChanged indicates the networkFS endpoint is changed
+	ConditionTypeEndpointChanged ConditionType = "EndpointChanged"
+	// ConditionTypeHealthy indicates the result of the active probe against the endpoint
+	ConditionTypeHealthy ConditionType = "Healthy"
+	// ConditionTypeIdle indicates the networkFS is disabled because it had no client for the idle timeout
+	ConditionTypeIdle ConditionType = "Idle"
+	// ConditionTypeEndpointChanging indicates the export is migrating to another node, the endpoint is unavailable until it is ready there
+	ConditionTypeEndpointChanging ConditionType = "EndpointChanging"
+
+	// NetworkFSProtocolNFS indicates the networkFS endpoint is NFS
+	NetworkFSProtocolNFS NetworkFSProtocol = "NFS"
+	// NetworkFSProtocolSMB indicates the networkFS endpoint is SMB/CIFS
+	NetworkFSProtocolSMB NetworkFSProtocol = "SMB"
+	// NetworkFSProtocolUnknown indicates the networkFS endpoint protocol is unknown
+	NetworkFSProtocolUnknown NetworkFSProtocol = "Unknown"
+
+	// ExportBackendLonghorn exports the Longhorn RWX volume through the Longhorn share manager
+	ExportBackendLonghorn ExportBackendType = "Longhorn"
+	// ExportBackendGanesha exports any PVC through a managed NFS-Ganesha server pod
+	ExportBackendGanesha ExportBackendType = "Ganesha"
+
+	// AccessReadWrite allows the clients to read and write the export
+	AccessReadWrite AccessType = "ReadWrite"
+	// AccessReadOnly allows the clients to only read the export
+	AccessReadOnly AccessType = "ReadOnly"
+
+	// SquashNone keeps the uid/gid of the clients
+	SquashNone SquashType = "None"
+	// SquashRoot maps the root user of the clients to the anonymous user
+	SquashRoot SquashType = "RootSquash"
+	// SquashAll maps all users of the clients to the anonymous user
+	SquashAll SquashType = "AllSquash"
+
+	// DefaultPreferredNodeWeight is the weight of the first candidate node converted from v1beta1
+	DefaultPreferredNodeWeight int32 = 100
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:shortName=netfilesystem;netfilesystems,scope=Namespaced
+// +kubebuilder:printcolumn:name="DesiredState",type="string",JSONPath=`.spec.desiredState`
+// +kubebuilder:printcolumn:name="Endpoint",type="string",JSONPath=`.status.endpoint`
+// +kubebuilder:printcolumn:name="EndpointStatus",type="string",JSONPath=`.status.status`
+// +kubebuilder:printcolumn:name="State",type="string",JSONPath=`.status.state`
+// +kubebuilder:printcolumn:name="Type",type="string",JSONPath=`.status.type`
+// +kubebuilder:subresource:status
+
+type NetworkFilesystem struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              NetworkFSSpec   `json:"spec"`
+	Status            NetworkFSStatus `json:"status,omitempty"`
+}
+
+type NetworkFSSpec struct {
+	// name of the networkFS to which the endpoint is exported,
+	// it is the Longhorn volume name for the Longhorn backend or the PVC name (in the same namespace) for the Ganesha backend
+	// +kubebuilder:validation:Required
+	NetworkFSName string `json:"networkFSName"`
+
+	// backend which exports the networkFS, options are "Longhorn" or "Ganesha"
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum:=Longhorn;Ganesha
+	// +kubebuilder:default:=Longhorn
+	ExportBackend ExportBackendType `json:"exportBackend,omitempty"`
+
+	// protocol of the export, options are "NFS" or "SMB", SMB is only supported by the Ganesha backend (served by Samba)
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum:=NFS;SMB
+	// +kubebuilder:default:=NFS
+	Protocol NetworkFSProtocol `json:"protocol,omitempty"`
+
+	// secret which contains the "username" and "password" of the SMB share, required by the SMB protocol
+	// +kubebuilder:validation:Optional
+	SMBCredentialsSecretRef *corev1.LocalObjectReference `json:"smbCredentialsSecretRef,omitempty"`
+
+	// access rules of the export, the export is open to all clients when it is empty,
+	// otherwise only the clients matched by the rules are allowed to mount it
+	// +kubebuilder:validation:Optional
+	AccessRules []AccessRule `json:"accessRules,omitempty"`
+
+	// the managed service which provides the stable address of the export
+	// +kubebuilder:validation:Optional
+	Service ExportService `json:"service,omitempty"`
+
+	// secret to which the connection details (server, port, path, protocol, mountOptions and uri) of the
+	// ready export are written, the secret is created and owned by the networkFS
+	// +kubebuilder:validation:Optional
+	ConnectionSecretRef *corev1.LocalObjectReference `json:"connectionSecretRef,omitempty"`
+
+	// the windows in which the networkFS is enabled, the desiredState is flipped by the manager at the scheduled times
+	// +kubebuilder:validation:Optional
+	Schedule *ExportSchedule `json:"schedule,omitempty"`
+
+	// the networkFS is disabled after it had no client for the duration, e.g. "2h", it is never disabled for idleness when it is empty
+	// +kubebuilder:validation:Optional
+	IdleTimeout *metav1.Duration `json:"idleTimeout,omitempty"`
+
+	// desired state of the networkFS endpoint, options are "Disabled" or "Enabled"
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum:=Disabled;Enabled
+	DesiredState NetworkFSState `json:"desiredState"`
+
+	// preferred nodes to which the networkFS endpoint is exported, the node with higher weight is preferred and
+	// the export is moved to the next available one when its node goes NotReady or is cordoned
+	// +kubebuilder:validation:Optional
+	PreferredNodes []PreferredNode `json:"preferredNodes,omitempty"`
+
+	// labels of the nodes to which the networkFS endpoint is exported, the matched nodes are the candidates
+	// (ordered by name) when the preferredNodes is empty, otherwise the preferredNodes are filtered by it
+	// +kubebuilder:validation:Optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// node to which the running export is migrated, changing it moves the export there in a planned way,
+	// the node is preferred over the preferredNodes while it is eligible
+	// +kubebuilder:validation:Optional
+	TargetNode string `json:"targetNode,omitempty"`
+}
+
+type PreferredNode struct {
+	// name of the node
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// weight of the node, in the range 1-100
+	// +kubebuilder:validation:Minimum:=1
+	// +kubebuilder:validation:Maximum:=100
+	// +kubebuilder:default:=100
+	Weight int32 `json:"weight"`
+}
+
+type NetworkFSStatus struct {
+
+	// the generation of the spec which is handled by the controller
+	// +kubebuilder:validation:Optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// the conditions of the networkFS
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:={}
+	NetworkFSConds []NetworkFSCondition `json:"conditions,omitempty"`
+
+	// the current Endpoint of the networkFS, it is the stable address of the managed service
+	// +kubebuilder:validation:
+	// +kubebuilder:default:=""
+	Endpoint string `json:"endpoint"`
+
+	// the current state of the networkFS endpoint, options are "Enabled", "Enabling", "Disabling", "Disabled", or "Unknown"
+	// +kubebuilder:validation:Enum:=Enabled;Enabling;Disabling;Disabled;Unknown
+	// +kubebuilder:default:=Disabled
+	State NetworkFSState `json:"state"`
+
+	// the protocol of the networkFS endpoint, options are "NFS", "SMB", or "Unknown"
+	// +kubebuilder:validation:Enum:=NFS;SMB;Unknown
+	// +kubebuilder:default:=NFS
+	Type NetworkFSProtocol `json:"type"`
+
+	// the status of the endpoint
+	// +kubebuilder:validation:Enum:=Ready;NotReady;Reconciling;Unknown
+	// +kubebuilder:default:=NotReady
+	Status EndpointStatus `json:"status"`
+
+	// the recommend mount options for the networkFS endpoint
+	MountOpts string `json:"mountOpts,omitempty"`
+
+	// the UNC path of the SMB share, e.g. \\10.0.0.1\share
+	UNCPath string `json:"uncPath,omitempty"`
+
+	// the access rules which are enforced by the export backend
+	AccessRules []AccessRule `json:"accessRules,omitempty"`
+
+	// the address of the server behind the managed service, it changes when the server is rescheduled
+	ServerAddress string `json:"serverAddress,omitempty"`
+
+	// the endpoints and server addresses the export was served from before, the latest is the last one.
+	// The mount agents only remount the stale mounts of these addresses.
+	PreviousAddresses []string `json:"previousAddresses,omitempty"`
+
+	// the number of the recovery attempts since the export failed, it is reset once the export is ready
+	RecoveryAttempts int32 `json:"recoveryAttempts,omitempty"`
+
+	// the last time the export was recovered
+	LastRecoveryTime *metav1.Time `json:"lastRecoveryTime,omitempty"`
+
+	// the path of the export on the server, e.g. the NFS pseudo path
+	ExportPath string `json:"exportPath,omitempty"`
+
+	// the result of the active probe against the endpoint
+	Health *HealthStatus `json:"health,omitempty"`
+
+	// the remount results of the stale mounts, reported by the mount agent of each node
+	Remounts []NodeRemountStatus `json:"remounts,omitempty"`
+
+	// the transitions of the desiredState planned and applied by the schedule
+	Schedule *ScheduleStatus `json:"schedule,omitempty"`
+
+	// the clients observed on the server, it is only reported when the idleTimeout is set
+	Activity *ActivityStatus `json:"activity,omitempty"`
+
+	// the node which is chosen from the candidates to host the export, it is empty when the backend picks the node
+	Node string `json:"node,omitempty"`
+
+	// the last planned migration of the export to the targetNode
+	Migration *MigrationStatus `json:"migration,omitempty"`
+}
+
+type ExportSchedule struct {
+	// cron expression (minute hour day-of-month month day-of-week) at which the networkFS is enabled
+	// +kubebuilder:validation:Optional
+	Enable string `json:"enable,omitempty"`
+
+	// cron expression (minute hour day-of-month month day-of-week) at which the networkFS is disabled
+	// +kubebuilder:validation:Optional
+	Disable string `json:"disable,omitempty"`
+
+	// IANA time zone of the cron expressions, e.g. "Asia/Taipei", UTC is used when it is empty
+	// +kubebuilder:validation:Optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+type ScheduleStatus struct {
+	// the desiredState which is planned by the schedule
+	NextState NetworkFSState `json:"nextState,omitempty"`
+
+	// the time of the planned transition
+	NextTime *metav1.Time `json:"nextTime,omitempty"`
+
+	// the desiredState which was applied by the schedule last time
+	LastState NetworkFSState `json:"lastState,omitempty"`
+
+	// the time of the last applied transition
+	LastTime *metav1.Time `json:"lastTime,omitempty"`
+
+	// the schedule is suspended by the manual override annotation, the planned transitions are skipped
+	Overridden bool `json:"overridden,omitempty"`
+}
+
+type RemountResult string
+
+const (
+	// RemountResultRemounted indicates the stale mounts are remounted from the current endpoint
+	RemountResultRemounted RemountResult = "Remounted"
+	// RemountResultFailed indicates the stale mounts could not be remounted
+	RemountResultFailed RemountResult = "Failed"
+)
+
+type NodeRemountStatus struct {
+	// name of the node
+	NodeName string `json:"nodeName"`
+
+	// result of the last remount on the node, options are "Remounted" or "Failed"
+	// +kubebuilder:validation:Enum:=Remounted;Failed
+	Result RemountResult `json:"result"`
+
+	// the mount points which were mounted from the stale address
+	MountPoints []string `json:"mountPoints,omitempty"`
+
+	// the stale server address of the mounts
+	StaleAddress string `json:"staleAddress,omitempty"`
+
+	// the details of the result
+	Message string `json:"message,omitempty"`
+
+	// the last time the stale mounts were handled
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+type MigrationPhase string
+
+const (
+	// MigrationPhasePending indicates the target node is not eligible, the migration starts once it is
+	MigrationPhasePending MigrationPhase = "Pending"
+	// MigrationPhaseDetaching indicates the server on the source node is being stopped
+	MigrationPhaseDetaching MigrationPhase = "Detaching"
+	// MigrationPhaseAttaching indicates the export is brought up on the target node, it waits for the new endpoint
+	MigrationPhaseAttaching MigrationPhase = "Attaching"
+	// MigrationPhaseCompleted indicates the export is served from the target node
+	MigrationPhaseCompleted MigrationPhase = "Completed"
+	// MigrationPhaseFailed indicates the export could not be moved to the target node
+	MigrationPhaseFailed MigrationPhase = "Failed"
+)
+
+type MigrationStatus struct {
+	// the node from which the export is migrated
+	SourceNode string `json:"sourceNode,omitempty"`
+
+	// the node to which the export is migrated
+	TargetNode string `json:"targetNode"`
+
+	// phase of the migration, options are "Pending", "Detaching", "Attaching", "Completed" or "Failed"
+	// +kubebuilder:validation:Enum:=Pending;Detaching;Attaching;Completed;Failed
+	Phase MigrationPhase `json:"phase"`
+
+	// the details of the phase
+	Message string `json:"message,omitempty"`
+
+	// the time the migration was announced by the EndpointChanging condition
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// the time the server on the source node was stopped
+	DetachedTime *metav1.Time `json:"detachedTime,omitempty"`
+
+	// the time the endpoint on the target node became ready
+	EndpointReadyTime *metav1.Time `json:"endpointReadyTime,omitempty"`
+
+	// the time the managed service and secrets were updated with the new endpoint
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+type ActivityStatus struct {
+	// the number of the clients connected to the server at the last check
+	Clients int32 `json:"clients,omitempty"`
+
+	// the time since which the server has had no client, it is cleared when a client connects
+	IdleSince *metav1.Time `json:"idleSince,omitempty"`
+}
+
+type HealthStatus struct {
+	// the latency of the last successful probe in milliseconds
+	LatencyMilliseconds int64 `json:"latencyMilliseconds,omitempty"`
+
+	// the last time the probe succeeded
+	LastSuccessTime *metav1.Time `json:"lastSuccessTime,omitempty"`
+}
+
+type ExportService struct {
+	// type of the service, options are "ClusterIP" or "LoadBalancer"
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum:=ClusterIP;LoadBalancer
+	// +kubebuilder:default:=ClusterIP
+	Type corev1.ServiceType `json:"type,omitempty"`
+
+	// requested address of the LoadBalancer service, it is set to the kube-vip annotation "kube-vip.io/loadbalancerIPs"
+	// +kubebuilder:validation:Optional
+	LoadBalancerIP string `json:"loadBalancerIP,omitempty"`
+
+	// extra annotations of the service, e.g. the IPAM annotation of the load balancer
+	// +kubebuilder:validation:Optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type AccessRule struct {
+	// client addresses matched by the rule, in CIDR notation or a single IP
+	// +kubebuilder:validation:MinItems:=1
+	Clients []string `json:"clients"`
+
+	// access of the matched clients, options are "ReadWrite" or "ReadOnly"
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum:=ReadWrite;ReadOnly
+	// +kubebuilder:default:=ReadWrite
+	Access AccessType `json:"access,omitempty"`
+
+	// squash of the matched clients, options are "None", "RootSquash" or "AllSquash"
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum:=None;RootSquash;AllSquash
+	// +kubebuilder:default:=None
+	Squash SquashType `json:"squash,omitempty"`
+}
+
+type NetworkFSCondition struct {
+	Type               ConditionType          `json:"type"`
+	Status             corev1.ConditionStatus `json:"status"`
+	LastTransitionTime metav1.Time            `json:"lastTransitionTime"`
+	Reason             string                 `json:"reason,omitempty"`
+	Message            string                 `json:"message,omitempty"`
+}
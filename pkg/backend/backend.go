@@ -0,0 +1,151 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+)
+
+// ExportStatus is the observed state of the export
+type ExportStatus struct {
+	// Ready means the server is exporting the volume on Endpoint
+	Ready bool
+	// Stopped means the server is completely gone, the networkFS can be marked as disabled
+	Stopped bool
+	// Failed means the server is in an error state which needs recovery
+	Failed bool
+	// Endpoint is the address of the server
+	Endpoint string
+	// Node is the node the server runs on, empty when it is unknown
+	Node string
+	// MountOpts is the recommended mount options of the export
+	MountOpts string
+	// ExportPath is the NFS pseudo path of the export, empty for the SMB share
+	ExportPath string
+	// ShareName is the name of the SMB share, empty for the NFS export
+	ShareName string
+	// Message describes the observed state
+	Message string
+}
+
+// ExportBackend exports the volume of the networkFS through a network filesystem server
+type ExportBackend interface {
+	// Enable asks the backend to export the volume, it should be idempotent
+	Enable(networkFS *networkfsv1.NetworkFilesystem) error
+	// Disable asks the backend to stop exporting the volume, it should be idempotent
+	Disable(networkFS *networkfsv1.NetworkFilesystem) error
+	// Observe returns the current endpoint and health of the export
+	Observe(networkFS *networkfsv1.NetworkFilesystem) (*ExportStatus, error)
+	// Recover tears down the failed server, the following Enable brings it up again
+	Recover(networkFS *networkfsv1.NetworkFilesystem) error
+	// Release stops exporting the volume of the deleting networkFS regardless of its other users, it returns inUse
+	// when the server keeps running for them, so it is not waited for
+	Release(networkFS *networkfsv1.NetworkFilesystem) (inUse bool, err error)
+}
+
+// Watcher is implemented by the backends which need to watch their own resources,
+// enqueue is called with the namespace/name of the networkFS whenever the export may change.
+type Watcher interface {
+	Watch(ctx context.Context, enqueue func(namespace, name string))
+}
+
+// ServerPodLocator is implemented by the backends which serve the export from a pod,
+// the pod is inspected to count the clients of the export.
+type ServerPodLocator interface {
+	// ServerPod returns the namespace, name and container of the pod serving the export
+	ServerPod(networkFS *networkfsv1.NetworkFilesystem) (namespace, name, container string)
+}
+
+// Backends maps the export backend type to the implementation
+type Backends map[networkfsv1.ExportBackendType]ExportBackend
+
+// TypeOf returns the export backend type of the networkFS, Longhorn is the default one
+func TypeOf(networkFS *networkfsv1.NetworkFilesystem) networkfsv1.ExportBackendType {
+	if networkFS.Spec.ExportBackend == "" {
+		return networkfsv1.ExportBackendLonghorn
+	}
+	return networkFS.Spec.ExportBackend
+}
+
+// ProtocolOf returns the protocol of the networkFS, NFS is the default one
+func ProtocolOf(networkFS *networkfsv1.NetworkFilesystem) string {
+	if networkFS.Spec.Protocol == "" {
+		return networkfsv1.NetworkFSTypeNFS
+	}
+	return networkFS.Spec.Protocol
+}
+
+// PortOf returns the server port of the protocol
+func PortOf(protocol string) int32 {
+	if protocol == networkfsv1.NetworkFSTypeSMB {
+		return 445
+	}
+	return 2049
+}
+
+// SupportsProtocol returns whether the export backend type is able to serve the protocol,
+// the Longhorn share manager only speaks NFS.
+func SupportsProtocol(backendType networkfsv1.ExportBackendType, protocol string) bool {
+	switch protocol {
+	case networkfsv1.NetworkFSTypeNFS:
+		return true
+	case networkfsv1.NetworkFSTypeSMB:
+		return backendType == networkfsv1.ExportBackendGanesha
+	}
+	return false
+}
+
+// AccessOf returns the access of the rule, ReadWrite is the default one
+func AccessOf(rule networkfsv1.AccessRule) networkfsv1.AccessType {
+	if rule.Access == "" {
+		return networkfsv1.AccessReadWrite
+	}
+	return rule.Access
+}
+
+// SquashOf returns the squash of the rule, None is the default one
+func SquashOf(rule networkfsv1.AccessRule) networkfsv1.SquashType {
+	if rule.Squash == "" {
+		return networkfsv1.SquashNone
+	}
+	return rule.Squash
+}
+
+// ClientCIDR returns the client address in CIDR notation, a single IP is converted to /32 (or /128 for IPv6)
+func ClientCIDR(client string) (string, error) {
+	if _, ipNet, err := net.ParseCIDR(client); err == nil {
+		return ipNet.String(), nil
+	}
+	ip := net.ParseIP(client)
+	if ip == nil {
+		return "", fmt.Errorf("invalid client %q, it should be a CIDR or an IP address", client)
+	}
+	if ip.To4() != nil {
+		return ip.String() + "/32", nil
+	}
+	return ip.String() + "/128", nil
+}
+
+// Get returns the export backend of the networkFS
+func (b Backends) Get(networkFS *networkfsv1.NetworkFilesystem) (ExportBackend, error) {
+	backendType := TypeOf(networkFS)
+	if protocol := ProtocolOf(networkFS); !SupportsProtocol(backendType, protocol) {
+		return nil, fmt.Errorf("export backend %s does not support protocol %s", backendType, protocol)
+	}
+	backend, found := b[backendType]
+	if !found {
+		return nil, fmt.Errorf("unsupported export backend %s", backendType)
+	}
+	return backend, nil
+}
+
+// Watch starts the watchers of all backends
+func (b Backends) Watch(ctx context.Context, enqueue func(namespace, name string)) {
+	for _, backend := range b {
+		if watcher, ok := backend.(Watcher); ok {
+			watcher.Watch(ctx, enqueue)
+		}
+	}
+}
@@ -0,0 +1,481 @@
+package ganesha
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"reflect"
+	"strings"
+
+	ctlv1 "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/backend"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+const (
+	// LabelNetworkFS is the label of the resources which are managed for the networkFS
+	LabelNetworkFS = "networkfs.harvesterhci.io/ganesha"
+
+	annotationConfigHash = "networkfs.harvesterhci.io/config-hash"
+
+	ganeshaPodHandlerName    = "harvester-netfs-ganesha-pod-handler"
+	ganeshaSecretHandlerName = "harvester-netfs-ganesha-secret-handler"
+
+	// podByCredentialsIndex indexes the server pods by the namespace/name of the SMB credentials secret they load
+	podByCredentialsIndex = "networkfs.harvesterhci.io/ganesha-pod-by-credentials"
+
+	nfsPort        = 2049
+	exportPath     = "/export"
+	configDir      = "/etc/ganesha"
+	configFileName = "ganesha.conf"
+
+	defaultMountOpts = "vers=4.1,noresvport,timeo=600,retrans=5,softerr"
+)
+
+// Backend exports any PVC through a managed server pod, NFS-Ganesha for NFS and Samba for SMB
+type Backend struct {
+	image      string
+	sambaImage string
+
+	Pods        ctlv1.PodController
+	PodCache    ctlv1.PodCache
+	ConfigMaps  ctlv1.ConfigMapController
+	Secrets     ctlv1.SecretController
+	SecretCache ctlv1.SecretCache
+}
+
+// New creates the NFS-Ganesha export backend
+func New(pods ctlv1.PodController, configmaps ctlv1.ConfigMapController, secrets ctlv1.SecretController, image, sambaImage string) *Backend {
+	b := &Backend{
+		image:       image,
+		sambaImage:  sambaImage,
+		Pods:        pods,
+		PodCache:    pods.Cache(),
+		ConfigMaps:  configmaps,
+		Secrets:     secrets,
+		SecretCache: secrets.Cache(),
+	}
+	b.PodCache.AddIndexer(podByCredentialsIndex, indexPodByCredentials)
+	return b
+}
+
+var _ backend.ExportBackend = &Backend{}
+var _ backend.Watcher = &Backend{}
+var _ backend.ServerPodLocator = &Backend{}
+
+// ResourceName returns the name of the pod and configmap of the networkFS
+func ResourceName(networkFS *networkfsv1.NetworkFilesystem) string {
+	return fmt.Sprintf("netfs-ganesha-%s", networkFS.Name)
+}
+
+func (b *Backend) Enable(networkFS *networkfsv1.NetworkFilesystem) error {
+	configFile, config := b.serverConfig(networkFS)
+	if err := b.ensureConfigMap(networkFS, map[string]string{configFile: config}); err != nil {
+		return err
+	}
+
+	configHash, err := b.configHash(networkFS, config)
+	if err != nil {
+		return err
+	}
+	pod, err := b.PodCache.Get(networkFS.Namespace, ResourceName(networkFS))
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get ganesha pod %s: %w", ResourceName(networkFS), err)
+	}
+	if err == nil {
+		// neither ganesha nor smbd reloads the exports, restart it when the config changes or it is dead
+		if pod.DeletionTimestamp != nil {
+			return nil
+		}
+		// the pod is pinned to the node when it is created, restart it to move the server to the chosen node
+		onChosenNode := networkFS.Status.Node == "" || pod.Spec.NodeName == "" || pod.Spec.NodeName == networkFS.Status.Node
+		if pod.Annotations[annotationConfigHash] == configHash && onChosenNode && pod.Status.Phase != corev1.PodFailed && pod.Status.Phase != corev1.PodSucceeded {
+			return nil
+		}
+		logrus.Infof("Restart ganesha pod %s/%s", pod.Namespace, pod.Name)
+		return b.deletePod(networkFS)
+	}
+
+	logrus.Infof("Create ganesha pod %s/%s", networkFS.Namespace, ResourceName(networkFS))
+	if _, err := b.Pods.Create(b.constructPod(networkFS, configHash)); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create ganesha pod %s: %w", ResourceName(networkFS), err)
+	}
+	return nil
+}
+
+func (b *Backend) Disable(networkFS *networkfsv1.NetworkFilesystem) error {
+	return b.deletePod(networkFS)
+}
+
+// Release deletes the pod, nothing else is served by it
+func (b *Backend) Release(networkFS *networkfsv1.NetworkFilesystem) (bool, error) {
+	return false, b.deletePod(networkFS)
+}
+
+// Recover deletes the failed pod, it is re-created by the following Enable
+func (b *Backend) Recover(networkFS *networkfsv1.NetworkFilesystem) error {
+	return b.deletePod(networkFS)
+}
+
+func (b *Backend) Observe(networkFS *networkfsv1.NetworkFilesystem) (*backend.ExportStatus, error) {
+	status := &backend.ExportStatus{}
+	pod, err := b.PodCache.Get(networkFS.Namespace, ResourceName(networkFS))
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			status.Stopped = true
+			status.Message = "Ganesha pod is stopped, means the networkfs is disabled"
+			return status, nil
+		}
+		return nil, fmt.Errorf("failed to get ganesha pod %s: %w", ResourceName(networkFS), err)
+	}
+
+	if pod.DeletionTimestamp == nil && (pod.Status.Phase == corev1.PodFailed || isPodCrashLooping(pod)) {
+		status.Failed = true
+		status.Message = fmt.Sprintf("Ganesha pod is failed, phase %s", pod.Status.Phase)
+		return status, nil
+	}
+	if pod.DeletionTimestamp != nil || !isPodReady(pod) || pod.Status.PodIP == "" {
+		status.Message = fmt.Sprintf("Ganesha pod is not ready, phase %s", pod.Status.Phase)
+		return status, nil
+	}
+	_, config := b.serverConfig(networkFS)
+	configHash, err := b.configHash(networkFS, config)
+	if err != nil {
+		return nil, err
+	}
+	if pod.Annotations[annotationConfigHash] != configHash {
+		// e.g. the SMB credentials are rotated, the following Enable restarts the server with them
+		status.Message = "Ganesha pod runs with the outdated config or credentials"
+		return status, nil
+	}
+
+	status.Ready = true
+	status.Endpoint = pod.Status.PodIP
+	status.Node = pod.Spec.NodeName
+	status.MountOpts = defaultMountOpts
+	status.ExportPath = "/" + networkFS.Name
+	status.Message = "Ganesha pod is ready"
+	if backend.ProtocolOf(networkFS) == networkfsv1.NetworkFSTypeSMB {
+		status.MountOpts = defaultSMBMountOpts
+		status.ExportPath = ""
+		status.ShareName = shareName(networkFS)
+		status.Message = "Samba pod is ready"
+	}
+	return status, nil
+}
+
+// Watch enqueues the networkFS when its ganesha pod or the SMB credentials loaded by the pod change
+func (b *Backend) Watch(ctx context.Context, enqueue func(namespace, name string)) {
+	b.Pods.OnChange(ctx, ganeshaPodHandlerName, func(_ string, pod *corev1.Pod) (*corev1.Pod, error) {
+		if pod == nil {
+			return nil, nil
+		}
+		if name, found := pod.Labels[LabelNetworkFS]; found {
+			enqueue(pod.Namespace, name)
+		}
+		return nil, nil
+	})
+	b.Secrets.OnChange(ctx, ganeshaSecretHandlerName, func(key string, _ *corev1.Secret) (*corev1.Secret, error) {
+		pods, err := b.PodCache.GetByIndex(podByCredentialsIndex, key)
+		if err != nil {
+			return nil, err
+		}
+		for _, pod := range pods {
+			enqueue(pod.Namespace, pod.Labels[LabelNetworkFS])
+		}
+		return nil, nil
+	})
+}
+
+// serverConfig returns the name and the content of the server config file of the networkFS
+func (b *Backend) serverConfig(networkFS *networkfsv1.NetworkFilesystem) (string, string) {
+	if backend.ProtocolOf(networkFS) == networkfsv1.NetworkFSTypeSMB {
+		return sambaConfigFileName, renderSambaConfig(networkFS)
+	}
+	return configFileName, renderConfig(networkFS)
+}
+
+// configHash returns the hash of everything the server loads on start, the server container differs between
+// protocols and the SMB credentials are only loaded on start, so they are part of the hash as well
+func (b *Backend) configHash(networkFS *networkfsv1.NetworkFilesystem, config string) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", backend.ProtocolOf(networkFS), config)
+	if secretName := credentialsSecretName(networkFS); secretName != "" {
+		secret, err := b.SecretCache.Get(networkFS.Namespace, secretName)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return "", fmt.Errorf("failed to get SMB credentials secret %s: %w", secretName, err)
+		}
+		// the server does not start without the secret, it is restarted once the secret is created
+		fmt.Fprintf(h, "%s\n", secretName)
+		if err == nil {
+			fmt.Fprintf(h, "%s\n%s\n", secret.Data[SMBUsernameKey], secret.Data[SMBPasswordKey])
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// indexPodByCredentials returns the namespace/name of the SMB credentials secret the server pod loads
+func indexPodByCredentials(pod *corev1.Pod) ([]string, error) {
+	if _, found := pod.Labels[LabelNetworkFS]; !found {
+		return nil, nil
+	}
+	var keys []string
+	for _, container := range pod.Spec.Containers {
+		for _, env := range container.Env {
+			if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+				keys = append(keys, pod.Namespace+"/"+env.ValueFrom.SecretKeyRef.Name)
+			}
+		}
+	}
+	return keys, nil
+}
+
+// ServerPod returns the ganesha pod of the networkFS
+func (b *Backend) ServerPod(networkFS *networkfsv1.NetworkFilesystem) (string, string, string) {
+	return networkFS.Namespace, ResourceName(networkFS), b.serverContainer(networkFS).Name
+}
+
+func (b *Backend) deletePod(networkFS *networkfsv1.NetworkFilesystem) error {
+	if err := b.Pods.Delete(networkFS.Namespace, ResourceName(networkFS), &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete ganesha pod %s: %w", ResourceName(networkFS), err)
+	}
+	return nil
+}
+
+func (b *Backend) ensureConfigMap(networkFS *networkfsv1.NetworkFilesystem, data map[string]string) error {
+	cm, err := b.ConfigMaps.Get(networkFS.Namespace, ResourceName(networkFS), metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get ganesha configmap %s: %w", ResourceName(networkFS), err)
+	}
+	if apierrors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            ResourceName(networkFS),
+				Namespace:       networkFS.Namespace,
+				Labels:          map[string]string{LabelNetworkFS: networkFS.Name},
+				OwnerReferences: utils.NetworkFSOwnerReferences(networkFS),
+			},
+			Data: data,
+		}
+		if _, err := b.ConfigMaps.Create(cm); err != nil && !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create ganesha configmap %s: %w", ResourceName(networkFS), err)
+		}
+		return nil
+	}
+
+	if reflect.DeepEqual(cm.Data, data) {
+		return nil
+	}
+	cmCpy := cm.DeepCopy()
+	cmCpy.Data = data
+	if _, err := b.ConfigMaps.Update(cmCpy); err != nil {
+		return fmt.Errorf("failed to update ganesha configmap %s: %w", ResourceName(networkFS), err)
+	}
+	return nil
+}
+
+func (b *Backend) constructPod(networkFS *networkfsv1.NetworkFilesystem, configHash string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            ResourceName(networkFS),
+			Namespace:       networkFS.Namespace,
+			Labels:          map[string]string{LabelNetworkFS: networkFS.Name},
+			Annotations:     map[string]string{annotationConfigHash: configHash},
+			OwnerReferences: utils.NetworkFSOwnerReferences(networkFS),
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyAlways,
+			Containers: []corev1.Container{
+				b.serverContainer(networkFS),
+			},
+			Volumes: []corev1.Volume{
+				{
+					Name: "export",
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+							ClaimName: networkFS.Spec.NetworkFSName,
+						},
+					},
+				},
+				{
+					Name: "config",
+					VolumeSource: corev1.VolumeSource{
+						ConfigMap: &corev1.ConfigMapVolumeSource{
+							LocalObjectReference: corev1.LocalObjectReference{Name: ResourceName(networkFS)},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if networkFS.Status.Node != "" {
+		pod.Spec.Affinity = &corev1.Affinity{
+			NodeAffinity: &corev1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+					NodeSelectorTerms: []corev1.NodeSelectorTerm{
+						{
+							MatchFields: []corev1.NodeSelectorRequirement{
+								{
+									Key:      "metadata.name",
+									Operator: corev1.NodeSelectorOpIn,
+									Values:   []string{networkFS.Status.Node},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+	return pod
+}
+
+func (b *Backend) serverContainer(networkFS *networkfsv1.NetworkFilesystem) corev1.Container {
+	if backend.ProtocolOf(networkFS) == networkfsv1.NetworkFSTypeSMB {
+		return b.sambaContainer(networkFS)
+	}
+
+	return corev1.Container{
+		Name:    "ganesha",
+		Image:   b.image,
+		Command: []string{"ganesha.nfsd", "-F", "-L", "/dev/stdout", "-f", configDir + "/" + configFileName},
+		Ports: []corev1.ContainerPort{
+			{
+				Name:          "nfs",
+				ContainerPort: nfsPort,
+				Protocol:      corev1.ProtocolTCP,
+			},
+		},
+		ReadinessProbe: &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(nfsPort)},
+			},
+			PeriodSeconds: 5,
+		},
+		// VFS opens the exported files by their handles, which needs DAC_READ_SEARCH, and raises its open file limit
+		SecurityContext: serverSecurityContext("DAC_READ_SEARCH", "SYS_RESOURCE"),
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      "export",
+				MountPath: exportPath,
+			},
+			{
+				Name:      "config",
+				MountPath: configDir,
+			},
+		},
+	}
+}
+
+// renderConfig renders the ganesha config which exports the PVC with the pseudo path /<networkFS name>,
+// the export is closed by default and opened to the clients of the access rules when there are any.
+func renderConfig(networkFS *networkfsv1.NetworkFilesystem) string {
+	accessType := "RW"
+	if len(networkFS.Spec.AccessRules) > 0 {
+		accessType = "None"
+	}
+
+	var clients strings.Builder
+	for _, rule := range networkFS.Spec.AccessRules {
+		cidrs := make([]string, 0, len(rule.Clients))
+		for _, client := range rule.Clients {
+			cidr, err := backend.ClientCIDR(client)
+			if err != nil {
+				// rejected by the webhook, skip it rather than breaking the whole config
+				logrus.Warnf("Skip the invalid client %s of network filesystem %s", client, networkFS.Name)
+				continue
+			}
+			cidrs = append(cidrs, cidr)
+		}
+		if len(cidrs) == 0 {
+			continue
+		}
+		fmt.Fprintf(&clients, `	CLIENT {
+		Clients = %s;
+		Access_Type = %s;
+		Squash = %s;
+	}
+`, strings.Join(cidrs, ", "), ganeshaAccessType(backend.AccessOf(rule)), ganeshaSquash(backend.SquashOf(rule)))
+	}
+
+	return fmt.Sprintf(`NFS_CORE_PARAM {
+	Protocols = 4;
+	NFS_Port = %d;
+}
+
+NFSV4 {
+	Graceless = true;
+	Minor_Versions = 1, 2;
+}
+
+EXPORT {
+	Export_Id = 1;
+	Path = %s;
+	Pseudo = /%s;
+	Protocols = 4;
+	Transports = TCP;
+	SecType = sys;
+	Access_Type = %s;
+	Squash = No_Root_Squash;
+	FSAL {
+		Name = VFS;
+	}
+%s}
+`, nfsPort, exportPath, networkFS.Name, accessType, clients.String())
+}
+
+func ganeshaAccessType(access networkfsv1.AccessType) string {
+	if access == networkfsv1.AccessReadOnly {
+		return "RO"
+	}
+	return "RW"
+}
+
+func ganeshaSquash(squash networkfsv1.SquashType) string {
+	switch squash {
+	case networkfsv1.SquashRoot:
+		return "Root_Squash"
+	case networkfsv1.SquashAll:
+		return "All_Squash"
+	}
+	return "No_Root_Squash"
+}
+
+// serverSecurityContext runs the server container without privileges, it keeps the capabilities of the root user
+// to serve the files of any owner and adds the given ones
+func serverSecurityContext(capabilities ...corev1.Capability) *corev1.SecurityContext {
+	allowPrivilegeEscalation := false
+	return &corev1.SecurityContext{
+		AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{"ALL"},
+			Add:  append([]corev1.Capability{"CHOWN", "DAC_OVERRIDE", "FOWNER", "FSETID", "SETGID", "SETUID"}, capabilities...),
+		},
+	}
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func isPodCrashLooping(pod *corev1.Pod) bool {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Waiting != nil && status.State.Waiting.Reason == "CrashLoopBackOff" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package ganesha
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/backend"
+)
+
+const (
+	smbPort             = 445
+	sambaConfigDir      = "/etc/netfs-samba"
+	sambaConfigFileName = "smb.conf"
+
+	// SMBUsernameKey and SMBPasswordKey are the keys of the SMB credentials secret
+	SMBUsernameKey = "username"
+	SMBPasswordKey = "password"
+
+	defaultSMBMountOpts = "vers=3.0,sec=ntlmssp,cache=strict"
+
+	// smbd authenticates against its own passdb, the credentials are loaded on every start
+	sambaEntrypoint = `set -e
+adduser -D -H "$SMB_USERNAME" 2>/dev/null || useradd -M "$SMB_USERNAME" 2>/dev/null || true
+printf '%s\n%s\n' "$SMB_PASSWORD" "$SMB_PASSWORD" | smbpasswd -s -a "$SMB_USERNAME"
+exec smbd --foreground --no-process-group --debug-stdout --configfile=` + sambaConfigDir + "/" + sambaConfigFileName
+)
+
+func shareName(networkFS *networkfsv1.NetworkFilesystem) string {
+	return networkFS.Name
+}
+
+func credentialsSecretName(networkFS *networkfsv1.NetworkFilesystem) string {
+	if networkFS.Spec.SMBCredentialsSecretRef == nil {
+		return ""
+	}
+	return networkFS.Spec.SMBCredentialsSecretRef.Name
+}
+
+func (b *Backend) sambaContainer(networkFS *networkfsv1.NetworkFilesystem) corev1.Container {
+	secretName := credentialsSecretName(networkFS)
+	return corev1.Container{
+		Name:    "samba",
+		Image:   b.sambaImage,
+		Command: []string{"/bin/sh", "-c", sambaEntrypoint},
+		Env: []corev1.EnvVar{
+			{
+				Name: "SMB_USERNAME",
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						Key:                  SMBUsernameKey,
+					},
+				},
+			},
+			{
+				Name: "SMB_PASSWORD",
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						Key:                  SMBPasswordKey,
+					},
+				},
+			},
+		},
+		Ports: []corev1.ContainerPort{
+			{
+				Name:          "smb",
+				ContainerPort: smbPort,
+				Protocol:      corev1.ProtocolTCP,
+			},
+		},
+		ReadinessProbe: &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(smbPort)},
+			},
+			PeriodSeconds: 5,
+		},
+		// smbd listens on the privileged SMB port
+		SecurityContext: serverSecurityContext("NET_BIND_SERVICE"),
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      "export",
+				MountPath: exportPath,
+			},
+			{
+				Name:      "config",
+				MountPath: sambaConfigDir,
+			},
+		},
+	}
+}
+
+// renderSambaConfig renders the smb.conf which shares the PVC with the share name <networkFS name>,
+// Samba can not differ the access per client, so the webhook only allows the rules with the same access.
+func renderSambaConfig(networkFS *networkfsv1.NetworkFilesystem) string {
+	readOnly := "no"
+	var hosts []string
+	for _, rule := range networkFS.Spec.AccessRules {
+		if backend.AccessOf(rule) == networkfsv1.AccessReadOnly {
+			readOnly = "yes"
+		}
+		for _, client := range rule.Clients {
+			cidr, err := backend.ClientCIDR(client)
+			if err != nil {
+				logrus.Warnf("Skip the invalid client %s of network filesystem %s", client, networkFS.Name)
+				continue
+			}
+			hosts = append(hosts, cidr)
+		}
+	}
+
+	hostsAllow := ""
+	if len(networkFS.Spec.AccessRules) > 0 {
+		// an empty list still has to deny all, "hosts allow" without any host means allow all
+		hostsAllow = "\thosts deny = ALL\n"
+		if len(hosts) > 0 {
+			hostsAllow = fmt.Sprintf("\thosts allow = %s\n", strings.Join(hosts, " ")) + hostsAllow
+		}
+	}
+
+	return fmt.Sprintf(`[global]
+	server role = standalone server
+	security = user
+	map to guest = never
+	server min protocol = SMB2_10
+	smb ports = %d
+	disable netbios = yes
+	load printers = no
+	printing = bsd
+	printcap name = /dev/null
+
+[%s]
+	path = %s
+	browseable = yes
+	read only = %s
+	guest ok = no
+	force user = root
+	create mask = 0664
+	directory mask = 0775
+%s`, smbPort, shareName(networkFS), exportPath, readOnly, hostsAllow)
+}
@@ -0,0 +1,219 @@
+package longhorn
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	longhornv2 "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	lhclientset "github.com/longhorn/longhorn-manager/k8s/pkg/client/clientset/versioned"
+	ctlv1 "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/backend"
+	ctllonghornv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/generated/controllers/longhorn.io/v1beta2"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+// Backend exports the Longhorn RWX volume through the Longhorn share manager
+type Backend struct {
+	client   kubernetes.Interface
+	lhClient lhclientset.Interface
+
+	// the addresses of the nodes allowed by the network policies, keyed by the node name
+	lock          sync.Mutex
+	nodeAddresses map[string]string
+
+	EndpointCache     ctlv1.EndpointsCache
+	PVCache           ctlv1.PersistentVolumeCache
+	ShareManagerCache ctllonghornv1.ShareManagerCache
+	Nodes             ctlv1.NodeController
+	NodeCache         ctlv1.NodeCache
+}
+
+// New creates the Longhorn share manager export backend
+func New(client kubernetes.Interface, lhClient lhclientset.Interface, endpoints ctlv1.EndpointsController, pvs ctlv1.PersistentVolumeController, sharemanagers ctllonghornv1.ShareManagerController, nodes ctlv1.NodeController) *Backend {
+	return &Backend{
+		client:            client,
+		lhClient:          lhClient,
+		nodeAddresses:     map[string]string{},
+		EndpointCache:     endpoints.Cache(),
+		PVCache:           pvs.Cache(),
+		ShareManagerCache: sharemanagers.Cache(),
+		Nodes:             nodes,
+		NodeCache:         nodes.Cache(),
+	}
+}
+
+var _ backend.ExportBackend = &Backend{}
+var _ backend.ServerPodLocator = &Backend{}
+var _ backend.Watcher = &Backend{}
+
+// the share manager pod is named after the volume by Longhorn
+const (
+	shareManagerPodPrefix = "share-manager-"
+	shareManagerContainer = "share-manager"
+)
+
+func (b *Backend) Enable(networkFS *networkfsv1.NetworkFilesystem) error {
+	// restrict the clients before the share manager starts exporting
+	if err := b.syncNetworkPolicy(networkFS); err != nil {
+		return err
+	}
+	return b.attachLHVolume(networkFS)
+}
+
+func (b *Backend) Disable(networkFS *networkfsv1.NetworkFilesystem) error {
+	if _, err := b.detachLHVolume(networkFS, true); err != nil {
+		return err
+	}
+	return b.deleteNetworkPolicy(networkFS)
+}
+
+// Release releases the owned attachment tickets even while the workloads use the volume, the share manager keeps
+// serving them then, so it is reported as in use rather than waited for.
+func (b *Backend) Release(networkFS *networkfsv1.NetworkFilesystem) (bool, error) {
+	inUse, err := b.detachLHVolume(networkFS, false)
+	if err != nil {
+		return false, err
+	}
+	return inUse, b.deleteNetworkPolicy(networkFS)
+}
+
+// Recover releases the owned attachment tickets, so the share manager is stopped and re-attached by the following Enable,
+// the workloads using the volume do not block it as the failed share manager serves nobody.
+func (b *Backend) Recover(networkFS *networkfsv1.NetworkFilesystem) error {
+	_, err := b.detachLHVolume(networkFS, false)
+	return err
+}
+
+// ServerPod returns the Longhorn share manager pod of the volume
+func (b *Backend) ServerPod(networkFS *networkfsv1.NetworkFilesystem) (string, string, string) {
+	return utils.LHNameSpace, shareManagerPodPrefix + networkFS.Spec.NetworkFSName, shareManagerContainer
+}
+
+func (b *Backend) Observe(networkFS *networkfsv1.NetworkFilesystem) (*backend.ExportStatus, error) {
+	status := &backend.ExportStatus{}
+	// the nodes allowed by the network policy change while the export is running
+	if networkFS.DeletionTimestamp == nil && networkFS.Spec.DesiredState == networkfsv1.NetworkFSStateEnabled {
+		if err := b.syncNetworkPolicy(networkFS); err != nil {
+			return nil, err
+		}
+	}
+
+	sharemanager, err := b.ShareManagerCache.Get(utils.LHNameSpace, networkFS.Spec.NetworkFSName)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get sharemanager %s: %w", networkFS.Spec.NetworkFSName, err)
+	}
+	if apierrors.IsNotFound(err) || sharemanager.Status.State == longhornv2.ShareManagerStateStopped {
+		status.Stopped = true
+		status.Message = "ShareManager is stopped, means the networkfs is disabled"
+		return status, nil
+	}
+	if sharemanager.Status.State == longhornv2.ShareManagerStateError {
+		status.Failed = true
+		status.Message = fmt.Sprintf("ShareManager is in %s state", sharemanager.Status.State)
+		return status, nil
+	}
+	// stopping is a transition of a disable or a reschedule, the export is not ready until it settles
+	if sharemanager.Status.State == longhornv2.ShareManagerStateStopping {
+		status.Message = fmt.Sprintf("ShareManager is in %s state", sharemanager.Status.State)
+		return status, nil
+	}
+
+	endpoint, err := b.EndpointCache.Get(utils.LHNameSpace, networkFS.Spec.NetworkFSName)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			status.Message = "Endpoint is not found"
+			return status, nil
+		}
+		return nil, fmt.Errorf("failed to get endpoint %s: %w", networkFS.Spec.NetworkFSName, err)
+	}
+	if len(endpoint.Subsets) == 0 || len(endpoint.Subsets[0].Addresses) == 0 {
+		status.Message = "Endpoint did not contain the corresponding address"
+		return status, nil
+	}
+
+	// LH RWX volume endpoint should only have one address and one port
+	if len(endpoint.Subsets) > 1 || len(endpoint.Subsets[0].Addresses) > 1 || len(endpoint.Subsets[0].Ports) > 1 {
+		return nil, fmt.Errorf("endpoint %s has more than one subSets", endpoint.Name)
+	}
+	if len(endpoint.Subsets[0].Ports) == 0 || endpoint.Subsets[0].Ports[0].Name != "nfs" {
+		return nil, fmt.Errorf("endpoint %s has no nfs port", endpoint.Name)
+	}
+
+	pv, err := b.PVCache.Get(networkFS.Spec.NetworkFSName)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get persistent volume %s: %w", networkFS.Spec.NetworkFSName, err)
+	}
+	if err == nil && pv.Spec.CSI != nil {
+		status.MountOpts = pv.Spec.CSI.VolumeAttributes["nfsOptions"]
+	}
+
+	status.Ready = true
+	status.Endpoint = endpoint.Subsets[0].Addresses[0].IP
+	status.Node = networkFS.Status.Node
+	if nodeName := endpoint.Subsets[0].Addresses[0].NodeName; nodeName != nil {
+		status.Node = *nodeName
+	}
+	status.ExportPath = "/" + networkFS.Spec.NetworkFSName
+	status.Message = "Endpoint contains the corresponding address"
+	return status, nil
+}
+
+// attachLHVolume adds the owned attachment tickets to the Longhorn volume attachment, so the share manager is started
+// on the chosen node, Longhorn picks the node when none is chosen.
+func (b *Backend) attachLHVolume(networkFS *networkfsv1.NetworkFilesystem) error {
+	logrus.Infof("Attach Longhorn volume %s for network filesystem %s", networkFS.Spec.NetworkFSName, networkFS.Name)
+	lhva, err := b.lhClient.LonghornV1beta2().VolumeAttachments(utils.LHNameSpace).Get(context.Background(), networkFS.Spec.NetworkFSName, metav1.GetOptions{})
+	if err != nil {
+		logrus.Errorf("Failed to get Longhorn volume attachment %s: %v", networkFS.Spec.NetworkFSName, err)
+		return err
+	}
+
+	tickets, err := mergeTickets(withoutLegacyTicket(networkFS, lhva.Spec.AttachmentTickets), desiredTickets(networkFS, networkFS.Status.Node))
+	if err != nil {
+		return fmt.Errorf("failed to attach volume %s: %w", networkFS.Spec.NetworkFSName, err)
+	}
+	return b.updateAttachmentTickets(lhva, tickets)
+}
+
+// detachLHVolume removes the owned attachment tickets from the Longhorn volume attachment, the tickets of the
+// other attachers are kept. It refuses while a workload uses the volume when checkInUse is set, otherwise it returns
+// whether a workload still uses the volume.
+func (b *Backend) detachLHVolume(networkFS *networkfsv1.NetworkFilesystem, checkInUse bool) (bool, error) {
+	logrus.Infof("Detach Longhorn volume %s for network filesystem %s", networkFS.Spec.NetworkFSName, networkFS.Name)
+	lhva, err := b.lhClient.LonghornV1beta2().VolumeAttachments(utils.LHNameSpace).Get(context.Background(), networkFS.Spec.NetworkFSName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			// the volume is gone, there is no ticket to release
+			return false, nil
+		}
+		logrus.Errorf("Failed to get Longhorn volume attachment %s: %v", networkFS.Spec.NetworkFSName, err)
+		return false, err
+	}
+
+	tickets, err := releaseTickets(networkFS, withoutLegacyTicket(networkFS, lhva.Spec.AttachmentTickets), checkInUse)
+	if err != nil {
+		return false, fmt.Errorf("failed to detach volume %s: %w", networkFS.Spec.NetworkFSName, err)
+	}
+	return len(workloadTicketIDs(tickets)) > 0, b.updateAttachmentTickets(lhva, tickets)
+}
+
+func (b *Backend) updateAttachmentTickets(lhva *longhornv2.VolumeAttachment, tickets map[string]*longhornv2.AttachmentTicket) error {
+	if reflect.DeepEqual(lhva.Spec.AttachmentTickets, tickets) {
+		return nil
+	}
+	lhvaCpy := lhva.DeepCopy()
+	lhvaCpy.Spec.AttachmentTickets = tickets
+	if _, err := b.lhClient.LonghornV1beta2().VolumeAttachments(utils.LHNameSpace).Update(context.Background(), lhvaCpy, metav1.UpdateOptions{}); err != nil {
+		logrus.Errorf("Failed to update Longhorn volume attachment %s: %v", lhva.Name, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package longhorn
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/backend"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+const (
+	// labelShareManager is the label of the share manager pod, the value is the volume name
+	labelShareManager = "longhorn.io/share-manager"
+
+	nfsPort = 2049
+
+	lhNodeHandlerName = "harvester-network-filesystem-longhorn-node-handler"
+)
+
+// Watch enqueues the networkFS with the network policy when the addresses of a node change, the policy allows the nodes
+func (b *Backend) Watch(ctx context.Context, enqueue func(namespace, name string)) {
+	b.Nodes.OnChange(ctx, lhNodeHandlerName, func(name string, node *corev1.Node) (*corev1.Node, error) {
+		addresses := ""
+		if node != nil && node.DeletionTimestamp == nil {
+			addresses = strings.Join(nodeCIDRs([]*corev1.Node{node}), ",")
+		}
+		b.lock.Lock()
+		previous, found := b.nodeAddresses[name]
+		if addresses == "" {
+			delete(b.nodeAddresses, name)
+		} else {
+			b.nodeAddresses[name] = addresses
+		}
+		b.lock.Unlock()
+		if (found && previous == addresses) || (!found && addresses == "") {
+			return nil, nil
+		}
+
+		selector := utils.LabelNetworkFSNamespace + "," + utils.LabelNetworkFSName
+		policies, err := b.client.NetworkingV1().NetworkPolicies(utils.LHNameSpace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list network policies: %w", err)
+		}
+		for _, policy := range policies.Items {
+			enqueue(policy.Labels[utils.LabelNetworkFSNamespace], policy.Labels[utils.LabelNetworkFSName])
+		}
+		return nil, nil
+	})
+}
+
+func networkPolicyName(networkFS *networkfsv1.NetworkFilesystem) string {
+	return fmt.Sprintf("netfs-%s", networkFS.Spec.NetworkFSName)
+}
+
+// syncNetworkPolicy restricts the NFS port of the share manager pod to the clients of the access rules.
+// The share manager exports the volume to everyone and it is managed by Longhorn, so only the clients
+// could be enforced here, the access and squash of the rules are rejected by the webhook.
+// The nodes are always allowed, the Longhorn CSI plugin mounts the volume and the manager probes it from the host network.
+func (b *Backend) syncNetworkPolicy(networkFS *networkfsv1.NetworkFilesystem) error {
+	if len(networkFS.Spec.AccessRules) == 0 {
+		return b.deleteNetworkPolicy(networkFS)
+	}
+
+	nodes, err := b.NodeCache.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list nodes: %w", err)
+	}
+	policy := constructNetworkPolicy(networkFS, nodeCIDRs(nodes))
+	policies := b.client.NetworkingV1().NetworkPolicies(utils.LHNameSpace)
+	existing, err := policies.Get(context.TODO(), policy.Name, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get network policy %s: %w", policy.Name, err)
+	}
+	if apierrors.IsNotFound(err) {
+		logrus.Infof("Create network policy %s/%s for network filesystem %s", policy.Namespace, policy.Name, networkFS.Name)
+		if _, err := policies.Create(context.TODO(), policy, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create network policy %s: %w", policy.Name, err)
+		}
+		return nil
+	}
+
+	if reflect.DeepEqual(existing.Spec, policy.Spec) && reflect.DeepEqual(existing.Labels, policy.Labels) {
+		return nil
+	}
+	existingCpy := existing.DeepCopy()
+	existingCpy.Labels = policy.Labels
+	existingCpy.Spec = policy.Spec
+	if _, err := policies.Update(context.TODO(), existingCpy, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update network policy %s: %w", policy.Name, err)
+	}
+	return nil
+}
+
+func (b *Backend) deleteNetworkPolicy(networkFS *networkfsv1.NetworkFilesystem) error {
+	name := networkPolicyName(networkFS)
+	if err := b.client.NetworkingV1().NetworkPolicies(utils.LHNameSpace).Delete(context.TODO(), name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete network policy %s: %w", name, err)
+	}
+	return nil
+}
+
+// nodeCIDRs returns the sorted single address CIDRs of the internal and external addresses of the nodes
+func nodeCIDRs(nodes []*corev1.Node) []string {
+	var cidrs []string
+	for _, node := range nodes {
+		for _, address := range node.Status.Addresses {
+			if address.Type != corev1.NodeInternalIP && address.Type != corev1.NodeExternalIP {
+				continue
+			}
+			if cidr, err := backend.ClientCIDR(address.Address); err == nil && !slices.Contains(cidrs, cidr) {
+				cidrs = append(cidrs, cidr)
+			}
+		}
+	}
+	sort.Strings(cidrs)
+	return cidrs
+}
+
+func constructNetworkPolicy(networkFS *networkfsv1.NetworkFilesystem, nodeCIDRs []string) *networkingv1.NetworkPolicy {
+	var peers []networkingv1.NetworkPolicyPeer
+	for _, rule := range networkFS.Spec.AccessRules {
+		for _, client := range rule.Clients {
+			cidr, err := backend.ClientCIDR(client)
+			if err != nil {
+				logrus.Warnf("Skip the invalid client %s of network filesystem %s", client, networkFS.Name)
+				continue
+			}
+			peers = append(peers, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+		}
+	}
+	for _, cidr := range nodeCIDRs {
+		peers = append(peers, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+	}
+
+	tcp := corev1.ProtocolTCP
+	nfs := intstr.FromInt32(nfsPort)
+	belowNFS, aboveNFS := intstr.FromInt32(1), intstr.FromInt32(nfsPort+1)
+	belowNFSEnd, aboveNFSEnd := int32(nfsPort-1), int32(65535)
+	policy := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      networkPolicyName(networkFS),
+			Namespace: utils.LHNameSpace,
+			Labels: map[string]string{
+				utils.LabelNetworkFSNamespace: networkFS.Namespace,
+				utils.LabelNetworkFSName:      networkFS.Name,
+			},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{labelShareManager: networkFS.Spec.NetworkFSName},
+			},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{
+					// keep the other ports (e.g. the share manager gRPC used by longhorn-manager) open
+					Ports: []networkingv1.NetworkPolicyPort{
+						{Protocol: &tcp, Port: &belowNFS, EndPort: &belowNFSEnd},
+						{Protocol: &tcp, Port: &aboveNFS, EndPort: &aboveNFSEnd},
+					},
+				},
+			},
+		},
+	}
+	// a rule without peers allows everyone, so NFS is denied entirely when neither a client nor a node is valid
+	if len(peers) > 0 {
+		policy.Spec.Ingress = append(policy.Spec.Ingress, networkingv1.NetworkPolicyIngressRule{
+			From:  peers,
+			Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &nfs}},
+		})
+	}
+	return policy
+}
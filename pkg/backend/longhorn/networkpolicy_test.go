@@ -0,0 +1,92 @@
+package longhorn
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+)
+
+func testNode(name string, addresses ...corev1.NodeAddress) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status:     corev1.NodeStatus{Addresses: addresses},
+	}
+}
+
+func TestNodeCIDRs(t *testing.T) {
+	nodes := []*corev1.Node{
+		testNode("node-2",
+			corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "10.0.0.2"},
+			corev1.NodeAddress{Type: corev1.NodeHostName, Address: "node-2"},
+		),
+		testNode("node-1",
+			corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+			corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "fd00::1"},
+			corev1.NodeAddress{Type: corev1.NodeExternalIP, Address: "192.168.1.1"},
+		),
+		// the address shared by the nodes is allowed once
+		testNode("node-3", corev1.NodeAddress{Type: corev1.NodeExternalIP, Address: "192.168.1.1"}),
+	}
+	want := []string{"10.0.0.1/32", "10.0.0.2/32", "192.168.1.1/32", "fd00::1/128"}
+	if got := nodeCIDRs(nodes); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected node CIDRs %v, got %v", want, got)
+	}
+}
+
+func TestConstructNetworkPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		clients   []string
+		nodeCIDRs []string
+		// the CIDRs allowed to the NFS port, nil means NFS is denied entirely
+		wantNFS []string
+	}{
+		{
+			name:      "clients and nodes",
+			clients:   []string{"10.10.0.0/16", "172.16.0.5"},
+			nodeCIDRs: []string{"10.0.0.1/32", "10.0.0.2/32"},
+			wantNFS:   []string{"10.10.0.0/16", "172.16.0.5/32", "10.0.0.1/32", "10.0.0.2/32"},
+		},
+		{
+			name:      "nodes are allowed when no client is valid",
+			clients:   []string{"not-an-address"},
+			nodeCIDRs: []string{"10.0.0.1/32"},
+			wantNFS:   []string{"10.0.0.1/32"},
+		},
+		{
+			name:    "NFS is denied without a client and a node",
+			clients: []string{"not-an-address"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			networkFS := testNetworkFS("node-1")
+			networkFS.Spec.AccessRules = []networkfsv1.AccessRule{{Clients: tt.clients}}
+			policy := constructNetworkPolicy(networkFS, tt.nodeCIDRs)
+
+			var got []string
+			for _, rule := range policy.Spec.Ingress {
+				if len(rule.Ports) != 1 || rule.Ports[0].Port.IntVal != nfsPort {
+					if len(rule.From) != 0 {
+						t.Errorf("the other ports are expected to be open to everyone, got peers %v", rule.From)
+					}
+					continue
+				}
+				if len(rule.From) == 0 {
+					t.Fatalf("the NFS port is open to everyone")
+				}
+				for _, peer := range rule.From {
+					got = append(got, peer.IPBlock.CIDR)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.wantNFS) {
+				t.Errorf("expected the NFS port to be allowed from %v, got %v", tt.wantNFS, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package longhorn
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	longhornv2 "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+)
+
+const (
+	// ticketParameterManagedBy marks the attachment tickets created by the manager, the other tickets are never touched
+	ticketParameterManagedBy = "networkfs.harvesterhci.io/managed-by"
+	ticketManager            = "networkfs-manager"
+	// ticketIDPrefix keeps the IDs of the owned tickets apart from the ones Longhorn and the CSI driver create for the volume
+	ticketIDPrefix = "networkfs-manager-"
+	// legacyTicketPrefix is the prefix of the CSI ticket created by the earlier releases, followed by the networkFS name
+	legacyTicketPrefix = "csi-"
+)
+
+// ticketIDs returns the IDs of the CSI and share manager attachment tickets created for the networkFS
+func ticketIDs(networkFS *networkfsv1.NetworkFilesystem) (string, string) {
+	return fmt.Sprintf("%scsi-%s", ticketIDPrefix, networkFS.Spec.NetworkFSName), fmt.Sprintf("%sshare-manager-%s", ticketIDPrefix, networkFS.Spec.NetworkFSName)
+}
+
+// desiredTickets returns the tickets which keep the share manager of the RWX volume running on the node,
+// Longhorn picks the node when it is empty.
+func desiredTickets(networkFS *networkfsv1.NetworkFilesystem, nodeID string) []*longhornv2.AttachmentTicket {
+	csiTicketID, shareMgrTicketID := ticketIDs(networkFS)
+	ticket := func(id string, attacherType longhornv2.AttacherType) *longhornv2.AttachmentTicket {
+		return &longhornv2.AttachmentTicket{
+			ID:     id,
+			Type:   attacherType,
+			NodeID: nodeID,
+			Parameters: map[string]string{
+				longhornv2.AttachmentParameterDisableFrontend: longhornv2.FalseValue,
+				ticketParameterManagedBy:                      ticketManager,
+			},
+		}
+	}
+	// RWX volume should have two attachment tickets (CSI and share-manager)
+	return []*longhornv2.AttachmentTicket{
+		ticket(csiTicketID, longhornv2.AttacherTypeCSIAttacher),
+		ticket(shareMgrTicketID, longhornv2.AttacherTypeShareManagerController),
+	}
+}
+
+// isOwnedTicket returns whether the ticket is created by the manager, it is only decided by the managed-by parameter
+// so a ticket of another attacher is never taken over.
+func isOwnedTicket(ticket *longhornv2.AttachmentTicket) bool {
+	return ticket != nil && ticket.Parameters[ticketParameterManagedBy] == ticketManager
+}
+
+// isLegacyTicket returns whether the ticket is the CSI ticket created for the networkFS by the earlier releases, it was
+// created without the managed-by parameter and is only recognised by its exact ID, type and parameters
+func isLegacyTicket(networkFS *networkfsv1.NetworkFilesystem, ticket *longhornv2.AttachmentTicket) bool {
+	legacyParameters := map[string]string{longhornv2.AttachmentParameterDisableFrontend: longhornv2.FalseValue}
+	return ticket != nil && ticket.ID == legacyTicketPrefix+networkFS.Name && ticket.Type == longhornv2.AttacherTypeCSIAttacher &&
+		reflect.DeepEqual(ticket.Parameters, legacyParameters)
+}
+
+// withoutLegacyTicket returns the tickets without the legacy CSI ticket of the networkFS, it is adopted by replacing
+// it with the owned one. The share manager ticket of the earlier releases has the ID of the Longhorn one and is kept.
+func withoutLegacyTicket(networkFS *networkfsv1.NetworkFilesystem, tickets map[string]*longhornv2.AttachmentTicket) map[string]*longhornv2.AttachmentTicket {
+	adopted := make(map[string]*longhornv2.AttachmentTicket, len(tickets))
+	for id, ticket := range tickets {
+		if !isLegacyTicket(networkFS, ticket) {
+			adopted[id] = ticket
+		}
+	}
+	return adopted
+}
+
+// mergeTickets returns the tickets with the desired ones added or updated, the tickets of the other attachers are
+// kept as they are. It fails when another attacher which would win over the share manager pins the volume to another node.
+func mergeTickets(tickets map[string]*longhornv2.AttachmentTicket, desired []*longhornv2.AttachmentTicket) (map[string]*longhornv2.AttachmentTicket, error) {
+	merged := make(map[string]*longhornv2.AttachmentTicket, len(tickets)+len(desired))
+	for id, ticket := range tickets {
+		merged[id] = ticket.DeepCopy()
+	}
+
+	for _, want := range desired {
+		if conflict := conflictingTicket(tickets, want); conflict != nil {
+			return nil, fmt.Errorf("attachment ticket %s of %s requests node %s, it conflicts with ticket %s on node %s", conflict.ID, conflict.Type, conflict.NodeID, want.ID, want.NodeID)
+		}
+
+		cur, found := merged[want.ID]
+		if !found {
+			merged[want.ID] = want.DeepCopy()
+			continue
+		}
+		if !isOwnedTicket(cur) {
+			return nil, fmt.Errorf("attachment ticket %s of %s is not created by %s", cur.ID, cur.Type, ticketManager)
+		}
+		// keep the other parameters and the generation of the existing ticket
+		cur.Type = want.Type
+		cur.NodeID = want.NodeID
+		if cur.Parameters == nil {
+			cur.Parameters = map[string]string{}
+		}
+		for key, value := range want.Parameters {
+			cur.Parameters[key] = value
+		}
+	}
+	return merged, nil
+}
+
+// conflictingTicket returns the ticket of the other attachers which pins the volume to another node with a priority
+// not lower than the wanted ticket. The CSI tickets of the workloads are not conflicts, the RWX volume is shared through
+// the share manager, and neither is the ticket of the Longhorn share manager controller, it follows the share manager.
+func conflictingTicket(tickets map[string]*longhornv2.AttachmentTicket, want *longhornv2.AttachmentTicket) *longhornv2.AttachmentTicket {
+	if want.NodeID == "" {
+		return nil
+	}
+	for _, id := range sortedTicketIDs(tickets) {
+		ticket := tickets[id]
+		if isOwnedTicket(ticket) || ticket.Type == longhornv2.AttacherTypeCSIAttacher || ticket.Type == longhornv2.AttacherTypeShareManagerController {
+			continue
+		}
+		if ticket.NodeID == "" || ticket.NodeID == want.NodeID {
+			continue
+		}
+		if longhornv2.GetAttacherPriorityLevel(ticket.Type) >= longhornv2.GetAttacherPriorityLevel(want.Type) {
+			return ticket
+		}
+	}
+	return nil
+}
+
+// releaseTickets returns the tickets without the owned ones. When checkInUse is set, it refuses to release them while
+// a workload still uses the volume, the share manager keeps serving it anyway.
+func releaseTickets(networkFS *networkfsv1.NetworkFilesystem, tickets map[string]*longhornv2.AttachmentTicket, checkInUse bool) (map[string]*longhornv2.AttachmentTicket, error) {
+	if checkInUse {
+		if users := workloadTicketIDs(tickets); len(users) > 0 {
+			return nil, fmt.Errorf("volume %s is still in use by the workload attachment tickets %s", networkFS.Spec.NetworkFSName, strings.Join(users, ", "))
+		}
+	}
+
+	released := make(map[string]*longhornv2.AttachmentTicket, len(tickets))
+	for id, ticket := range tickets {
+		if !isOwnedTicket(ticket) {
+			released[id] = ticket.DeepCopy()
+		}
+	}
+	return released, nil
+}
+
+// workloadTicketIDs returns the CSI tickets of the other attachers, they are created for the pods using the volume
+func workloadTicketIDs(tickets map[string]*longhornv2.AttachmentTicket) []string {
+	var ids []string
+	for _, id := range sortedTicketIDs(tickets) {
+		if ticket := tickets[id]; ticket.Type == longhornv2.AttacherTypeCSIAttacher && !isOwnedTicket(ticket) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// sortedTicketIDs returns the ticket IDs in order, so the reported ticket is stable
+func sortedTicketIDs(tickets map[string]*longhornv2.AttachmentTicket) []string {
+	ids := make([]string, 0, len(tickets))
+	for id, ticket := range tickets {
+		if ticket != nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,294 @@
+package longhorn
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	longhornv2 "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	lhfake "github.com/longhorn/longhorn-manager/k8s/pkg/client/clientset/versioned/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+const testVolume = "pvc-1234"
+
+func testNetworkFS(node string) *networkfsv1.NetworkFilesystem {
+	return &networkfsv1.NetworkFilesystem{
+		ObjectMeta: metav1.ObjectMeta{Name: testVolume, Namespace: "harvester-system"},
+		Spec:       networkfsv1.NetworkFSSpec{NetworkFSName: testVolume},
+		Status:     networkfsv1.NetworkFSStatus{Node: node},
+	}
+}
+
+func testTicket(id string, attacherType longhornv2.AttacherType, node string, owned bool) *longhornv2.AttachmentTicket {
+	ticket := &longhornv2.AttachmentTicket{
+		ID:         id,
+		Type:       attacherType,
+		NodeID:     node,
+		Parameters: map[string]string{longhornv2.AttachmentParameterDisableFrontend: longhornv2.FalseValue},
+	}
+	if owned {
+		ticket.Parameters[ticketParameterManagedBy] = ticketManager
+	}
+	return ticket
+}
+
+func testVolumeAttachment(tickets ...*longhornv2.AttachmentTicket) *longhornv2.VolumeAttachment {
+	lhva := &longhornv2.VolumeAttachment{
+		ObjectMeta: metav1.ObjectMeta{Name: testVolume, Namespace: utils.LHNameSpace},
+		Spec: longhornv2.VolumeAttachmentSpec{
+			AttachmentTickets: map[string]*longhornv2.AttachmentTicket{},
+			Volume:            testVolume,
+		},
+	}
+	for _, ticket := range tickets {
+		lhva.Spec.AttachmentTickets[ticket.ID] = ticket
+	}
+	return lhva
+}
+
+// the IDs Longhorn gives to its own tickets of the volume
+var (
+	lhShareManagerTicketID = longhornv2.GetAttachmentTicketID(longhornv2.AttacherTypeShareManagerController, testVolume)
+	ownedCSITicketID       = ticketIDPrefix + "csi-" + testVolume
+	ownedShareMgrTicketID  = ticketIDPrefix + "share-manager-" + testVolume
+	// the CSI ticket created by the earlier releases without the managed-by parameter
+	legacyCSITicketID = legacyTicketPrefix + testVolume
+)
+
+func TestAttachLHVolume(t *testing.T) {
+	tests := []struct {
+		name    string
+		node    string
+		tickets []*longhornv2.AttachmentTicket
+		wantErr string
+		// the IDs of the tickets expected after the attach, the owned ones are checked separately
+		wantOthers map[string]*longhornv2.AttachmentTicket
+	}{
+		{
+			name: "adds the owned tickets to the empty attachment",
+			node: "node-1",
+		},
+		{
+			name: "keeps the tickets of the other attachers",
+			node: "node-1",
+			tickets: []*longhornv2.AttachmentTicket{
+				testTicket("csi-workload", longhornv2.AttacherTypeCSIAttacher, "node-2", false),
+				testTicket(lhShareManagerTicketID, longhornv2.AttacherTypeShareManagerController, "node-2", false),
+				testTicket("backup-1", longhornv2.AttacherTypeBackupController, "node-2", false),
+			},
+			wantOthers: map[string]*longhornv2.AttachmentTicket{
+				"csi-workload":         testTicket("csi-workload", longhornv2.AttacherTypeCSIAttacher, "node-2", false),
+				lhShareManagerTicketID: testTicket(lhShareManagerTicketID, longhornv2.AttacherTypeShareManagerController, "node-2", false),
+				"backup-1":             testTicket("backup-1", longhornv2.AttacherTypeBackupController, "node-2", false),
+			},
+		},
+		{
+			name: "moves the owned tickets to the chosen node",
+			node: "node-2",
+			tickets: []*longhornv2.AttachmentTicket{
+				testTicket(ownedCSITicketID, longhornv2.AttacherTypeCSIAttacher, "node-1", true),
+				testTicket(ownedShareMgrTicketID, longhornv2.AttacherTypeShareManagerController, "node-1", true),
+			},
+		},
+		{
+			name: "lets Longhorn pick the node when none is chosen",
+			tickets: []*longhornv2.AttachmentTicket{
+				testTicket("restore-1", longhornv2.AttacherTypeVolumeRestoreController, "node-2", false),
+			},
+			wantOthers: map[string]*longhornv2.AttachmentTicket{
+				"restore-1": testTicket("restore-1", longhornv2.AttacherTypeVolumeRestoreController, "node-2", false),
+			},
+		},
+		{
+			name: "refuses the node when a higher priority attacher pins another one",
+			node: "node-1",
+			tickets: []*longhornv2.AttachmentTicket{
+				testTicket("restore-1", longhornv2.AttacherTypeVolumeRestoreController, "node-2", false),
+			},
+			wantErr: "conflicts with ticket",
+		},
+		{
+			name: "refuses the node when an attacher of the same priority pins another one",
+			node: "node-1",
+			tickets: []*longhornv2.AttachmentTicket{
+				testTicket("salvage-1", longhornv2.AttacherTypeSalvageController, "node-2", false),
+			},
+			wantErr: "conflicts with ticket",
+		},
+		{
+			name: "adopts the legacy CSI ticket",
+			node: "node-2",
+			tickets: []*longhornv2.AttachmentTicket{
+				testTicket(legacyCSITicketID, longhornv2.AttacherTypeCSIAttacher, "node-1", false),
+				testTicket(lhShareManagerTicketID, longhornv2.AttacherTypeShareManagerController, "node-1", false),
+			},
+			wantOthers: map[string]*longhornv2.AttachmentTicket{
+				lhShareManagerTicketID: testTicket(lhShareManagerTicketID, longhornv2.AttacherTypeShareManagerController, "node-1", false),
+			},
+		},
+		{
+			name: "keeps the ticket with the legacy ID and other parameters",
+			node: "node-1",
+			tickets: []*longhornv2.AttachmentTicket{
+				{ID: legacyCSITicketID, Type: longhornv2.AttacherTypeCSIAttacher, NodeID: "node-2", Parameters: map[string]string{"fsType": "ext4"}},
+			},
+			wantOthers: map[string]*longhornv2.AttachmentTicket{
+				legacyCSITicketID: {ID: legacyCSITicketID, Type: longhornv2.AttacherTypeCSIAttacher, NodeID: "node-2", Parameters: map[string]string{"fsType": "ext4"}},
+			},
+		},
+		{
+			name: "refuses to take over the ticket with an owned ID which is not labelled",
+			node: "node-1",
+			tickets: []*longhornv2.AttachmentTicket{
+				testTicket(ownedCSITicketID, longhornv2.AttacherTypeCSIAttacher, "node-1", false),
+			},
+			wantErr: "is not created by",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lhClient := lhfake.NewSimpleClientset(testVolumeAttachment(tt.tickets...))
+			b := &Backend{lhClient: lhClient}
+
+			err := b.attachLHVolume(testNetworkFS(tt.node))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			lhva, err := lhClient.LonghornV1beta2().VolumeAttachments(utils.LHNameSpace).Get(context.TODO(), testVolume, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get volume attachment: %v", err)
+			}
+			others := map[string]*longhornv2.AttachmentTicket{}
+			owned := 0
+			for id, ticket := range lhva.Spec.AttachmentTickets {
+				if !isOwnedTicket(ticket) {
+					others[id] = ticket
+					continue
+				}
+				owned++
+				if !strings.HasPrefix(id, ticketIDPrefix) {
+					t.Errorf("owned ticket %s does not have the prefix %s", id, ticketIDPrefix)
+				}
+				if ticket.NodeID != tt.node {
+					t.Errorf("owned ticket %s is on node %q, expected %q", id, ticket.NodeID, tt.node)
+				}
+			}
+			if owned != 2 {
+				t.Errorf("expected 2 owned tickets, got %d", owned)
+			}
+			if len(tt.wantOthers) == 0 {
+				tt.wantOthers = map[string]*longhornv2.AttachmentTicket{}
+			}
+			if !reflect.DeepEqual(others, tt.wantOthers) {
+				t.Errorf("tickets of the other attachers are changed, got %v, expected %v", others, tt.wantOthers)
+			}
+		})
+	}
+}
+
+func TestDetachLHVolume(t *testing.T) {
+	tests := []struct {
+		name       string
+		checkInUse bool
+		tickets    []*longhornv2.AttachmentTicket
+		wantErr    string
+		wantIDs    []string
+		wantInUse  bool
+	}{
+		{
+			name: "removes only the owned tickets",
+			tickets: []*longhornv2.AttachmentTicket{
+				testTicket(ownedCSITicketID, longhornv2.AttacherTypeCSIAttacher, "node-1", true),
+				testTicket(ownedShareMgrTicketID, longhornv2.AttacherTypeShareManagerController, "node-1", true),
+				testTicket(lhShareManagerTicketID, longhornv2.AttacherTypeShareManagerController, "node-1", false),
+				testTicket("backup-1", longhornv2.AttacherTypeBackupController, "node-1", false),
+			},
+			wantIDs: []string{"backup-1", lhShareManagerTicketID},
+		},
+		{
+			name:       "refuses while a workload uses the volume",
+			checkInUse: true,
+			tickets: []*longhornv2.AttachmentTicket{
+				testTicket(ownedCSITicketID, longhornv2.AttacherTypeCSIAttacher, "node-1", true),
+				testTicket("csi-workload", longhornv2.AttacherTypeCSIAttacher, "node-2", false),
+			},
+			wantErr: "still in use",
+		},
+		{
+			name: "releases the owned tickets of the volume in use when asked to",
+			tickets: []*longhornv2.AttachmentTicket{
+				testTicket(ownedCSITicketID, longhornv2.AttacherTypeCSIAttacher, "node-1", true),
+				testTicket("csi-workload", longhornv2.AttacherTypeCSIAttacher, "node-2", false),
+			},
+			wantIDs:   []string{"csi-workload"},
+			wantInUse: true,
+		},
+		{
+			name:       "releases the legacy CSI ticket which is not a workload",
+			checkInUse: true,
+			tickets: []*longhornv2.AttachmentTicket{
+				testTicket(legacyCSITicketID, longhornv2.AttacherTypeCSIAttacher, "node-1", false),
+				testTicket(ownedShareMgrTicketID, longhornv2.AttacherTypeShareManagerController, "node-1", true),
+				testTicket(lhShareManagerTicketID, longhornv2.AttacherTypeShareManagerController, "node-1", false),
+			},
+			wantIDs: []string{lhShareManagerTicketID},
+		},
+		{
+			name:       "never touches the Longhorn share manager ticket",
+			checkInUse: true,
+			tickets: []*longhornv2.AttachmentTicket{
+				testTicket(lhShareManagerTicketID, longhornv2.AttacherTypeShareManagerController, "node-1", false),
+			},
+			wantIDs: []string{lhShareManagerTicketID},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lhClient := lhfake.NewSimpleClientset(testVolumeAttachment(tt.tickets...))
+			b := &Backend{lhClient: lhClient}
+
+			inUse, err := b.detachLHVolume(testNetworkFS("node-1"), tt.checkInUse)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			lhva, err := lhClient.LonghornV1beta2().VolumeAttachments(utils.LHNameSpace).Get(context.TODO(), testVolume, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get volume attachment: %v", err)
+			}
+			if ids := sortedTicketIDs(lhva.Spec.AttachmentTickets); !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("expected tickets %v, got %v", tt.wantIDs, ids)
+			}
+			if inUse != tt.wantInUse {
+				t.Errorf("expected in use %v, got %v", tt.wantInUse, inUse)
+			}
+		})
+	}
+}
+
+func TestDetachLHVolumeWithoutAttachment(t *testing.T) {
+	b := &Backend{lhClient: lhfake.NewSimpleClientset()}
+	if _, err := b.detachLHVolume(testNetworkFS("node-1"), true); err != nil {
+		t.Fatalf("expected the missing volume attachment to be ignored, got %v", err)
+	}
+}
@@ -0,0 +1,374 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rancher/wrangler/v3/pkg/kubeconfig"
+	"github.com/urfave/cli/v2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/yaml"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/generated/clientset/versioned"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+const (
+	// waitForReady waits until the endpoint is ready, the other values of --for are the states of the networkFS
+	waitForReady = "Ready"
+
+	waitPollInterval = 2 * time.Second
+)
+
+// Commands returns the subcommands which operate on the NetworkFilesystem, they share the kubeconfig and namespace flags of the manager
+func Commands(opt *utils.Option) []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:      "list",
+			Usage:     "List the network filesystems",
+			ArgsUsage: " ",
+			Action: func(c *cli.Context) error {
+				return list(c.Context, opt, c.App.Writer)
+			},
+		},
+		{
+			Name:      "get",
+			Usage:     "Print the network filesystem",
+			ArgsUsage: "NAME",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "output",
+					Aliases: []string{"o"},
+					Value:   "yaml",
+					Usage:   "Output format, options are \"yaml\" or \"json\"",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				name, err := nameArg(c)
+				if err != nil {
+					return err
+				}
+				return get(c.Context, opt, name, c.String("output"), c.App.Writer)
+			},
+		},
+		{
+			Name:      "enable",
+			Usage:     "Enable the endpoint of the network filesystem",
+			ArgsUsage: "NAME",
+			Action: func(c *cli.Context) error {
+				name, err := nameArg(c)
+				if err != nil {
+					return err
+				}
+				return setDesiredState(c.Context, opt, name, networkfsv1.NetworkFSStateEnabled, c.App.Writer)
+			},
+		},
+		{
+			Name:      "disable",
+			Usage:     "Disable the endpoint of the network filesystem",
+			ArgsUsage: "NAME",
+			Action: func(c *cli.Context) error {
+				name, err := nameArg(c)
+				if err != nil {
+					return err
+				}
+				return setDesiredState(c.Context, opt, name, networkfsv1.NetworkFSStateDisabled, c.App.Writer)
+			},
+		},
+		{
+			Name:      "wait",
+			Usage:     "Wait until the network filesystem reaches the state",
+			ArgsUsage: "NAME",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "for",
+					Value: string(networkfsv1.NetworkFSStateEnabled),
+					Usage: "State to wait for, options are \"Enabled\", \"Disabled\" or \"Ready\" (the endpoint is ready)",
+				},
+				&cli.DurationFlag{
+					Name:  "timeout",
+					Value: 5 * time.Minute,
+					Usage: "Time to wait before giving up",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				name, err := nameArg(c)
+				if err != nil {
+					return err
+				}
+				return waitFor(c.Context, opt, name, c.String("for"), c.Duration("timeout"), c.App.Writer)
+			},
+		},
+		{
+			Name:      "migrate",
+			Usage:     "Move the export of the network filesystem to the node",
+			ArgsUsage: "NAME",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "node",
+					Required: true,
+					Usage:    "Node to which the export is migrated",
+				},
+				&cli.BoolFlag{
+					Name:  "wait",
+					Usage: "Wait until the export is served from the node",
+				},
+				&cli.DurationFlag{
+					Name:  "timeout",
+					Value: 5 * time.Minute,
+					Usage: "Time to wait before giving up",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				name, err := nameArg(c)
+				if err != nil {
+					return err
+				}
+				return migrate(c.Context, opt, name, c.String("node"), c.Bool("wait"), c.Duration("timeout"), c.App.Writer)
+			},
+		},
+		{
+			Name:      "mount-command",
+			Usage:     "Print the command which mounts the ready network filesystem",
+			ArgsUsage: "NAME",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "mount-point",
+					Usage: "Mount point of the network filesystem, default to /mnt/NAME",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				name, err := nameArg(c)
+				if err != nil {
+					return err
+				}
+				return mountCommand(c.Context, opt, name, c.String("mount-point"), c.App.Writer)
+			},
+		},
+	}
+}
+
+func nameArg(c *cli.Context) (string, error) {
+	if c.NArg() != 1 {
+		return "", fmt.Errorf("%s requires exactly one network filesystem name", c.Command.Name)
+	}
+	return c.Args().First(), nil
+}
+
+// newClient is replaced by the tests with a fake clientset
+var newClient = func(opt *utils.Option) (versioned.Interface, error) {
+	config, err := kubeconfig.GetNonInteractiveClientConfig(opt.KubeConfig).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find kubeconfig: %v", err)
+	}
+	return versioned.NewForConfig(config)
+}
+
+func list(ctx context.Context, opt *utils.Option, out io.Writer) error {
+	client, err := newClient(opt)
+	if err != nil {
+		return err
+	}
+	networkFSes, err := client.HarvesterhciV1beta1().NetworkFilesystems(opt.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list network filesystems: %w", err)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tDESIRED\tSTATE\tSTATUS\tTYPE\tENDPOINT")
+	for _, networkFS := range networkFSes.Items {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", networkFS.Name, networkFS.Spec.DesiredState, networkFS.Status.State,
+			networkFS.Status.Status, networkFS.Status.Type, networkFS.Status.Endpoint)
+	}
+	return w.Flush()
+}
+
+func get(ctx context.Context, opt *utils.Option, name, output string, out io.Writer) error {
+	client, err := newClient(opt)
+	if err != nil {
+		return err
+	}
+	networkFS, err := client.HarvesterhciV1beta1().NetworkFilesystems(opt.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get network filesystem %s: %w", name, err)
+	}
+	// the type meta is dropped by the client
+	networkFS.APIVersion = networkfsv1.SchemeGroupVersion.String()
+	networkFS.Kind = "NetworkFilesystem"
+
+	var data []byte
+	switch output {
+	case "yaml":
+		data, err = yaml.Marshal(networkFS)
+	case "json":
+		data, err = json.MarshalIndent(networkFS, "", "  ")
+		data = append(data, '\n')
+	default:
+		return fmt.Errorf("invalid output %q, only \"yaml\" and \"json\" are allowed", output)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(data)
+	return err
+}
+
+func setDesiredState(ctx context.Context, opt *utils.Option, name string, state networkfsv1.NetworkFSState, out io.Writer) error {
+	client, err := newClient(opt)
+	if err != nil {
+		return err
+	}
+	patch := fmt.Sprintf(`{"spec":{"desiredState":%q}}`, state)
+	if _, err := client.HarvesterhciV1beta1().NetworkFilesystems(opt.Namespace).Patch(ctx, name, types.MergePatchType, []byte(patch), metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("failed to set desiredState of network filesystem %s to %s: %w", name, state, err)
+	}
+	fmt.Fprintf(out, "networkfilesystem %s/%s desiredState is %s\n", opt.Namespace, name, state)
+	return nil
+}
+
+func waitFor(ctx context.Context, opt *utils.Option, name, target string, timeout time.Duration, out io.Writer) error {
+	switch target {
+	case string(networkfsv1.NetworkFSStateEnabled), string(networkfsv1.NetworkFSStateDisabled), waitForReady:
+	default:
+		return fmt.Errorf("invalid --for %q, only %q, %q and %q are allowed", target, networkfsv1.NetworkFSStateEnabled, networkfsv1.NetworkFSStateDisabled, waitForReady)
+	}
+	client, err := newClient(opt)
+	if err != nil {
+		return err
+	}
+
+	var last *networkfsv1.NetworkFilesystem
+	err = wait.PollUntilContextTimeout(ctx, waitPollInterval, timeout, true, func(ctx context.Context) (bool, error) {
+		networkFS, err := client.HarvesterhciV1beta1().NetworkFilesystems(opt.Namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			// the network filesystem may not be created yet
+			if apierrors.IsNotFound(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		last = networkFS
+		return reached(networkFS, target), nil
+	})
+	if err != nil && last != nil {
+		return fmt.Errorf("network filesystem %s did not reach %s, state: %s, status: %s: %w", name, target, last.Status.State, last.Status.Status, err)
+	}
+	if err != nil {
+		return fmt.Errorf("network filesystem %s did not reach %s: %w", name, target, err)
+	}
+	fmt.Fprintf(out, "networkfilesystem %s/%s is %s\n", opt.Namespace, name, target)
+	return nil
+}
+
+// reached returns true if the status of the latest spec reaches the target
+func reached(networkFS *networkfsv1.NetworkFilesystem, target string) bool {
+	if networkFS.Status.ObservedGeneration != networkFS.Generation {
+		return false
+	}
+	if target == waitForReady {
+		return networkFS.Status.State == networkfsv1.NetworkFSStateEnabled && networkFS.Status.Status == networkfsv1.EndpointStatusReady
+	}
+	return string(networkFS.Status.State) == target
+}
+
+func migrate(ctx context.Context, opt *utils.Option, name, node string, waitMigrated bool, timeout time.Duration, out io.Writer) error {
+	client, err := newClient(opt)
+	if err != nil {
+		return err
+	}
+	patch := fmt.Sprintf(`{"spec":{"targetNode":%q}}`, node)
+	networkFS, err := client.HarvesterhciV1beta1().NetworkFilesystems(opt.Namespace).Patch(ctx, name, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to set targetNode of network filesystem %s to %s: %w", name, node, err)
+	}
+	fmt.Fprintf(out, "networkfilesystem %s/%s targetNode is %s\n", opt.Namespace, name, node)
+	if !waitMigrated {
+		return nil
+	}
+	if networkFS.Spec.DesiredState != networkfsv1.NetworkFSStateEnabled {
+		return fmt.Errorf("network filesystem %s is not enabled, it is served from node %s once it is enabled", name, node)
+	}
+
+	last := networkFS
+	err = wait.PollUntilContextTimeout(ctx, waitPollInterval, timeout, true, func(ctx context.Context) (bool, error) {
+		networkFS, err := client.HarvesterhciV1beta1().NetworkFilesystems(opt.Namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		last = networkFS
+		return migrated(networkFS, node)
+	})
+	if err != nil {
+		if migration := last.Status.Migration; migration != nil && migration.TargetNode == node {
+			return fmt.Errorf("network filesystem %s was not migrated to node %s, phase: %s, %s: %w", name, node, migration.Phase, migration.Message, err)
+		}
+		return fmt.Errorf("network filesystem %s was not migrated to node %s, it is served from node %q: %w", name, node, last.Status.Node, err)
+	}
+
+	migration := last.Status.Migration
+	if migration == nil || migration.TargetNode != node || migration.Phase != networkfsv1.MigrationPhaseCompleted || migration.StartTime == nil {
+		_, err = fmt.Fprintf(out, "networkfilesystem %s/%s is served from node %s\n", opt.Namespace, name, node)
+		return err
+	}
+	_, err = fmt.Fprintf(out, "networkfilesystem %s/%s is migrated to node %s, detached in %s, endpoint ready in %s, unavailable for %s\n", opt.Namespace, name, node,
+		since(migration.StartTime, migration.DetachedTime), since(migration.StartTime, migration.EndpointReadyTime), since(migration.StartTime, migration.CompletionTime))
+	return err
+}
+
+// migrated returns true if the ready export is served from the node, it fails when the migration to the node failed
+func migrated(networkFS *networkfsv1.NetworkFilesystem, node string) (bool, error) {
+	if migration := networkFS.Status.Migration; migration != nil && migration.TargetNode == node && migration.Phase == networkfsv1.MigrationPhaseFailed {
+		return false, fmt.Errorf("migration failed: %s", migration.Message)
+	}
+	return networkFS.Status.Node == node && reached(networkFS, waitForReady), nil
+}
+
+// since returns the duration between the times, it is unknown when either is missing
+func since(from, to *metav1.Time) string {
+	if from == nil || to == nil {
+		return "unknown"
+	}
+	return to.Sub(from.Time).Round(time.Millisecond).String()
+}
+
+func mountCommand(ctx context.Context, opt *utils.Option, name, mountPoint string, out io.Writer) error {
+	client, err := newClient(opt)
+	if err != nil {
+		return err
+	}
+	networkFS, err := client.HarvesterhciV1beta1().NetworkFilesystems(opt.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get network filesystem %s: %w", name, err)
+	}
+	if networkFS.Status.Status != networkfsv1.EndpointStatusReady || networkFS.Status.Endpoint == "" {
+		return fmt.Errorf("network filesystem %s is not ready, state: %s, status: %s", name, networkFS.Status.State, networkFS.Status.Status)
+	}
+	if mountPoint == "" {
+		mountPoint = "/mnt/" + name
+	}
+
+	if networkFS.Status.Type == networkfsv1.NetworkFSTypeSMB {
+		// the UNC path \\server\share is //server/share for mount.cifs, the credentials are given by the user
+		share := strings.ReplaceAll(networkFS.Status.UNCPath, `\`, "/")
+		options := strings.Trim(networkFS.Status.MountOpts+",credentials=/path/to/credentials", ",")
+		_, err = fmt.Fprintf(out, "mount -t cifs -o %s %s %s\n", options, share, mountPoint)
+		return err
+	}
+
+	source := utils.NFSSource(networkFS.Status.Endpoint, utils.NFSExportPath(networkFS))
+	if networkFS.Status.MountOpts == "" {
+		_, err = fmt.Fprintf(out, "mount -t nfs %s %s\n", source, mountPoint)
+		return err
+	}
+	_, err = fmt.Fprintf(out, "mount -t nfs -o %s %s %s\n", networkFS.Status.MountOpts, source, mountPoint)
+	return err
+}
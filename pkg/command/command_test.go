@@ -0,0 +1,157 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/generated/clientset/versioned"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/generated/clientset/versioned/fake"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+const testNamespace = "harvester-system"
+
+func testNetworkFS(name, fsType string, state networkfsv1.NetworkFSState, status networkfsv1.EndpointStatus) *networkfsv1.NetworkFilesystem {
+	return &networkfsv1.NetworkFilesystem{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace, Generation: 1},
+		Spec: networkfsv1.NetworkFSSpec{
+			NetworkFSName: name,
+			DesiredState:  state,
+		},
+		Status: networkfsv1.NetworkFSStatus{
+			ObservedGeneration: 1,
+			State:              state,
+			Status:             status,
+			Type:               fsType,
+			Endpoint:           "10.53.0.10",
+			MountOpts:          "vers=4.1",
+		},
+	}
+}
+
+func TestCommands(t *testing.T) {
+	smb := testNetworkFS("pvc-smb", networkfsv1.NetworkFSTypeSMB, networkfsv1.NetworkFSStateEnabled, networkfsv1.EndpointStatusReady)
+	smb.Status.UNCPath = `\\10.53.0.10\pvc-smb`
+	smb.Status.MountOpts = "vers=3.0"
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "list",
+			args: []string{"list"},
+			want: "pvc-1234       Enabled    Enabled    Ready      NFS    10.53.0.10\n",
+		},
+		{
+			name: "get",
+			args: []string{"get", "-o", "json", "pvc-1234"},
+			want: `"kind": "NetworkFilesystem"`,
+		},
+		{
+			name:    "get with an invalid output",
+			args:    []string{"get", "-o", "xml", "pvc-1234"},
+			wantErr: `invalid output "xml"`,
+		},
+		{
+			name: "enable",
+			args: []string{"enable", "pvc-1234"},
+			want: "networkfilesystem harvester-system/pvc-1234 desiredState is Enabled\n",
+		},
+		{
+			name: "disable",
+			args: []string{"disable", "pvc-1234"},
+			want: "networkfilesystem harvester-system/pvc-1234 desiredState is Disabled\n",
+		},
+		{
+			name:    "disable without a name",
+			args:    []string{"disable"},
+			wantErr: "disable requires exactly one network filesystem name",
+		},
+		{
+			name: "wait for the ready endpoint",
+			args: []string{"wait", "--for", "Ready", "pvc-1234"},
+			want: "networkfilesystem harvester-system/pvc-1234 is Ready\n",
+		},
+		{
+			name:    "wait for an invalid state",
+			args:    []string{"wait", "--for", "Running", "pvc-1234"},
+			wantErr: `invalid --for "Running"`,
+		},
+		{
+			name: "mount command of NFS",
+			args: []string{"mount-command", "pvc-1234"},
+			want: "mount -t nfs -o vers=4.1 10.53.0.10:/pvc-1234 /mnt/pvc-1234\n",
+		},
+		{
+			name: "mount command of SMB",
+			args: []string{"mount-command", "--mount-point", "/data", "pvc-smb"},
+			want: "mount -t cifs -o vers=3.0,credentials=/path/to/credentials //10.53.0.10/pvc-smb /data\n",
+		},
+		{
+			name:    "mount command of the not ready network filesystem",
+			args:    []string{"mount-command", "pvc-disabled"},
+			wantErr: "network filesystem pvc-disabled is not ready",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(
+				testNetworkFS("pvc-1234", networkfsv1.NetworkFSTypeNFS, networkfsv1.NetworkFSStateEnabled, networkfsv1.EndpointStatusReady),
+				testNetworkFS("pvc-disabled", networkfsv1.NetworkFSTypeNFS, networkfsv1.NetworkFSStateDisabled, networkfsv1.EndpointStatusNotReady),
+				smb,
+			)
+			newClient = func(*utils.Option) (versioned.Interface, error) { return client, nil }
+
+			var out bytes.Buffer
+			app := &cli.App{
+				Name:     "networkfs-manager",
+				Writer:   &out,
+				Commands: Commands(&utils.Option{Namespace: testNamespace}),
+			}
+			err := app.RunContext(context.Background(), append([]string{app.Name}, tt.args...))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.Contains(out.String(), tt.want) {
+				t.Errorf("expected output containing %q, got %q", tt.want, out.String())
+			}
+		})
+	}
+}
+
+func TestSetDesiredState(t *testing.T) {
+	client := fake.NewSimpleClientset(testNetworkFS("pvc-1234", networkfsv1.NetworkFSTypeNFS, networkfsv1.NetworkFSStateEnabled, networkfsv1.EndpointStatusReady))
+	newClient = func(*utils.Option) (versioned.Interface, error) { return client, nil }
+
+	opt := &utils.Option{Namespace: testNamespace}
+	var out bytes.Buffer
+	if err := setDesiredState(context.Background(), opt, "pvc-1234", networkfsv1.NetworkFSStateDisabled, &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	networkFS, err := client.HarvesterhciV1beta1().NetworkFilesystems(testNamespace).Get(context.Background(), "pvc-1234", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get the network filesystem: %v", err)
+	}
+	if networkFS.Spec.DesiredState != networkfsv1.NetworkFSStateDisabled {
+		t.Errorf("expected desiredState %s, got %s", networkfsv1.NetworkFSStateDisabled, networkFS.Spec.DesiredState)
+	}
+	if err := setDesiredState(context.Background(), opt, "pvc-missing", networkfsv1.NetworkFSStateEnabled, &out); err == nil {
+		t.Errorf("expected an error of the missing network filesystem")
+	}
+}
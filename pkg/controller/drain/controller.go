@@ -0,0 +1,260 @@
+package drain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	ctlcorev1 "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/record"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/backend"
+	ctlntefsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/generated/controllers/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/metrics"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/placement"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+// the interval of checking the draining node again while its exports are not migrated off yet
+const drainRetryInterval = 30 * time.Second
+
+const (
+	drainNodeHandlerName = "harvester-network-filesystem-drain-node-handler"
+
+	// networkFSByDrainedNodeIndex indexes the networkFS with the draining node it is migrated off
+	networkFSByDrainedNodeIndex = "networkfs.harvesterhci.io/drained-node"
+)
+
+// drainMigration is the value of the drain migration annotation of the networkFS
+type drainMigration struct {
+	// the draining node the export is migrated off
+	Node string `json:"node"`
+	// the targetNode which is set by the drain controller
+	TargetNode string `json:"targetNode"`
+	// the targetNode which is set by the user before the drain, it is restored once the node is back
+	PreviousTargetNode string `json:"previousTargetNode,omitempty"`
+}
+
+// Controller migrates the exports off the node which is cordoned or put into the maintenance mode,
+// so the server is moved in a planned way before its pod is evicted. At most maxMigrations exports
+// are moving at once, the others wait on the draining node until the budget is available.
+type Controller struct {
+	maxMigrations int
+	backends      backend.Backends
+	placer        *placement.Placer
+	recorder      record.EventRecorder
+
+	Nodes             ctlcorev1.NodeController
+	PodCache          ctlcorev1.PodCache
+	NetworkFSCache    ctlntefsv1.NetworkFilesystemCache
+	NetworkFilsystems ctlntefsv1.NetworkFilesystemController
+}
+
+// Register registers the drain controller, it is disabled when the number of the concurrent migrations is zero
+func Register(ctx context.Context, backends backend.Backends, placer *placement.Placer, nodes ctlcorev1.NodeController, pods ctlcorev1.PodController, netfilesystems ctlntefsv1.NetworkFilesystemController, recorder record.EventRecorder, opt *utils.Option) error {
+	if opt.DrainMaxMigrations <= 0 {
+		logrus.Info("Drain controller is disabled, the exports fail over once their node is cordoned")
+		return nil
+	}
+
+	c := &Controller{
+		maxMigrations:     opt.DrainMaxMigrations,
+		backends:          backends,
+		placer:            placer,
+		recorder:          recorder,
+		Nodes:             nodes,
+		PodCache:          pods.Cache(),
+		NetworkFSCache:    netfilesystems.Cache(),
+		NetworkFilsystems: netfilesystems,
+	}
+	c.NetworkFSCache.AddIndexer(networkFSByDrainedNodeIndex, func(networkFS *networkfsv1.NetworkFilesystem) ([]string, error) {
+		if record, ok := getDrainMigration(networkFS); ok {
+			return []string{record.Node}, nil
+		}
+		return nil, nil
+	})
+	c.Nodes.OnChange(ctx, drainNodeHandlerName, metrics.CountErrors("drain", c.OnNodeChange))
+	return nil
+}
+
+func (c *Controller) OnNodeChange(name string, node *corev1.Node) (*corev1.Node, error) {
+	if node == nil || node.DeletionTimestamp != nil || !placement.IsDraining(node) {
+		return nil, c.restoreTargetNodes(name)
+	}
+
+	networkFSes, err := c.NetworkFSCache.List("", labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network filesystems: %w", err)
+	}
+	migrations := 0
+	var hosted []*networkfsv1.NetworkFilesystem
+	for _, networkFS := range networkFSes {
+		if isMigrating(networkFS) {
+			migrations++
+			continue
+		}
+		if networkFS.DeletionTimestamp != nil || networkFS.Spec.DesiredState != networkfsv1.NetworkFSStateEnabled || networkFS.Status.State != networkfsv1.NetworkFSStateEnabled {
+			continue
+		}
+		if c.serverNode(networkFS) == node.Name {
+			hosted = append(hosted, networkFS)
+		}
+	}
+	if len(hosted) == 0 {
+		return nil, nil
+	}
+
+	// the node is checked again until all the exports are gone, the budget may be available by then
+	c.Nodes.EnqueueAfter(node.Name, drainRetryInterval)
+	sort.Slice(hosted, func(i, j int) bool {
+		if hosted[i].Namespace != hosted[j].Namespace {
+			return hosted[i].Namespace < hosted[j].Namespace
+		}
+		return hosted[i].Name < hosted[j].Name
+	})
+	for _, networkFS := range hosted {
+		if migrations >= c.maxMigrations {
+			logrus.Infof("Wait for %d migrations to complete before migrating network filesystem %s off node %s", migrations, networkFS.Name, node.Name)
+			return nil, nil
+		}
+		migrated, err := c.migrate(networkFS, node)
+		if err != nil {
+			return nil, err
+		}
+		if migrated {
+			migrations++
+		}
+	}
+	return nil, nil
+}
+
+// migrate sets the targetNode of the networkFS to another eligible node, the networkfilesystem controller moves the export there.
+// The previous targetNode is recorded in the annotation, it is restored once the node is no longer draining.
+func (c *Controller) migrate(networkFS *networkfsv1.NetworkFilesystem, node *corev1.Node) (bool, error) {
+	target, err := c.placer.Evacuate(networkFS, node.Name)
+	if err != nil {
+		logrus.Warnf("Failed to migrate network filesystem %s off draining node %s: %v", networkFS.Name, node.Name, err)
+		c.recorder.Eventf(networkFS, corev1.EventTypeWarning, utils.EventReasonDrainBlocked, "Export stays on draining node %s: %v", node.Name, err)
+		return false, nil
+	}
+
+	logrus.Infof("Migrate network filesystem %s off draining node %s to %s", networkFS.Name, node.Name, target)
+	record := drainMigration{Node: node.Name, TargetNode: target, PreviousTargetNode: networkFS.Spec.TargetNode}
+	// the export drained off a node again keeps the targetNode set by the user
+	if previous, ok := getDrainMigration(networkFS); ok && previous.TargetNode == networkFS.Spec.TargetNode {
+		record.PreviousTargetNode = previous.PreviousTargetNode
+	}
+	value, err := json.Marshal(record)
+	if err != nil {
+		return false, err
+	}
+	networkFSCpy := networkFS.DeepCopy()
+	networkFSCpy.Spec.TargetNode = target
+	if networkFSCpy.Annotations == nil {
+		networkFSCpy.Annotations = map[string]string{}
+	}
+	networkFSCpy.Annotations[utils.AnnotationDrainMigration] = string(value)
+	if _, err := c.NetworkFilsystems.Update(networkFSCpy); err != nil {
+		if apierrors.IsConflict(err) {
+			// the networkFS is changed meanwhile, it is handled in the next round
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to set targetNode of network filesystem %s to %s: %w", networkFS.Name, target, err)
+	}
+	c.recorder.Eventf(networkFS, corev1.EventTypeNormal, utils.EventReasonDrainMigration, "Migrating the export off node %s which is %s to node %s", node.Name, drainReason(node), target)
+	return true, nil
+}
+
+// restoreTargetNodes restores the targetNode of the exports migrated off the node once it is no longer draining,
+// the targetNode changed by the user meanwhile is kept
+func (c *Controller) restoreTargetNodes(nodeName string) error {
+	networkFSes, err := c.NetworkFSCache.GetByIndex(networkFSByDrainedNodeIndex, nodeName)
+	if err != nil {
+		return fmt.Errorf("failed to list network filesystems migrated off node %s: %w", nodeName, err)
+	}
+	for _, networkFS := range networkFSes {
+		record, ok := getDrainMigration(networkFS)
+		if !ok || record.Node != nodeName {
+			continue
+		}
+		networkFSCpy := networkFS.DeepCopy()
+		delete(networkFSCpy.Annotations, utils.AnnotationDrainMigration)
+		if networkFS.Spec.TargetNode == record.TargetNode {
+			networkFSCpy.Spec.TargetNode = record.PreviousTargetNode
+		}
+		logrus.Infof("Node %s is no longer draining, restore targetNode %q of network filesystem %s", nodeName, networkFSCpy.Spec.TargetNode, networkFS.Name)
+		if _, err := c.NetworkFilsystems.Update(networkFSCpy); err != nil {
+			return fmt.Errorf("failed to restore targetNode of network filesystem %s: %w", networkFS.Name, err)
+		}
+	}
+	return nil
+}
+
+// getDrainMigration returns the move of the networkFS off the draining node recorded by the drain controller
+func getDrainMigration(networkFS *networkfsv1.NetworkFilesystem) (drainMigration, bool) {
+	var record drainMigration
+	value, ok := networkFS.Annotations[utils.AnnotationDrainMigration]
+	if !ok {
+		return record, false
+	}
+	if err := json.Unmarshal([]byte(value), &record); err != nil || record.Node == "" {
+		logrus.Warnf("Ignore malformed annotation %s of network filesystem %s: %q", utils.AnnotationDrainMigration, networkFS.Name, value)
+		return record, false
+	}
+	return record, true
+}
+
+// serverNode returns the node which the server of the export runs on, the backend picks it when the networkFS has no placement rule
+func (c *Controller) serverNode(networkFS *networkfsv1.NetworkFilesystem) string {
+	if networkFS.Status.Node != "" {
+		return networkFS.Status.Node
+	}
+	exportBackend, err := c.backends.Get(networkFS)
+	if err != nil {
+		return ""
+	}
+	locator, ok := exportBackend.(backend.ServerPodLocator)
+	if !ok {
+		return ""
+	}
+	namespace, name, _ := locator.ServerPod(networkFS)
+	pod, err := c.PodCache.Get(namespace, name)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			logrus.Errorf("Failed to get server pod %s/%s of network filesystem %s: %v", namespace, name, networkFS.Name, err)
+		}
+		return ""
+	}
+	return pod.Spec.NodeName
+}
+
+// isMigrating returns whether the export is moving between nodes, or is about to move to the targetNode.
+// The migration waiting for the ineligible targetNode does not count, it does not disrupt the export.
+func isMigrating(networkFS *networkfsv1.NetworkFilesystem) bool {
+	if migration := networkFS.Status.Migration; migration != nil {
+		switch migration.Phase {
+		case networkfsv1.MigrationPhaseDetaching, networkfsv1.MigrationPhaseAttaching:
+			return true
+		case networkfsv1.MigrationPhasePending:
+			if migration.TargetNode == networkFS.Spec.TargetNode {
+				return false
+			}
+		}
+	}
+	target := networkFS.Spec.TargetNode
+	return target != "" && target != networkFS.Status.Node && networkFS.Status.State == networkfsv1.NetworkFSStateEnabled
+}
+
+func drainReason(node *corev1.Node) string {
+	if status := node.Annotations[utils.AnnotationMaintainStatus]; status != "" {
+		return fmt.Sprintf("in maintenance mode (%s)", status)
+	}
+	return "cordoned"
+}
@@ -0,0 +1,337 @@
+package drain
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	ctlcorev1 "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/record"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	ctlntefsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/generated/controllers/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/placement"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+// testNodeCache serves the nodes of the test to the placer
+type testNodeCache struct {
+	ctlcorev1.NodeCache
+	nodes map[string]*corev1.Node
+}
+
+func (c *testNodeCache) Get(name string) (*corev1.Node, error) {
+	if node, found := c.nodes[name]; found {
+		return node, nil
+	}
+	return nil, apierrors.NewNotFound(corev1.Resource("nodes"), name)
+}
+
+func (c *testNodeCache) List(selector labels.Selector) ([]*corev1.Node, error) {
+	var nodes []*corev1.Node
+	for _, node := range c.nodes {
+		if selector.Matches(labels.Set(node.Labels)) {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes, nil
+}
+
+// testNodeController drops the retries of the draining node
+type testNodeController struct {
+	ctlcorev1.NodeController
+}
+
+func (c *testNodeController) EnqueueAfter(string, time.Duration) {}
+
+// testNetworkFSCache serves the networkFSes of the test
+type testNetworkFSCache struct {
+	ctlntefsv1.NetworkFilesystemCache
+	networkFSes []*networkfsv1.NetworkFilesystem
+}
+
+func (c *testNetworkFSCache) List(string, labels.Selector) ([]*networkfsv1.NetworkFilesystem, error) {
+	return c.networkFSes, nil
+}
+
+func (c *testNetworkFSCache) GetByIndex(_, node string) ([]*networkfsv1.NetworkFilesystem, error) {
+	var ret []*networkfsv1.NetworkFilesystem
+	for _, networkFS := range c.networkFSes {
+		if record, ok := getDrainMigration(networkFS); ok && record.Node == node {
+			ret = append(ret, networkFS)
+		}
+	}
+	return ret, nil
+}
+
+// testNetworkFSController records the updated networkFSes
+type testNetworkFSController struct {
+	ctlntefsv1.NetworkFilesystemController
+	updated map[string]*networkfsv1.NetworkFilesystem
+}
+
+func (c *testNetworkFSController) Update(networkFS *networkfsv1.NetworkFilesystem) (*networkfsv1.NetworkFilesystem, error) {
+	c.updated[networkFS.Name] = networkFS
+	return networkFS, nil
+}
+
+func testNode(name string, modify func(*corev1.Node)) *corev1.Node {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+		},
+	}
+	if modify != nil {
+		modify(node)
+	}
+	return node
+}
+
+// testNetworkFS returns the enabled networkFS served on the node, the Ganesha backend does not need the Longhorn nodes
+func testNetworkFS(name, node string, modify func(*networkfsv1.NetworkFilesystem)) *networkfsv1.NetworkFilesystem {
+	networkFS := &networkfsv1.NetworkFilesystem{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "harvester-system"},
+		Spec: networkfsv1.NetworkFSSpec{
+			NetworkFSName: name,
+			DesiredState:  networkfsv1.NetworkFSStateEnabled,
+			ExportBackend: networkfsv1.ExportBackendGanesha,
+		},
+		Status: networkfsv1.NetworkFSStatus{
+			State: networkfsv1.NetworkFSStateEnabled,
+			Node:  node,
+		},
+	}
+	if modify != nil {
+		modify(networkFS)
+	}
+	return networkFS
+}
+
+func withDrainMigration(value string) func(*networkfsv1.NetworkFilesystem) {
+	return func(networkFS *networkfsv1.NetworkFilesystem) {
+		networkFS.Annotations = map[string]string{utils.AnnotationDrainMigration: value}
+	}
+}
+
+func TestOnNodeChange(t *testing.T) {
+	cordoned := func(node *corev1.Node) { node.Spec.Unschedulable = true }
+	maintained := func(node *corev1.Node) {
+		node.Annotations = map[string]string{utils.AnnotationMaintainStatus: "running"}
+	}
+
+	tests := []struct {
+		name        string
+		node        *corev1.Node
+		networkFSes []*networkfsv1.NetworkFilesystem
+		// the targetNode of the updated networkFSes
+		wantTargets map[string]string
+	}{
+		{
+			name: "exports of the cordoned node are migrated in order within the budget",
+			node: testNode("node-1", cordoned),
+			networkFSes: []*networkfsv1.NetworkFilesystem{
+				testNetworkFS("pvc-b", "node-1", nil),
+				testNetworkFS("pvc-a", "node-1", nil),
+				testNetworkFS("pvc-c", "node-2", nil),
+			},
+			wantTargets: map[string]string{"pvc-a": "node-2"},
+		},
+		{
+			name: "migration of another export uses up the budget",
+			node: testNode("node-1", maintained),
+			networkFSes: []*networkfsv1.NetworkFilesystem{
+				testNetworkFS("pvc-a", "node-1", nil),
+				testNetworkFS("pvc-b", "node-2", func(networkFS *networkfsv1.NetworkFilesystem) {
+					networkFS.Spec.TargetNode = "node-3"
+				}),
+			},
+			wantTargets: map[string]string{},
+		},
+		{
+			name: "disabled export stays",
+			node: testNode("node-1", cordoned),
+			networkFSes: []*networkfsv1.NetworkFilesystem{
+				testNetworkFS("pvc-a", "node-1", func(networkFS *networkfsv1.NetworkFilesystem) {
+					networkFS.Spec.DesiredState = networkfsv1.NetworkFSStateDisabled
+				}),
+			},
+			wantTargets: map[string]string{},
+		},
+		{
+			name: "targetNode is restored once the node is back",
+			node: testNode("node-1", nil),
+			networkFSes: []*networkfsv1.NetworkFilesystem{
+				testNetworkFS("pvc-a", "node-2", func(networkFS *networkfsv1.NetworkFilesystem) {
+					withDrainMigration(`{"node":"node-1","targetNode":"node-2","previousTargetNode":"node-1"}`)(networkFS)
+					networkFS.Spec.TargetNode = "node-2"
+				}),
+				// the targetNode changed by the user meanwhile is kept
+				testNetworkFS("pvc-b", "node-3", func(networkFS *networkfsv1.NetworkFilesystem) {
+					withDrainMigration(`{"node":"node-1","targetNode":"node-2"}`)(networkFS)
+					networkFS.Spec.TargetNode = "node-3"
+				}),
+				testNetworkFS("pvc-c", "node-2", func(networkFS *networkfsv1.NetworkFilesystem) {
+					withDrainMigration(`{"node":"node-3","targetNode":"node-2"}`)(networkFS)
+					networkFS.Spec.TargetNode = "node-2"
+				}),
+			},
+			wantTargets: map[string]string{"pvc-a": "node-1", "pvc-b": "node-3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes := &testNodeCache{nodes: map[string]*corev1.Node{
+				"node-1": tt.node,
+				"node-2": testNode("node-2", nil),
+				"node-3": testNode("node-3", nil),
+			}}
+			networkFSes := &testNetworkFSController{updated: map[string]*networkfsv1.NetworkFilesystem{}}
+			c := &Controller{
+				maxMigrations:     1,
+				placer:            &placement.Placer{NodeCache: nodes},
+				recorder:          record.NewFakeRecorder(10),
+				Nodes:             &testNodeController{},
+				NetworkFSCache:    &testNetworkFSCache{networkFSes: tt.networkFSes},
+				NetworkFilsystems: networkFSes,
+			}
+			if _, err := c.OnNodeChange(tt.node.Name, tt.node); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := map[string]string{}
+			for name, networkFS := range networkFSes.updated {
+				got[name] = networkFS.Spec.TargetNode
+				_, recorded := networkFS.Annotations[utils.AnnotationDrainMigration]
+				if draining := placement.IsDraining(tt.node); recorded != draining {
+					t.Errorf("expected the drain migration of %s to be recorded %v, got %v", name, draining, recorded)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.wantTargets) {
+				t.Errorf("expected targetNodes %v, got %v", tt.wantTargets, got)
+			}
+		})
+	}
+}
+
+func TestIsMigrating(t *testing.T) {
+	tests := []struct {
+		name      string
+		networkFS *networkfsv1.NetworkFilesystem
+		want      bool
+	}{
+		{
+			name:      "no targetNode",
+			networkFS: testNetworkFS("pvc-a", "node-1", nil),
+		},
+		{
+			name: "served on the targetNode",
+			networkFS: testNetworkFS("pvc-a", "node-1", func(networkFS *networkfsv1.NetworkFilesystem) {
+				networkFS.Spec.TargetNode = "node-1"
+			}),
+		},
+		{
+			name: "about to move to the targetNode",
+			networkFS: testNetworkFS("pvc-a", "node-1", func(networkFS *networkfsv1.NetworkFilesystem) {
+				networkFS.Spec.TargetNode = "node-2"
+			}),
+			want: true,
+		},
+		{
+			name: "disabled export does not move",
+			networkFS: testNetworkFS("pvc-a", "node-1", func(networkFS *networkfsv1.NetworkFilesystem) {
+				networkFS.Spec.TargetNode = "node-2"
+				networkFS.Status.State = networkfsv1.NetworkFSStateDisabled
+			}),
+		},
+		{
+			name: "attaching on the targetNode",
+			networkFS: testNetworkFS("pvc-a", "node-2", func(networkFS *networkfsv1.NetworkFilesystem) {
+				networkFS.Spec.TargetNode = "node-2"
+				networkFS.Status.Migration = &networkfsv1.MigrationStatus{TargetNode: "node-2", Phase: networkfsv1.MigrationPhaseAttaching}
+			}),
+			want: true,
+		},
+		{
+			name: "waiting for the ineligible targetNode",
+			networkFS: testNetworkFS("pvc-a", "node-1", func(networkFS *networkfsv1.NetworkFilesystem) {
+				networkFS.Spec.TargetNode = "node-2"
+				networkFS.Status.Migration = &networkfsv1.MigrationStatus{TargetNode: "node-2", Phase: networkfsv1.MigrationPhasePending}
+			}),
+		},
+		{
+			name: "targetNode changed while waiting",
+			networkFS: testNetworkFS("pvc-a", "node-1", func(networkFS *networkfsv1.NetworkFilesystem) {
+				networkFS.Spec.TargetNode = "node-3"
+				networkFS.Status.Migration = &networkfsv1.MigrationStatus{TargetNode: "node-2", Phase: networkfsv1.MigrationPhasePending}
+			}),
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isMigrating(tt.networkFS); got != tt.want {
+				t.Errorf("expected migrating %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGetDrainMigration(t *testing.T) {
+	tests := []struct {
+		name      string
+		networkFS *networkfsv1.NetworkFilesystem
+		want      drainMigration
+		wantFound bool
+	}{
+		{
+			name:      "no annotation",
+			networkFS: testNetworkFS("pvc-a", "node-1", nil),
+		},
+		{
+			name:      "recorded migration",
+			networkFS: testNetworkFS("pvc-a", "node-2", withDrainMigration(`{"node":"node-1","targetNode":"node-2","previousTargetNode":"node-1"}`)),
+			want:      drainMigration{Node: "node-1", TargetNode: "node-2", PreviousTargetNode: "node-1"},
+			wantFound: true,
+		},
+		{
+			name:      "malformed annotation",
+			networkFS: testNetworkFS("pvc-a", "node-2", withDrainMigration("node-1")),
+		},
+		{
+			name:      "annotation without the node",
+			networkFS: testNetworkFS("pvc-a", "node-2", withDrainMigration(`{"targetNode":"node-2"}`)),
+			want:      drainMigration{TargetNode: "node-2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := getDrainMigration(tt.networkFS)
+			if found != tt.wantFound {
+				t.Fatalf("expected found %v, got %v", tt.wantFound, found)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected drain migration %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDrainReason(t *testing.T) {
+	if got := drainReason(testNode("node-1", func(node *corev1.Node) { node.Spec.Unschedulable = true })); got != "cordoned" {
+		t.Errorf("expected reason cordoned, got %s", got)
+	}
+	maintained := testNode("node-1", func(node *corev1.Node) {
+		node.Annotations = map[string]string{utils.AnnotationMaintainStatus: "completed"}
+	})
+	if got, want := drainReason(maintained), "in maintenance mode (completed)"; got != want {
+		t.Errorf("expected reason %s, got %s", want, got)
+	}
+}
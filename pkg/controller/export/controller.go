@@ -0,0 +1,259 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	ctlcorev1 "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/backend"
+	ctlntefsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/generated/controllers/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/metrics"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+// Controller provisions the static NFS PV (and PVC) of the NetworkFilesystemExport to the guest cluster
+type Controller struct {
+	recorder record.EventRecorder
+
+	// the clients of the guest clusters, keyed by the namespace/name of the kubeconfig secret
+	lock    sync.Mutex
+	clients map[string]*guestClient
+
+	ExportCache       ctlntefsv1.NetworkFilesystemExportCache
+	Exports           ctlntefsv1.NetworkFilesystemExportController
+	NetworkFSCache    ctlntefsv1.NetworkFilesystemCache
+	NetworkFilsystems ctlntefsv1.NetworkFilesystemController
+	SecretCache       ctlcorev1.SecretCache
+}
+
+const (
+	netFSExportHandlerName          = "harvester-netfs-export-handler"
+	netFSExportNetworkFSHandlerName = "harvester-netfs-export-networkfs-handler"
+	netFSExportSecretHandlerName    = "harvester-netfs-export-secret-handler"
+
+	// exportByNetworkFSIndex indexes the NetworkFilesystemExport with the namespace/name of the networkFS
+	exportByNetworkFSIndex = "networkfs.harvesterhci.io/export-by-networkfs"
+	// exportByKubeconfigIndex indexes the NetworkFilesystemExport with the namespace/name of the kubeconfig secret
+	exportByKubeconfigIndex = "networkfs.harvesterhci.io/export-by-kubeconfig"
+
+	// the PV and PVC in the guest cluster are removed before the finalizer is dropped
+	exportFinalizer = "networkfs.harvesterhci.io/guest-volume"
+
+	// the guest cluster is not watched, its resources are checked periodically
+	resyncPeriod = 5 * time.Minute
+)
+
+// Register registers the controller which provisions the NetworkFilesystemExport to the guest clusters
+func Register(ctx context.Context, exports ctlntefsv1.NetworkFilesystemExportController, netfilesystems ctlntefsv1.NetworkFilesystemController, secrets ctlcorev1.SecretController, recorder record.EventRecorder, opt *utils.Option) error {
+	c := &Controller{
+		recorder:          recorder,
+		clients:           map[string]*guestClient{},
+		Exports:           exports,
+		ExportCache:       exports.Cache(),
+		NetworkFilsystems: netfilesystems,
+		NetworkFSCache:    netfilesystems.Cache(),
+		SecretCache:       secrets.Cache(),
+	}
+
+	c.ExportCache.AddIndexer(exportByNetworkFSIndex, func(export *networkfsv1.NetworkFilesystemExport) ([]string, error) {
+		return []string{export.Namespace + "/" + export.Spec.NetworkFilesystemName}, nil
+	})
+	c.ExportCache.AddIndexer(exportByKubeconfigIndex, func(export *networkfsv1.NetworkFilesystemExport) ([]string, error) {
+		return []string{export.Namespace + "/" + export.Spec.KubeconfigSecretRef.Name}, nil
+	})
+	c.Exports.OnChange(ctx, netFSExportHandlerName, metrics.CountErrors("export", c.OnExportChange))
+	netfilesystems.OnChange(ctx, netFSExportNetworkFSHandlerName, c.OnNetworkFSChange)
+	secrets.OnChange(ctx, netFSExportSecretHandlerName, c.OnSecretChange)
+	return nil
+}
+
+// OnNetworkFSChange enqueues the exports of the networkFS, e.g. its endpoint is ready or changed
+func (c *Controller) OnNetworkFSChange(_ string, networkFS *networkfsv1.NetworkFilesystem) (*networkfsv1.NetworkFilesystem, error) {
+	if networkFS == nil {
+		return nil, nil
+	}
+	return nil, c.enqueueByIndex(exportByNetworkFSIndex, networkFS.Namespace+"/"+networkFS.Name)
+}
+
+// OnSecretChange enqueues the exports of the kubeconfig secret, e.g. the kubeconfig is rotated
+func (c *Controller) OnSecretChange(_ string, secret *corev1.Secret) (*corev1.Secret, error) {
+	if secret == nil {
+		return nil, nil
+	}
+	return nil, c.enqueueByIndex(exportByKubeconfigIndex, secret.Namespace+"/"+secret.Name)
+}
+
+func (c *Controller) enqueueByIndex(index, key string) error {
+	exports, err := c.ExportCache.GetByIndex(index, key)
+	if err != nil {
+		return err
+	}
+	for _, export := range exports {
+		c.Exports.Enqueue(export.Namespace, export.Name)
+	}
+	return nil
+}
+
+func (c *Controller) OnExportChange(_ string, export *networkfsv1.NetworkFilesystemExport) (*networkfsv1.NetworkFilesystemExport, error) {
+	if export == nil {
+		return nil, nil
+	}
+	if export.DeletionTimestamp != nil {
+		return c.release(export)
+	}
+	logrus.Debugf("Handling networkfilesystem export %s/%s change event", export.Namespace, export.Name)
+
+	if !hasFinalizer(export) {
+		exportCpy := export.DeepCopy()
+		exportCpy.Finalizers = append(exportCpy.Finalizers, exportFinalizer)
+		return c.Exports.Update(exportCpy)
+	}
+
+	networkFS, err := c.NetworkFSCache.Get(export.Namespace, export.Spec.NetworkFilesystemName)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	if apierrors.IsNotFound(err) {
+		return c.updateStatus(export, networkfsv1.GuestVolumeStatePending, fmt.Sprintf("NetworkFilesystem %s is not found", export.Spec.NetworkFilesystemName))
+	}
+	if protocol := backend.ProtocolOf(networkFS); protocol != networkfsv1.NetworkFSTypeNFS {
+		return c.updateStatus(export, networkfsv1.GuestVolumeStateFailed, fmt.Sprintf("Protocol %s can not be provisioned as the NFS PersistentVolume", protocol))
+	}
+	if err := checkGuestReachable(networkFS); err != nil {
+		return c.updateStatus(export, networkfsv1.GuestVolumeStateFailed, err.Error())
+	}
+	// the PV is kept while the export is not ready, the guest clients reconnect to the stable address once it is ready again
+	if networkFS.Spec.DesiredState != networkfsv1.NetworkFSStateEnabled || networkFS.Status.Status != networkfsv1.EndpointStatusReady || networkFS.Status.Endpoint == "" {
+		return c.updateStatus(export, networkfsv1.GuestVolumeStatePending, fmt.Sprintf("Waiting for the endpoint of NetworkFilesystem %s", networkFS.Name))
+	}
+
+	client, err := c.guestClient(export)
+	if err != nil {
+		return c.failed(export, err)
+	}
+	guest := &guestVolume{client: client, export: export, networkFS: networkFS, recorder: c.recorder}
+	pending, err := guest.sync()
+	if err != nil {
+		return c.failed(export, err)
+	}
+	c.Exports.EnqueueAfter(export.Namespace, export.Name, resyncPeriod)
+	if pending != "" {
+		return c.updateStatus(export, networkfsv1.GuestVolumeStatePending, pending)
+	}
+	return c.updateSyncStatus(export, networkfsv1.GuestVolumeStateSynced, networkFS.Status.Endpoint, utils.NFSExportPath(networkFS), "")
+}
+
+// release removes the PV and PVC from the guest cluster and drops the finalizer
+func (c *Controller) release(export *networkfsv1.NetworkFilesystemExport) (*networkfsv1.NetworkFilesystemExport, error) {
+	if !hasFinalizer(export) {
+		return nil, nil
+	}
+
+	client, err := c.guestClient(export)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return c.failed(export, err)
+	}
+	if apierrors.IsNotFound(err) {
+		// the guest cluster is unreachable without the kubeconfig, its resources are left as they are
+		logrus.Warnf("Kubeconfig secret of networkfilesystem export %s/%s is not found, skip removing the resources of the guest cluster", export.Namespace, export.Name)
+	} else {
+		guest := &guestVolume{client: client, export: export, recorder: c.recorder}
+		pending, err := guest.cleanup("", "")
+		if err != nil {
+			return c.failed(export, err)
+		}
+		if pending != "" {
+			// the finalizer is kept until the pods in the guest cluster stop using the PVCs
+			c.Exports.EnqueueAfter(export.Namespace, export.Name, resyncPeriod)
+			return c.updateStatus(export, networkfsv1.GuestVolumeStatePending, pending)
+		}
+	}
+	c.forgetClient(export)
+
+	exportCpy := export.DeepCopy()
+	var finalizers []string
+	for _, f := range exportCpy.Finalizers {
+		if f != exportFinalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	exportCpy.Finalizers = finalizers
+	return c.Exports.Update(exportCpy)
+}
+
+func (c *Controller) failed(export *networkfsv1.NetworkFilesystemExport, err error) (*networkfsv1.NetworkFilesystemExport, error) {
+	logrus.Errorf("Failed to provision networkfilesystem export %s/%s to the guest cluster: %v", export.Namespace, export.Name, err)
+	if export.Status.State != networkfsv1.GuestVolumeStateFailed || export.Status.Message != err.Error() {
+		c.recorder.Eventf(export, corev1.EventTypeWarning, utils.EventReasonGuestVolumeFailed, "Failed to provision PV %s to the guest cluster: %v", export.Spec.PersistentVolume.Name, err)
+	}
+	if _, updateErr := c.updateStatus(export, networkfsv1.GuestVolumeStateFailed, err.Error()); updateErr != nil {
+		logrus.Errorf("Failed to update status of networkfilesystem export %s/%s: %v", export.Namespace, export.Name, updateErr)
+	}
+	return nil, err
+}
+
+// updateStatus updates the state of the export, the server and path of the PV are kept
+func (c *Controller) updateStatus(export *networkfsv1.NetworkFilesystemExport, state networkfsv1.GuestVolumeState, message string) (*networkfsv1.NetworkFilesystemExport, error) {
+	return c.updateSyncStatus(export, state, export.Status.Server, export.Status.Path, message)
+}
+
+// updateSyncStatus updates the status of the export, the transition time is kept if nothing changed
+func (c *Controller) updateSyncStatus(export *networkfsv1.NetworkFilesystemExport, state networkfsv1.GuestVolumeState, server, path, message string) (*networkfsv1.NetworkFilesystemExport, error) {
+	status := networkfsv1.NetworkFSExportStatus{
+		State:              state,
+		Server:             server,
+		Path:               path,
+		Message:            message,
+		LastTransitionTime: metav1.Now(),
+	}
+	if cur := export.Status; cur.State == state && cur.Server == server && cur.Path == path && cur.Message == message {
+		status.LastTransitionTime = cur.LastTransitionTime
+	}
+
+	exportCpy := export.DeepCopy()
+	exportCpy.Status = status
+	if reflect.DeepEqual(export, exportCpy) {
+		return nil, nil
+	}
+	return c.Exports.UpdateStatus(exportCpy)
+}
+
+func hasFinalizer(export *networkfsv1.NetworkFilesystemExport) bool {
+	for _, f := range export.Finalizers {
+		if f == exportFinalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// checkGuestReachable returns the error if the endpoint is only routable in the host cluster, the ClusterIP of the managed
+// service is not reachable from the guest cluster, only the LoadBalancer address is
+func checkGuestReachable(networkFS *networkfsv1.NetworkFilesystem) error {
+	if svcType := networkFS.Spec.Service.Type; svcType != corev1.ServiceTypeLoadBalancer {
+		if svcType == "" {
+			svcType = corev1.ServiceTypeClusterIP
+		}
+		return fmt.Errorf("NetworkFilesystem %s is exported by the %s service which is not reachable from the guest cluster, set service.type to %s", networkFS.Name, svcType, corev1.ServiceTypeLoadBalancer)
+	}
+	return nil
+}
+
+// mountOptions returns the mount options of the PV, the recommended mount options of the networkFS are used by default
+func mountOptions(export *networkfsv1.NetworkFilesystemExport, networkFS *networkfsv1.NetworkFilesystem) []string {
+	opts := export.Spec.PersistentVolume.MountOptions
+	if opts == "" {
+		opts = networkFS.Status.MountOpts
+	}
+	return utils.NFSMountOptions(opts)
+}
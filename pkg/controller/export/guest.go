@@ -0,0 +1,415 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/client-go/tools/record"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+// the guest cluster may be unreachable, the requests do not block the workers for long
+const guestRequestTimeout = 30 * time.Second
+
+type guestClient struct {
+	// the resource version of the kubeconfig secret, the client is rebuilt once the secret changes
+	resourceVersion string
+	key             string
+	client          kubernetes.Interface
+}
+
+// guestClient returns the client of the guest cluster, the NotFound error is returned if the kubeconfig secret is not found
+func (c *Controller) guestClient(export *networkfsv1.NetworkFilesystemExport) (kubernetes.Interface, error) {
+	name := export.Spec.KubeconfigSecretRef.Name
+	secret, err := c.SecretCache.Get(export.Namespace, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kubeconfig secret %s: %w", name, err)
+	}
+	key := utils.KubeconfigKey(export)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	cacheKey := export.Namespace + "/" + name
+	if cached, ok := c.clients[cacheKey]; ok && cached.resourceVersion == secret.ResourceVersion && cached.key == key {
+		return cached.client, nil
+	}
+
+	kubeconfig := secret.Data[key]
+	if len(kubeconfig) == 0 {
+		return nil, fmt.Errorf("kubeconfig secret %s does not contain %q", name, key)
+	}
+	config, err := sanitizedRESTConfig(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig of secret %s: %w", name, err)
+	}
+	config.Timeout = guestRequestTimeout
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create guest cluster client of secret %s: %w", name, err)
+	}
+	c.clients[cacheKey] = &guestClient{resourceVersion: secret.ResourceVersion, key: key, client: client}
+	return client, nil
+}
+
+// sanitizedRESTConfig returns the config of the current context of the tenant kubeconfig. Only the server, the CA data
+// and the inline token or client certificate data are taken, the kubeconfig which runs a command, reads a file of the
+// manager or goes through a proxy is refused.
+func sanitizedRESTConfig(kubeconfig []byte) (*rest.Config, error) {
+	raw, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	contextName := raw.CurrentContext
+	kubeContext, ok := raw.Contexts[contextName]
+	if !ok {
+		return nil, fmt.Errorf("context %q is not found", contextName)
+	}
+	cluster, ok := raw.Clusters[kubeContext.Cluster]
+	if !ok {
+		return nil, fmt.Errorf("cluster %q is not found", kubeContext.Cluster)
+	}
+	authInfo, ok := raw.AuthInfos[kubeContext.AuthInfo]
+	if !ok {
+		authInfo = clientcmdapi.NewAuthInfo()
+	}
+
+	switch {
+	case cluster.Server == "":
+		return nil, fmt.Errorf("cluster %q has no server", kubeContext.Cluster)
+	case cluster.CertificateAuthority != "":
+		return nil, fmt.Errorf("cluster %q refers to the CA file, only certificate-authority-data is allowed", kubeContext.Cluster)
+	case cluster.InsecureSkipTLSVerify:
+		return nil, fmt.Errorf("cluster %q skips the TLS verification", kubeContext.Cluster)
+	case cluster.ProxyURL != "":
+		return nil, fmt.Errorf("cluster %q goes through a proxy", kubeContext.Cluster)
+	case authInfo.Exec != nil:
+		return nil, fmt.Errorf("user %q runs the exec plugin", kubeContext.AuthInfo)
+	case authInfo.AuthProvider != nil:
+		return nil, fmt.Errorf("user %q uses the auth provider", kubeContext.AuthInfo)
+	case authInfo.TokenFile != "":
+		return nil, fmt.Errorf("user %q refers to the token file, only token is allowed", kubeContext.AuthInfo)
+	case authInfo.ClientCertificate != "" || authInfo.ClientKey != "":
+		return nil, fmt.Errorf("user %q refers to the client certificate files, only client-certificate-data and client-key-data are allowed", kubeContext.AuthInfo)
+	case authInfo.Username != "" || authInfo.Password != "":
+		return nil, fmt.Errorf("user %q uses the basic authentication", kubeContext.AuthInfo)
+	case authInfo.Impersonate != "" || len(authInfo.ImpersonateGroups) > 0 || len(authInfo.ImpersonateUserExtra) > 0 || authInfo.ImpersonateUID != "":
+		return nil, fmt.Errorf("user %q impersonates another user", kubeContext.AuthInfo)
+	}
+
+	return &rest.Config{
+		Host:        cluster.Server,
+		BearerToken: authInfo.Token,
+		TLSClientConfig: rest.TLSClientConfig{
+			ServerName: cluster.TLSServerName,
+			CAData:     cluster.CertificateAuthorityData,
+			CertData:   authInfo.ClientCertificateData,
+			KeyData:    authInfo.ClientKeyData,
+		},
+	}, nil
+}
+
+// forgetClient drops the cached client once no export references the kubeconfig secret
+func (c *Controller) forgetClient(export *networkfsv1.NetworkFilesystemExport) {
+	cacheKey := export.Namespace + "/" + export.Spec.KubeconfigSecretRef.Name
+	exports, err := c.ExportCache.GetByIndex(exportByKubeconfigIndex, cacheKey)
+	if err != nil {
+		return
+	}
+	for _, other := range exports {
+		if other.UID != export.UID {
+			return
+		}
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.clients, cacheKey)
+}
+
+// guestVolume provisions the PV (and PVC) of the export to the guest cluster
+type guestVolume struct {
+	client    kubernetes.Interface
+	export    *networkfsv1.NetworkFilesystemExport
+	networkFS *networkfsv1.NetworkFilesystem
+	recorder  record.EventRecorder
+}
+
+// sync makes sure the PV (and PVC) point at the current endpoint, the message is returned if it is waiting for the guest cluster
+func (g *guestVolume) sync() (string, error) {
+	pvName := g.export.Spec.PersistentVolume.Name
+	pvcName, pvcNamespace := "", ""
+	if claim := g.export.Spec.PersistentVolumeClaim; claim != nil {
+		pvcName, pvcNamespace = claim.Name, claim.Namespace
+	}
+	// the PV or PVC is renamed
+	if pending, err := g.cleanup(pvName, pvcNamespace+"/"+pvcName); err != nil || pending != "" {
+		return pending, err
+	}
+
+	pending, err := g.syncPersistentVolume()
+	if err != nil || pending != "" {
+		return pending, err
+	}
+	if pvcName == "" {
+		return "", nil
+	}
+	return g.syncPersistentVolumeClaim()
+}
+
+func (g *guestVolume) syncPersistentVolume() (string, error) {
+	desired := g.constructPersistentVolume()
+	pv, err := g.client.CoreV1().PersistentVolumes().Get(context.TODO(), desired.Name, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return "", fmt.Errorf("failed to get PV %s: %w", desired.Name, err)
+	}
+	if apierrors.IsNotFound(err) {
+		logrus.Infof("Create PV %s for networkfilesystem export %s/%s in the guest cluster", desired.Name, g.export.Namespace, g.export.Name)
+		if _, err := g.client.CoreV1().PersistentVolumes().Create(context.TODO(), desired, metav1.CreateOptions{}); err != nil {
+			return "", fmt.Errorf("failed to create PV %s: %w", desired.Name, err)
+		}
+		g.recorder.Eventf(g.export, corev1.EventTypeNormal, utils.EventReasonGuestVolumeProvisioned, "Provisioned PV %s from %s:%s to the guest cluster", desired.Name, desired.Spec.NFS.Server, desired.Spec.NFS.Path)
+		return "", nil
+	}
+	if pv.Labels[utils.LabelExportUID] != string(g.export.UID) {
+		return "", fmt.Errorf("PV %s is not managed by networkfilesystem export %s", pv.Name, g.export.Name)
+	}
+	if pv.DeletionTimestamp != nil && pv.Spec.ClaimRef != nil {
+		return fmt.Sprintf("Waiting for PV %s to be deleted, it is bound to PVC %s/%s", pv.Name, pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name), nil
+	}
+	if pv.DeletionTimestamp != nil {
+		return fmt.Sprintf("Waiting for PV %s to be deleted", pv.Name), nil
+	}
+
+	// the volume source of the PV is immutable, the PV is recreated for the new endpoint once no pod mounts it
+	if pv.Spec.NFS == nil || !reflect.DeepEqual(*pv.Spec.NFS, *desired.Spec.NFS) {
+		if claim := pv.Spec.ClaimRef; claim != nil {
+			pods, err := g.podsUsingClaim(claim.Namespace, claim.Name)
+			if err != nil {
+				return "", err
+			}
+			if len(pods) > 0 {
+				message := fmt.Sprintf("PV %s is not recreated for the endpoint %s while PVC %s/%s is used by pods %s", pv.Name, desired.Spec.NFS.Server, claim.Namespace, claim.Name, strings.Join(pods, ", "))
+				g.recorder.Event(g.export, corev1.EventTypeWarning, utils.EventReasonGuestVolumeInUse, message)
+				return message, nil
+			}
+		}
+		logrus.Infof("Recreate PV %s for networkfilesystem export %s/%s in the guest cluster, the endpoint is changed to %s", pv.Name, g.export.Namespace, g.export.Name, desired.Spec.NFS.Server)
+		g.recorder.Eventf(g.export, corev1.EventTypeNormal, utils.EventReasonGuestVolumeRecreating, "Recreating PV %s in the guest cluster, the endpoint is changed to %s", pv.Name, desired.Spec.NFS.Server)
+		// the bound PV is only deleted once the PVC is gone, the managed PVC is recreated along with the PV
+		if g.export.Spec.PersistentVolumeClaim != nil {
+			if err := g.deletePersistentVolumeClaim(g.export.Spec.PersistentVolumeClaim.Namespace, g.export.Spec.PersistentVolumeClaim.Name); err != nil {
+				return "", err
+			}
+		}
+		if err := g.deletePersistentVolume(pv.Name); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Recreating PV %s for the endpoint %s", pv.Name, desired.Spec.NFS.Server), nil
+	}
+
+	pvCpy := pv.DeepCopy()
+	if pvCpy.Labels == nil {
+		pvCpy.Labels = map[string]string{}
+	}
+	for k, v := range desired.Labels {
+		pvCpy.Labels[k] = v
+	}
+	pvCpy.Spec.Capacity = desired.Spec.Capacity
+	pvCpy.Spec.AccessModes = desired.Spec.AccessModes
+	pvCpy.Spec.MountOptions = desired.Spec.MountOptions
+	if reflect.DeepEqual(pv, pvCpy) {
+		return "", nil
+	}
+	logrus.Infof("Update PV %s for networkfilesystem export %s/%s in the guest cluster", pv.Name, g.export.Namespace, g.export.Name)
+	if _, err := g.client.CoreV1().PersistentVolumes().Update(context.TODO(), pvCpy, metav1.UpdateOptions{}); err != nil {
+		return "", fmt.Errorf("failed to update PV %s: %w", pv.Name, err)
+	}
+	return "", nil
+}
+
+func (g *guestVolume) syncPersistentVolumeClaim() (string, error) {
+	desired := g.constructPersistentVolumeClaim()
+	pvc, err := g.client.CoreV1().PersistentVolumeClaims(desired.Namespace).Get(context.TODO(), desired.Name, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return "", fmt.Errorf("failed to get PVC %s/%s: %w", desired.Namespace, desired.Name, err)
+	}
+	if apierrors.IsNotFound(err) {
+		logrus.Infof("Create PVC %s/%s for networkfilesystem export %s/%s in the guest cluster", desired.Namespace, desired.Name, g.export.Namespace, g.export.Name)
+		if _, err := g.client.CoreV1().PersistentVolumeClaims(desired.Namespace).Create(context.TODO(), desired, metav1.CreateOptions{}); err != nil {
+			return "", fmt.Errorf("failed to create PVC %s/%s: %w", desired.Namespace, desired.Name, err)
+		}
+		return "", nil
+	}
+	if pvc.Labels[utils.LabelExportUID] != string(g.export.UID) {
+		return "", fmt.Errorf("PVC %s/%s is not managed by networkfilesystem export %s", pvc.Namespace, pvc.Name, g.export.Name)
+	}
+	if pvc.DeletionTimestamp != nil {
+		return fmt.Sprintf("Waiting for PVC %s/%s to be deleted, it may be still used by the pods", pvc.Namespace, pvc.Name), nil
+	}
+	if pvc.Spec.VolumeName != desired.Spec.VolumeName {
+		return "", fmt.Errorf("PVC %s/%s is bound to PV %s instead of %s", pvc.Namespace, pvc.Name, pvc.Spec.VolumeName, desired.Spec.VolumeName)
+	}
+	return "", nil
+}
+
+// cleanup removes the PVs and PVCs of the export except the ones to keep, the PVC to keep is given by namespace/name.
+// The PVCs mounted by the pods are kept along with their PVs, the message is returned while it is waiting for them.
+func (g *guestVolume) cleanup(keepPV, keepPVC string) (string, error) {
+	selector := labels.SelectorFromSet(map[string]string{utils.LabelExportUID: string(g.export.UID)}).String()
+	pvcs, err := g.client.CoreV1().PersistentVolumeClaims(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return "", fmt.Errorf("failed to list PVCs: %w", err)
+	}
+	var inUse []string
+	for _, pvc := range pvcs.Items {
+		if pvc.Namespace+"/"+pvc.Name == keepPVC || pvc.DeletionTimestamp != nil {
+			continue
+		}
+		pods, err := g.podsUsingClaim(pvc.Namespace, pvc.Name)
+		if err != nil {
+			return "", err
+		}
+		if len(pods) > 0 {
+			inUse = append(inUse, fmt.Sprintf("PVC %s/%s is used by pods %s", pvc.Namespace, pvc.Name, strings.Join(pods, ", ")))
+			continue
+		}
+		if err := g.deletePersistentVolumeClaim(pvc.Namespace, pvc.Name); err != nil {
+			return "", err
+		}
+	}
+	if len(inUse) > 0 {
+		return fmt.Sprintf("Waiting for the pods to stop before the PVCs are removed: %s", strings.Join(inUse, "; ")), nil
+	}
+
+	pvs, err := g.client.CoreV1().PersistentVolumes().List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return "", fmt.Errorf("failed to list PVs: %w", err)
+	}
+	for _, pv := range pvs.Items {
+		if pv.Name == keepPV || pv.DeletionTimestamp != nil {
+			continue
+		}
+		if err := g.deletePersistentVolume(pv.Name); err != nil {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// podsUsingClaim returns the namespace/name of the pods which are not terminated and mount the PVC
+func (g *guestVolume) podsUsingClaim(namespace, name string) ([]string, error) {
+	pods, err := g.client.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods of namespace %s: %w", namespace, err)
+	}
+	var users []string
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == name {
+				users = append(users, pod.Namespace+"/"+pod.Name)
+				break
+			}
+		}
+	}
+	return users, nil
+}
+
+func (g *guestVolume) deletePersistentVolumeClaim(namespace, name string) error {
+	logrus.Infof("Delete PVC %s/%s of networkfilesystem export %s/%s in the guest cluster", namespace, name, g.export.Namespace, g.export.Name)
+	if err := g.client.CoreV1().PersistentVolumeClaims(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete PVC %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
+
+func (g *guestVolume) deletePersistentVolume(name string) error {
+	logrus.Infof("Delete PV %s of networkfilesystem export %s/%s in the guest cluster", name, g.export.Namespace, g.export.Name)
+	if err := g.client.CoreV1().PersistentVolumes().Delete(context.TODO(), name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete PV %s: %w", name, err)
+	}
+	return nil
+}
+
+func (g *guestVolume) constructPersistentVolume() *corev1.PersistentVolume {
+	spec := g.export.Spec.PersistentVolume
+	accessModes := spec.AccessModes
+	if len(accessModes) == 0 {
+		accessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}
+	}
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   spec.Name,
+			Labels: map[string]string{utils.LabelExportUID: string(g.export.UID)},
+		},
+		Spec: corev1.PersistentVolumeSpec{
+			Capacity: corev1.ResourceList{
+				corev1.ResourceStorage: spec.Capacity,
+			},
+			AccessModes: accessModes,
+			// the data belongs to the networkFS, it is never recycled by the guest cluster
+			PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimRetain,
+			StorageClassName:              spec.StorageClassName,
+			MountOptions:                  mountOptions(g.export, g.networkFS),
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				NFS: &corev1.NFSVolumeSource{
+					Server: g.networkFS.Status.Endpoint,
+					Path:   utils.NFSExportPath(g.networkFS),
+				},
+			},
+		},
+	}
+	// the PV is reserved for the managed PVC
+	if claim := g.export.Spec.PersistentVolumeClaim; claim != nil {
+		pv.Spec.ClaimRef = &corev1.ObjectReference{
+			Kind:       "PersistentVolumeClaim",
+			APIVersion: "v1",
+			Namespace:  claim.Namespace,
+			Name:       claim.Name,
+		}
+	}
+	return pv
+}
+
+func (g *guestVolume) constructPersistentVolumeClaim() *corev1.PersistentVolumeClaim {
+	spec := g.export.Spec.PersistentVolume
+	claim := g.export.Spec.PersistentVolumeClaim
+	accessModes := spec.AccessModes
+	if len(accessModes) == 0 {
+		accessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}
+	}
+	// the empty storage class disables the dynamic provisioning of the default class
+	storageClassName := spec.StorageClassName
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      claim.Name,
+			Namespace: claim.Namespace,
+			Labels:    map[string]string{utils.LabelExportUID: string(g.export.UID)},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: accessModes,
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: spec.Capacity,
+				},
+			},
+			VolumeName:       spec.Name,
+			StorageClassName: &storageClassName,
+		},
+	}
+}
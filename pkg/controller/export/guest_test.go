@@ -0,0 +1,509 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+const testExportUID = types.UID("3f2b8c1e-export")
+
+func testExport(pvName string, claim *networkfsv1.GuestPersistentVolumeClaim) *networkfsv1.NetworkFilesystemExport {
+	return &networkfsv1.NetworkFilesystemExport{
+		ObjectMeta: metav1.ObjectMeta{Name: "export-1", Namespace: "default", UID: testExportUID},
+		Spec: networkfsv1.NetworkFSExportSpec{
+			NetworkFilesystemName: "pvc-1234",
+			KubeconfigSecretRef:   corev1.LocalObjectReference{Name: "guest-kubeconfig"},
+			PersistentVolume: networkfsv1.GuestPersistentVolume{
+				Name:     pvName,
+				Capacity: resource.MustParse("10Gi"),
+			},
+			PersistentVolumeClaim: claim,
+		},
+	}
+}
+
+func testExportNetworkFS(endpoint string) *networkfsv1.NetworkFilesystem {
+	return &networkfsv1.NetworkFilesystem{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc-1234", Namespace: "default"},
+		Spec: networkfsv1.NetworkFSSpec{
+			NetworkFSName: "pvc-1234",
+			DesiredState:  networkfsv1.NetworkFSStateEnabled,
+			Service:       networkfsv1.ExportService{Type: corev1.ServiceTypeLoadBalancer},
+		},
+		Status: networkfsv1.NetworkFSStatus{
+			Endpoint:   endpoint,
+			Status:     networkfsv1.EndpointStatusReady,
+			MountOpts:  "vers=4.1,hard",
+			ExportPath: "/pvc-1234",
+		},
+	}
+}
+
+// testGuestPV returns the PV in the guest cluster, it is labelled with the UID of the export when it is managed
+func testGuestPV(name, server string, managed bool) *corev1.PersistentVolume {
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: corev1.PersistentVolumeSpec{
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				NFS: &corev1.NFSVolumeSource{Server: server, Path: "/pvc-1234"},
+			},
+		},
+	}
+	if managed {
+		pv.Labels = map[string]string{utils.LabelExportUID: string(testExportUID)}
+	}
+	return pv
+}
+
+func testGuestPVC(namespace, name, volumeName string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{utils.LabelExportUID: string(testExportUID)},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{VolumeName: volumeName},
+	}
+}
+
+// testGuestPod returns the running pod in the guest cluster which mounts the PVC
+func testGuestPod(namespace, name, claimName string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{{
+				Name: "data",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claimName},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+func TestGuestVolumeSync(t *testing.T) {
+	deleting := metav1.Now()
+	deletingPVC := testGuestPVC("apps", "data", "guest-pv")
+	deletingPVC.DeletionTimestamp = &deleting
+	deletingPVC.Finalizers = []string{"kubernetes.io/pvc-protection"}
+
+	tests := []struct {
+		name      string
+		export    *networkfsv1.NetworkFilesystemExport
+		endpoint  string
+		objects   []runtime.Object
+		wantErr   string
+		wantMsg   string
+		wantPVs   []string
+		wantPVCs  []string
+		wantEvent string
+		// the NFS server of the PV which is expected in the guest cluster
+		wantServer string
+	}{
+		{
+			name:       "provisions the PV and PVC to the empty guest cluster",
+			export:     testExport("guest-pv", &networkfsv1.GuestPersistentVolumeClaim{Namespace: "apps", Name: "data"}),
+			endpoint:   "192.168.1.10",
+			wantPVs:    []string{"guest-pv"},
+			wantPVCs:   []string{"apps/data"},
+			wantEvent:  utils.EventReasonGuestVolumeProvisioned,
+			wantServer: "192.168.1.10",
+		},
+		{
+			name:       "provisions only the PV without the claim",
+			export:     testExport("guest-pv", nil),
+			endpoint:   "192.168.1.10",
+			wantPVs:    []string{"guest-pv"},
+			wantEvent:  utils.EventReasonGuestVolumeProvisioned,
+			wantServer: "192.168.1.10",
+		},
+		{
+			name:       "keeps the PV of the current endpoint",
+			export:     testExport("guest-pv", &networkfsv1.GuestPersistentVolumeClaim{Namespace: "apps", Name: "data"}),
+			endpoint:   "192.168.1.10",
+			objects:    []runtime.Object{testGuestPV("guest-pv", "192.168.1.10", true), testGuestPVC("apps", "data", "guest-pv")},
+			wantPVs:    []string{"guest-pv"},
+			wantPVCs:   []string{"apps/data"},
+			wantServer: "192.168.1.10",
+		},
+		{
+			name:      "recreates the PV and PVC for the new endpoint",
+			export:    testExport("guest-pv", &networkfsv1.GuestPersistentVolumeClaim{Namespace: "apps", Name: "data"}),
+			endpoint:  "192.168.1.11",
+			objects:   []runtime.Object{testGuestPV("guest-pv", "192.168.1.10", true), testGuestPVC("apps", "data", "guest-pv")},
+			wantMsg:   "Recreating PV guest-pv for the endpoint 192.168.1.11",
+			wantEvent: utils.EventReasonGuestVolumeRecreating,
+		},
+		{
+			name:     "keeps the PV of the previous endpoint while the PVC is used",
+			export:   testExport("guest-pv", &networkfsv1.GuestPersistentVolumeClaim{Namespace: "apps", Name: "data"}),
+			endpoint: "192.168.1.11",
+			objects: []runtime.Object{
+				boundGuestPV("guest-pv", "192.168.1.10", "apps", "data"),
+				testGuestPVC("apps", "data", "guest-pv"),
+				testGuestPod("apps", "web-0", "data"),
+			},
+			wantMsg:    "is used by pods apps/web-0",
+			wantPVs:    []string{"guest-pv"},
+			wantPVCs:   []string{"apps/data"},
+			wantEvent:  utils.EventReasonGuestVolumeInUse,
+			wantServer: "192.168.1.10",
+		},
+		{
+			name:     "recreates the PV of the previous endpoint once the pods are completed",
+			export:   testExport("guest-pv", &networkfsv1.GuestPersistentVolumeClaim{Namespace: "apps", Name: "data"}),
+			endpoint: "192.168.1.11",
+			objects: []runtime.Object{
+				boundGuestPV("guest-pv", "192.168.1.10", "apps", "data"),
+				testGuestPVC("apps", "data", "guest-pv"),
+				completedGuestPod("apps", "job-0", "data"),
+			},
+			wantMsg:   "Recreating PV guest-pv for the endpoint 192.168.1.11",
+			wantEvent: utils.EventReasonGuestVolumeRecreating,
+		},
+		{
+			name:     "keeps the renamed PVC used by the pods",
+			export:   testExport("guest-pv-2", &networkfsv1.GuestPersistentVolumeClaim{Namespace: "apps", Name: "data-2"}),
+			endpoint: "192.168.1.10",
+			objects: []runtime.Object{
+				testGuestPV("guest-pv", "192.168.1.10", true),
+				testGuestPVC("apps", "data", "guest-pv"),
+				testGuestPod("apps", "web-0", "data"),
+			},
+			wantMsg:  "PVC apps/data is used by pods apps/web-0",
+			wantPVs:  []string{"guest-pv"},
+			wantPVCs: []string{"apps/data"},
+		},
+		{
+			name:       "removes the renamed PV and PVC",
+			export:     testExport("guest-pv-2", &networkfsv1.GuestPersistentVolumeClaim{Namespace: "apps", Name: "data-2"}),
+			endpoint:   "192.168.1.10",
+			objects:    []runtime.Object{testGuestPV("guest-pv", "192.168.1.10", true), testGuestPVC("apps", "data", "guest-pv")},
+			wantPVs:    []string{"guest-pv-2"},
+			wantPVCs:   []string{"apps/data-2"},
+			wantEvent:  utils.EventReasonGuestVolumeProvisioned,
+			wantServer: "192.168.1.10",
+		},
+		{
+			name:     "refuses to take over the PV of the guest cluster",
+			export:   testExport("guest-pv", nil),
+			endpoint: "192.168.1.10",
+			objects:  []runtime.Object{testGuestPV("guest-pv", "192.168.1.10", false)},
+			wantErr:  "is not managed by networkfilesystem export",
+		},
+		{
+			name:     "refuses the PVC bound to another PV",
+			export:   testExport("guest-pv", &networkfsv1.GuestPersistentVolumeClaim{Namespace: "apps", Name: "data"}),
+			endpoint: "192.168.1.10",
+			objects:  []runtime.Object{testGuestPV("guest-pv", "192.168.1.10", true), testGuestPVC("apps", "data", "other-pv")},
+			wantErr:  "is bound to PV other-pv instead of guest-pv",
+		},
+		{
+			name:     "waits for the PVC used by the pods",
+			export:   testExport("guest-pv", &networkfsv1.GuestPersistentVolumeClaim{Namespace: "apps", Name: "data"}),
+			endpoint: "192.168.1.10",
+			objects:  []runtime.Object{testGuestPV("guest-pv", "192.168.1.10", true), deletingPVC},
+			wantMsg:  "Waiting for PVC apps/data to be deleted",
+			wantPVs:  []string{"guest-pv"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(tt.objects...)
+			recorder := record.NewFakeRecorder(10)
+			guest := &guestVolume{client: client, export: tt.export, networkFS: testExportNetworkFS(tt.endpoint), recorder: recorder}
+
+			msg, err := guest.sync()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.Contains(msg, tt.wantMsg) || (tt.wantMsg == "" && msg != "") {
+				t.Errorf("expected message containing %q, got %q", tt.wantMsg, msg)
+			}
+
+			pvs, err := client.CoreV1().PersistentVolumes().List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("failed to list PVs: %v", err)
+			}
+			var pvNames []string
+			for _, pv := range pvs.Items {
+				pvNames = append(pvNames, pv.Name)
+				if tt.wantServer != "" && pv.Spec.NFS.Server != tt.wantServer {
+					t.Errorf("PV %s points at %s, expected %s", pv.Name, pv.Spec.NFS.Server, tt.wantServer)
+				}
+			}
+			pvcs, err := client.CoreV1().PersistentVolumeClaims(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("failed to list PVCs: %v", err)
+			}
+			var pvcNames []string
+			for _, pvc := range pvcs.Items {
+				if pvc.DeletionTimestamp == nil {
+					pvcNames = append(pvcNames, pvc.Namespace+"/"+pvc.Name)
+				}
+			}
+			sort.Strings(pvNames)
+			sort.Strings(pvcNames)
+			if !reflect.DeepEqual(pvNames, tt.wantPVs) {
+				t.Errorf("expected PVs %v, got %v", tt.wantPVs, pvNames)
+			}
+			if !reflect.DeepEqual(pvcNames, tt.wantPVCs) {
+				t.Errorf("expected PVCs %v, got %v", tt.wantPVCs, pvcNames)
+			}
+
+			select {
+			case event := <-recorder.Events:
+				if tt.wantEvent == "" || !strings.Contains(event, tt.wantEvent) {
+					t.Errorf("unexpected event %q, expected %q", event, tt.wantEvent)
+				}
+			default:
+				if tt.wantEvent != "" {
+					t.Errorf("expected event %q", tt.wantEvent)
+				}
+			}
+		})
+	}
+}
+
+func boundGuestPV(name, server, claimNamespace, claimName string) *corev1.PersistentVolume {
+	pv := testGuestPV(name, server, true)
+	pv.Spec.ClaimRef = &corev1.ObjectReference{Kind: "PersistentVolumeClaim", Namespace: claimNamespace, Name: claimName}
+	return pv
+}
+
+func completedGuestPod(namespace, name, claimName string) *corev1.Pod {
+	pod := testGuestPod(namespace, name, claimName)
+	pod.Status.Phase = corev1.PodSucceeded
+	return pod
+}
+
+func TestGuestVolumeSyncPersistentVolume(t *testing.T) {
+	export := testExport("guest-pv", &networkfsv1.GuestPersistentVolumeClaim{Namespace: "apps", Name: "data"})
+	export.Spec.PersistentVolume.MountOptions = "vers=4.2,soft"
+	client := fake.NewSimpleClientset(testGuestPV("guest-pv", "192.168.1.10", true))
+	guest := &guestVolume{client: client, export: export, networkFS: testExportNetworkFS("192.168.1.10"), recorder: record.NewFakeRecorder(10)}
+
+	if _, err := guest.syncPersistentVolume(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pv, err := client.CoreV1().PersistentVolumes().Get(context.TODO(), "guest-pv", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get PV: %v", err)
+	}
+	if want := []string{"vers=4.2", "soft"}; !reflect.DeepEqual(pv.Spec.MountOptions, want) {
+		t.Errorf("expected mount options %v, got %v", want, pv.Spec.MountOptions)
+	}
+	if want := []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}; !reflect.DeepEqual(pv.Spec.AccessModes, want) {
+		t.Errorf("expected access modes %v, got %v", want, pv.Spec.AccessModes)
+	}
+	if pv.Spec.ClaimRef != nil {
+		t.Errorf("the claimRef of the existing PV is immutable, got %v", pv.Spec.ClaimRef)
+	}
+}
+
+func TestGuestVolumeCleanup(t *testing.T) {
+	other := testGuestPV("other-pv", "10.0.0.1", false)
+	client := fake.NewSimpleClientset(
+		testGuestPV("guest-pv", "192.168.1.10", true),
+		testGuestPVC("apps", "data", "guest-pv"),
+		other,
+	)
+	guest := &guestVolume{client: client, export: testExport("guest-pv", nil), recorder: record.NewFakeRecorder(10)}
+
+	if pending, err := guest.cleanup("", ""); err != nil || pending != "" {
+		t.Fatalf("unexpected pending %q or error: %v", pending, err)
+	}
+	pvs, err := client.CoreV1().PersistentVolumes().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list PVs: %v", err)
+	}
+	if len(pvs.Items) != 1 || pvs.Items[0].Name != other.Name {
+		t.Errorf("expected only PV %s of the guest cluster to be kept, got %v", other.Name, pvs.Items)
+	}
+	pvcs, err := client.CoreV1().PersistentVolumeClaims(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list PVCs: %v", err)
+	}
+	if len(pvcs.Items) != 0 {
+		t.Errorf("expected the managed PVCs to be removed, got %v", pvcs.Items)
+	}
+}
+
+func TestGuestVolumeCleanupInUse(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		testGuestPV("guest-pv", "192.168.1.10", true),
+		testGuestPVC("apps", "data", "guest-pv"),
+		testGuestPod("apps", "web-0", "data"),
+	)
+	guest := &guestVolume{client: client, export: testExport("guest-pv", nil), recorder: record.NewFakeRecorder(10)}
+
+	pending, err := guest.cleanup("", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "PVC apps/data is used by pods apps/web-0"; !strings.Contains(pending, want) {
+		t.Errorf("expected pending message containing %q, got %q", want, pending)
+	}
+	if _, err := client.CoreV1().PersistentVolumeClaims("apps").Get(context.TODO(), "data", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the PVC used by the pod to be kept: %v", err)
+	}
+	if _, err := client.CoreV1().PersistentVolumes().Get(context.TODO(), "guest-pv", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the PV of the PVC used by the pod to be kept: %v", err)
+	}
+}
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+current-context: guest
+clusters:
+- name: guest
+  cluster:
+    server: https://192.168.1.100:6443
+    certificate-authority-data: Y2E=
+contexts:
+- name: guest
+  context:
+    cluster: guest
+    user: admin
+users:
+- name: admin
+  user:
+%s
+`
+
+func TestSanitizedRESTConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    string
+		cluster string
+		wantErr string
+	}{
+		{
+			name: "inline token",
+			user: "    token: secret-token",
+		},
+		{
+			name: "inline client certificate",
+			user: "    client-certificate-data: Y2VydA==\n    client-key-data: a2V5",
+		},
+		{
+			name:    "exec plugin",
+			user:    "    exec:\n      apiVersion: client.authentication.k8s.io/v1\n      command: /bin/sh",
+			wantErr: "runs the exec plugin",
+		},
+		{
+			name:    "auth provider",
+			user:    "    auth-provider:\n      name: oidc",
+			wantErr: "uses the auth provider",
+		},
+		{
+			name:    "token file",
+			user:    "    tokenFile: /var/run/secrets/kubernetes.io/serviceaccount/token",
+			wantErr: "refers to the token file",
+		},
+		{
+			name:    "client certificate files",
+			user:    "    client-certificate: /etc/kubernetes/pki/admin.crt\n    client-key: /etc/kubernetes/pki/admin.key",
+			wantErr: "refers to the client certificate files",
+		},
+		{
+			name:    "CA file",
+			user:    "    token: secret-token",
+			cluster: "certificate-authority: /etc/kubernetes/pki/ca.crt",
+			wantErr: "refers to the CA file",
+		},
+		{
+			name:    "TLS verification is skipped",
+			user:    "    token: secret-token",
+			cluster: "insecure-skip-tls-verify: true",
+			wantErr: "skips the TLS verification",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kubeconfig := fmt.Sprintf(testKubeconfig, tt.user)
+			if tt.cluster != "" {
+				kubeconfig = strings.Replace(kubeconfig, "certificate-authority-data: Y2E=", tt.cluster, 1)
+			}
+			config, err := sanitizedRESTConfig([]byte(kubeconfig))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if config.Host != "https://192.168.1.100:6443" || string(config.CAData) != "ca" {
+				t.Errorf("unexpected server %s or CA data %q", config.Host, config.CAData)
+			}
+			if config.ExecProvider != nil || config.AuthProvider != nil || config.BearerTokenFile != "" || config.CertFile != "" || config.KeyFile != "" {
+				t.Errorf("unexpected credentials outside of the kubeconfig %+v", config)
+			}
+		})
+	}
+}
+
+func TestCheckGuestReachable(t *testing.T) {
+	tests := []struct {
+		name    string
+		svcType corev1.ServiceType
+		wantErr string
+	}{
+		{
+			name:    "LoadBalancer service",
+			svcType: corev1.ServiceTypeLoadBalancer,
+		},
+		{
+			name:    "default service",
+			wantErr: "exported by the ClusterIP service which is not reachable from the guest cluster",
+		},
+		{
+			name:    "ClusterIP service",
+			svcType: corev1.ServiceTypeClusterIP,
+			wantErr: "exported by the ClusterIP service which is not reachable from the guest cluster",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			networkFS := testExportNetworkFS("10.53.0.10")
+			networkFS.Spec.Service.Type = tt.svcType
+			err := checkGuestReachable(networkFS)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
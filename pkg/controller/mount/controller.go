@@ -0,0 +1,363 @@
+package mount
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	ctlcorev1 "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/record"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/backend"
+	ctlntefsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/generated/controllers/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/mounter"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+// Controller is the node agent which mounts the networkFS onto the host paths declared by the NetworkFilesystemMount
+type Controller struct {
+	namespace string
+	nodeName  string
+	finalizer string
+	mounter   mounter.Mounter
+	recorder  record.EventRecorder
+
+	// the host paths must be below the prefix
+	hostPathPrefix string
+
+	NodeCache         ctlcorev1.NodeCache
+	MountCache        ctlntefsv1.NetworkFilesystemMountCache
+	Mounts            ctlntefsv1.NetworkFilesystemMountController
+	NetworkFSCache    ctlntefsv1.NetworkFilesystemCache
+	NetworkFilsystems ctlntefsv1.NetworkFilesystemController
+}
+
+const (
+	netFSMountHandlerName          = "harvester-netfs-mount-handler"
+	netFSMountNetworkFSHandlerName = "harvester-netfs-mount-networkfs-handler"
+	netFSMountNodeHandlerName      = "harvester-netfs-mount-node-handler"
+
+	// mountByNetworkFSIndex indexes the NetworkFilesystemMount with the namespace/name of the networkFS
+	mountByNetworkFSIndex = "networkfs.harvesterhci.io/mount-by-networkfs"
+	// the finalizer of the node which mounts the networkFS, it is removed once the node unmounts it
+	nodeFinalizerPrefix = "networkfs.harvesterhci.io/node-"
+
+	// the mounts are checked periodically, they may be unmounted out of band
+	resyncPeriod = time.Minute
+
+	nfsFSType = "nfs4"
+)
+
+// Register registers the node agent of the NetworkFilesystemMount, it runs on every node regardless of the leadership
+func Register(ctx context.Context, mounts ctlntefsv1.NetworkFilesystemMountController, netfilesystems ctlntefsv1.NetworkFilesystemController, nodes ctlcorev1.NodeController, m mounter.Mounter, recorder record.EventRecorder, opt *utils.Option) error {
+	if opt.NodeName == "" {
+		return fmt.Errorf("node name is required by the mount agent")
+	}
+
+	c := &Controller{
+		namespace:         opt.Namespace,
+		nodeName:          opt.NodeName,
+		finalizer:         nodeFinalizerPrefix + opt.NodeName,
+		mounter:           m,
+		recorder:          recorder,
+		hostPathPrefix:    opt.MountHostPathPrefix,
+		NodeCache:         nodes.Cache(),
+		Mounts:            mounts,
+		MountCache:        mounts.Cache(),
+		NetworkFilsystems: netfilesystems,
+		NetworkFSCache:    netfilesystems.Cache(),
+	}
+
+	c.MountCache.AddIndexer(mountByNetworkFSIndex, func(mount *networkfsv1.NetworkFilesystemMount) ([]string, error) {
+		return []string{mount.Namespace + "/" + mount.Spec.NetworkFilesystemName}, nil
+	})
+	c.Mounts.OnChange(ctx, netFSMountHandlerName, c.OnMountChange)
+	netfilesystems.OnChange(ctx, netFSMountNetworkFSHandlerName, c.OnNetworkFSChange)
+	nodes.OnChange(ctx, netFSMountNodeHandlerName, c.OnNodeChange)
+	return nil
+}
+
+// OnNetworkFSChange enqueues the mounts of the networkFS, e.g. its endpoint is ready or changed
+func (c *Controller) OnNetworkFSChange(_ string, networkFS *networkfsv1.NetworkFilesystem) (*networkfsv1.NetworkFilesystem, error) {
+	if networkFS == nil {
+		return nil, nil
+	}
+	mounts, err := c.MountCache.GetByIndex(mountByNetworkFSIndex, networkFS.Namespace+"/"+networkFS.Name)
+	if err != nil {
+		return nil, err
+	}
+	for _, mount := range mounts {
+		c.Mounts.Enqueue(mount.Namespace, mount.Name)
+	}
+	return c.remountStaleMounts(networkFS)
+}
+
+// OnNodeChange enqueues all mounts when the node of the agent changes, its labels may select other mounts
+func (c *Controller) OnNodeChange(_ string, node *corev1.Node) (*corev1.Node, error) {
+	if node == nil || node.Name != c.nodeName {
+		return nil, nil
+	}
+	mounts, err := c.MountCache.List("", labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, mount := range mounts {
+		c.Mounts.Enqueue(mount.Namespace, mount.Name)
+	}
+	return nil, nil
+}
+
+func (c *Controller) OnMountChange(_ string, mount *networkfsv1.NetworkFilesystemMount) (*networkfsv1.NetworkFilesystemMount, error) {
+	if mount == nil {
+		return nil, nil
+	}
+
+	selected, err := c.isSelected(mount)
+	if err != nil {
+		return nil, err
+	}
+	if mount.DeletionTimestamp != nil || !selected {
+		return c.release(mount)
+	}
+	logrus.Debugf("Handling networkfilesystem mount %s/%s change event", mount.Namespace, mount.Name)
+
+	// the webhook rejects the host path too, it is checked again in case the mount was created before the prefix
+	// changed or without the webhook
+	if err := utils.ValidateMountHostPath(mount.Spec.HostPath, c.hostPathPrefix); err != nil {
+		return c.unmount(mount, networkfsv1.MountStateFailed, err.Error())
+	}
+
+	networkFS, err := c.NetworkFSCache.Get(mount.Namespace, mount.Spec.NetworkFilesystemName)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	if apierrors.IsNotFound(err) {
+		return c.unmount(mount, networkfsv1.MountStatePending, fmt.Sprintf("NetworkFilesystem %s is not found", mount.Spec.NetworkFilesystemName))
+	}
+	if networkFS.Spec.DesiredState != networkfsv1.NetworkFSStateEnabled {
+		return c.unmount(mount, networkfsv1.MountStateUnmounted, fmt.Sprintf("NetworkFilesystem %s is disabled", networkFS.Name))
+	}
+	if protocol := backend.ProtocolOf(networkFS); protocol != networkfsv1.NetworkFSTypeNFS {
+		return c.updateNodeStatus(mount, networkfsv1.MountStateFailed, "", fmt.Sprintf("Protocol %s is not supported by the mount agent", protocol))
+	}
+	// the existing mount is kept, the clients reconnect to the stable address once the export is ready again
+	if networkFS.Status.Status != networkfsv1.EndpointStatusReady || networkFS.Status.Endpoint == "" {
+		return c.updateNodeStatus(mount, networkfsv1.MountStatePending, nodeSource(mount, c.nodeName), fmt.Sprintf("Waiting for the endpoint of NetworkFilesystem %s", networkFS.Name))
+	}
+
+	if !hasFinalizer(mount, c.finalizer) {
+		mountCpy := mount.DeepCopy()
+		mountCpy.Finalizers = append(mountCpy.Finalizers, c.finalizer)
+		return c.Mounts.Update(mountCpy)
+	}
+
+	source := utils.NFSSource(networkFS.Status.Endpoint, utils.NFSExportPath(networkFS))
+	if err := c.mount(mount, source, mountOptions(mount, networkFS)); err != nil {
+		logrus.Errorf("Failed to mount networkfilesystem %s to %s: %v", networkFS.Name, mount.Spec.HostPath, err)
+		c.recorder.Eventf(mount, corev1.EventTypeWarning, utils.EventReasonMountFailed, "Failed to mount %s to %s on node %s: %v", source, mount.Spec.HostPath, c.nodeName, err)
+		return c.updateNodeStatus(mount, networkfsv1.MountStateFailed, source, err.Error())
+	}
+	c.Mounts.EnqueueAfter(mount.Namespace, mount.Name, resyncPeriod)
+	return c.updateNodeStatus(mount, networkfsv1.MountStateMounted, source, "")
+}
+
+// mount makes sure the source is mounted to the host path, the host path is remounted if it is mounted from another source
+func (c *Controller) mount(mount *networkfsv1.NetworkFilesystemMount, source string, options []string) error {
+	mounts, err := c.mounter.List()
+	if err != nil {
+		return err
+	}
+	current := mounter.FindMount(mounts, mount.Spec.HostPath)
+	if current != nil && current.Source == source {
+		return nil
+	}
+	if current != nil && !current.IsNFS() {
+		return fmt.Errorf("host path %s is already mounted by %s %s", mount.Spec.HostPath, current.FSType, current.Source)
+	}
+	if current != nil {
+		logrus.Infof("Remount %s from %s, it is mounted from %s", mount.Spec.HostPath, source, current.Source)
+		// the previous server may be unreachable, the lazy unmount does not hang on it
+		if err := c.mounter.Unmount(mount.Spec.HostPath, true); err != nil {
+			return err
+		}
+		if err := c.mounter.Mount(source, mount.Spec.HostPath, nfsFSType, options); err != nil {
+			return err
+		}
+		c.recorder.Eventf(mount, corev1.EventTypeNormal, utils.EventReasonRemounted, "Remounted %s from %s on node %s, it was mounted from %s", mount.Spec.HostPath, source, c.nodeName, current.Source)
+		return nil
+	}
+
+	logrus.Infof("Mount %s to %s", source, mount.Spec.HostPath)
+	if err := c.mounter.Mount(source, mount.Spec.HostPath, nfsFSType, options); err != nil {
+		return err
+	}
+	c.recorder.Eventf(mount, corev1.EventTypeNormal, utils.EventReasonMounted, "Mounted %s to %s on node %s", source, mount.Spec.HostPath, c.nodeName)
+	return nil
+}
+
+// unmount unmounts the host path and reports the state, the finalizer is kept because the mount may be mounted again
+func (c *Controller) unmount(mount *networkfsv1.NetworkFilesystemMount, state networkfsv1.MountState, message string) (*networkfsv1.NetworkFilesystemMount, error) {
+	if hasFinalizer(mount, c.finalizer) {
+		if err := c.unmountHostPath(mount); err != nil {
+			return c.updateNodeStatus(mount, networkfsv1.MountStateFailed, nodeSource(mount, c.nodeName), err.Error())
+		}
+	}
+	return c.updateNodeStatus(mount, state, "", message)
+}
+
+// release unmounts the host path and drops the status and the finalizer of the node, the mount is deleted or the node is not selected
+func (c *Controller) release(mount *networkfsv1.NetworkFilesystemMount) (*networkfsv1.NetworkFilesystemMount, error) {
+	if hasFinalizer(mount, c.finalizer) {
+		if err := c.unmountHostPath(mount); err != nil {
+			return c.updateNodeStatus(mount, networkfsv1.MountStateFailed, nodeSource(mount, c.nodeName), err.Error())
+		}
+	}
+
+	if nodeStatus(mount, c.nodeName) != nil {
+		mountCpy := mount.DeepCopy()
+		mountCpy.Status.Nodes = removeNodeStatus(mountCpy.Status.Nodes, c.nodeName)
+		updated, err := c.Mounts.UpdateStatus(mountCpy)
+		if err != nil {
+			return nil, err
+		}
+		mount = updated
+	}
+
+	if hasFinalizer(mount, c.finalizer) {
+		mountCpy := mount.DeepCopy()
+		mountCpy.Finalizers = removeFinalizer(mountCpy.Finalizers, c.finalizer)
+		return c.Mounts.Update(mountCpy)
+	}
+	return nil, nil
+}
+
+func (c *Controller) unmountHostPath(mount *networkfsv1.NetworkFilesystemMount) error {
+	mounts, err := c.mounter.List()
+	if err != nil {
+		return err
+	}
+	current := mounter.FindMount(mounts, mount.Spec.HostPath)
+	if current == nil || !current.IsNFS() {
+		return nil
+	}
+
+	logrus.Infof("Unmount %s from %s", current.Source, mount.Spec.HostPath)
+	if err := c.mounter.Unmount(mount.Spec.HostPath, false); err != nil {
+		logrus.Warnf("Failed to unmount %s, fall back to the lazy unmount: %v", mount.Spec.HostPath, err)
+		if err := c.mounter.Unmount(mount.Spec.HostPath, true); err != nil {
+			c.recorder.Eventf(mount, corev1.EventTypeWarning, utils.EventReasonMountFailed, "Failed to unmount %s on node %s: %v", mount.Spec.HostPath, c.nodeName, err)
+			return err
+		}
+	}
+	c.recorder.Eventf(mount, corev1.EventTypeNormal, utils.EventReasonUnmounted, "Unmounted %s from %s on node %s", current.Source, mount.Spec.HostPath, c.nodeName)
+	return nil
+}
+
+func (c *Controller) isSelected(mount *networkfsv1.NetworkFilesystemMount) (bool, error) {
+	if len(mount.Spec.NodeSelector) == 0 {
+		return true, nil
+	}
+	node, err := c.NodeCache.Get(c.nodeName)
+	if err != nil {
+		return false, err
+	}
+	return labels.SelectorFromSet(mount.Spec.NodeSelector).Matches(labels.Set(node.Labels)), nil
+}
+
+// updateNodeStatus updates the status of the node, the transition time is kept if nothing changed
+func (c *Controller) updateNodeStatus(mount *networkfsv1.NetworkFilesystemMount, state networkfsv1.MountState, source, message string) (*networkfsv1.NetworkFilesystemMount, error) {
+	mountCpy := mount.DeepCopy()
+	status := networkfsv1.NodeMountStatus{
+		NodeName:           c.nodeName,
+		State:              state,
+		Source:             source,
+		Message:            message,
+		LastTransitionTime: metav1.Now(),
+	}
+	if cur := nodeStatus(mount, c.nodeName); cur != nil && cur.State == state && cur.Source == source && cur.Message == message {
+		status.LastTransitionTime = cur.LastTransitionTime
+	}
+	mountCpy.Status.Nodes = append(removeNodeStatus(mountCpy.Status.Nodes, c.nodeName), status)
+	sort.Slice(mountCpy.Status.Nodes, func(i, j int) bool {
+		return mountCpy.Status.Nodes[i].NodeName < mountCpy.Status.Nodes[j].NodeName
+	})
+
+	if reflect.DeepEqual(mount, mountCpy) {
+		return nil, nil
+	}
+	return c.Mounts.UpdateStatus(mountCpy)
+}
+
+func nodeStatus(mount *networkfsv1.NetworkFilesystemMount, nodeName string) *networkfsv1.NodeMountStatus {
+	for i := range mount.Status.Nodes {
+		if mount.Status.Nodes[i].NodeName == nodeName {
+			return &mount.Status.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// nodeSource returns the source which is reported by the node, it is kept while the mount is pending
+func nodeSource(mount *networkfsv1.NetworkFilesystemMount, nodeName string) string {
+	if status := nodeStatus(mount, nodeName); status != nil {
+		return status.Source
+	}
+	return ""
+}
+
+func removeNodeStatus(nodes []networkfsv1.NodeMountStatus, nodeName string) []networkfsv1.NodeMountStatus {
+	var ret []networkfsv1.NodeMountStatus
+	for _, node := range nodes {
+		if node.NodeName != nodeName {
+			ret = append(ret, node)
+		}
+	}
+	return ret
+}
+
+func hasFinalizer(mount *networkfsv1.NetworkFilesystemMount, finalizer string) bool {
+	for _, f := range mount.Finalizers {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+func removeFinalizer(finalizers []string, finalizer string) []string {
+	var ret []string
+	for _, f := range finalizers {
+		if f != finalizer {
+			ret = append(ret, f)
+		}
+	}
+	return ret
+}
+
+// mountOptions returns the allowed mount options with the access mode of the mount, nosuid and nodev are always set
+func mountOptions(mount *networkfsv1.NetworkFilesystemMount, networkFS *networkfsv1.NetworkFilesystem) []string {
+	opts := mount.Spec.MountOptions
+	if opts == "" {
+		opts = networkFS.Status.MountOpts
+	}
+	var options []string
+	for _, opt := range utils.NFSMountOptions(opts) {
+		if opt != "rw" && opt != "ro" {
+			options = append(options, opt)
+		}
+	}
+	if mount.Spec.ReadOnly {
+		options = append(options, "ro")
+	} else {
+		options = append(options, "rw")
+	}
+	return utils.RestrictNFSMountOptions(options)
+}
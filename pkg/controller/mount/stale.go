@@ -0,0 +1,193 @@
+package mount
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/backend"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/mounter"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+// the mounts of the kubelet are owned by the CSI driver, they are not remounted by the agent
+const kubeletRootDir = "/var/lib/kubelet/"
+
+// remountStaleMounts lazily unmounts the mounts of the networkFS which are still mounted from a previous address and
+// mounts them from the current endpoint, so the hard mounts do not hang on the server which is gone.
+func (c *Controller) remountStaleMounts(networkFS *networkfsv1.NetworkFilesystem) (*networkfsv1.NetworkFilesystem, error) {
+	if networkFS.DeletionTimestamp != nil || networkFS.Spec.DesiredState != networkfsv1.NetworkFSStateEnabled {
+		return nil, nil
+	}
+	if networkFS.Status.Status != networkfsv1.EndpointStatusReady || networkFS.Status.Endpoint == "" {
+		return nil, nil
+	}
+	if backend.ProtocolOf(networkFS) != networkfsv1.NetworkFSTypeNFS {
+		return nil, nil
+	}
+	// the mounts are checked periodically, they may be mounted out of band
+	defer c.NetworkFilsystems.EnqueueAfter(networkFS.Namespace, networkFS.Name, resyncPeriod)
+
+	mounts, err := c.mounter.List()
+	if err != nil {
+		return nil, err
+	}
+	previous, err := c.previousAddresses(networkFS)
+	if err != nil {
+		return nil, err
+	}
+	if len(previous) == 0 {
+		return nil, nil
+	}
+	managed, err := c.managedHostPaths(networkFS)
+	if err != nil {
+		return nil, err
+	}
+	stale := staleMounts(mounts, utils.NFSExportPath(networkFS), previous, managed)
+	if len(stale) == 0 {
+		return nil, nil
+	}
+
+	var mountPoints, failures []string
+	staleAddress := stale[0].NFSServer()
+	for _, m := range stale {
+		mountPoints = append(mountPoints, m.MountPoint)
+		if err := c.remount(networkFS, m); err != nil {
+			logrus.Errorf("Failed to remount stale mount %s of network filesystem %s: %v", m.MountPoint, networkFS.Name, err)
+			failures = append(failures, fmt.Sprintf("%s: %v", m.MountPoint, err))
+		}
+	}
+
+	result := networkfsv1.NodeRemountStatus{
+		NodeName:           c.nodeName,
+		Result:             networkfsv1.RemountResultRemounted,
+		MountPoints:        mountPoints,
+		StaleAddress:       staleAddress,
+		Message:            fmt.Sprintf("Remounted from %s", networkFS.Status.Endpoint),
+		LastTransitionTime: metav1.Now(),
+	}
+	if len(failures) > 0 {
+		result.Result = networkfsv1.RemountResultFailed
+		result.Message = strings.Join(failures, "; ")
+		c.recorder.Eventf(networkFS, corev1.EventTypeWarning, utils.EventReasonStaleMountRemountFailed, "Failed to remount the stale mounts of %s on node %s: %s", staleAddress, c.nodeName, result.Message)
+	} else {
+		c.recorder.Eventf(networkFS, corev1.EventTypeNormal, utils.EventReasonStaleMountRemounted, "Remounted %s on node %s from %s, they were mounted from %s", strings.Join(mountPoints, ", "), c.nodeName, networkFS.Status.Endpoint, staleAddress)
+	}
+	return c.updateRemountStatus(networkFS, result)
+}
+
+func (c *Controller) remount(networkFS *networkfsv1.NetworkFilesystem, m mounter.MountInfo) error {
+	source := utils.NFSSource(networkFS.Status.Endpoint, m.NFSPath())
+	logrus.Infof("Remount stale mount %s from %s, it is mounted from %s", m.MountPoint, source, m.Source)
+	if err := c.mounter.Unmount(m.MountPoint, true); err != nil {
+		return err
+	}
+	options := remountOptions(networkFS.Status.MountOpts, m.Options)
+	return c.mounter.Mount(source, m.MountPoint, m.FSType, options)
+}
+
+// previousAddresses returns the addresses the networkFS was served from before, the current addresses of all networkFS are
+// excluded as they may be reused by another export. The mounts of the other addresses are not created for the networkFS.
+func (c *Controller) previousAddresses(networkFS *networkfsv1.NetworkFilesystem) (map[string]bool, error) {
+	if len(networkFS.Status.PreviousAddresses) == 0 {
+		return nil, nil
+	}
+	networkFSes, err := c.NetworkFSCache.List("", labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	previous := map[string]bool{}
+	for _, address := range networkFS.Status.PreviousAddresses {
+		previous[address] = true
+	}
+	delete(previous, networkFS.Status.Endpoint)
+	delete(previous, networkFS.Status.ServerAddress)
+	// the export path is not unique among the servers, e.g. two Ganesha exports of the PVCs with the same name
+	for _, other := range networkFSes {
+		delete(previous, other.Status.Endpoint)
+		delete(previous, other.Status.ServerAddress)
+	}
+	return previous, nil
+}
+
+// managedHostPaths returns the host paths of the NetworkFilesystemMount of the networkFS, they are remounted by the mount controller
+func (c *Controller) managedHostPaths(networkFS *networkfsv1.NetworkFilesystem) (map[string]bool, error) {
+	mounts, err := c.MountCache.GetByIndex(mountByNetworkFSIndex, networkFS.Namespace+"/"+networkFS.Name)
+	if err != nil {
+		return nil, err
+	}
+	managed := map[string]bool{}
+	for _, mount := range mounts {
+		managed[mount.Spec.HostPath] = true
+	}
+	return managed, nil
+}
+
+// staleMounts returns the NFS mounts of the export path (or its sub directories) which are mounted from a previous address,
+// the mounts of any other server are left alone
+func staleMounts(mounts []mounter.MountInfo, exportPath string, previousAddresses, skippedMountPoints map[string]bool) []mounter.MountInfo {
+	var stale []mounter.MountInfo
+	exportPath = strings.TrimSuffix(exportPath, "/")
+	for _, m := range mounts {
+		if !m.IsNFS() || skippedMountPoints[m.MountPoint] || strings.HasPrefix(m.MountPoint, kubeletRootDir) {
+			continue
+		}
+		path := m.NFSPath()
+		if path != exportPath && !strings.HasPrefix(path, exportPath+"/") {
+			continue
+		}
+		if !previousAddresses[m.NFSServer()] {
+			continue
+		}
+		stale = append(stale, m)
+	}
+	return stale
+}
+
+// remountOptions returns the recommended options of the networkFS, the read-only mount is kept read-only
+func remountOptions(recommended, current string) []string {
+	var options []string
+	for _, opt := range utils.NFSMountOptions(recommended) {
+		if opt != "rw" && opt != "ro" {
+			options = append(options, opt)
+		}
+	}
+	for _, opt := range strings.Split(current, ",") {
+		if opt == "ro" {
+			return append(options, "ro")
+		}
+	}
+	return append(options, "rw")
+}
+
+// updateRemountStatus updates the remount result of the node, the transition time is kept if nothing changed
+func (c *Controller) updateRemountStatus(networkFS *networkfsv1.NetworkFilesystem, result networkfsv1.NodeRemountStatus) (*networkfsv1.NetworkFilesystem, error) {
+	networkFSCpy := networkFS.DeepCopy()
+	var remounts []networkfsv1.NodeRemountStatus
+	for _, remount := range networkFSCpy.Status.Remounts {
+		if remount.NodeName != c.nodeName {
+			remounts = append(remounts, remount)
+			continue
+		}
+		if remount.Result == result.Result && remount.Message == result.Message && remount.StaleAddress == result.StaleAddress && reflect.DeepEqual(remount.MountPoints, result.MountPoints) {
+			result.LastTransitionTime = remount.LastTransitionTime
+		}
+	}
+	remounts = append(remounts, result)
+	sort.Slice(remounts, func(i, j int) bool {
+		return remounts[i].NodeName < remounts[j].NodeName
+	})
+	networkFSCpy.Status.Remounts = remounts
+
+	if reflect.DeepEqual(networkFS, networkFSCpy) {
+		return nil, nil
+	}
+	return c.NetworkFilsystems.UpdateStatus(networkFSCpy)
+}
@@ -0,0 +1,125 @@
+package mount
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/mounter"
+)
+
+// the mounts of a node which mounted the export of pvc-1234 from the previous server 10.52.0.19
+const testMountInfo = `25 1 253:1 / / rw,relatime shared:1 - ext4 /dev/vda1 rw
+612 25 0:52 / /mnt/current rw,relatime shared:301 - nfs4 10.53.0.10:/pvc-1234 rw,vers=4.1,hard,addr=10.53.0.10
+613 25 0:53 / /mnt/stale rw,relatime shared:302 - nfs4 10.52.0.19:/pvc-1234 rw,vers=4.1,hard,addr=10.52.0.19
+614 25 0:54 / /mnt/stale-sub ro,relatime shared:303 - nfs4 10.52.0.19:/pvc-1234/data ro,vers=4.1,hard,addr=10.52.0.19
+615 25 0:55 / /mnt/external rw,relatime shared:304 - nfs4 192.168.1.50:/pvc-1234 rw,vers=4.1,hard,addr=192.168.1.50
+616 25 0:56 / /mnt/other-export rw,relatime shared:305 - nfs4 10.52.0.19:/pvc-12345 rw,vers=4.1,hard,addr=10.52.0.19
+617 25 0:57 / /mnt/managed rw,relatime shared:306 - nfs4 10.52.0.19:/pvc-1234 rw,vers=4.1,hard,addr=10.52.0.19
+618 25 0:58 / /var/lib/kubelet/pods/1f0c/volumes/kubernetes.io~csi/pvc-1234/mount rw,relatime - nfs4 10.52.0.19:/pvc-1234 rw,vers=4.1,addr=10.52.0.19
+619 25 0:59 / /mnt/cifs rw,relatime - cifs //10.52.0.19/pvc-1234 rw,vers=3.0
+`
+
+func TestStaleMounts(t *testing.T) {
+	mounts, err := mounter.ParseMountInfo(strings.NewReader(testMountInfo))
+	if err != nil {
+		t.Fatalf("failed to parse the mountinfo fixture: %v", err)
+	}
+
+	tests := []struct {
+		name               string
+		exportPath         string
+		previousAddresses  map[string]bool
+		skippedMountPoints map[string]bool
+		want               []string
+	}{
+		{
+			name:              "mounts of the previous address are stale",
+			exportPath:        "/pvc-1234",
+			previousAddresses: map[string]bool{"10.52.0.19": true},
+			want:              []string{"/mnt/stale", "/mnt/stale-sub", "/mnt/managed"},
+		},
+		{
+			name:               "mounts of the NetworkFilesystemMount are skipped",
+			exportPath:         "/pvc-1234",
+			previousAddresses:  map[string]bool{"10.52.0.19": true},
+			skippedMountPoints: map[string]bool{"/mnt/managed": true},
+			want:               []string{"/mnt/stale", "/mnt/stale-sub"},
+		},
+		{
+			name:              "export path with the trailing slash",
+			exportPath:        "/pvc-1234/",
+			previousAddresses: map[string]bool{"10.52.0.19": true},
+			want:              []string{"/mnt/stale", "/mnt/stale-sub", "/mnt/managed"},
+		},
+		{
+			name:       "external mounts are never stale without previous addresses",
+			exportPath: "/pvc-1234",
+		},
+		{
+			name:              "sub directory export",
+			exportPath:        "/pvc-1234/data",
+			previousAddresses: map[string]bool{"10.52.0.19": true},
+			want:              []string{"/mnt/stale-sub"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, m := range staleMounts(mounts, tt.exportPath, tt.previousAddresses, tt.skippedMountPoints) {
+				got = append(got, m.MountPoint)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected stale mounts %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRemountOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		recommended string
+		current     string
+		want        []string
+	}{
+		{
+			name:        "read-write mount",
+			recommended: "vers=4.1,hard,timeo=600",
+			current:     "rw,relatime",
+			want:        []string{"vers=4.1", "hard", "timeo=600", "rw"},
+		},
+		{
+			name:        "read-only mount stays read-only",
+			recommended: "rw,vers=4.1,hard",
+			current:     "ro,relatime",
+			want:        []string{"vers=4.1", "hard", "ro"},
+		},
+		{
+			name:        "access mode of the recommended options is dropped",
+			recommended: " ro , vers=4.2,",
+			current:     "rw",
+			want:        []string{"vers=4.2", "rw"},
+		},
+		{
+			name:    "no recommended options",
+			current: "relatime,ro",
+			want:    []string{"ro"},
+		},
+		{
+			name:        "option containing ro is not the read-only flag",
+			recommended: "vers=4.1",
+			current:     "rw,errors=remount-ro",
+			want:        []string{"vers=4.1", "rw"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := remountOptions(tt.recommended, tt.current); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected options %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
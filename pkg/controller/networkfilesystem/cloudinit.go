@@ -0,0 +1,87 @@
+package networkfilesystem
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/backend"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+const (
+	// CloudInitUserDataKey is the key of the whole cloud-config, it is the key referenced by the KubeVirt cloudInitNoCloud secretRef
+	CloudInitUserDataKey = "userdata"
+	// CloudInitMountsKey is the key of the cloud-init "mounts" snippet
+	CloudInitMountsKey = "mounts"
+	// CloudInitRunCmdKey is the key of the cloud-init "runcmd" snippet
+	CloudInitRunCmdKey = "runcmd"
+	// CloudInitFstabKey is the key of the /etc/fstab line
+	CloudInitFstabKey = "fstab"
+	// CloudInitMountPointKey is the key of the mount point in the VM
+	CloudInitMountPointKey = "mountPoint"
+
+	defaultNFSVersion = "vers=4.1"
+	// the VM boots even if the export is unreachable, and waits for the network before mounting it
+	cloudInitExtraMountOpts = "_netdev,nofail"
+
+	secretTypeCloudInit = "cloud-init"
+)
+
+// syncCloudInitSecret renders the cloud-init snippets which mount the ready export in the VMs,
+// the secret is kept when the export is disabled because the address of the managed service does not change.
+func (c *Controller) syncCloudInitSecret(networkFS *networkfsv1.NetworkFilesystem) error {
+	// the SMB share needs the credentials, which should not be copied to the VMs by us
+	if backend.ProtocolOf(networkFS) != networkfsv1.NetworkFSTypeNFS || networkFS.Status.Endpoint == "" {
+		return nil
+	}
+	return c.ensureSecret(constructCloudInitSecret(networkFS))
+}
+
+func constructCloudInitSecret(networkFS *networkfsv1.NetworkFilesystem) *corev1.Secret {
+	mountPoint := "/mnt/" + networkFS.Name
+	source := utils.NFSSource(networkFS.Status.Endpoint, utils.NFSExportPath(networkFS))
+	options := cloudInitMountOpts(networkFS.Status.MountOpts)
+
+	mounts := fmt.Sprintf("mounts:\n  - [ %s, %s, nfs, %s, \"0\", \"0\" ]\n",
+		strconv.Quote(source), strconv.Quote(mountPoint), strconv.Quote(options))
+	runcmd := fmt.Sprintf("runcmd:\n  - [ mkdir, -p, %s ]\n  - [ mount, -a ]\n", strconv.Quote(mountPoint))
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            utils.CloudInitSecretName(networkFS),
+			Namespace:       networkFS.Namespace,
+			Labels:          secretLabels(networkFS, secretTypeCloudInit),
+			OwnerReferences: utils.NetworkFSOwnerReferences(networkFS),
+		},
+		Type: corev1.SecretTypeOpaque,
+		StringData: map[string]string{
+			CloudInitUserDataKey:   "#cloud-config\n" + mounts + runcmd,
+			CloudInitMountsKey:     mounts,
+			CloudInitRunCmdKey:     runcmd,
+			CloudInitFstabKey:      fmt.Sprintf("%s %s nfs %s 0 0\n", source, mountPoint, options),
+			CloudInitMountPointKey: mountPoint,
+		},
+	}
+}
+
+// cloudInitMountOpts returns the recommended mount options with the NFS version, the default version of the
+// Longhorn share manager is used if the options do not specify it
+func cloudInitMountOpts(mountOpts string) string {
+	var options []string
+	hasVersion := false
+	for _, opt := range utils.NFSMountOptions(mountOpts) {
+		if strings.HasPrefix(opt, "vers=") || strings.HasPrefix(opt, "nfsvers=") {
+			hasVersion = true
+		}
+		options = append(options, opt)
+	}
+	if !hasVersion {
+		options = append([]string{defaultNFSVersion}, options...)
+	}
+	return strings.Join(append(options, cloudInitExtraMountOpts), ",")
+}
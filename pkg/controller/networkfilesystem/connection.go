@@ -0,0 +1,109 @@
+package networkfilesystem
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/backend"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+const (
+	// ConnectionServerKey is the key of the server address in the connection secret
+	ConnectionServerKey = "server"
+	// ConnectionPortKey is the key of the server port in the connection secret
+	ConnectionPortKey = "port"
+	// ConnectionPathKey is the key of the export path (or the SMB share) in the connection secret
+	ConnectionPathKey = "path"
+	// ConnectionProtocolKey is the key of the protocol in the connection secret
+	ConnectionProtocolKey = "protocol"
+	// ConnectionMountOptionsKey is the key of the recommended mount options in the connection secret
+	ConnectionMountOptionsKey = "mountOptions"
+	// ConnectionURIKey is the key of the URI (e.g. nfs://10.53.0.10:2049/pvc-xxx) in the connection secret
+	ConnectionURIKey = "uri"
+
+	secretTypeConnection = "connection"
+)
+
+// syncConnectionSecret writes the connection details of the ready export to the secret of spec.connectionSecretRef,
+// the connection secrets which are not referenced anymore are removed.
+func (c *Controller) syncConnectionSecret(networkFS *networkfsv1.NetworkFilesystem) error {
+	name := ""
+	if ref := networkFS.Spec.ConnectionSecretRef; ref != nil {
+		name = ref.Name
+	}
+	if err := c.cleanupConnectionSecrets(networkFS, name); err != nil {
+		return err
+	}
+	if name == "" || networkFS.Status.Endpoint == "" {
+		return nil
+	}
+	return c.ensureSecret(constructConnectionSecret(networkFS, name))
+}
+
+func (c *Controller) cleanupConnectionSecrets(networkFS *networkfsv1.NetworkFilesystem, keep string) error {
+	selector := labels.SelectorFromSet(secretLabels(networkFS, secretTypeConnection))
+	secrets, err := c.SecretCache.List(networkFS.Namespace, selector)
+	if err != nil {
+		return fmt.Errorf("failed to list connection secrets: %w", err)
+	}
+	for _, secret := range secrets {
+		if secret.Name == keep {
+			continue
+		}
+		logrus.Infof("Remove connection secret %s/%s which is not referenced by network filesystem %s", secret.Namespace, secret.Name, networkFS.Name)
+		if err := c.Secrets.Delete(secret.Namespace, secret.Name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete connection secret %s: %w", secret.Name, err)
+		}
+	}
+	return nil
+}
+
+func constructConnectionSecret(networkFS *networkfsv1.NetworkFilesystem, name string) *corev1.Secret {
+	protocol := backend.ProtocolOf(networkFS)
+	port := backend.PortOf(protocol)
+	server := networkFS.Status.Endpoint
+	path := networkFS.Status.ExportPath
+	uri := url.URL{
+		Scheme: "nfs",
+		Host:   net.JoinHostPort(server, strconv.Itoa(int(port))),
+		Path:   path,
+	}
+	if protocol == networkfsv1.NetworkFSTypeSMB {
+		// the share name is the last element of the UNC path, e.g. \\10.0.0.1\share
+		path = "/" + networkFS.Status.UNCPath[strings.LastIndex(networkFS.Status.UNCPath, `\`)+1:]
+		uri = url.URL{
+			Scheme: "smb",
+			Host:   net.JoinHostPort(server, strconv.Itoa(int(port))),
+			Path:   path,
+		}
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       networkFS.Namespace,
+			Labels:          secretLabels(networkFS, secretTypeConnection),
+			OwnerReferences: utils.NetworkFSOwnerReferences(networkFS),
+		},
+		Type: corev1.SecretTypeOpaque,
+		StringData: map[string]string{
+			ConnectionServerKey:       server,
+			ConnectionPortKey:         strconv.Itoa(int(port)),
+			ConnectionPathKey:         path,
+			ConnectionProtocolKey:     protocol,
+			ConnectionMountOptionsKey: networkFS.Status.MountOpts,
+			ConnectionURIKey:          uri.String(),
+		},
+	}
+}
@@ -4,17 +4,19 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"time"
 
-	longhornv2 "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
-	lhclientset "github.com/longhorn/longhorn-manager/k8s/pkg/client/clientset/versioned"
-	ctlv1 "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	ctlcorev1 "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
 	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
 
 	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/backend"
 	ctlntefsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/generated/controllers/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/metrics"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/placement"
 	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
 )
 
@@ -22,52 +24,90 @@ type Controller struct {
 	namespace string
 	nodeName  string
 
-	coreClient        ctlv1.Interface
-	lhClient          *lhclientset.Clientset
-	endpointsClient   ctlv1.EndpointsController
-	NetworkFSCache    ctlntefsv1.NetworkFilesystemCache
-	NetworkFilsystems ctlntefsv1.NetworkFilesystemController
+	recoveryRetryBudget int
+	teardownTimeout     time.Duration
+	backends            backend.Backends
+	placer              *placement.Placer
+	recorder            record.EventRecorder
+	NetworkFSCache      ctlntefsv1.NetworkFilesystemCache
+	NetworkFilsystems   ctlntefsv1.NetworkFilesystemController
+	ServiceCache        ctlcorev1.ServiceCache
+	Services            ctlcorev1.ServiceController
+	EndpointCache       ctlcorev1.EndpointsCache
+	Endpoints           ctlcorev1.EndpointsController
+	SecretCache         ctlcorev1.SecretCache
+	Secrets             ctlcorev1.SecretController
 }
 
 const (
-	netFSHandlerName = "harvester-network-filesystem-handler"
+	netFSHandlerName        = "harvester-network-filesystem-handler"
+	netFSServiceHandlerName = "harvester-network-filesystem-service-handler"
+	netFSSecretHandlerName  = "harvester-network-filesystem-secret-handler"
 )
 
-// Register register the longhorn node CRD controller
-func Register(ctx context.Context, coreClient ctlv1.Interface, lhClient *lhclientset.Clientset, endpoints ctlv1.EndpointsController, netfilesystems ctlntefsv1.NetworkFilesystemController, opt *utils.Option) error {
+// the number of the previous addresses kept in the status, the mounts of the older ones are not remounted
+const maxPreviousAddresses = 8
+
+// Register register the networkfilesystem CRD controller
+func Register(ctx context.Context, backends backend.Backends, placer *placement.Placer, netfilesystems ctlntefsv1.NetworkFilesystemController, services ctlcorev1.ServiceController, endpoints ctlcorev1.EndpointsController, secrets ctlcorev1.SecretController, recorder record.EventRecorder, opt *utils.Option) error {
 
 	c := &Controller{
-		namespace:         opt.Namespace,
-		nodeName:          opt.NodeName,
-		coreClient:        coreClient,
-		lhClient:          lhClient,
-		endpointsClient:   endpoints,
-		NetworkFilsystems: netfilesystems,
-		NetworkFSCache:    netfilesystems.Cache(),
+		namespace:           opt.Namespace,
+		nodeName:            opt.NodeName,
+		recoveryRetryBudget: opt.RecoveryRetryBudget,
+		teardownTimeout:     opt.TeardownTimeout,
+		backends:            backends,
+		placer:              placer,
+		recorder:            recorder,
+		NetworkFilsystems:   netfilesystems,
+		NetworkFSCache:      netfilesystems.Cache(),
+		Services:            services,
+		ServiceCache:        services.Cache(),
+		Endpoints:           endpoints,
+		EndpointCache:       endpoints.Cache(),
+		Secrets:             secrets,
+		SecretCache:         secrets.Cache(),
 	}
 
-	c.NetworkFilsystems.OnChange(ctx, netFSHandlerName, c.OnNetworkFSChange)
+	c.NetworkFSCache.AddIndexer(utils.NetworkFSByLHVolumeIndex, utils.IndexNetworkFSByLHVolume)
+	c.NetworkFSCache.AddIndexer(utils.NetworkFSByNodeIndex, utils.IndexNetworkFSByNode)
+	c.NetworkFilsystems.OnChange(ctx, netFSHandlerName, metrics.CountErrors("networkfilesystem", c.OnNetworkFSChange))
 	c.NetworkFilsystems.OnRemove(ctx, netFSHandlerName, c.OnNetworkFSDelete)
+	c.Services.OnChange(ctx, netFSServiceHandlerName, c.OnServiceChange)
+	c.Secrets.OnChange(ctx, netFSSecretHandlerName, c.OnSecretChange)
+	c.backends.Watch(ctx, c.NetworkFilsystems.Enqueue)
+	c.placer.Watch(ctx, c.enqueueByNode)
 	return nil
 }
 
-func (c *Controller) OnNetworkFSChange(_ string, networkFS *networkfsv1.NetworkFilesystem) (*networkfsv1.NetworkFilesystem, error) {
-	if networkFS == nil || networkFS.DeletionTimestamp != nil {
-		logrus.Infof("Skip this round because the network filesystem %s is deleting", networkFS.Name)
+// OnServiceChange enqueues the networkFS when its managed service changes (e.g. the LoadBalancer address is allocated)
+func (c *Controller) OnServiceChange(_ string, svc *corev1.Service) (*corev1.Service, error) {
+	if svc == nil || svc.DeletionTimestamp != nil {
 		return nil, nil
 	}
-	logrus.Infof("Handling network filesystem %s change event", networkFS.Name)
+	if name, found := svc.Labels[utils.LabelNetworkFSName]; found && svc.Labels[utils.LabelNetworkFSNamespace] == svc.Namespace {
+		c.NetworkFilsystems.Enqueue(svc.Namespace, name)
+	}
+	return nil, nil
+}
 
-	if networkFS.Spec.DesiredState == networkFS.Status.State {
-		logrus.Infof("Skip this round because the network filesystem %s is already in desired state %s", networkFS.Name, networkFS.Spec.DesiredState)
+func (c *Controller) OnNetworkFSChange(_ string, networkFS *networkfsv1.NetworkFilesystem) (*networkfsv1.NetworkFilesystem, error) {
+	if networkFS == nil || networkFS.DeletionTimestamp != nil {
+		logrus.Debugf("Skip this round because the network filesystem is deleted or deleting")
 		return nil, nil
 	}
+	logrus.Debugf("Handling network filesystem %s change event", networkFS.Name)
 
 	// Disabled -> Enabling -> Enabled -> Disabling -> Disabled
 	switch networkFS.Spec.DesiredState {
 	case networkfsv1.NetworkFSStateEnabled:
+		// keep observing the enabled networkFS, the endpoint may change
 		return c.enableNetworkFS(networkFS)
 	case networkfsv1.NetworkFSStateDisabled:
+		if networkFS.Status.State == networkfsv1.NetworkFSStateDisabled {
+			logrus.Debugf("Skip this round because the network filesystem %s is already in desired state %s", networkFS.Name, networkFS.Spec.DesiredState)
+			return nil, nil
+		}
 		return c.disableNetworkFS(networkFS)
 	default:
 		logrus.Errorf("Unknown desired state %s for network filesystem %s", networkFS.Spec.DesiredState, networkFS.Name)
@@ -77,216 +117,330 @@ func (c *Controller) OnNetworkFSChange(_ string, networkFS *networkfsv1.NetworkF
 }
 
 func (c *Controller) OnNetworkFSDelete(_ string, networkFS *networkfsv1.NetworkFilesystem) (*networkfsv1.NetworkFilesystem, error) {
-	if networkFS == nil || networkFS.DeletionTimestamp != nil {
-		logrus.Infof("Skip this round because the network filesystem %s is deleting", networkFS.Name)
+	if networkFS == nil {
 		return nil, nil
 	}
 	logrus.Infof("Handling network filesystem %s delete event", networkFS.Name)
 
+	if err := c.teardownNetworkFS(networkFS); err != nil {
+		return nil, err
+	}
+	metrics.Forget(networkFS)
 	return nil, nil
 }
 
 func (c *Controller) disableNetworkFS(networkFS *networkfsv1.NetworkFilesystem) (*networkfsv1.NetworkFilesystem, error) {
-	logrus.Infof("Disable network filesystem %s", networkFS.Name)
+	exportBackend, err := c.backends.Get(networkFS)
+	if err != nil {
+		return nil, err
+	}
 
 	if !isDisabling(networkFS) {
-		if err := c.updateLHVolumeAttachment(networkFS, false); err != nil {
+		logrus.Infof("Disable network filesystem %s", networkFS.Name)
+		if err := exportBackend.Disable(networkFS); err != nil {
+			c.recorder.Eventf(networkFS, corev1.EventTypeWarning, exportFailedReason(networkFS), "Failed to disable the export: %v", err)
 			return nil, err
 		}
+		c.recorder.Event(networkFS, corev1.EventTypeNormal, utils.EventReasonDisableRequested, "Disabling the export")
+		metrics.TransitionStarted(networkFS, metrics.TransitionDisable)
 		networkFSCpy := networkFS.DeepCopy()
 		networkFSCpy.Status.State = networkfsv1.NetworkFSStateDisabling
+		networkFSCpy.Status.ObservedGeneration = networkFS.Generation
 		if !reflect.DeepEqual(networkFS, networkFSCpy) {
 			return c.NetworkFilsystems.UpdateStatus(networkFSCpy)
 		}
+		return nil, nil
 	}
-	return nil, nil
+
+	exportStatus, err := exportBackend.Observe(networkFS)
+	if err != nil {
+		logrus.Errorf("Failed to observe network filesystem %s: %v", networkFS.Name, err)
+		return nil, err
+	}
+	if !exportStatus.Stopped {
+		logrus.Debugf("Wait for the export of network filesystem %s to stop", networkFS.Name)
+		return nil, nil
+	}
+	// keep the service (and its address) for the next enabling, only drop the stopped server
+	if _, err := c.syncService(networkFS, "", ""); err != nil {
+		return nil, err
+	}
+
+	networkFSCpy := networkFS.DeepCopy()
+	metrics.TransitionFinished(networkFS, metrics.TransitionDisable)
+	networkFSCpy.Status.State = networkfsv1.NetworkFSStateDisabled
+	networkFSCpy.Status.ObservedGeneration = networkFS.Generation
+	networkFSCpy.Status.Endpoint = ""
+	networkFSCpy.Status.Status = networkfsv1.EndpointStatusNotReady
+	networkFSCpy.Status.Type = backend.ProtocolOf(networkFS)
+	networkFSCpy.Status.MountOpts = ""
+	networkFSCpy.Status.UNCPath = ""
+	networkFSCpy.Status.AccessRules = nil
+	networkFSCpy.Status.ServerAddress = ""
+	networkFSCpy.Status.RecoveryAttempts = 0
+	networkFSCpy.Status.LastRecoveryTime = nil
+	networkFSCpy.Status.ExportPath = ""
+	networkFSCpy.Status.Health = nil
+	networkFSCpy.Status.Node = ""
+	abortMigration(networkFSCpy)
+	if hasCondition(networkFSCpy, networkfsv1.ConditionTypeHealthy) {
+		networkFSCpy.Status.NetworkFSConds = utils.UpdateNetworkFSConds(networkFSCpy.Status.NetworkFSConds, networkfsv1.NetworkFSCondition{
+			Type:               networkfsv1.ConditionTypeHealthy,
+			Status:             corev1.ConditionUnknown,
+			LastTransitionTime: metav1.Now(),
+			Reason:             "Export is disabled",
+		})
+	}
+	conds := networkfsv1.NetworkFSCondition{
+		Type:               networkfsv1.ConditionTypeNotReady,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             "Export is stopped",
+		Message:            exportStatus.Message,
+	}
+	networkFSCpy.Status.NetworkFSConds = utils.UpdateNetworkFSConds(networkFSCpy.Status.NetworkFSConds, conds)
+	rememberAddresses(networkFS, networkFSCpy)
+	logrus.Infof("Prepare to update networkfilesystem %+v", networkFSCpy)
+	updated, err := c.NetworkFilsystems.UpdateStatus(networkFSCpy)
+	if err == nil {
+		c.recorder.Event(networkFS, corev1.EventTypeNormal, utils.EventReasonDisabled, "Export is stopped")
+	}
+	return updated, err
 }
 
 func (c *Controller) enableNetworkFS(networkFS *networkfsv1.NetworkFilesystem) (*networkfsv1.NetworkFilesystem, error) {
-	logrus.Infof("Enable network filesystem %s", networkFS.Name)
-
-	// check endpoint status first
-	endpoint, err := c.endpointsClient.Get(utils.LHNameSpace, networkFS.Name, metav1.GetOptions{})
-	if err != nil && !errors.IsNotFound(err) {
-		logrus.Errorf("Failed to get endpoint %s: %v", networkFS.Name, err)
+	exportBackend, err := c.backends.Get(networkFS)
+	if err != nil {
 		return nil, err
 	}
-	if !isEnabling(networkFS) || errors.IsNotFound(err) || endpoint.Subsets == nil || len(endpoint.Subsets) == 0 || len(endpoint.Subsets[0].Addresses) == 0 {
-		logrus.Infof("Endpoint %s is not ready, update lhVA to trigger export endpoint", networkFS.Name)
-		if err := c.updateLHVolumeAttachment(networkFS, true); err != nil {
+
+	// the planned migration goes first, it moves the export in phases rather than at once
+	if updated, done, err := c.migrateNetworkFS(networkFS, exportBackend); done || err != nil {
+		return updated, err
+	}
+	// the node is chosen before the backend is driven, so the export is brought up (or moved) there
+	if updated, done, err := c.placeNetworkFS(networkFS, exportBackend); done || err != nil {
+		return updated, err
+	}
+
+	if !isEnabling(networkFS) && !isEnabled(networkFS) {
+		logrus.Infof("Enable network filesystem %s", networkFS.Name)
+		if err := exportBackend.Enable(networkFS); err != nil {
+			c.recorder.Eventf(networkFS, corev1.EventTypeWarning, exportFailedReason(networkFS), "Failed to enable the export: %v", err)
 			return nil, err
 		}
+		c.recorder.Eventf(networkFS, corev1.EventTypeNormal, utils.EventReasonEnableRequested, "Enabling the %s export with the %s backend", backend.ProtocolOf(networkFS), backend.TypeOf(networkFS))
+		metrics.TransitionStarted(networkFS, metrics.TransitionEnable)
 		networkFSCpy := networkFS.DeepCopy()
 		networkFSCpy.Status.State = networkfsv1.NetworkFSStateEnabling
+		networkFSCpy.Status.ObservedGeneration = networkFS.Generation
 		networkFSCpy.Status.Status = networkfsv1.EndpointStatusNotReady
-		networkFSCpy.Status.Type = networkfsv1.NetworkFSTypeNFS
+		networkFSCpy.Status.Type = backend.ProtocolOf(networkFS)
 		if !reflect.DeepEqual(networkFS, networkFSCpy) {
 			return c.NetworkFilsystems.UpdateStatus(networkFSCpy)
 		}
+		return nil, nil
 	}
 
-	// LH RWX volume endpoint should only have one address and one port
-	if len(endpoint.Subsets) > 1 || len(endpoint.Subsets[0].Addresses) > 1 || len(endpoint.Subsets[0].Ports) > 1 {
-		return nil, fmt.Errorf("endpoint %s has more than one subSets", networkFS.Name)
-	}
-	if endpoint.Subsets[0].Ports[0].Name != "nfs" {
-		return nil, fmt.Errorf("endpoint %s has no nfs port", networkFS.Name)
+	// the spec (e.g. access rules) is changed after the export is enabled, apply it to the backend
+	if networkFS.Status.ObservedGeneration != networkFS.Generation {
+		logrus.Infof("Spec of network filesystem %s is changed, update the export", networkFS.Name)
+		if err := exportBackend.Enable(networkFS); err != nil {
+			c.recorder.Eventf(networkFS, corev1.EventTypeWarning, exportFailedReason(networkFS), "Failed to update the export: %v", err)
+			return nil, err
+		}
 	}
 
-	pv, err := c.coreClient.PersistentVolume().Get(networkFS.Name, metav1.GetOptions{})
-	if err != nil && !errors.IsNotFound(err) {
-		logrus.Errorf("Failed to get persistent volume %s: %v", networkFS.Name, err)
+	exportStatus, err := exportBackend.Observe(networkFS)
+	if err != nil {
+		logrus.Errorf("Failed to observe network filesystem %s: %v", networkFS.Name, err)
 		return nil, err
 	}
-	opts := ""
-	if _, found := pv.Spec.CSI.VolumeAttributes["nfsOptions"]; found {
-		opts = pv.Spec.CSI.VolumeAttributes["nfsOptions"]
-	}
-	// update network filesystem status
-	networkFSCpy := networkFS.DeepCopy()
-	networkFSCpy.Status.Endpoint = endpoint.Subsets[0].Addresses[0].IP
-	networkFSCpy.Status.State = networkfsv1.NetworkFSStateEnabled
-	networkFSCpy.Status.Type = networkfsv1.NetworkFSTypeNFS
-	networkFSCpy.Status.Status = networkfsv1.EndpointStatusReady
-	networkFSCpy.Status.MountOpts = opts
-	conds := networkfsv1.NetworkFSCondition{
-		Type:               networkfsv1.ConditionTypeReady,
-		Status:             corev1.ConditionTrue,
-		LastTransitionTime: metav1.Now(),
-		Reason:             "Endpoint is ready",
-		Message:            "Endpoint contains the corresponding address",
+	if exportStatus.Failed {
+		return c.recoverNetworkFS(networkFS, exportBackend, exportStatus)
 	}
-	networkFSCpy.Status.NetworkFSConds = utils.UpdateNetworkFSConds(networkFSCpy.Status.NetworkFSConds, conds)
-	logrus.Infof("Prepare to update networkfilesystem %+v", networkFSCpy)
-	return c.NetworkFilsystems.UpdateStatus(networkFSCpy)
-}
-
-func (c *Controller) updateLHVolumeAttachment(networkFS *networkfsv1.NetworkFilesystem, attach bool) error {
-	logrus.Infof("Update Longhorn volume attachment for network filesystem %s, attach: %v", networkFS.Name, attach)
 
-	// get Longhorn volume attachment
-	lhva, err := c.lhClient.LonghornV1beta2().VolumeAttachments(utils.LHNameSpace).Get(context.Background(), networkFS.Name, metav1.GetOptions{})
+	// clients mount the stable address of the managed service, the server address behind it may change
+	ready, message, serverAddress, serverNode := exportStatus.Ready, exportStatus.Message, "", ""
+	if ready {
+		serverAddress, serverNode = exportStatus.Endpoint, exportStatus.Node
+	}
+	address, err := c.syncService(networkFS, serverAddress, serverNode)
 	if err != nil {
-		logrus.Errorf("Failed to get Longhorn volume attachment %s: %v", networkFS.Name, err)
-		return err
-	}
-
-	if attach {
-		return c.doAttachLHVolumeAttachment(networkFS, lhva)
-	}
-	return c.doDeattachLHVolumeAttachment(networkFS, lhva)
-	//lhvaCpy := lhva.DeepCopy()
-	//lhvaCpy.Spec.AttachmentTickets = map[string]*longhornv2.AttachmentTicket{}
-	//nodeID := ""
-	//if networkFS.Spec.PreferredNode != "" {
-	//	nodeID = networkFS.Spec.PreferredNode
-	//}
-	//csiTicketID := fmt.Sprintf("csi-%s", networkFS.Name)
-	//shareMgrTicketID := fmt.Sprintf("share-manager-controller-%s", networkFS.Name)
-
-	//// RWX volume should have two attachment tickets (CSI and share-manager)
-	//attachmentTicketCSI, ok := lhva.Spec.AttachmentTickets[csiTicketID]
-	//if !ok {
-	//	// Create new one
-	//	attachmentTicketCSI = &longhornv2.AttachmentTicket{
-	//		ID:     csiTicketID,
-	//		Type:   longhornv2.AttacherTypeCSIAttacher,
-	//		NodeID: nodeID,
-	//		Parameters: map[string]string{
-	//			longhornv2.AttachmentParameterDisableFrontend: "false",
-	//		},
-	//	}
-	//}
-	//lhvaCpy.Spec.AttachmentTickets[csiTicketID] = attachmentTicketCSI
-
-	//attachmentTicketSM, ok := lhva.Spec.AttachmentTickets[shareMgrTicketID]
-	//if !ok {
-	//	// Create new one
-	//	attachmentTicketSM = &longhornv2.AttachmentTicket{
-	//		ID:     shareMgrTicketID,
-	//		Type:   longhornv2.AttacherTypeShareManagerController,
-	//		NodeID: nodeID,
-	//		Parameters: map[string]string{
-	//			longhornv2.AttachmentParameterDisableFrontend: "false",
-	//		},
-	//	}
-	//}
-	//lhvaCpy.Spec.AttachmentTickets[shareMgrTicketID] = attachmentTicketSM
-
-	//if !reflect.DeepEqual(lhva, lhvaCpy) {
-	//	if _, err := c.lhClient.LonghornV1beta2().VolumeAttachments(utils.LHNameSpace).Update(context.Background(), lhvaCpy, metav1.UpdateOptions{}); err != nil {
-	//		logrus.Errorf("Failed to update Longhorn volume attachment %s: %v", networkFS.Name, err)
-	//		return err
-	//	}
-	//}
-
-	//return nil
-}
+		logrus.Errorf("Failed to sync service of network filesystem %s: %v", networkFS.Name, err)
+		return nil, err
+	}
+	if ready && address == "" {
+		ready = false
+		message = fmt.Sprintf("Waiting for the address of service %s", ServiceName(networkFS))
+	}
 
-func (c *Controller) doDeattachLHVolumeAttachment(networkFS *networkfsv1.NetworkFilesystem, lhva *longhornv2.VolumeAttachment) error {
-	lhvaCpy := lhva.DeepCopy()
-	lhvaCpy.Spec.AttachmentTickets = map[string]*longhornv2.AttachmentTicket{}
-	if !reflect.DeepEqual(lhva, lhvaCpy) {
-		if _, err := c.lhClient.LonghornV1beta2().VolumeAttachments(utils.LHNameSpace).Update(context.Background(), lhvaCpy, metav1.UpdateOptions{}); err != nil {
-			logrus.Errorf("Failed to update Longhorn volume attachment %s: %v", networkFS.Name, err)
-			return err
+	endpointChanged, endpointReady := false, false
+	var migrationReason, migrationMessage string
+	networkFSCpy := networkFS.DeepCopy()
+	networkFSCpy.Status.ObservedGeneration = networkFS.Generation
+	networkFSCpy.Status.Type = backend.ProtocolOf(networkFS)
+	if !ready {
+		if !exportStatus.Ready {
+			logrus.Infof("Endpoint of network filesystem %s is not ready, re-drive the export", networkFS.Name)
+			if err := exportBackend.Enable(networkFS); err != nil {
+				c.recorder.Eventf(networkFS, corev1.EventTypeWarning, exportFailedReason(networkFS), "Failed to re-drive the export: %v", err)
+				return nil, err
+			}
 		}
+		// the stable address is still published, the clients reconnect to it once the server is ready
+		networkFSCpy.Status.Endpoint = address
+		networkFSCpy.Status.ServerAddress = serverAddress
+		networkFSCpy.Status.UNCPath = ""
+		networkFSCpy.Status.Status = networkfsv1.EndpointStatusNotReady
+		networkFSCpy.Status.State = networkfsv1.NetworkFSStateEnabling
+		conds := networkfsv1.NetworkFSCondition{
+			Type:               networkfsv1.ConditionTypeNotReady,
+			Status:             corev1.ConditionTrue,
+			LastTransitionTime: metav1.Now(),
+			Reason:             "Endpoint is not ready",
+			Message:            message,
+		}
+		networkFSCpy.Status.NetworkFSConds = utils.UpdateNetworkFSConds(networkFSCpy.Status.NetworkFSConds, conds)
+	} else {
+		if networkFSCpy.Status.Endpoint != address {
+			changedMsg := "Endpoint address is initialized with " + address
+			if networkFSCpy.Status.Endpoint != "" {
+				changedMsg = "Endpoint address is changed, previous address is " + networkFSCpy.Status.Endpoint
+				endpointChanged = true
+			}
+			conds := networkfsv1.NetworkFSCondition{
+				Type:               networkfsv1.ConditionTypeEndpointChanged,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.Now(),
+				Reason:             "Endpoint is changed",
+				Message:            changedMsg,
+			}
+			networkFSCpy.Status.NetworkFSConds = utils.UpdateNetworkFSConds(networkFSCpy.Status.NetworkFSConds, conds)
+		}
+		networkFSCpy.Status.Endpoint = address
+		networkFSCpy.Status.ServerAddress = serverAddress
+		if isEnabling(networkFS) {
+			metrics.TransitionFinished(networkFS, metrics.TransitionEnable)
+			endpointReady = true
+		}
+		networkFSCpy.Status.State = networkfsv1.NetworkFSStateEnabled
+		networkFSCpy.Status.Status = networkfsv1.EndpointStatusReady
+		networkFSCpy.Status.MountOpts = exportStatus.MountOpts
+		networkFSCpy.Status.UNCPath = uncPath(address, exportStatus.ShareName)
+		networkFSCpy.Status.ExportPath = exportStatus.ExportPath
+		networkFSCpy.Status.AccessRules = networkFSCpy.Spec.AccessRules
+		networkFSCpy.Status.RecoveryAttempts = 0
+		networkFSCpy.Status.LastRecoveryTime = nil
+		conds := networkfsv1.NetworkFSCondition{
+			Type:               networkfsv1.ConditionTypeReady,
+			Status:             corev1.ConditionTrue,
+			LastTransitionTime: metav1.Now(),
+			Reason:             "Endpoint is ready",
+			Message:            message,
+		}
+		networkFSCpy.Status.NetworkFSConds = utils.UpdateNetworkFSConds(networkFSCpy.Status.NetworkFSConds, conds)
 	}
-	return nil
-}
 
-func (c *Controller) doAttachLHVolumeAttachment(networkFS *networkfsv1.NetworkFilesystem, lhva *longhornv2.VolumeAttachment) error {
-	lhvaCpy := lhva.DeepCopy()
-	lhvaCpy.Spec.AttachmentTickets = map[string]*longhornv2.AttachmentTicket{}
-	nodeID := ""
-	if networkFS.Spec.PreferredNode != "" {
-		nodeID = networkFS.Spec.PreferredNode
-	}
-	csiTicketID := fmt.Sprintf("csi-%s", networkFS.Name)
-	shareMgrTicketID := fmt.Sprintf("share-manager-controller-%s", networkFS.Name)
-
-	// RWX volume should have two attachment tickets (CSI and share-manager)
-	attachmentTicketCSI, ok := lhva.Spec.AttachmentTickets[csiTicketID]
-	if !ok {
-		// Create new one
-		attachmentTicketCSI = &longhornv2.AttachmentTicket{
-			ID:     csiTicketID,
-			Type:   longhornv2.AttacherTypeCSIAttacher,
-			NodeID: nodeID,
-			Parameters: map[string]string{
-				longhornv2.AttachmentParameterDisableFrontend: "false",
-			},
+	if ready {
+		// the consumers reference the secrets directly, keep them in sync with the ready status
+		if err := c.syncCloudInitSecret(networkFSCpy); err != nil {
+			logrus.Errorf("Failed to sync cloud-init secret of network filesystem %s: %v", networkFS.Name, err)
+			return nil, err
 		}
-	}
-	lhvaCpy.Spec.AttachmentTickets[csiTicketID] = attachmentTicketCSI
-
-	attachmentTicketSM, ok := lhva.Spec.AttachmentTickets[shareMgrTicketID]
-	if !ok {
-		// Create new one
-		attachmentTicketSM = &longhornv2.AttachmentTicket{
-			ID:     shareMgrTicketID,
-			Type:   longhornv2.AttacherTypeShareManagerController,
-			NodeID: nodeID,
-			Parameters: map[string]string{
-				longhornv2.AttachmentParameterDisableFrontend: "false",
-			},
+		if err := c.syncConnectionSecret(networkFSCpy); err != nil {
+			logrus.Errorf("Failed to sync connection secret of network filesystem %s: %v", networkFS.Name, err)
+			return nil, err
 		}
+		migrationReason, migrationMessage = completeMigration(networkFSCpy)
 	}
-	lhvaCpy.Spec.AttachmentTickets[shareMgrTicketID] = attachmentTicketSM
+	rememberAddresses(networkFS, networkFSCpy)
 
-	if !reflect.DeepEqual(lhva, lhvaCpy) {
-		if _, err := c.lhClient.LonghornV1beta2().VolumeAttachments(utils.LHNameSpace).Update(context.Background(), lhvaCpy, metav1.UpdateOptions{}); err != nil {
-			logrus.Errorf("Failed to update Longhorn volume attachment %s: %v", networkFS.Name, err)
-			return err
+	if !reflect.DeepEqual(networkFS, networkFSCpy) {
+		logrus.Infof("Prepare to update networkfilesystem %+v", networkFSCpy)
+		updated, err := c.NetworkFilsystems.UpdateStatus(networkFSCpy)
+		if err != nil {
+			return updated, err
+		}
+		if endpointChanged {
+			metrics.EndpointChanged(networkFS)
+			c.recorder.Eventf(networkFS, corev1.EventTypeNormal, utils.EventReasonEndpointChanged, "Endpoint address is changed from %s to %s", networkFS.Status.Endpoint, address)
+		}
+		if endpointReady {
+			c.recorder.Eventf(networkFS, corev1.EventTypeNormal, utils.EventReasonEndpointReady, "Endpoint %s is ready", address)
 		}
+		if migrationReason == utils.EventReasonMigrationCompleted {
+			c.recorder.Event(networkFS, corev1.EventTypeNormal, migrationReason, migrationMessage)
+		} else if migrationReason != "" {
+			c.recorder.Event(networkFS, corev1.EventTypeWarning, migrationReason, migrationMessage)
+		}
+		return updated, err
 	}
-	return nil
+	return nil, nil
+}
+
+// exportFailedReason returns the event reason of the failed export update, the Longhorn backend updates the volume attachment
+func exportFailedReason(networkFS *networkfsv1.NetworkFilesystem) string {
+	if backend.TypeOf(networkFS) == networkfsv1.ExportBackendLonghorn {
+		return utils.EventReasonAttachmentUpdateFailed
+	}
+	return utils.EventReasonExportUpdateFailed
 }
 
 func isEnabling(networkFS *networkfsv1.NetworkFilesystem) bool {
 	return networkFS.Status.State == networkfsv1.NetworkFSStateEnabling
 }
 
+func isEnabled(networkFS *networkfsv1.NetworkFilesystem) bool {
+	return networkFS.Status.State == networkfsv1.NetworkFSStateEnabled
+}
+
 func isDisabling(networkFS *networkfsv1.NetworkFilesystem) bool {
 	return networkFS.Status.State == networkfsv1.NetworkFSStateDisabling
 }
+
+func hasCondition(networkFS *networkfsv1.NetworkFilesystem, condType networkfsv1.ConditionType) bool {
+	for _, cond := range networkFS.Status.NetworkFSConds {
+		if cond.Type == condType {
+			return true
+		}
+	}
+	return false
+}
+
+func hasConditionReason(networkFS *networkfsv1.NetworkFilesystem, condType networkfsv1.ConditionType, reason string) bool {
+	for _, cond := range networkFS.Status.NetworkFSConds {
+		if cond.Type == condType {
+			return cond.Reason == reason
+		}
+	}
+	return false
+}
+
+// rememberAddresses records the endpoint and server address which are replaced in the copy of the networkFS,
+// the mount agents remount the mounts of them from the new endpoint
+func rememberAddresses(networkFS, networkFSCpy *networkfsv1.NetworkFilesystem) {
+	current := map[string]bool{networkFSCpy.Status.Endpoint: true, networkFSCpy.Status.ServerAddress: true}
+	var addresses []string
+	for _, address := range networkFS.Status.PreviousAddresses {
+		if !current[address] {
+			addresses = append(addresses, address)
+		}
+	}
+	for _, address := range []string{networkFS.Status.Endpoint, networkFS.Status.ServerAddress} {
+		if address == "" || current[address] {
+			continue
+		}
+		for i := range addresses {
+			if addresses[i] == address {
+				addresses = append(addresses[:i], addresses[i+1:]...)
+				break
+			}
+		}
+		addresses = append(addresses, address)
+	}
+	if len(addresses) > maxPreviousAddresses {
+		addresses = addresses[len(addresses)-maxPreviousAddresses:]
+	}
+	networkFSCpy.Status.PreviousAddresses = addresses
+}
@@ -0,0 +1,74 @@
+package networkfilesystem
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+)
+
+func testAddresses(endpoint, serverAddress string, previous ...string) *networkfsv1.NetworkFilesystem {
+	return &networkfsv1.NetworkFilesystem{
+		Status: networkfsv1.NetworkFSStatus{Endpoint: endpoint, ServerAddress: serverAddress, PreviousAddresses: previous},
+	}
+}
+
+func TestRememberAddresses(t *testing.T) {
+	var full []string
+	for i := 0; i < maxPreviousAddresses; i++ {
+		full = append(full, fmt.Sprintf("10.52.0.%d", i))
+	}
+
+	tests := []struct {
+		name string
+		old  *networkfsv1.NetworkFilesystem
+		new  *networkfsv1.NetworkFilesystem
+		want []string
+	}{
+		{
+			name: "unchanged addresses",
+			old:  testAddresses("10.53.0.10", "10.52.0.19"),
+			new:  testAddresses("10.53.0.10", "10.52.0.19"),
+		},
+		{
+			name: "rescheduled server",
+			old:  testAddresses("10.53.0.10", "10.52.0.19"),
+			new:  testAddresses("10.53.0.10", "10.52.0.20"),
+			want: []string{"10.52.0.19"},
+		},
+		{
+			name: "recreated service",
+			old:  testAddresses("10.53.0.10", "10.52.0.19", "10.52.0.18"),
+			new:  testAddresses("10.53.0.11", ""),
+			want: []string{"10.52.0.18", "10.53.0.10", "10.52.0.19"},
+		},
+		{
+			name: "reused address is no longer previous",
+			old:  testAddresses("10.53.0.10", "", "10.52.0.19", "10.52.0.18"),
+			new:  testAddresses("10.53.0.10", "10.52.0.19", "10.52.0.19", "10.52.0.18"),
+			want: []string{"10.52.0.18"},
+		},
+		{
+			name: "remembered address moves to the end",
+			old:  testAddresses("10.53.0.10", "10.52.0.18", "10.52.0.18", "10.52.0.17"),
+			new:  testAddresses("10.53.0.10", "10.52.0.19", "10.52.0.18", "10.52.0.17"),
+			want: []string{"10.52.0.17", "10.52.0.18"},
+		},
+		{
+			name: "oldest address is dropped",
+			old:  testAddresses("10.53.0.10", "10.52.0.100", full...),
+			new:  testAddresses("10.53.0.10", "", full...),
+			want: append(append([]string(nil), full[1:]...), "10.52.0.100"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rememberAddresses(tt.old, tt.new)
+			if !reflect.DeepEqual(tt.new.Status.PreviousAddresses, tt.want) {
+				t.Errorf("expected previous addresses %v, got %v", tt.want, tt.new.Status.PreviousAddresses)
+			}
+		})
+	}
+}
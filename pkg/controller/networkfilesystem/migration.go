@@ -0,0 +1,273 @@
+package networkfilesystem
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/backend"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+const (
+	// the interval of checking whether the server on the source node is stopped
+	migrationPollInterval = 5 * time.Second
+	// the export is attached on the target node anyway when the server on the source node did not stop in time
+	migrationDetachTimeout = 2 * time.Minute
+	// the migration which is not completed in time fails, the export is rolled back to the source node
+	migrationTimeout = 10 * time.Minute
+	// the interval before the migration to the same target node is tried again once it timed out
+	migrationRetryInterval = 10 * time.Minute
+
+	migrationTimedOutReason = "Migration timed out"
+)
+
+// migrateNetworkFS moves the ready export to the targetNode in phases. The EndpointChanging condition is announced
+// and the server on the source node is stopped (Detaching), then the export is brought up on the target node
+// (Attaching) and the rest of the reconciliation waits for the new endpoint, it completes the migration once the
+// managed service and secrets are updated. It returns done when the rest of the reconciliation should wait.
+func (c *Controller) migrateNetworkFS(networkFS *networkfsv1.NetworkFilesystem, exportBackend backend.ExportBackend) (*networkfsv1.NetworkFilesystem, bool, error) {
+	// e.g. the server on the source node is kept for the workloads, or the export never comes up on the target node
+	if migration := networkFS.Status.Migration; isMigrating(networkFS) && migration.StartTime != nil && time.Since(migration.StartTime.Time) >= migrationTimeout {
+		return c.rollbackMigration(networkFS, exportBackend)
+	}
+	if migration := networkFS.Status.Migration; migration != nil && migration.Phase == networkfsv1.MigrationPhaseDetaching {
+		return c.detachForMigration(networkFS, exportBackend)
+	}
+
+	target := networkFS.Spec.TargetNode
+	if migration := networkFS.Status.Migration; migration != nil && migration.Phase == networkfsv1.MigrationPhasePending && (target != migration.TargetNode || target == networkFS.Status.Node) {
+		// the target is changed or the export is already there, nothing is waiting for the node any more
+		networkFSCpy := networkFS.DeepCopy()
+		networkFSCpy.Status.Migration = nil
+		updated, err := c.NetworkFilsystems.UpdateStatus(networkFSCpy)
+		return updated, true, err
+	}
+	if target == "" || target == networkFS.Status.Node || isMigrating(networkFS) {
+		return nil, false, nil
+	}
+	if !isEnabled(networkFS) || networkFS.Status.Status != networkfsv1.EndpointStatusReady {
+		// the export which is not serving yet is simply brought up on the target node
+		return nil, false, nil
+	}
+	if wait := migrationBackoff(networkFS, time.Now()); wait > 0 {
+		// keep serving from the source node, placeNetworkFS does not move it to the target node either
+		c.NetworkFilsystems.EnqueueAfter(networkFS.Namespace, networkFS.Name, wait)
+		return nil, false, nil
+	}
+
+	if err := c.placer.Eligible(networkFS, target); err != nil {
+		// keep serving from the current node, the migration starts once the target node is eligible
+		c.NetworkFilsystems.EnqueueAfter(networkFS.Namespace, networkFS.Name, placementRetryInterval)
+		return c.pendMigration(networkFS, target, err)
+	}
+	return c.startMigration(networkFS, exportBackend, target)
+}
+
+func (c *Controller) pendMigration(networkFS *networkfsv1.NetworkFilesystem, target string, reason error) (*networkfsv1.NetworkFilesystem, bool, error) {
+	message := fmt.Sprintf("Waiting for node %s to host the export: %v", target, reason)
+	if migration := networkFS.Status.Migration; migration != nil && migration.TargetNode == target && migration.Phase == networkfsv1.MigrationPhasePending && migration.Message == message {
+		return nil, false, nil
+	}
+
+	networkFSCpy := networkFS.DeepCopy()
+	networkFSCpy.Status.Migration = &networkfsv1.MigrationStatus{
+		SourceNode: networkFS.Status.Node,
+		TargetNode: target,
+		Phase:      networkfsv1.MigrationPhasePending,
+		Message:    message,
+	}
+	updated, err := c.NetworkFilsystems.UpdateStatus(networkFSCpy)
+	if err != nil {
+		return nil, true, err
+	}
+	c.recorder.Event(networkFS, corev1.EventTypeWarning, utils.EventReasonMigrationPending, message)
+	return updated, true, nil
+}
+
+// startMigration announces the migration and stops the server on the source node, the clients are routed away from it first
+func (c *Controller) startMigration(networkFS *networkfsv1.NetworkFilesystem, exportBackend backend.ExportBackend, target string) (*networkfsv1.NetworkFilesystem, bool, error) {
+	source := networkFS.Status.Node
+	logrus.Infof("Migrate the export of network filesystem %s from node %q to %s", networkFS.Name, source, target)
+	address, err := c.syncService(networkFS, "", "")
+	if err != nil {
+		return nil, true, err
+	}
+	if err := exportBackend.Recover(networkFS); err != nil {
+		c.recorder.Eventf(networkFS, corev1.EventTypeWarning, exportFailedReason(networkFS), "Failed to stop the export for the migration: %v", err)
+		return nil, true, err
+	}
+
+	now := metav1.Now()
+	message := fmt.Sprintf("The export is migrating from node %q to %s, the endpoint is unavailable until it is ready there", source, target)
+	networkFSCpy := networkFS.DeepCopy()
+	networkFSCpy.Status.Endpoint = address
+	networkFSCpy.Status.ServerAddress = ""
+	rememberAddresses(networkFS, networkFSCpy)
+	networkFSCpy.Status.Status = networkfsv1.EndpointStatusNotReady
+	networkFSCpy.Status.Migration = &networkfsv1.MigrationStatus{
+		SourceNode: source,
+		TargetNode: target,
+		Phase:      networkfsv1.MigrationPhaseDetaching,
+		Message:    fmt.Sprintf("Stopping the server on node %q", source),
+		StartTime:  &now,
+	}
+	networkFSCpy.Status.NetworkFSConds = utils.UpdateNetworkFSConds(networkFSCpy.Status.NetworkFSConds, networkfsv1.NetworkFSCondition{
+		Type:               networkfsv1.ConditionTypeEndpointChanging,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: now,
+		Reason:             "Migrating",
+		Message:            message,
+	})
+	updated, err := c.NetworkFilsystems.UpdateStatus(networkFSCpy)
+	if err != nil {
+		return nil, true, err
+	}
+	c.recorder.Event(networkFS, corev1.EventTypeNormal, utils.EventReasonMigrationStarted, message)
+	c.NetworkFilsystems.EnqueueAfter(networkFS.Namespace, networkFS.Name, migrationPollInterval)
+	c.NetworkFilsystems.EnqueueAfter(networkFS.Namespace, networkFS.Name, migrationTimeout)
+	return updated, true, nil
+}
+
+// detachForMigration waits for the server on the source node to stop, then brings the export up on the target node
+func (c *Controller) detachForMigration(networkFS *networkfsv1.NetworkFilesystem, exportBackend backend.ExportBackend) (*networkfsv1.NetworkFilesystem, bool, error) {
+	migration := networkFS.Status.Migration
+	exportStatus, err := exportBackend.Observe(networkFS)
+	if err != nil {
+		logrus.Errorf("Failed to observe network filesystem %s: %v", networkFS.Name, err)
+		return nil, true, err
+	}
+
+	message := fmt.Sprintf("Waiting for the endpoint on node %s", migration.TargetNode)
+	if !exportStatus.Stopped {
+		if migration.StartTime != nil && time.Since(migration.StartTime.Time) < migrationDetachTimeout {
+			logrus.Debugf("Wait for the server of network filesystem %s on node %q to stop", networkFS.Name, migration.SourceNode)
+			c.NetworkFilsystems.EnqueueAfter(networkFS.Namespace, networkFS.Name, migrationPollInterval)
+			return nil, true, nil
+		}
+		logrus.Warnf("Server of network filesystem %s on node %q did not stop within %s, attach it on node %s anyway", networkFS.Name, migration.SourceNode, migrationDetachTimeout, migration.TargetNode)
+		message = fmt.Sprintf("The server on node %q did not stop within %s, waiting for the endpoint on node %s", migration.SourceNode, migrationDetachTimeout, migration.TargetNode)
+	}
+
+	now := metav1.Now()
+	networkFSCpy := networkFS.DeepCopy()
+	networkFSCpy.Status.Node = migration.TargetNode
+	networkFSCpy.Status.Migration.Phase = networkfsv1.MigrationPhaseAttaching
+	networkFSCpy.Status.Migration.Message = message
+	networkFSCpy.Status.Migration.DetachedTime = &now
+	if err := exportBackend.Enable(networkFSCpy); err != nil {
+		c.recorder.Eventf(networkFS, corev1.EventTypeWarning, exportFailedReason(networkFS), "Failed to bring the export up on node %s: %v", migration.TargetNode, err)
+		return nil, true, err
+	}
+	updated, err := c.NetworkFilsystems.UpdateStatus(networkFSCpy)
+	return updated, true, err
+}
+
+// rollbackMigration fails the migration which did not complete within migrationTimeout and brings the export up on
+// the source node again, the migration to the same target node is retried after migrationRetryInterval
+func (c *Controller) rollbackMigration(networkFS *networkfsv1.NetworkFilesystem, exportBackend backend.ExportBackend) (*networkfsv1.NetworkFilesystem, bool, error) {
+	migration := networkFS.Status.Migration
+	logrus.Warnf("Migration of network filesystem %s to node %s did not complete within %s, roll it back to node %q", networkFS.Name, migration.TargetNode, migrationTimeout, migration.SourceNode)
+
+	now := metav1.Now()
+	networkFSCpy := networkFS.DeepCopy()
+	networkFSCpy.Status.Node = migration.SourceNode
+	networkFSCpy.Status.Status = networkfsv1.EndpointStatusNotReady
+	if err := exportBackend.Enable(networkFSCpy); err != nil {
+		c.recorder.Eventf(networkFS, corev1.EventTypeWarning, exportFailedReason(networkFS), "Failed to bring the export back up on node %q: %v", migration.SourceNode, err)
+		return nil, true, err
+	}
+	networkFSCpy.Status.Migration.Phase = networkfsv1.MigrationPhaseFailed
+	networkFSCpy.Status.Migration.Message = fmt.Sprintf("The export was not served from node %s within %s, it is rolled back to node %q", migration.TargetNode, migrationTimeout, migration.SourceNode)
+	networkFSCpy.Status.NetworkFSConds = utils.UpdateNetworkFSConds(networkFSCpy.Status.NetworkFSConds, networkfsv1.NetworkFSCondition{
+		Type:               networkfsv1.ConditionTypeEndpointChanging,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: now,
+		Reason:             migrationTimedOutReason,
+		Message:            networkFSCpy.Status.Migration.Message,
+	})
+	updated, err := c.NetworkFilsystems.UpdateStatus(networkFSCpy)
+	if err != nil {
+		return nil, true, err
+	}
+	c.recorder.Event(networkFS, corev1.EventTypeWarning, utils.EventReasonMigrationFailed, networkFSCpy.Status.Migration.Message)
+	return updated, true, nil
+}
+
+// migrationBackoff returns how long the export waits before it is migrated to the targetNode again, it is zero
+// unless the last migration to the targetNode timed out within migrationRetryInterval
+func migrationBackoff(networkFS *networkfsv1.NetworkFilesystem, now time.Time) time.Duration {
+	migration := networkFS.Status.Migration
+	if migration == nil || migration.Phase != networkfsv1.MigrationPhaseFailed || migration.TargetNode != networkFS.Spec.TargetNode {
+		return 0
+	}
+	for _, cond := range networkFS.Status.NetworkFSConds {
+		if cond.Type == networkfsv1.ConditionTypeEndpointChanging && cond.Reason == migrationTimedOutReason {
+			if wait := cond.LastTransitionTime.Add(migrationRetryInterval).Sub(now); wait > 0 {
+				return wait
+			}
+		}
+	}
+	return 0
+}
+
+// completeMigration records the end of the migration on the ready networkFS, the managed service and secrets
+// are already updated with the new endpoint. It returns the event to record once the status is updated.
+func completeMigration(networkFS *networkfsv1.NetworkFilesystem) (string, string) {
+	migration := networkFS.Status.Migration
+	if migration == nil || migration.Phase != networkfsv1.MigrationPhaseAttaching {
+		return "", ""
+	}
+
+	now := metav1.Now()
+	migration.EndpointReadyTime = &now
+	migration.CompletionTime = &now
+	reason, eventReason := "Migrated", utils.EventReasonMigrationCompleted
+	migration.Phase = networkfsv1.MigrationPhaseCompleted
+	migration.Message = fmt.Sprintf("The export is served from node %s", migration.TargetNode)
+	if migration.StartTime != nil {
+		migration.Message = fmt.Sprintf("%s, the endpoint was unavailable for %s", migration.Message, now.Sub(migration.StartTime.Time).Round(time.Second))
+	}
+	if networkFS.Status.Node != migration.TargetNode {
+		// the target node failed while the export was attaching, it is served from another candidate
+		reason, eventReason = "Migration failed", utils.EventReasonMigrationFailed
+		migration.Phase = networkfsv1.MigrationPhaseFailed
+		migration.CompletionTime = nil
+		migration.Message = fmt.Sprintf("The export is served from node %q instead of node %s", networkFS.Status.Node, migration.TargetNode)
+	}
+	networkFS.Status.NetworkFSConds = utils.UpdateNetworkFSConds(networkFS.Status.NetworkFSConds, networkfsv1.NetworkFSCondition{
+		Type:               networkfsv1.ConditionTypeEndpointChanging,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: now,
+		Reason:             reason,
+		Message:            migration.Message,
+	})
+	return eventReason, migration.Message
+}
+
+// abortMigration fails the unfinished migration of the disabled networkFS
+func abortMigration(networkFS *networkfsv1.NetworkFilesystem) {
+	if !isMigrating(networkFS) {
+		return
+	}
+	migration := networkFS.Status.Migration
+	migration.Phase = networkfsv1.MigrationPhaseFailed
+	migration.Message = "The network filesystem is disabled before the migration is completed"
+	networkFS.Status.NetworkFSConds = utils.UpdateNetworkFSConds(networkFS.Status.NetworkFSConds, networkfsv1.NetworkFSCondition{
+		Type:               networkfsv1.ConditionTypeEndpointChanging,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             "Migration failed",
+		Message:            migration.Message,
+	})
+}
+
+// isMigrating returns whether the export is moving between nodes
+func isMigrating(networkFS *networkfsv1.NetworkFilesystem) bool {
+	migration := networkFS.Status.Migration
+	return migration != nil && (migration.Phase == networkfsv1.MigrationPhaseDetaching || migration.Phase == networkfsv1.MigrationPhaseAttaching)
+}
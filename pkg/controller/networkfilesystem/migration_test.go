@@ -0,0 +1,70 @@
+package networkfilesystem
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+)
+
+func testFailedMigration(specTarget, migrationTarget, reason string, failedAt time.Time) *networkfsv1.NetworkFilesystem {
+	return &networkfsv1.NetworkFilesystem{
+		Spec: networkfsv1.NetworkFSSpec{TargetNode: specTarget},
+		Status: networkfsv1.NetworkFSStatus{
+			Node: "node-1",
+			Migration: &networkfsv1.MigrationStatus{
+				SourceNode: "node-1",
+				TargetNode: migrationTarget,
+				Phase:      networkfsv1.MigrationPhaseFailed,
+			},
+			NetworkFSConds: []networkfsv1.NetworkFSCondition{{
+				Type:               networkfsv1.ConditionTypeEndpointChanging,
+				Status:             corev1.ConditionFalse,
+				LastTransitionTime: metav1.NewTime(failedAt),
+				Reason:             reason,
+			}},
+		},
+	}
+}
+
+func TestMigrationBackoff(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		networkFS *networkfsv1.NetworkFilesystem
+		want      time.Duration
+	}{
+		{
+			name:      "timed out migration to the same target",
+			networkFS: testFailedMigration("node-2", "node-2", migrationTimedOutReason, now.Add(-time.Minute)),
+			want:      migrationRetryInterval - time.Minute,
+		},
+		{
+			name:      "retry interval passed",
+			networkFS: testFailedMigration("node-2", "node-2", migrationTimedOutReason, now.Add(-migrationRetryInterval)),
+		},
+		{
+			name:      "target node is changed",
+			networkFS: testFailedMigration("node-3", "node-2", migrationTimedOutReason, now.Add(-time.Minute)),
+		},
+		{
+			name:      "migration failed for another reason",
+			networkFS: testFailedMigration("node-2", "node-2", "Migration failed", now.Add(-time.Minute)),
+		},
+		{
+			name:      "no migration",
+			networkFS: &networkfsv1.NetworkFilesystem{Spec: networkfsv1.NetworkFSSpec{TargetNode: "node-2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := migrationBackoff(tt.networkFS, now); got != tt.want {
+				t.Errorf("expected backoff %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
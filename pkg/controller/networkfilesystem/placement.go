@@ -0,0 +1,95 @@
+package networkfilesystem
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/backend"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+// the interval of choosing the node again while none of the candidates is eligible
+const placementRetryInterval = 30 * time.Second
+
+const noEligibleNodeReason = "No eligible node"
+
+// enqueueByNode enqueues the networkFS hosted on the node, its export is moved when the node is no longer eligible
+func (c *Controller) enqueueByNode(node string) {
+	networkFSes, err := c.NetworkFSCache.GetByIndex(utils.NetworkFSByNodeIndex, node)
+	if err != nil {
+		logrus.Errorf("Failed to get network filesystems on node %s: %v", node, err)
+		return
+	}
+	for _, networkFS := range networkFSes {
+		c.NetworkFilsystems.Enqueue(networkFS.Namespace, networkFS.Name)
+	}
+}
+
+// placeNetworkFS chooses the node which hosts the export and records it in the status, the export of the enabled
+// networkFS is moved to the new node at once. It returns done when the status is updated (or should not go further),
+// the networkFS is handled again with the updated status.
+func (c *Controller) placeNetworkFS(networkFS *networkfsv1.NetworkFilesystem, exportBackend backend.ExportBackend) (*networkfsv1.NetworkFilesystem, bool, error) {
+	node, err := c.placer.Select(networkFS)
+	if err != nil {
+		c.NetworkFilsystems.EnqueueAfter(networkFS.Namespace, networkFS.Name, placementRetryInterval)
+		if isEnabling(networkFS) || isEnabled(networkFS) {
+			// keep the running export where it is rather than stopping it for nothing
+			logrus.Warnf("Keep the export of network filesystem %s on node %q: %v", networkFS.Name, networkFS.Status.Node, err)
+			return nil, false, nil
+		}
+
+		if !hasConditionReason(networkFS, networkfsv1.ConditionTypeNotReady, noEligibleNodeReason) {
+			c.recorder.Event(networkFS, corev1.EventTypeWarning, utils.EventReasonNoEligibleNode, err.Error())
+		}
+		networkFSCpy := networkFS.DeepCopy()
+		networkFSCpy.Status.NetworkFSConds = utils.UpdateNetworkFSConds(networkFSCpy.Status.NetworkFSConds, networkfsv1.NetworkFSCondition{
+			Type:               networkfsv1.ConditionTypeNotReady,
+			Status:             corev1.ConditionTrue,
+			LastTransitionTime: metav1.Now(),
+			Reason:             noEligibleNodeReason,
+			Message:            err.Error(),
+		})
+		if reflect.DeepEqual(networkFS, networkFSCpy) {
+			return nil, true, nil
+		}
+		updated, err := c.NetworkFilsystems.UpdateStatus(networkFSCpy)
+		return updated, true, err
+	}
+	if node == networkFS.Status.Node {
+		return nil, false, nil
+	}
+	if node == networkFS.Spec.TargetNode && networkFS.Status.Node != "" && migrationBackoff(networkFS, time.Now()) > 0 {
+		// the migration to the targetNode timed out, the export stays on the node it was rolled back to for a while
+		return nil, false, nil
+	}
+
+	previous := networkFS.Status.Node
+	networkFSCpy := networkFS.DeepCopy()
+	networkFSCpy.Status.Node = node
+	if isEnabling(networkFS) || isEnabled(networkFS) {
+		logrus.Infof("Move the export of network filesystem %s from node %q to %q", networkFS.Name, previous, node)
+		if err := exportBackend.Enable(networkFSCpy); err != nil {
+			c.recorder.Eventf(networkFS, corev1.EventTypeWarning, exportFailedReason(networkFS), "Failed to move the export to node %s: %v", node, err)
+			return nil, true, err
+		}
+		if previous != "" && node != "" {
+			c.recorder.Eventf(networkFS, corev1.EventTypeNormal, utils.EventReasonNodeFailover, "Moved the export from node %s to %s: %s", previous, node, c.leaveReason(networkFS, previous))
+		}
+	}
+	updated, err := c.NetworkFilsystems.UpdateStatus(networkFSCpy)
+	return updated, true, err
+}
+
+// leaveReason returns why the export left the node
+func (c *Controller) leaveReason(networkFS *networkfsv1.NetworkFilesystem, node string) string {
+	if err := c.placer.Eligible(networkFS, node); err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("node %s is no longer a candidate", node)
+}
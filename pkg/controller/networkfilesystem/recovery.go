@@ -0,0 +1,93 @@
+package networkfilesystem
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/backend"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+const (
+	recoveryBaseBackoff = 10 * time.Second
+	recoveryMaxBackoff  = 5 * time.Minute
+)
+
+// recoverNetworkFS marks the failed export as not ready and re-drives it with exponential backoff,
+// it gives up when the retry budget is exhausted and leaves the export Enabled with the endpoint NotReady,
+// until the networkFS is disabled and enabled again.
+func (c *Controller) recoverNetworkFS(networkFS *networkfsv1.NetworkFilesystem, exportBackend backend.ExportBackend, exportStatus *backend.ExportStatus) (*networkfsv1.NetworkFilesystem, error) {
+	// the server is gone, do not route the clients to it
+	address, err := c.syncService(networkFS, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	networkFSCpy := networkFS.DeepCopy()
+	networkFSCpy.Status.ObservedGeneration = networkFS.Generation
+	networkFSCpy.Status.Endpoint = address
+	networkFSCpy.Status.ServerAddress = ""
+	rememberAddresses(networkFS, networkFSCpy)
+	networkFSCpy.Status.Status = networkfsv1.EndpointStatusNotReady
+	networkFSCpy.Status.State = networkfsv1.NetworkFSStateEnabling
+
+	attempts := networkFS.Status.RecoveryAttempts
+	reason := "Export is failed"
+	message := exportStatus.Message
+	if int(attempts) >= c.recoveryRetryBudget {
+		reason = "Recovery retry budget is exhausted"
+		message = fmt.Sprintf("%s, gave up after %d recovery attempts, disable and enable the network filesystem to retry", exportStatus.Message, attempts)
+		// the transition is over, the export stays Enabled with the endpoint NotReady until the user disables it
+		networkFSCpy.Status.State = networkfsv1.NetworkFSStateEnabled
+		// only record it once, the recovery attempts report the other reason before it is exhausted again
+		if !hasConditionReason(networkFS, networkfsv1.ConditionTypeNotReady, reason) {
+			c.recorder.Event(networkFS, corev1.EventTypeWarning, utils.EventReasonRecoveryExhausted, message)
+		}
+	} else if wait := recoveryBackoff(networkFS.Status.LastRecoveryTime, attempts); wait > 0 {
+		logrus.Debugf("Wait %v for the next recovery of network filesystem %s", wait, networkFS.Name)
+		c.NetworkFilsystems.EnqueueAfter(networkFS.Namespace, networkFS.Name, wait)
+	} else {
+		logrus.Warnf("Export of network filesystem %s is failed, recover it (attempt %d/%d): %s", networkFS.Name, attempts+1, c.recoveryRetryBudget, exportStatus.Message)
+		if err := exportBackend.Recover(networkFS); err != nil {
+			c.recorder.Eventf(networkFS, corev1.EventTypeWarning, exportFailedReason(networkFS), "Failed to recover the export: %v", err)
+			return nil, err
+		}
+		c.recorder.Eventf(networkFS, corev1.EventTypeWarning, utils.EventReasonRecovering, "Export is failed, recovery attempt %d/%d: %s", attempts+1, c.recoveryRetryBudget, exportStatus.Message)
+		now := metav1.Now()
+		networkFSCpy.Status.RecoveryAttempts = attempts + 1
+		networkFSCpy.Status.LastRecoveryTime = &now
+		message = fmt.Sprintf("%s, recovery attempt %d/%d", exportStatus.Message, attempts+1, c.recoveryRetryBudget)
+	}
+
+	conds := networkfsv1.NetworkFSCondition{
+		Type:               networkfsv1.ConditionTypeNotReady,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	}
+	networkFSCpy.Status.NetworkFSConds = utils.UpdateNetworkFSConds(networkFSCpy.Status.NetworkFSConds, conds)
+	if !reflect.DeepEqual(networkFS, networkFSCpy) {
+		logrus.Infof("Prepare to update networkfilesystem %+v", networkFSCpy)
+		return c.NetworkFilsystems.UpdateStatus(networkFSCpy)
+	}
+	return nil, nil
+}
+
+// recoveryBackoff returns how long to wait before the next recovery attempt, it doubles on every attempt
+func recoveryBackoff(lastRecoveryTime *metav1.Time, attempts int32) time.Duration {
+	if lastRecoveryTime == nil || attempts == 0 {
+		return 0
+	}
+	backoff := recoveryMaxBackoff
+	if attempts < 16 {
+		backoff = min(recoveryBaseBackoff<<(attempts-1), recoveryMaxBackoff)
+	}
+	return time.Until(lastRecoveryTime.Add(backoff))
+}
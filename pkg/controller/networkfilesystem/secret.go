@@ -0,0 +1,72 @@
+package networkfilesystem
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+// OnSecretChange enqueues the networkFS when its managed secret changes, so the manual changes are reverted
+func (c *Controller) OnSecretChange(_ string, secret *corev1.Secret) (*corev1.Secret, error) {
+	if secret == nil || secret.DeletionTimestamp != nil {
+		return nil, nil
+	}
+	if name, found := secret.Labels[utils.LabelNetworkFSName]; found && secret.Labels[utils.LabelNetworkFSNamespace] == secret.Namespace {
+		c.NetworkFilsystems.Enqueue(secret.Namespace, name)
+	}
+	return nil, nil
+}
+
+// ensureSecret creates or updates the managed secret, the data is given by the StringData of the desired secret
+func (c *Controller) ensureSecret(desired *corev1.Secret) error {
+	secret, err := c.SecretCache.Get(desired.Namespace, desired.Name)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get secret %s: %w", desired.Name, err)
+	}
+	if apierrors.IsNotFound(err) {
+		logrus.Infof("Create secret %s/%s", desired.Namespace, desired.Name)
+		if _, err := c.Secrets.Create(desired); err != nil && !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create secret %s: %w", desired.Name, err)
+		}
+		return nil
+	}
+
+	// never take over the secret of the others, e.g. the connection secret ref points to an existing secret
+	if secret.Labels[utils.LabelNetworkFSNamespace] != desired.Labels[utils.LabelNetworkFSNamespace] ||
+		secret.Labels[utils.LabelNetworkFSName] != desired.Labels[utils.LabelNetworkFSName] {
+		return fmt.Errorf("secret %s/%s is not managed by network filesystem %s", secret.Namespace, secret.Name, desired.Labels[utils.LabelNetworkFSName])
+	}
+
+	data := make(map[string][]byte, len(desired.StringData))
+	for k, v := range desired.StringData {
+		data[k] = []byte(v)
+	}
+	secretCpy := secret.DeepCopy()
+	secretCpy.Data = data
+	if secretCpy.Labels == nil {
+		secretCpy.Labels = map[string]string{}
+	}
+	for k, v := range desired.Labels {
+		secretCpy.Labels[k] = v
+	}
+	if reflect.DeepEqual(secret, secretCpy) {
+		return nil
+	}
+	logrus.Infof("Update secret %s/%s", secret.Namespace, secret.Name)
+	if _, err := c.Secrets.Update(secretCpy); err != nil {
+		return fmt.Errorf("failed to update secret %s: %w", secret.Name, err)
+	}
+	return nil
+}
+
+func secretLabels(networkFS *networkfsv1.NetworkFilesystem, secretType string) map[string]string {
+	labels := serviceLabels(networkFS)
+	labels[utils.LabelSecretType] = secretType
+	return labels
+}
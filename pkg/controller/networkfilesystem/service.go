@@ -0,0 +1,252 @@
+package networkfilesystem
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/backend"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+const (
+	// annotationKubeVIPLoadBalancerIPs requests the address of the LoadBalancer service from kube-vip
+	annotationKubeVIPLoadBalancerIPs = "kube-vip.io/loadbalancerIPs"
+
+	// the length of the hash suffix of the truncated service name
+	serviceNameHashLength = 8
+)
+
+// ServiceName returns the name of the managed service (and its endpoints) of the networkFS. The service name is a
+// DNS label, the name which is too long or has dots is truncated and made unique by the hash of the networkFS name.
+func ServiceName(networkFS *networkfsv1.NetworkFilesystem) string {
+	name := fmt.Sprintf("netfs-%s", networkFS.Name)
+	if len(name) <= validation.DNS1035LabelMaxLength && !strings.Contains(name, ".") {
+		return name
+	}
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(networkFS.Name)))[:serviceNameHashLength]
+	prefix := strings.ReplaceAll(name, ".", "-")
+	if maxLength := validation.DNS1035LabelMaxLength - serviceNameHashLength - 1; len(prefix) > maxLength {
+		prefix = prefix[:maxLength]
+	}
+	return strings.TrimRight(prefix, "-") + "-" + hash
+}
+
+// syncService makes the managed service point to the server of the export and returns the stable address of the service,
+// an empty address means the address is not allocated yet (e.g. the pending LoadBalancer).
+// The server may live in another namespace (e.g. the Longhorn share manager), so the service has no selector
+// and its endpoints are maintained here. The node of the server is recorded in the endpoints, so the LoadBalancer
+// service with the local external traffic policy routes the clients to it.
+func (c *Controller) syncService(networkFS *networkfsv1.NetworkFilesystem, serverAddress, serverNode string) (string, error) {
+	svc, err := c.ensureService(networkFS)
+	if err != nil {
+		return "", err
+	}
+	if err := c.ensureServiceEndpoints(networkFS, serverAddress, serverNode); err != nil {
+		return "", err
+	}
+	return serviceAddress(svc), nil
+}
+
+func (c *Controller) ensureService(networkFS *networkfsv1.NetworkFilesystem) (*corev1.Service, error) {
+	desired := constructService(networkFS)
+	svc, err := c.ServiceCache.Get(networkFS.Namespace, desired.Name)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get service %s: %w", desired.Name, err)
+	}
+	if apierrors.IsNotFound(err) {
+		logrus.Infof("Create service %s/%s for network filesystem %s", desired.Namespace, desired.Name, networkFS.Name)
+		created, err := c.Services.Create(desired)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create service %s: %w", desired.Name, err)
+		}
+		return created, nil
+	}
+
+	// the cluster IP is immutable and kept across the type changes, only the managed fields are updated
+	svcCpy := svc.DeepCopy()
+	svcCpy.Spec.Type = desired.Spec.Type
+	svcCpy.Spec.ExternalTrafficPolicy = desired.Spec.ExternalTrafficPolicy
+	svcCpy.Spec.Ports = mergeServicePorts(svc, desired)
+	if svcCpy.Labels == nil {
+		svcCpy.Labels = map[string]string{}
+	}
+	for k, v := range desired.Labels {
+		svcCpy.Labels[k] = v
+	}
+	if svcCpy.Annotations == nil {
+		svcCpy.Annotations = map[string]string{}
+	}
+	delete(svcCpy.Annotations, annotationKubeVIPLoadBalancerIPs)
+	for k, v := range desired.Annotations {
+		svcCpy.Annotations[k] = v
+	}
+	if reflect.DeepEqual(svc, svcCpy) {
+		return svc, nil
+	}
+	updated, err := c.Services.Update(svcCpy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update service %s: %w", svc.Name, err)
+	}
+	return updated, nil
+}
+
+func (c *Controller) ensureServiceEndpoints(networkFS *networkfsv1.NetworkFilesystem, serverAddress, serverNode string) error {
+	name := ServiceName(networkFS)
+	var subsets []corev1.EndpointSubset
+	if serverAddress != "" {
+		protocol := backend.ProtocolOf(networkFS)
+		address := corev1.EndpointAddress{IP: serverAddress}
+		if serverNode != "" {
+			address.NodeName = &serverNode
+		}
+		subsets = []corev1.EndpointSubset{
+			{
+				Addresses: []corev1.EndpointAddress{address},
+				Ports: []corev1.EndpointPort{
+					{
+						Name:     strings.ToLower(protocol),
+						Port:     backend.PortOf(protocol),
+						Protocol: corev1.ProtocolTCP,
+					},
+				},
+			},
+		}
+	}
+
+	endpoints, err := c.EndpointCache.Get(networkFS.Namespace, name)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get endpoints %s: %w", name, err)
+	}
+	if apierrors.IsNotFound(err) {
+		endpoints = &corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name,
+				Namespace:       networkFS.Namespace,
+				Labels:          serviceLabels(networkFS),
+				OwnerReferences: utils.NetworkFSOwnerReferences(networkFS),
+			},
+			Subsets: subsets,
+		}
+		if _, err := c.Endpoints.Create(endpoints); err != nil && !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create endpoints %s: %w", name, err)
+		}
+		return nil
+	}
+
+	if reflect.DeepEqual(endpoints.Subsets, subsets) {
+		return nil
+	}
+	logrus.Infof("Update endpoints %s/%s to the server address %q", endpoints.Namespace, endpoints.Name, serverAddress)
+	endpointsCpy := endpoints.DeepCopy()
+	endpointsCpy.Subsets = subsets
+	if _, err := c.Endpoints.Update(endpointsCpy); err != nil {
+		return fmt.Errorf("failed to update endpoints %s: %w", name, err)
+	}
+	return nil
+}
+
+func constructService(networkFS *networkfsv1.NetworkFilesystem) *corev1.Service {
+	protocol := backend.ProtocolOf(networkFS)
+	port := backend.PortOf(protocol)
+	svcType := networkFS.Spec.Service.Type
+	if svcType == "" {
+		svcType = corev1.ServiceTypeClusterIP
+	}
+
+	annotations := map[string]string{}
+	for k, v := range networkFS.Spec.Service.Annotations {
+		annotations[k] = v
+	}
+	if svcType == corev1.ServiceTypeLoadBalancer && networkFS.Spec.Service.LoadBalancerIP != "" {
+		annotations[annotationKubeVIPLoadBalancerIPs] = networkFS.Spec.Service.LoadBalancerIP
+	}
+	// the access rules see the addresses of the LoadBalancer clients only if they are not translated to the node address
+	var trafficPolicy corev1.ServiceExternalTrafficPolicy
+	if svcType == corev1.ServiceTypeLoadBalancer {
+		trafficPolicy = corev1.ServiceExternalTrafficPolicyCluster
+		if len(networkFS.Spec.AccessRules) > 0 {
+			trafficPolicy = corev1.ServiceExternalTrafficPolicyLocal
+		}
+	}
+
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            ServiceName(networkFS),
+			Namespace:       networkFS.Namespace,
+			Labels:          serviceLabels(networkFS),
+			Annotations:     annotations,
+			OwnerReferences: utils.NetworkFSOwnerReferences(networkFS),
+		},
+		Spec: corev1.ServiceSpec{
+			Type:                  svcType,
+			ExternalTrafficPolicy: trafficPolicy,
+			Ports: []corev1.ServicePort{
+				{
+					Name:       strings.ToLower(protocol),
+					Port:       port,
+					TargetPort: intstr.FromInt32(port),
+					Protocol:   corev1.ProtocolTCP,
+				},
+			},
+		},
+	}
+}
+
+// mergeServicePorts keeps the allocated node port of the LoadBalancer service
+func mergeServicePorts(svc, desired *corev1.Service) []corev1.ServicePort {
+	ports := make([]corev1.ServicePort, 0, len(desired.Spec.Ports))
+	for _, port := range desired.Spec.Ports {
+		if desired.Spec.Type == corev1.ServiceTypeLoadBalancer {
+			for _, existing := range svc.Spec.Ports {
+				if existing.Name == port.Name && existing.Port == port.Port {
+					port.NodePort = existing.NodePort
+				}
+			}
+		}
+		ports = append(ports, port)
+	}
+	return ports
+}
+
+func serviceLabels(networkFS *networkfsv1.NetworkFilesystem) map[string]string {
+	return map[string]string{
+		utils.LabelNetworkFSNamespace: networkFS.Namespace,
+		utils.LabelNetworkFSName:      networkFS.Name,
+	}
+}
+
+func serviceAddress(svc *corev1.Service) string {
+	if svc.Spec.Type == corev1.ServiceTypeLoadBalancer {
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			if ingress.IP != "" {
+				return ingress.IP
+			}
+			if ingress.Hostname != "" {
+				return ingress.Hostname
+			}
+		}
+		return ""
+	}
+	if svc.Spec.ClusterIP == corev1.ClusterIPNone {
+		return ""
+	}
+	return svc.Spec.ClusterIP
+}
+
+// uncPath returns the UNC path of the SMB share on the address
+func uncPath(address, shareName string) string {
+	if address == "" || shareName == "" {
+		return ""
+	}
+	return fmt.Sprintf(`\\%s\%s`, address, shareName)
+}
@@ -0,0 +1,69 @@
+package networkfilesystem
+
+import (
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+)
+
+func TestServiceName(t *testing.T) {
+	long := strings.Repeat("a", 100)
+	tests := []struct {
+		name       string
+		networkFS  string
+		want       string
+		wantPrefix string
+	}{
+		{
+			name:      "short name",
+			networkFS: "pvc-1234",
+			want:      "netfs-pvc-1234",
+		},
+		{
+			name:      "name of the longest DNS label",
+			networkFS: strings.Repeat("a", 57),
+			want:      "netfs-" + strings.Repeat("a", 57),
+		},
+		{
+			name:       "long name",
+			networkFS:  long,
+			wantPrefix: "netfs-" + strings.Repeat("a", 48) + "-",
+		},
+		{
+			name:       "name with dots",
+			networkFS:  "data.example",
+			wantPrefix: "netfs-data-example-",
+		},
+		{
+			name:       "truncated at a dash",
+			networkFS:  strings.Repeat("a", 47) + "-b" + long,
+			wantPrefix: "netfs-" + strings.Repeat("a", 47) + "-",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ServiceName(&networkfsv1.NetworkFilesystem{ObjectMeta: metav1.ObjectMeta{Name: tt.networkFS}})
+			if errs := validation.IsDNS1035Label(got); len(errs) > 0 {
+				t.Fatalf("service name %q is invalid: %v", got, errs)
+			}
+			if tt.want != "" && got != tt.want {
+				t.Errorf("expected service name %q, got %q", tt.want, got)
+			}
+			if tt.wantPrefix != "" && (!strings.HasPrefix(got, tt.wantPrefix) || len(got) != len(tt.wantPrefix)+serviceNameHashLength) {
+				t.Errorf("expected service name %q with the hash suffix, got %q", tt.wantPrefix, got)
+			}
+		})
+	}
+
+	// the truncated names of the different networkFSes do not collide
+	a := ServiceName(&networkfsv1.NetworkFilesystem{ObjectMeta: metav1.ObjectMeta{Name: long + "-1"}})
+	b := ServiceName(&networkfsv1.NetworkFilesystem{ObjectMeta: metav1.ObjectMeta{Name: long + "-2"}})
+	if a == b {
+		t.Errorf("expected different service names, both are %q", a)
+	}
+}
@@ -0,0 +1,103 @@
+package networkfilesystem
+
+import (
+	"time"
+
+	"github.com/rancher/wrangler/v3/pkg/generic"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+// the interval of checking whether the export of the deleting networkFS is stopped
+const teardownPollInterval = 5 * time.Second
+
+// teardownNetworkFS disables the export of the deleting networkFS and waits for it to stop, the finalizer is kept
+// until it returns nil. generic.ErrSkip keeps the finalizer without logging an error, the networkFS is enqueued
+// again to check the progress.
+func (c *Controller) teardownNetworkFS(networkFS *networkfsv1.NetworkFilesystem) error {
+	if networkFS.Annotations[utils.AnnotationForceDelete] == "true" {
+		logrus.Warnf("Force delete network filesystem %s, skip the teardown of the export", networkFS.Name)
+		c.recorder.Eventf(networkFS, corev1.EventTypeWarning, utils.EventReasonForceDeleted, "Skipped the teardown of the export, forced by %s", utils.AnnotationForceDelete)
+		c.releaseBestEffort(networkFS)
+		return nil
+	}
+	if networkFS.Status.State == networkfsv1.NetworkFSStateDisabled {
+		return nil
+	}
+
+	exportBackend, err := c.backends.Get(networkFS)
+	if err != nil {
+		// nothing was exported by an unknown backend
+		logrus.Warnf("Skip the teardown of network filesystem %s: %v", networkFS.Name, err)
+		return nil
+	}
+
+	// the owned resources are released even while the volume is in use, the workloads do not block the deletion
+	timedOut := time.Since(networkFS.DeletionTimestamp.Time) >= c.teardownTimeout
+	inUse, err := exportBackend.Release(networkFS)
+	if err != nil {
+		c.recorder.Eventf(networkFS, corev1.EventTypeWarning, exportFailedReason(networkFS), "Failed to release the export: %v", err)
+		if timedOut {
+			return c.giveUpTeardown(networkFS)
+		}
+		return err
+	}
+	if inUse {
+		logrus.Infof("Volume of network filesystem %s is still in use, its server keeps running for the workloads, remove the finalizer", networkFS.Name)
+		return nil
+	}
+	if !isDisabling(networkFS) {
+		logrus.Infof("Disable network filesystem %s before it is deleted", networkFS.Name)
+		c.recorder.Event(networkFS, corev1.EventTypeNormal, utils.EventReasonDisableRequested, "Disabling the export before the network filesystem is deleted")
+		networkFSCpy := networkFS.DeepCopy()
+		networkFSCpy.Status.State = networkfsv1.NetworkFSStateDisabling
+		if _, err := c.NetworkFilsystems.UpdateStatus(networkFSCpy); err != nil {
+			return err
+		}
+		c.NetworkFilsystems.EnqueueAfter(networkFS.Namespace, networkFS.Name, teardownPollInterval)
+		return generic.ErrSkip
+	}
+
+	exportStatus, err := exportBackend.Observe(networkFS)
+	if err != nil {
+		logrus.Errorf("Failed to observe network filesystem %s: %v", networkFS.Name, err)
+		if timedOut {
+			return c.giveUpTeardown(networkFS)
+		}
+		return err
+	}
+	if exportStatus.Stopped {
+		logrus.Infof("Export of network filesystem %s is stopped, remove the finalizer", networkFS.Name)
+		return nil
+	}
+	if timedOut {
+		return c.giveUpTeardown(networkFS)
+	}
+	logrus.Debugf("Wait for the export of network filesystem %s to stop before it is deleted", networkFS.Name)
+	c.NetworkFilsystems.EnqueueAfter(networkFS.Namespace, networkFS.Name, teardownPollInterval)
+	return generic.ErrSkip
+}
+
+// giveUpTeardown lets the networkFS go when its export did not stop in time, the backend resources may be left behind
+func (c *Controller) giveUpTeardown(networkFS *networkfsv1.NetworkFilesystem) error {
+	logrus.Warnf("Export of network filesystem %s did not stop within %s, remove the finalizer anyway", networkFS.Name, c.teardownTimeout)
+	c.recorder.Eventf(networkFS, corev1.EventTypeWarning, utils.EventReasonTeardownTimeout, "Export did not stop within %s, the network filesystem is deleted anyway", c.teardownTimeout)
+	c.releaseBestEffort(networkFS)
+	return nil
+}
+
+// releaseBestEffort releases the owned resources of the networkFS before its finalizer is removed without waiting for
+// the export, nothing retries once the finalizer is gone so the errors are only logged
+func (c *Controller) releaseBestEffort(networkFS *networkfsv1.NetworkFilesystem) {
+	exportBackend, err := c.backends.Get(networkFS)
+	if err != nil {
+		logrus.Warnf("Skip releasing network filesystem %s: %v", networkFS.Name, err)
+		return
+	}
+	if _, err := exportBackend.Release(networkFS); err != nil {
+		logrus.Warnf("Failed to release network filesystem %s, its resources may be left behind: %v", networkFS.Name, err)
+	}
+}
@@ -0,0 +1,119 @@
+package schedule
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	ctlntefsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/generated/controllers/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/metrics"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+// Controller flips the desiredState of the networkFS at the times of its schedule
+type Controller struct {
+	recorder record.EventRecorder
+
+	NetworkFSCache    ctlntefsv1.NetworkFilesystemCache
+	NetworkFilsystems ctlntefsv1.NetworkFilesystemController
+}
+
+const netFSScheduleHandlerName = "harvester-netfs-schedule-handler"
+
+// Register registers the scheduler of the networkFS
+func Register(ctx context.Context, netfilesystems ctlntefsv1.NetworkFilesystemController, recorder record.EventRecorder, _ *utils.Option) error {
+	c := &Controller{
+		recorder:          recorder,
+		NetworkFSCache:    netfilesystems.Cache(),
+		NetworkFilsystems: netfilesystems,
+	}
+	c.NetworkFilsystems.OnChange(ctx, netFSScheduleHandlerName, metrics.CountErrors("schedule", c.OnNetworkFSChange))
+	return nil
+}
+
+func (c *Controller) OnNetworkFSChange(_ string, networkFS *networkfsv1.NetworkFilesystem) (*networkfsv1.NetworkFilesystem, error) {
+	if networkFS == nil || networkFS.DeletionTimestamp != nil {
+		return nil, nil
+	}
+	if networkFS.Spec.Schedule == nil {
+		if networkFS.Status.Schedule == nil {
+			return nil, nil
+		}
+		networkFSCpy := networkFS.DeepCopy()
+		networkFSCpy.Status.Schedule = nil
+		return c.NetworkFilsystems.UpdateStatus(networkFSCpy)
+	}
+
+	schedule, err := utils.ParseSchedule(networkFS.Spec.Schedule)
+	if err != nil {
+		// the schedule is validated by the webhook, the invalid one is ignored until it is fixed
+		logrus.Errorf("Invalid schedule of network filesystem %s: %v", networkFS.Name, err)
+		return nil, nil
+	}
+
+	now := time.Now()
+	status := &networkfsv1.ScheduleStatus{}
+	if networkFS.Status.Schedule != nil {
+		status = networkFS.Status.Schedule.DeepCopy()
+	}
+	status.Overridden = networkFS.Annotations[utils.AnnotationScheduleOverride] == "true"
+
+	if status.NextTime != nil && !status.NextTime.Time.After(now) {
+		state, at := schedule.LastDue(status.NextState, status.NextTime.Time, now)
+		if status.Overridden {
+			logrus.Infof("Skip the scheduled transition of network filesystem %s to %s, the schedule is overridden", networkFS.Name, state)
+			c.recorder.Eventf(networkFS, corev1.EventTypeNormal, utils.EventReasonScheduleOverridden, "Skipped the scheduled transition to %s at %s, the schedule is overridden by %s", state, at.Format(time.RFC3339), utils.AnnotationScheduleOverride)
+		} else {
+			updated, err := c.applyState(networkFS, state, at)
+			if err != nil {
+				return nil, err
+			}
+			networkFS = updated
+			status.LastState = state
+			status.LastTime = &metav1.Time{Time: at}
+		}
+	}
+
+	// the next transition is always planned from now, so the change of the schedule takes effect at once
+	nextState, next := schedule.Next(now)
+	status.NextState = nextState
+	if next.IsZero() {
+		status.NextTime = nil
+	} else {
+		// the time is kept if nothing changed, so the status is not updated in every round
+		if status.NextTime == nil || !status.NextTime.Time.Equal(next) {
+			status.NextTime = &metav1.Time{Time: next}
+		}
+		c.NetworkFilsystems.EnqueueAfter(networkFS.Namespace, networkFS.Name, next.Sub(now)+time.Second)
+	}
+
+	networkFSCpy := networkFS.DeepCopy()
+	networkFSCpy.Status.Schedule = status
+	if reflect.DeepEqual(networkFS.Status, networkFSCpy.Status) {
+		return nil, nil
+	}
+	return c.NetworkFilsystems.UpdateStatus(networkFSCpy)
+}
+
+// applyState flips the desiredState, it fails while the networkFS is transitioning and is retried then
+func (c *Controller) applyState(networkFS *networkfsv1.NetworkFilesystem, state networkfsv1.NetworkFSState, at time.Time) (*networkfsv1.NetworkFilesystem, error) {
+	if networkFS.Spec.DesiredState == state {
+		return networkFS, nil
+	}
+	logrus.Infof("Set desiredState of network filesystem %s to %s as scheduled at %s", networkFS.Name, state, at.Format(time.RFC3339))
+	networkFSCpy := networkFS.DeepCopy()
+	networkFSCpy.Spec.DesiredState = state
+	updated, err := c.NetworkFilsystems.Update(networkFSCpy)
+	if err != nil {
+		logrus.Errorf("Failed to set desiredState of network filesystem %s to %s as scheduled: %v", networkFS.Name, state, err)
+		return nil, err
+	}
+	c.recorder.Eventf(updated, corev1.EventTypeNormal, utils.EventReasonScheduled, "Set desiredState to %s as scheduled at %s", state, at.Format(time.RFC3339))
+	return updated, nil
+}
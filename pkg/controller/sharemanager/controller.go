@@ -2,23 +2,23 @@ package sharemanager
 
 import (
 	"context"
-	"reflect"
 
 	longhornv1 "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
 	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
 
 	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
 	ctlntefsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/generated/controllers/harvesterhci.io/v1beta1"
 	ctllonghornv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/generated/controllers/longhorn.io/v1beta2"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/metrics"
 	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
 )
 
 type Controller struct {
 	namespace string
 	nodeName  string
+	recorder  record.EventRecorder
 
 	ShareManagerCache ctllonghornv1.ShareManagerCache
 	ShareManagers     ctllonghornv1.ShareManagerController
@@ -30,74 +30,58 @@ const (
 	netFSEndpointHandlerName = "harvester-netfs-sharemanager-handler"
 )
 
-// Register register the longhorn node CRD controller
-func Register(ctx context.Context, sharemanager ctllonghornv1.ShareManagerController, netfilesystems ctlntefsv1.NetworkFilesystemController, opt *utils.Option) error {
+// Register register the sharemanager controller, it notifies the networkFS exported by the Longhorn share manager
+func Register(ctx context.Context, sharemanager ctllonghornv1.ShareManagerController, netfilesystems ctlntefsv1.NetworkFilesystemController, recorder record.EventRecorder, opt *utils.Option) error {
 
 	c := &Controller{
 		namespace:         opt.Namespace,
 		nodeName:          opt.NodeName,
+		recorder:          recorder,
 		ShareManagers:     sharemanager,
 		ShareManagerCache: sharemanager.Cache(),
 		NetworkFilsystems: netfilesystems,
 		NetworkFSCache:    netfilesystems.Cache(),
 	}
 
-	c.ShareManagers.OnChange(ctx, netFSEndpointHandlerName, c.OnShareManagerChange)
+	c.ShareManagers.OnChange(ctx, netFSEndpointHandlerName, metrics.CountErrors("sharemanager", c.OnShareManagerChange))
 	return nil
 }
 
-// OnChange watch the node CR on change and sync up to block device CR
+// OnShareManagerChange watch the share manager on change and enqueue the corresponding networkFS
 func (c *Controller) OnShareManagerChange(_ string, sharemanager *longhornv1.ShareManager) (*longhornv1.ShareManager, error) {
 	if sharemanager == nil || sharemanager.DeletionTimestamp != nil {
-		logrus.Infof("Skip this round because sharemanager is deleted or deleting")
+		logrus.Debugf("Skip this round because sharemanager is deleted or deleting")
 		return nil, nil
 	}
 
-	// only handle the stopped sharemanager (for update the networkfs status)
-	if sharemanager.Status.State != longhornv1.ShareManagerStateStopped {
-		return nil, nil
-	}
-
-	logrus.Infof("Handling sharemanager %s change event", sharemanager.Name)
-	networkFS, err := c.NetworkFilsystems.Get(c.namespace, sharemanager.Name, metav1.GetOptions{})
-	if err != nil && apierrors.IsNotFound(err) {
-		logrus.Errorf("Failed to get networkFS %s: %v", sharemanager.Name, err)
+	logrus.Debugf("Handling sharemanager %s change event, state %s", sharemanager.Name, sharemanager.Status.State)
+	networkFSes, err := c.NetworkFSCache.GetByIndex(utils.NetworkFSByLHVolumeIndex, sharemanager.Name)
+	if err != nil {
+		logrus.Errorf("Failed to get networkFS of sharemanager %s: %v", sharemanager.Name, err)
 		return nil, err
 	}
 
-	// already disabled, return
-	if networkFS.Status.State == networkfsv1.NetworkFSStateDisabled {
-		logrus.Infof("Skip update with sharemanager change event because networkfilesystem %s is not enabled", networkFS.Name)
-		return nil, nil
-	}
-
-	// only handle it on the networkfs is disabled.
-	if networkFS.Spec.DesiredState != networkfsv1.NetworkFSStateDisabled || networkFS.Status.State != networkfsv1.NetworkFSStateDisabling {
-		return nil, nil
-	}
-
-	networkFSCpy := networkFS.DeepCopy()
-	networkFSCpy.Status.State = networkfsv1.NetworkFSStateDisabled
-	networkFSCpy.Status.Endpoint = ""
-	networkFSCpy.Status.Status = networkfsv1.EndpointStatusNotReady
-	networkFSCpy.Status.Type = networkfsv1.NetworkFSTypeNFS
-	networkFSCpy.Status.MountOpts = ""
-	conds := networkfsv1.NetworkFSCondition{
-		Type:               networkfsv1.ConditionTypeNotReady,
-		Status:             corev1.ConditionTrue,
-		LastTransitionTime: metav1.Now(),
-		Reason:             "ShareManager is stopped",
-		Message:            "ShareManager is stopped, means the networkfs is disabled",
+	// the networkfilesystem controller observes the share manager state (e.g. stopped means disabled)
+	for _, networkFS := range networkFSes {
+		c.recordShareManagerEvent(networkFS, sharemanager)
+		c.NetworkFilsystems.Enqueue(networkFS.Namespace, networkFS.Name)
 	}
-	networkFSCpy.Status.NetworkFSConds = utils.UpdateNetworkFSConds(networkFSCpy.Status.NetworkFSConds, conds)
+	return nil, nil
+}
 
-	if !reflect.DeepEqual(networkFS, networkFSCpy) {
-		logrus.Infof("Prepare to update networkfilesystem %+v", networkFSCpy)
-		if _, err := c.NetworkFilsystems.UpdateStatus(networkFSCpy); err != nil {
-			logrus.Errorf("Failed to update networkFS %s: %v", networkFS.Name, err)
-			return nil, err
+// recordShareManagerEvent reports the stopped or failed share manager on the networkFS which is exporting through it
+func (c *Controller) recordShareManagerEvent(networkFS *networkfsv1.NetworkFilesystem, sharemanager *longhornv1.ShareManager) {
+	switch sharemanager.Status.State {
+	case longhornv1.ShareManagerStateStopped:
+		switch networkFS.Status.State {
+		case networkfsv1.NetworkFSStateDisabling:
+			c.recorder.Eventf(networkFS, corev1.EventTypeNormal, utils.EventReasonShareManagerStopped, "Share manager %s is stopped", sharemanager.Name)
+		case networkfsv1.NetworkFSStateEnabled:
+			c.recorder.Eventf(networkFS, corev1.EventTypeWarning, utils.EventReasonShareManagerStopped, "Share manager %s is stopped while the export is enabled", sharemanager.Name)
+		}
+	case longhornv1.ShareManagerStateError:
+		if networkFS.Spec.DesiredState == networkfsv1.NetworkFSStateEnabled {
+			c.recorder.Eventf(networkFS, corev1.EventTypeWarning, utils.EventReasonShareManagerError, "Share manager %s is in error state", sharemanager.Name)
 		}
 	}
-
-	return nil, nil
 }
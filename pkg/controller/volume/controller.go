@@ -0,0 +1,222 @@
+package volume
+
+import (
+	"context"
+	"reflect"
+
+	longhornv1 "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	ctlv1 "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	ctlntefsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/generated/controllers/harvesterhci.io/v1beta1"
+	ctllonghornv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/generated/controllers/longhorn.io/v1beta2"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/metrics"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+type Controller struct {
+	namespace string
+	nodeName  string
+
+	VolumeCache       ctllonghornv1.VolumeCache
+	Volumes           ctllonghornv1.VolumeController
+	NetworkFSCache    ctlntefsv1.NetworkFilesystemCache
+	NetworkFilsystems ctlntefsv1.NetworkFilesystemController
+}
+
+const (
+	netFSVolumeHandlerName       = "harvester-netfs-volume-handler"
+	netFSPVCHandlerName          = "harvester-netfs-pvc-handler"
+	netFSShareManagerHandlerName = "harvester-netfs-discovery-sharemanager-handler"
+	netFSVolumeGCHandlerName     = "harvester-netfs-discovery-gc-handler"
+)
+
+// Register register the longhorn volume discovery controller
+func Register(ctx context.Context, volumes ctllonghornv1.VolumeController, sharemanagers ctllonghornv1.ShareManagerController, pvcs ctlv1.PersistentVolumeClaimController, netfilesystems ctlntefsv1.NetworkFilesystemController, opt *utils.Option) error {
+
+	c := &Controller{
+		namespace:         opt.Namespace,
+		nodeName:          opt.NodeName,
+		Volumes:           volumes,
+		VolumeCache:       volumes.Cache(),
+		NetworkFilsystems: netfilesystems,
+		NetworkFSCache:    netfilesystems.Cache(),
+	}
+
+	c.Volumes.OnChange(ctx, netFSVolumeHandlerName, metrics.CountErrors("volume", c.OnVolumeChange))
+	c.NetworkFilsystems.OnChange(ctx, netFSVolumeGCHandlerName, metrics.CountErrors("volume", c.OnNetworkFSChange))
+	pvcs.OnChange(ctx, netFSPVCHandlerName, metrics.CountErrors("volume", c.OnPVCChange))
+	sharemanagers.OnChange(ctx, netFSShareManagerHandlerName, metrics.CountErrors("volume", c.OnShareManagerChange))
+	return nil
+}
+
+// OnVolumeChange makes sure every Longhorn RWX volume has a corresponding networkFS, the discovered networkFS is
+// removed along with the volume. No finalizer is put on the volumes, the ones removed while the manager is down
+// are collected by OnNetworkFSChange.
+func (c *Controller) OnVolumeChange(key string, volume *longhornv1.Volume) (*longhornv1.Volume, error) {
+	if volume == nil {
+		_, name, err := cache.SplitMetaNamespaceKey(key)
+		if err != nil {
+			return nil, err
+		}
+		return nil, c.removeDiscoveredNetworkFS(name)
+	}
+	if volume.DeletionTimestamp != nil {
+		return nil, nil
+	}
+
+	if volume.Spec.AccessMode != longhornv1.AccessModeReadWriteMany {
+		return nil, nil
+	}
+
+	logrus.Debugf("Handling volume %s change event", volume.Name)
+	networkFS, err := c.NetworkFSCache.Get(c.namespace, volume.Name)
+	if err != nil && !apierrors.IsNotFound(err) {
+		logrus.Errorf("Failed to get networkFS %s: %v", volume.Name, err)
+		return nil, err
+	}
+
+	if apierrors.IsNotFound(err) {
+		return nil, c.createNetworkFS(volume)
+	}
+
+	// only sync up the networkFS which is created by us
+	if networkFS.Labels[utils.LabelDiscovered] != "true" {
+		return nil, nil
+	}
+
+	networkFSCpy := networkFS.DeepCopy()
+	c.syncDiscoveredMeta(volume, networkFSCpy)
+	if !reflect.DeepEqual(networkFS, networkFSCpy) {
+		logrus.Infof("Prepare to update discovered networkfilesystem %s metadata", networkFS.Name)
+		if _, err := c.NetworkFilsystems.Update(networkFSCpy); err != nil {
+			logrus.Errorf("Failed to update networkFS %s: %v", networkFS.Name, err)
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// OnNetworkFSChange garbage-collects the discovered networkFS whose volume is gone
+func (c *Controller) OnNetworkFSChange(_ string, networkFS *networkfsv1.NetworkFilesystem) (*networkfsv1.NetworkFilesystem, error) {
+	if networkFS == nil || networkFS.DeletionTimestamp != nil || networkFS.Labels[utils.LabelDiscovered] != "true" {
+		return nil, nil
+	}
+	if _, err := c.VolumeCache.Get(utils.LHNameSpace, networkFS.Spec.NetworkFSName); !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	return nil, c.removeDiscoveredNetworkFS(networkFS.Name)
+}
+
+// removeDiscoveredNetworkFS deletes the discovered networkFS of the removed volume
+func (c *Controller) removeDiscoveredNetworkFS(name string) error {
+	networkFS, err := c.NetworkFSCache.Get(c.namespace, name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		logrus.Errorf("Failed to get networkFS %s: %v", name, err)
+		return err
+	}
+
+	if networkFS.Labels[utils.LabelDiscovered] != "true" {
+		logrus.Infof("Skip removing networkfilesystem %s because it is not created by discovery", networkFS.Name)
+		return nil
+	}
+	if networkFS.DeletionTimestamp != nil {
+		return nil
+	}
+
+	logrus.Infof("Remove networkfilesystem %s because the volume is removed", networkFS.Name)
+	if err := c.NetworkFilsystems.Delete(c.namespace, networkFS.Name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		logrus.Errorf("Failed to delete networkFS %s: %v", networkFS.Name, err)
+		return err
+	}
+	return nil
+}
+
+// OnPVCChange enqueues the Longhorn volume bound to a RWX PVC
+func (c *Controller) OnPVCChange(_ string, pvc *corev1.PersistentVolumeClaim) (*corev1.PersistentVolumeClaim, error) {
+	if pvc == nil || pvc.DeletionTimestamp != nil {
+		return nil, nil
+	}
+
+	if pvc.Spec.VolumeName == "" || !isRWXClaim(pvc) {
+		return nil, nil
+	}
+
+	c.Volumes.Enqueue(utils.LHNameSpace, pvc.Spec.VolumeName)
+	return nil, nil
+}
+
+// OnShareManagerChange enqueues the Longhorn volume which is exported by the share manager
+func (c *Controller) OnShareManagerChange(_ string, sharemanager *longhornv1.ShareManager) (*longhornv1.ShareManager, error) {
+	if sharemanager == nil || sharemanager.DeletionTimestamp != nil {
+		return nil, nil
+	}
+
+	c.Volumes.Enqueue(utils.LHNameSpace, sharemanager.Name)
+	return nil, nil
+}
+
+func (c *Controller) createNetworkFS(volume *longhornv1.Volume) error {
+	networkFS := &networkfsv1.NetworkFilesystem{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      volume.Name,
+			Namespace: c.namespace,
+		},
+		Spec: networkfsv1.NetworkFSSpec{
+			NetworkFSName: volume.Name,
+			DesiredState:  networkfsv1.NetworkFSStateDisabled,
+		},
+	}
+	c.syncDiscoveredMeta(volume, networkFS)
+
+	logrus.Infof("Create networkfilesystem %s for RWX volume", volume.Name)
+	if _, err := c.NetworkFilsystems.Create(networkFS); err != nil && !apierrors.IsAlreadyExists(err) {
+		logrus.Errorf("Failed to create networkFS %s: %v", volume.Name, err)
+		return err
+	}
+	return nil
+}
+
+// syncDiscoveredMeta updates the labels and annotations of the discovered networkFS. The networkFS has no owner, it is
+// removed along with the Longhorn volume rather than the PV, so deleting a retained PV does not take it away.
+func (c *Controller) syncDiscoveredMeta(volume *longhornv1.Volume, networkFS *networkfsv1.NetworkFilesystem) {
+	if networkFS.Labels == nil {
+		networkFS.Labels = map[string]string{}
+	}
+	networkFS.Labels[utils.LabelDiscovered] = "true"
+	// the earlier releases recorded the PVC in the labels and owned the networkFS by the PV
+	delete(networkFS.Labels, utils.AnnotationPVCNamespace)
+	delete(networkFS.Labels, utils.AnnotationPVCName)
+	var ownerReferences []metav1.OwnerReference
+	for _, ref := range networkFS.OwnerReferences {
+		if ref.APIVersion != "v1" || ref.Kind != "PersistentVolume" {
+			ownerReferences = append(ownerReferences, ref)
+		}
+	}
+	networkFS.OwnerReferences = ownerReferences
+
+	if kubeStatus := volume.Status.KubernetesStatus; kubeStatus.PVCName != "" {
+		if networkFS.Annotations == nil {
+			networkFS.Annotations = map[string]string{}
+		}
+		networkFS.Annotations[utils.AnnotationPVCNamespace] = kubeStatus.Namespace
+		networkFS.Annotations[utils.AnnotationPVCName] = kubeStatus.PVCName
+	}
+}
+
+func isRWXClaim(pvc *corev1.PersistentVolumeClaim) bool {
+	for _, mode := range pvc.Spec.AccessModes {
+		if mode == corev1.ReadWriteMany {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package volume
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	longhornv1 "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+func TestSyncDiscoveredMeta(t *testing.T) {
+	longName := strings.Repeat("a", 100)
+	tests := []struct {
+		name            string
+		networkFS       *networkfsv1.NetworkFilesystem
+		kubeStatus      longhornv1.KubernetesStatus
+		wantLabels      map[string]string
+		wantAnnotations map[string]string
+		wantOwners      []metav1.OwnerReference
+	}{
+		{
+			name:            "records the PVC with the long name",
+			networkFS:       &networkfsv1.NetworkFilesystem{},
+			kubeStatus:      longhornv1.KubernetesStatus{Namespace: "default", PVCName: longName, PVName: "pvc-1234"},
+			wantLabels:      map[string]string{utils.LabelDiscovered: "true"},
+			wantAnnotations: map[string]string{utils.AnnotationPVCNamespace: "default", utils.AnnotationPVCName: longName},
+		},
+		{
+			name: "drops the PVC labels and the PV owner of the earlier releases",
+			networkFS: &networkfsv1.NetworkFilesystem{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						utils.LabelDiscovered:        "true",
+						utils.AnnotationPVCNamespace: "default",
+						utils.AnnotationPVCName:      "data",
+						"app":                        "web",
+					},
+					OwnerReferences: []metav1.OwnerReference{
+						{APIVersion: "v1", Kind: "PersistentVolume", Name: "pvc-1234", UID: "pv-uid"},
+						{APIVersion: "v1", Kind: "ConfigMap", Name: "owner", UID: "cm-uid"},
+					},
+				},
+			},
+			kubeStatus:      longhornv1.KubernetesStatus{Namespace: "default", PVCName: "data", PVName: "pvc-1234"},
+			wantLabels:      map[string]string{utils.LabelDiscovered: "true", "app": "web"},
+			wantAnnotations: map[string]string{utils.AnnotationPVCNamespace: "default", utils.AnnotationPVCName: "data"},
+			wantOwners:      []metav1.OwnerReference{{APIVersion: "v1", Kind: "ConfigMap", Name: "owner", UID: "cm-uid"}},
+		},
+		{
+			name:       "volume without PVC",
+			networkFS:  &networkfsv1.NetworkFilesystem{},
+			wantLabels: map[string]string{utils.LabelDiscovered: "true"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Controller{}
+			volume := &longhornv1.Volume{Status: longhornv1.VolumeStatus{KubernetesStatus: tt.kubeStatus}}
+			c.syncDiscoveredMeta(volume, tt.networkFS)
+			if !reflect.DeepEqual(tt.networkFS.Labels, tt.wantLabels) {
+				t.Errorf("expected labels %v, got %v", tt.wantLabels, tt.networkFS.Labels)
+			}
+			if !reflect.DeepEqual(tt.networkFS.Annotations, tt.wantAnnotations) {
+				t.Errorf("expected annotations %v, got %v", tt.wantAnnotations, tt.networkFS.Annotations)
+			}
+			if !reflect.DeepEqual(tt.networkFS.OwnerReferences, tt.wantOwners) {
+				t.Errorf("expected owner references %v, got %v", tt.wantOwners, tt.networkFS.OwnerReferences)
+			}
+		})
+	}
+}
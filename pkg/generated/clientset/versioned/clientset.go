@@ -23,6 +23,7 @@ import (
 	"net/http"
 
 	harvesterhciv1beta1 "github.com/Vicente-Cheng/networkfs-manager/pkg/generated/clientset/versioned/typed/harvesterhci.io/v1beta1"
+	harvesterhciv1beta2 "github.com/Vicente-Cheng/networkfs-manager/pkg/generated/clientset/versioned/typed/harvesterhci.io/v1beta2"
 	longhornv1beta2 "github.com/Vicente-Cheng/networkfs-manager/pkg/generated/clientset/versioned/typed/longhorn.io/v1beta2"
 	discovery "k8s.io/client-go/discovery"
 	rest "k8s.io/client-go/rest"
@@ -31,6 +32,7 @@ import (
 
 type Interface interface {
 	Discovery() discovery.DiscoveryInterface
+	HarvesterhciV1beta2() harvesterhciv1beta2.HarvesterhciV1beta2Interface
 	HarvesterhciV1beta1() harvesterhciv1beta1.HarvesterhciV1beta1Interface
 	LonghornV1beta2() longhornv1beta2.LonghornV1beta2Interface
 }
@@ -38,10 +40,16 @@ type Interface interface {
 // Clientset contains the clients for groups.
 type Clientset struct {
 	*discovery.DiscoveryClient
+	harvesterhciV1beta2 *harvesterhciv1beta2.HarvesterhciV1beta2Client
 	harvesterhciV1beta1 *harvesterhciv1beta1.HarvesterhciV1beta1Client
 	longhornV1beta2     *longhornv1beta2.LonghornV1beta2Client
 }
 
+// HarvesterhciV1beta2 retrieves the HarvesterhciV1beta2Client
+func (c *Clientset) HarvesterhciV1beta2() harvesterhciv1beta2.HarvesterhciV1beta2Interface {
+	return c.harvesterhciV1beta2
+}
+
 // HarvesterhciV1beta1 retrieves the HarvesterhciV1beta1Client
 func (c *Clientset) HarvesterhciV1beta1() harvesterhciv1beta1.HarvesterhciV1beta1Interface {
 	return c.harvesterhciV1beta1
@@ -96,6 +104,10 @@ func NewForConfigAndClient(c *rest.Config, httpClient *http.Client) (*Clientset,
 
 	var cs Clientset
 	var err error
+	cs.harvesterhciV1beta2, err = harvesterhciv1beta2.NewForConfigAndClient(&configShallowCopy, httpClient)
+	if err != nil {
+		return nil, err
+	}
 	cs.harvesterhciV1beta1, err = harvesterhciv1beta1.NewForConfigAndClient(&configShallowCopy, httpClient)
 	if err != nil {
 		return nil, err
@@ -125,6 +137,7 @@ func NewForConfigOrDie(c *rest.Config) *Clientset {
 // New creates a new Clientset for the given RESTClient.
 func New(c rest.Interface) *Clientset {
 	var cs Clientset
+	cs.harvesterhciV1beta2 = harvesterhciv1beta2.New(c)
 	cs.harvesterhciV1beta1 = harvesterhciv1beta1.New(c)
 	cs.longhornV1beta2 = longhornv1beta2.New(c)
 
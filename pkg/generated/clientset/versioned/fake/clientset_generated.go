@@ -22,6 +22,8 @@ import (
 	clientset "github.com/Vicente-Cheng/networkfs-manager/pkg/generated/clientset/versioned"
 	harvesterhciv1beta1 "github.com/Vicente-Cheng/networkfs-manager/pkg/generated/clientset/versioned/typed/harvesterhci.io/v1beta1"
 	fakeharvesterhciv1beta1 "github.com/Vicente-Cheng/networkfs-manager/pkg/generated/clientset/versioned/typed/harvesterhci.io/v1beta1/fake"
+	harvesterhciv1beta2 "github.com/Vicente-Cheng/networkfs-manager/pkg/generated/clientset/versioned/typed/harvesterhci.io/v1beta2"
+	fakeharvesterhciv1beta2 "github.com/Vicente-Cheng/networkfs-manager/pkg/generated/clientset/versioned/typed/harvesterhci.io/v1beta2/fake"
 	longhornv1beta2 "github.com/Vicente-Cheng/networkfs-manager/pkg/generated/clientset/versioned/typed/longhorn.io/v1beta2"
 	fakelonghornv1beta2 "github.com/Vicente-Cheng/networkfs-manager/pkg/generated/clientset/versioned/typed/longhorn.io/v1beta2/fake"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -81,6 +83,11 @@ var (
 	_ testing.FakeClient  = &Clientset{}
 )
 
+// HarvesterhciV1beta2 retrieves the HarvesterhciV1beta2Client
+func (c *Clientset) HarvesterhciV1beta2() harvesterhciv1beta2.HarvesterhciV1beta2Interface {
+	return &fakeharvesterhciv1beta2.FakeHarvesterhciV1beta2{Fake: &c.Fake}
+}
+
 // HarvesterhciV1beta1 retrieves the HarvesterhciV1beta1Client
 func (c *Clientset) HarvesterhciV1beta1() harvesterhciv1beta1.HarvesterhciV1beta1Interface {
 	return &fakeharvesterhciv1beta1.FakeHarvesterhciV1beta1{Fake: &c.Fake}
@@ -20,6 +20,7 @@ package scheme
 
 import (
 	harvesterhciv1beta1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	harvesterhciv1beta2 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta2"
 	longhornv1beta2 "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
@@ -32,6 +33,7 @@ var Scheme = runtime.NewScheme()
 var Codecs = serializer.NewCodecFactory(Scheme)
 var ParameterCodec = runtime.NewParameterCodec(Scheme)
 var localSchemeBuilder = runtime.SchemeBuilder{
+	harvesterhciv1beta2.AddToScheme,
 	harvesterhciv1beta1.AddToScheme,
 	longhornv1beta2.AddToScheme,
 }
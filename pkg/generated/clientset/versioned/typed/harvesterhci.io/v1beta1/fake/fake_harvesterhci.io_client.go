@@ -32,6 +32,14 @@ func (c *FakeHarvesterhciV1beta1) NetworkFilesystems(namespace string) v1beta1.N
 	return &FakeNetworkFilesystems{c, namespace}
 }
 
+func (c *FakeHarvesterhciV1beta1) NetworkFilesystemExports(namespace string) v1beta1.NetworkFilesystemExportInterface {
+	return &FakeNetworkFilesystemExports{c, namespace}
+}
+
+func (c *FakeHarvesterhciV1beta1) NetworkFilesystemMounts(namespace string) v1beta1.NetworkFilesystemMountInterface {
+	return &FakeNetworkFilesystemMounts{c, namespace}
+}
+
 // RESTClient returns a RESTClient that is used to communicate
 // with API server by this client implementation.
 func (c *FakeHarvesterhciV1beta1) RESTClient() rest.Interface {
@@ -0,0 +1,141 @@
+/*
+Copyright 2024 Rancher Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by main. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	v1beta1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeNetworkFilesystemExports implements NetworkFilesystemExportInterface
+type FakeNetworkFilesystemExports struct {
+	Fake *FakeHarvesterhciV1beta1
+	ns   string
+}
+
+var networkfilesystemexportsResource = v1beta1.SchemeGroupVersion.WithResource("networkfilesystemexports")
+
+var networkfilesystemexportsKind = v1beta1.SchemeGroupVersion.WithKind("NetworkFilesystemExport")
+
+// Get takes name of the networkFilesystemExport, and returns the corresponding networkFilesystemExport object, and an error if there is any.
+func (c *FakeNetworkFilesystemExports) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1beta1.NetworkFilesystemExport, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewGetAction(networkfilesystemexportsResource, c.ns, name), &v1beta1.NetworkFilesystemExport{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta1.NetworkFilesystemExport), err
+}
+
+// List takes label and field selectors, and returns the list of NetworkFilesystemExports that match those selectors.
+func (c *FakeNetworkFilesystemExports) List(ctx context.Context, opts v1.ListOptions) (result *v1beta1.NetworkFilesystemExportList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewListAction(networkfilesystemexportsResource, networkfilesystemexportsKind, c.ns, opts), &v1beta1.NetworkFilesystemExportList{})
+
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1beta1.NetworkFilesystemExportList{ListMeta: obj.(*v1beta1.NetworkFilesystemExportList).ListMeta}
+	for _, item := range obj.(*v1beta1.NetworkFilesystemExportList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested networkFilesystemExports.
+func (c *FakeNetworkFilesystemExports) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchAction(networkfilesystemexportsResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a networkFilesystemExport and creates it.  Returns the server's representation of the networkFilesystemExport, and an error, if there is any.
+func (c *FakeNetworkFilesystemExports) Create(ctx context.Context, networkFilesystemExport *v1beta1.NetworkFilesystemExport, opts v1.CreateOptions) (result *v1beta1.NetworkFilesystemExport, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateAction(networkfilesystemexportsResource, c.ns, networkFilesystemExport), &v1beta1.NetworkFilesystemExport{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta1.NetworkFilesystemExport), err
+}
+
+// Update takes the representation of a networkFilesystemExport and updates it. Returns the server's representation of the networkFilesystemExport, and an error, if there is any.
+func (c *FakeNetworkFilesystemExports) Update(ctx context.Context, networkFilesystemExport *v1beta1.NetworkFilesystemExport, opts v1.UpdateOptions) (result *v1beta1.NetworkFilesystemExport, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateAction(networkfilesystemexportsResource, c.ns, networkFilesystemExport), &v1beta1.NetworkFilesystemExport{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta1.NetworkFilesystemExport), err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeNetworkFilesystemExports) UpdateStatus(ctx context.Context, networkFilesystemExport *v1beta1.NetworkFilesystemExport, opts v1.UpdateOptions) (*v1beta1.NetworkFilesystemExport, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(networkfilesystemexportsResource, "status", c.ns, networkFilesystemExport), &v1beta1.NetworkFilesystemExport{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta1.NetworkFilesystemExport), err
+}
+
+// Delete takes name of the networkFilesystemExport and deletes it. Returns an error if one occurs.
+func (c *FakeNetworkFilesystemExports) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteActionWithOptions(networkfilesystemexportsResource, c.ns, name, opts), &v1beta1.NetworkFilesystemExport{})
+
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeNetworkFilesystemExports) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	action := testing.NewDeleteCollectionAction(networkfilesystemexportsResource, c.ns, listOpts)
+
+	_, err := c.Fake.Invokes(action, &v1beta1.NetworkFilesystemExportList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched networkFilesystemExport.
+func (c *FakeNetworkFilesystemExports) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1beta1.NetworkFilesystemExport, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(networkfilesystemexportsResource, c.ns, name, pt, data, subresources...), &v1beta1.NetworkFilesystemExport{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta1.NetworkFilesystemExport), err
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2024 Rancher Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by main. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	v1beta1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeNetworkFilesystemMounts implements NetworkFilesystemMountInterface
+type FakeNetworkFilesystemMounts struct {
+	Fake *FakeHarvesterhciV1beta1
+	ns   string
+}
+
+var networkfilesystemmountsResource = v1beta1.SchemeGroupVersion.WithResource("networkfilesystemmounts")
+
+var networkfilesystemmountsKind = v1beta1.SchemeGroupVersion.WithKind("NetworkFilesystemMount")
+
+// Get takes name of the networkFilesystemMount, and returns the corresponding networkFilesystemMount object, and an error if there is any.
+func (c *FakeNetworkFilesystemMounts) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1beta1.NetworkFilesystemMount, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewGetAction(networkfilesystemmountsResource, c.ns, name), &v1beta1.NetworkFilesystemMount{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta1.NetworkFilesystemMount), err
+}
+
+// List takes label and field selectors, and returns the list of NetworkFilesystemMounts that match those selectors.
+func (c *FakeNetworkFilesystemMounts) List(ctx context.Context, opts v1.ListOptions) (result *v1beta1.NetworkFilesystemMountList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewListAction(networkfilesystemmountsResource, networkfilesystemmountsKind, c.ns, opts), &v1beta1.NetworkFilesystemMountList{})
+
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1beta1.NetworkFilesystemMountList{ListMeta: obj.(*v1beta1.NetworkFilesystemMountList).ListMeta}
+	for _, item := range obj.(*v1beta1.NetworkFilesystemMountList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested networkFilesystemMounts.
+func (c *FakeNetworkFilesystemMounts) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchAction(networkfilesystemmountsResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a networkFilesystemMount and creates it.  Returns the server's representation of the networkFilesystemMount, and an error, if there is any.
+func (c *FakeNetworkFilesystemMounts) Create(ctx context.Context, networkFilesystemMount *v1beta1.NetworkFilesystemMount, opts v1.CreateOptions) (result *v1beta1.NetworkFilesystemMount, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateAction(networkfilesystemmountsResource, c.ns, networkFilesystemMount), &v1beta1.NetworkFilesystemMount{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta1.NetworkFilesystemMount), err
+}
+
+// Update takes the representation of a networkFilesystemMount and updates it. Returns the server's representation of the networkFilesystemMount, and an error, if there is any.
+func (c *FakeNetworkFilesystemMounts) Update(ctx context.Context, networkFilesystemMount *v1beta1.NetworkFilesystemMount, opts v1.UpdateOptions) (result *v1beta1.NetworkFilesystemMount, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateAction(networkfilesystemmountsResource, c.ns, networkFilesystemMount), &v1beta1.NetworkFilesystemMount{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta1.NetworkFilesystemMount), err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeNetworkFilesystemMounts) UpdateStatus(ctx context.Context, networkFilesystemMount *v1beta1.NetworkFilesystemMount, opts v1.UpdateOptions) (*v1beta1.NetworkFilesystemMount, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(networkfilesystemmountsResource, "status", c.ns, networkFilesystemMount), &v1beta1.NetworkFilesystemMount{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta1.NetworkFilesystemMount), err
+}
+
+// Delete takes name of the networkFilesystemMount and deletes it. Returns an error if one occurs.
+func (c *FakeNetworkFilesystemMounts) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteActionWithOptions(networkfilesystemmountsResource, c.ns, name, opts), &v1beta1.NetworkFilesystemMount{})
+
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeNetworkFilesystemMounts) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	action := testing.NewDeleteCollectionAction(networkfilesystemmountsResource, c.ns, listOpts)
+
+	_, err := c.Fake.Invokes(action, &v1beta1.NetworkFilesystemMountList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched networkFilesystemMount.
+func (c *FakeNetworkFilesystemMounts) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1beta1.NetworkFilesystemMount, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(networkfilesystemmountsResource, c.ns, name, pt, data, subresources...), &v1beta1.NetworkFilesystemMount{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta1.NetworkFilesystemMount), err
+}
@@ -19,3 +19,7 @@ limitations under the License.
 package v1beta1
 
 type NetworkFilesystemExpansion interface{}
+
+type NetworkFilesystemExportExpansion interface{}
+
+type NetworkFilesystemMountExpansion interface{}
@@ -29,6 +29,8 @@ import (
 type HarvesterhciV1beta1Interface interface {
 	RESTClient() rest.Interface
 	NetworkFilesystemsGetter
+	NetworkFilesystemExportsGetter
+	NetworkFilesystemMountsGetter
 }
 
 // HarvesterhciV1beta1Client is used to interact with features provided by the harvesterhci.io group.
@@ -40,6 +42,14 @@ func (c *HarvesterhciV1beta1Client) NetworkFilesystems(namespace string) Network
 	return newNetworkFilesystems(c, namespace)
 }
 
+func (c *HarvesterhciV1beta1Client) NetworkFilesystemExports(namespace string) NetworkFilesystemExportInterface {
+	return newNetworkFilesystemExports(c, namespace)
+}
+
+func (c *HarvesterhciV1beta1Client) NetworkFilesystemMounts(namespace string) NetworkFilesystemMountInterface {
+	return newNetworkFilesystemMounts(c, namespace)
+}
+
 // NewForConfig creates a new HarvesterhciV1beta1Client for the given config.
 // NewForConfig is equivalent to NewForConfigAndClient(c, httpClient),
 // where httpClient was generated with rest.HTTPClientFor(c).
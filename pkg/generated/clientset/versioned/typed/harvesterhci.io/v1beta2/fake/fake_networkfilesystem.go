@@ -0,0 +1,141 @@
+/*
+Copyright 2024 Rancher Labs, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by main. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	v1beta2 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta2"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeNetworkFilesystems implements NetworkFilesystemInterface
+type FakeNetworkFilesystems struct {
+	Fake *FakeHarvesterhciV1beta2
+	ns   string
+}
+
+var networkfilesystemsResource = v1beta2.SchemeGroupVersion.WithResource("networkfilesystems")
+
+var networkfilesystemsKind = v1beta2.SchemeGroupVersion.WithKind("NetworkFilesystem")
+
+// Get takes name of the networkFilesystem, and returns the corresponding networkFilesystem object, and an error if there is any.
+func (c *FakeNetworkFilesystems) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1beta2.NetworkFilesystem, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewGetAction(networkfilesystemsResource, c.ns, name), &v1beta2.NetworkFilesystem{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta2.NetworkFilesystem), err
+}
+
+// List takes label and field selectors, and returns the list of NetworkFilesystems that match those selectors.
+func (c *FakeNetworkFilesystems) List(ctx context.Context, opts v1.ListOptions) (result *v1beta2.NetworkFilesystemList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewListAction(networkfilesystemsResource, networkfilesystemsKind, c.ns, opts), &v1beta2.NetworkFilesystemList{})
+
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1beta2.NetworkFilesystemList{ListMeta: obj.(*v1beta2.NetworkFilesystemList).ListMeta}
+	for _, item := range obj.(*v1beta2.NetworkFilesystemList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested networkFilesystems.
+func (c *FakeNetworkFilesystems) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchAction(networkfilesystemsResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a networkFilesystem and creates it.  Returns the server's representation of the networkFilesystem, and an error, if there is any.
+func (c *FakeNetworkFilesystems) Create(ctx context.Context, networkFilesystem *v1beta2.NetworkFilesystem, opts v1.CreateOptions) (result *v1beta2.NetworkFilesystem, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateAction(networkfilesystemsResource, c.ns, networkFilesystem), &v1beta2.NetworkFilesystem{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta2.NetworkFilesystem), err
+}
+
+// Update takes the representation of a networkFilesystem and updates it. Returns the server's representation of the networkFilesystem, and an error, if there is any.
+func (c *FakeNetworkFilesystems) Update(ctx context.Context, networkFilesystem *v1beta2.NetworkFilesystem, opts v1.UpdateOptions) (result *v1beta2.NetworkFilesystem, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateAction(networkfilesystemsResource, c.ns, networkFilesystem), &v1beta2.NetworkFilesystem{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta2.NetworkFilesystem), err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeNetworkFilesystems) UpdateStatus(ctx context.Context, networkFilesystem *v1beta2.NetworkFilesystem, opts v1.UpdateOptions) (*v1beta2.NetworkFilesystem, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(networkfilesystemsResource, "status", c.ns, networkFilesystem), &v1beta2.NetworkFilesystem{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta2.NetworkFilesystem), err
+}
+
+// Delete takes name of the networkFilesystem and deletes it. Returns an error if one occurs.
+func (c *FakeNetworkFilesystems) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteActionWithOptions(networkfilesystemsResource, c.ns, name, opts), &v1beta2.NetworkFilesystem{})
+
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeNetworkFilesystems) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	action := testing.NewDeleteCollectionAction(networkfilesystemsResource, c.ns, listOpts)
+
+	_, err := c.Fake.Invokes(action, &v1beta2.NetworkFilesystemList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched networkFilesystem.
+func (c *FakeNetworkFilesystems) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1beta2.NetworkFilesystem, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(networkfilesystemsResource, c.ns, name, pt, data, subresources...), &v1beta2.NetworkFilesystem{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta2.NetworkFilesystem), err
+}
@@ -32,6 +32,8 @@ func init() {
 
 type Interface interface {
 	NetworkFilesystem() NetworkFilesystemController
+	NetworkFilesystemExport() NetworkFilesystemExportController
+	NetworkFilesystemMount() NetworkFilesystemMountController
 }
 
 func New(controllerFactory controller.SharedControllerFactory) Interface {
@@ -47,3 +49,11 @@ type version struct {
 func (v *version) NetworkFilesystem() NetworkFilesystemController {
 	return generic.NewController[*v1beta1.NetworkFilesystem, *v1beta1.NetworkFilesystemList](schema.GroupVersionKind{Group: "harvesterhci.io", Version: "v1beta1", Kind: "NetworkFilesystem"}, "networkfilesystems", true, v.controllerFactory)
 }
+
+func (v *version) NetworkFilesystemExport() NetworkFilesystemExportController {
+	return generic.NewController[*v1beta1.NetworkFilesystemExport, *v1beta1.NetworkFilesystemExportList](schema.GroupVersionKind{Group: "harvesterhci.io", Version: "v1beta1", Kind: "NetworkFilesystemExport"}, "networkfilesystemexports", true, v.controllerFactory)
+}
+
+func (v *version) NetworkFilesystemMount() NetworkFilesystemMountController {
+	return generic.NewController[*v1beta1.NetworkFilesystemMount, *v1beta1.NetworkFilesystemMountList](schema.GroupVersionKind{Group: "harvesterhci.io", Version: "v1beta1", Kind: "NetworkFilesystemMount"}, "networkfilesystemmounts", true, v.controllerFactory)
+}
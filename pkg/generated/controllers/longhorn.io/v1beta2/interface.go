@@ -31,7 +31,9 @@ func init() {
 }
 
 type Interface interface {
+	Node() NodeController
 	ShareManager() ShareManagerController
+	Volume() VolumeController
 }
 
 func New(controllerFactory controller.SharedControllerFactory) Interface {
@@ -44,6 +46,14 @@ type version struct {
 	controllerFactory controller.SharedControllerFactory
 }
 
+func (v *version) Node() NodeController {
+	return generic.NewController[*v1beta2.Node, *v1beta2.NodeList](schema.GroupVersionKind{Group: "longhorn.io", Version: "v1beta2", Kind: "Node"}, "nodes", true, v.controllerFactory)
+}
+
 func (v *version) ShareManager() ShareManagerController {
 	return generic.NewController[*v1beta2.ShareManager, *v1beta2.ShareManagerList](schema.GroupVersionKind{Group: "longhorn.io", Version: "v1beta2", Kind: "ShareManager"}, "sharemanagers", true, v.controllerFactory)
 }
+
+func (v *version) Volume() VolumeController {
+	return generic.NewController[*v1beta2.Volume, *v1beta2.VolumeList](schema.GroupVersionKind{Group: "longhorn.io", Version: "v1beta2", Kind: "Volume"}, "volumes", true, v.controllerFactory)
+}
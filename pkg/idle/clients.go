@@ -0,0 +1,82 @@
+package idle
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// the socket tables of the network namespace of the pod, tcp6 is missing when IPv6 is disabled
+var readSocketsCommand = []string{"/bin/sh", "-c", "cat /proc/net/tcp /proc/net/tcp6 2>/dev/null || true"}
+
+// the state of the established connections in /proc/net/tcp
+const tcpEstablished = "01"
+
+// ClientCounter counts the clients of the server by the established connections to its port,
+// the NFSv4 clients keep their connection open as long as the export is mounted.
+type ClientCounter struct {
+	config *rest.Config
+	client kubernetes.Interface
+}
+
+// NewClientCounter creates the counter which reads the socket tables through the pod exec
+func NewClientCounter(config *rest.Config, client kubernetes.Interface) *ClientCounter {
+	return &ClientCounter{
+		config: config,
+		client: client,
+	}
+}
+
+// Count returns the number of the distinct remote addresses connected to the port in the pod
+func (c *ClientCounter) Count(ctx context.Context, namespace, name, container string, port int32) (int, error) {
+	req := c.client.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(name).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   readSocketsCommand,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+	executor, err := remotecommand.NewSPDYExecutor(c.config, "POST", req.URL())
+	if err != nil {
+		return 0, fmt.Errorf("failed to create executor of pod %s/%s: %w", namespace, name, err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := executor.StreamWithContext(ctx, remotecommand.StreamOptions{Stdout: &stdout, Stderr: &stderr}); err != nil {
+		return 0, fmt.Errorf("failed to read sockets of pod %s/%s: %w, stderr: %s", namespace, name, err, strings.TrimSpace(stderr.String()))
+	}
+	return countClients(stdout.String(), port), nil
+}
+
+// countClients parses the /proc/net/tcp format, e.g.
+// "0: 0A2A0010:0801 0A2A0021:03FF 01 00000000:00000000 ...", the addresses are in hex.
+func countClients(sockets string, port int32) int {
+	localPort := fmt.Sprintf("%04X", port)
+	remotes := map[string]struct{}{}
+	scanner := bufio.NewScanner(strings.NewReader(sockets))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[3] != tcpEstablished {
+			continue
+		}
+		local := strings.SplitN(fields[1], ":", 2)
+		remote := strings.SplitN(fields[2], ":", 2)
+		if len(local) != 2 || len(remote) != 2 || !strings.EqualFold(local[1], localPort) {
+			continue
+		}
+		remotes[remote[0]] = struct{}{}
+	}
+	return len(remotes)
+}
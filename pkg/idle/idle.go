@@ -0,0 +1,192 @@
+package idle
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/backend"
+	ctlntefsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/generated/controllers/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+// the timeout of reading the sockets of a single server pod
+const countTimeout = 30 * time.Second
+
+// Checker periodically counts the clients of the enabled networkFS with the idleTimeout,
+// the networkFS which has had no client for the idleTimeout is disabled.
+type Checker struct {
+	interval time.Duration
+	backends backend.Backends
+	counter  *ClientCounter
+	recorder record.EventRecorder
+
+	NetworkFSCache    ctlntefsv1.NetworkFilesystemCache
+	NetworkFilsystems ctlntefsv1.NetworkFilesystemController
+}
+
+// Register starts the idle checker, it is disabled when the check interval is zero
+func Register(ctx context.Context, backends backend.Backends, netfilesystems ctlntefsv1.NetworkFilesystemController, counter *ClientCounter, recorder record.EventRecorder, opt *utils.Option) error {
+	if opt.IdleCheckInterval <= 0 {
+		logrus.Info("Idle checker is disabled")
+		return nil
+	}
+
+	c := &Checker{
+		interval:          opt.IdleCheckInterval,
+		backends:          backends,
+		counter:           counter,
+		recorder:          recorder,
+		NetworkFSCache:    netfilesystems.Cache(),
+		NetworkFilsystems: netfilesystems,
+	}
+
+	go wait.UntilWithContext(ctx, c.checkAll, c.interval)
+	return nil
+}
+
+func (c *Checker) checkAll(ctx context.Context) {
+	networkFSes, err := c.NetworkFSCache.List("", labels.Everything())
+	if err != nil {
+		logrus.Errorf("Failed to list network filesystems for idle check: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, networkFS := range networkFSes {
+		if networkFS.DeletionTimestamp != nil {
+			continue
+		}
+		if networkFS.Spec.IdleTimeout == nil || networkFS.Status.State == networkfsv1.NetworkFSStateDisabled {
+			// the idle time is counted again once the networkFS is enabled
+			c.clearActivity(networkFS)
+			continue
+		}
+		if networkFS.Status.State != networkfsv1.NetworkFSStateEnabled || networkFS.Status.Status != networkfsv1.EndpointStatusReady {
+			continue
+		}
+		wg.Add(1)
+		go func(networkFS *networkfsv1.NetworkFilesystem) {
+			defer wg.Done()
+			c.check(ctx, networkFS)
+		}(networkFS)
+	}
+	wg.Wait()
+}
+
+func (c *Checker) clearActivity(networkFS *networkfsv1.NetworkFilesystem) {
+	if networkFS.Status.Activity == nil {
+		return
+	}
+	networkFSCpy := networkFS.DeepCopy()
+	networkFSCpy.Status.Activity = nil
+	if _, err := c.NetworkFilsystems.UpdateStatus(networkFSCpy); err != nil {
+		logrus.Debugf("Failed to clear activity of network filesystem %s: %v", networkFS.Name, err)
+	}
+}
+
+func (c *Checker) count(ctx context.Context, networkFS *networkfsv1.NetworkFilesystem) (int, error) {
+	b, err := c.backends.Get(networkFS)
+	if err != nil {
+		return 0, err
+	}
+	locator, ok := b.(backend.ServerPodLocator)
+	if !ok {
+		return 0, fmt.Errorf("export backend %s does not report the clients", backend.TypeOf(networkFS))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, countTimeout)
+	defer cancel()
+	namespace, name, container := locator.ServerPod(networkFS)
+	return c.counter.Count(ctx, namespace, name, container, backend.PortOf(backend.ProtocolOf(networkFS)))
+}
+
+func (c *Checker) check(ctx context.Context, networkFS *networkfsv1.NetworkFilesystem) {
+	clients, err := c.count(ctx, networkFS)
+	if err != nil {
+		// the networkFS is never disabled while its clients are unknown
+		logrus.Warnf("Failed to count clients of network filesystem %s: %v", networkFS.Name, err)
+		return
+	}
+
+	// the count may take a while, update the latest one
+	latest, err := c.NetworkFSCache.Get(networkFS.Namespace, networkFS.Name)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			logrus.Errorf("Failed to get network filesystem %s after counting clients: %v", networkFS.Name, err)
+		}
+		return
+	}
+	networkFS = latest
+	if networkFS.Spec.IdleTimeout == nil || networkFS.Status.State != networkfsv1.NetworkFSStateEnabled {
+		return
+	}
+
+	now := time.Now()
+	networkFSCpy := networkFS.DeepCopy()
+	activity := &networkfsv1.ActivityStatus{Clients: int32(clients)}
+	cond := networkfsv1.NetworkFSCondition{
+		Type:               networkfsv1.ConditionTypeIdle,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             "Clients connected",
+		Message:            "The server has connected clients",
+	}
+	if clients == 0 {
+		activity.IdleSince = &metav1.Time{Time: now}
+		if networkFS.Status.Activity != nil && networkFS.Status.Activity.IdleSince != nil {
+			activity.IdleSince = networkFS.Status.Activity.IdleSince
+		}
+		timeout := networkFS.Spec.IdleTimeout.Duration
+		cond.Reason = "No client"
+		cond.Message = fmt.Sprintf("The server has had no client since %s, it is disabled after %s", activity.IdleSince.Format(time.RFC3339), timeout)
+		if now.Sub(activity.IdleSince.Time) >= timeout {
+			cond.Status = corev1.ConditionTrue
+			cond.Reason = "Idle timeout"
+			cond.Message = fmt.Sprintf("The server had no client since %s for the idleTimeout %s, the networkFS is disabled", activity.IdleSince.Format(time.RFC3339), timeout)
+		}
+	}
+	networkFSCpy.Status.Activity = activity
+	networkFSCpy.Status.NetworkFSConds = utils.UpdateNetworkFSConds(networkFSCpy.Status.NetworkFSConds, cond)
+
+	if !reflect.DeepEqual(networkFS.Status, networkFSCpy.Status) {
+		// the condition is recorded before the networkFS is disabled, so the reason is kept even if it is disabled at once
+		updated, err := c.NetworkFilsystems.UpdateStatus(networkFSCpy)
+		if err != nil {
+			// conflicts with the controller are expected, the next round checks it again
+			logrus.Debugf("Failed to update activity of network filesystem %s: %v", networkFS.Name, err)
+			return
+		}
+		networkFSCpy = updated
+	}
+	if cond.Status == corev1.ConditionTrue {
+		c.disable(networkFSCpy, cond.Message)
+	}
+}
+
+// disable sets the desiredState to Disabled, it fails while the networkFS is transitioning and is retried in the next round
+func (c *Checker) disable(networkFS *networkfsv1.NetworkFilesystem, message string) {
+	if networkFS.Spec.DesiredState == networkfsv1.NetworkFSStateDisabled {
+		return
+	}
+	logrus.Infof("Disable idle network filesystem %s: %s", networkFS.Name, message)
+	networkFSCpy := networkFS.DeepCopy()
+	networkFSCpy.Spec.DesiredState = networkfsv1.NetworkFSStateDisabled
+	updated, err := c.NetworkFilsystems.Update(networkFSCpy)
+	if err != nil {
+		logrus.Errorf("Failed to disable idle network filesystem %s: %v", networkFS.Name, err)
+		return
+	}
+	c.recorder.Event(updated, corev1.EventTypeNormal, utils.EventReasonIdleDisabled, message)
+}
@@ -0,0 +1,221 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/labels"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	ctlntefsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/generated/controllers/harvesterhci.io/v1beta1"
+)
+
+const (
+	namespace = "networkfs_manager"
+
+	// MetricsPath is the path of the metrics endpoint
+	MetricsPath = "/metrics"
+
+	// TransitionEnable is the transition from Enabling to Enabled
+	TransitionEnable = "enable"
+	// TransitionDisable is the transition from Disabling to Disabled
+	TransitionDisable = "disable"
+)
+
+var (
+	registry = prometheus.NewRegistry()
+
+	reconcileErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconcile_errors_total",
+		Help:      "Number of the reconcile errors per controller",
+	}, []string{"controller"})
+
+	transitionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "transition_duration_seconds",
+		Help:      "Duration of the enable/disable transitions of the network filesystems",
+		Buckets:   []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800},
+	}, []string{"transition"})
+
+	endpointChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "endpoint_changes_total",
+		Help:      "Number of the endpoint changes per network filesystem",
+	}, []string{"namespace", "name"})
+
+	stateDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "networkfilesystem", "state"),
+		"Current state of the network filesystem, 1 for the current state and 0 for the others",
+		[]string{"namespace", "name", "state"}, nil)
+
+	endpointReadyDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "networkfilesystem", "endpoint_ready"),
+		"Whether the endpoint of the network filesystem is ready",
+		[]string{"namespace", "name"}, nil)
+
+	states = []networkfsv1.NetworkFSState{
+		networkfsv1.NetworkFSStateEnabling,
+		networkfsv1.NetworkFSStateEnabled,
+		networkfsv1.NetworkFSStateDisabling,
+		networkfsv1.NetworkFSStateDisabled,
+		networkfsv1.NetworkFSStateUnknown,
+	}
+
+	transitions = &transitionTracker{started: map[string]transitionStart{}}
+)
+
+func init() {
+	registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		reconcileErrors,
+		transitionDuration,
+		endpointChanges,
+	)
+	// expose the counters of every controller even before the first error
+	for _, controller := range []string{"networkfilesystem", "endpoint", "sharemanager", "volume"} {
+		reconcileErrors.WithLabelValues(controller)
+	}
+}
+
+// ListenAndServe serves the metrics on the port until the context is done
+func ListenAndServe(ctx context.Context, port int) error {
+	mux := http.NewServeMux()
+	mux.Handle(MetricsPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry}))
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = server.Shutdown(context.Background())
+	}()
+
+	logrus.Infof("Starting metrics server on :%d%s", port, MetricsPath)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// RegisterNetworkFSCollector exposes the state of the network filesystems in the cache, it is only
+// registered by the leader because the caches of the other replicas are not started.
+func RegisterNetworkFSCollector(cache ctlntefsv1.NetworkFilesystemCache) error {
+	return registry.Register(&networkFSCollector{cache: cache})
+}
+
+// CountErrors wraps the handler and counts its errors as the reconcile errors of the controller
+func CountErrors[T any](controller string, handler func(string, T) (T, error)) func(string, T) (T, error) {
+	counter := reconcileErrors.WithLabelValues(controller)
+	return func(key string, obj T) (T, error) {
+		ret, err := handler(key, obj)
+		if err != nil {
+			counter.Inc()
+		}
+		return ret, err
+	}
+}
+
+// EndpointChanged counts the endpoint change of the network filesystem
+func EndpointChanged(networkFS *networkfsv1.NetworkFilesystem) {
+	endpointChanges.WithLabelValues(networkFS.Namespace, networkFS.Name).Inc()
+}
+
+// TransitionStarted records the start of the transition, the earlier start of the same transition is kept
+func TransitionStarted(networkFS *networkfsv1.NetworkFilesystem, transition string) {
+	transitions.start(key(networkFS), transition)
+}
+
+// TransitionFinished observes the duration of the transition if its start is recorded
+func TransitionFinished(networkFS *networkfsv1.NetworkFilesystem, transition string) {
+	if duration, found := transitions.finish(key(networkFS), transition); found {
+		transitionDuration.WithLabelValues(transition).Observe(duration.Seconds())
+	}
+}
+
+// Forget drops the metrics of the removed network filesystem
+func Forget(networkFS *networkfsv1.NetworkFilesystem) {
+	endpointChanges.DeleteLabelValues(networkFS.Namespace, networkFS.Name)
+	transitions.forget(key(networkFS))
+}
+
+func key(networkFS *networkfsv1.NetworkFilesystem) string {
+	return networkFS.Namespace + "/" + networkFS.Name
+}
+
+type transitionStart struct {
+	transition string
+	time       time.Time
+}
+
+type transitionTracker struct {
+	mutex   sync.Mutex
+	started map[string]transitionStart
+}
+
+func (t *transitionTracker) start(key, transition string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if cur, found := t.started[key]; found && cur.transition == transition {
+		return
+	}
+	t.started[key] = transitionStart{transition: transition, time: time.Now()}
+}
+
+func (t *transitionTracker) finish(key, transition string) (time.Duration, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	cur, found := t.started[key]
+	if !found || cur.transition != transition {
+		return 0, false
+	}
+	delete(t.started, key)
+	return time.Since(cur.time), true
+}
+
+func (t *transitionTracker) forget(key string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.started, key)
+}
+
+type networkFSCollector struct {
+	cache ctlntefsv1.NetworkFilesystemCache
+}
+
+func (c *networkFSCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- stateDesc
+	ch <- endpointReadyDesc
+}
+
+func (c *networkFSCollector) Collect(ch chan<- prometheus.Metric) {
+	networkFSes, err := c.cache.List("", labels.Everything())
+	if err != nil {
+		logrus.Errorf("Failed to list network filesystems for metrics: %v", err)
+		return
+	}
+	for _, networkFS := range networkFSes {
+		for _, state := range states {
+			value := 0.0
+			if networkFS.Status.State == state {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(stateDesc, prometheus.GaugeValue, value, networkFS.Namespace, networkFS.Name, string(state))
+		}
+		ready := 0.0
+		if networkFS.Status.Status == networkfsv1.EndpointStatusReady {
+			ready = 1
+		}
+		ch <- prometheus.MustNewConstMetric(endpointReadyDesc, prometheus.GaugeValue, ready, networkFS.Namespace, networkFS.Name)
+	}
+}
@@ -0,0 +1,90 @@
+package mounter
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultHostProcPath is where the DaemonSet mounts the procfs of the host
+const DefaultHostProcPath = "/host/proc"
+
+// Mounter mounts the network filesystems on the host
+type Mounter interface {
+	// Mount mounts the source to the target with the filesystem type and options, the target is created if it does not exist
+	Mount(source, target, fsType string, options []string) error
+	// Unmount unmounts the target, the lazy unmount detaches it even if it is busy or the server is unreachable
+	Unmount(target string, lazy bool) error
+	// List returns the mounts of the host
+	List() ([]MountInfo, error)
+}
+
+// HostMounter runs the mount commands in the mount namespace of the host (the one of the init process)
+type HostMounter struct {
+	// ProcPath is the path of the procfs of the host
+	ProcPath string
+	// MountInfoPath is the mountinfo of the host mount namespace, it could be replaced with a fixture
+	MountInfoPath string
+}
+
+// NewHostMounter creates the mounter with the procfs of the host mounted at the procPath
+func NewHostMounter(procPath string) *HostMounter {
+	if procPath == "" {
+		procPath = DefaultHostProcPath
+	}
+	// the mountinfo of the init process, the one of the agent itself is in the container mount namespace
+	return &HostMounter{
+		ProcPath:      procPath,
+		MountInfoPath: filepath.Join(procPath, "1", "mountinfo"),
+	}
+}
+
+func (m *HostMounter) Mount(source, target, fsType string, options []string) error {
+	if err := m.run("mkdir", "-p", target); err != nil {
+		return err
+	}
+	args := []string{"-t", fsType}
+	if len(options) > 0 {
+		args = append(args, "-o", strings.Join(options, ","))
+	}
+	return m.run("mount", append(args, source, target)...)
+}
+
+func (m *HostMounter) Unmount(target string, lazy bool) error {
+	if lazy {
+		return m.run("umount", "-l", target)
+	}
+	return m.run("umount", target)
+}
+
+func (m *HostMounter) List() ([]MountInfo, error) {
+	file, err := os.Open(m.MountInfoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseMountInfo(file)
+}
+
+func (m *HostMounter) run(name string, args ...string) error {
+	nsArgs := append([]string{"--mount=" + filepath.Join(m.ProcPath, "1", "ns", "mnt"), "--", name}, args...)
+	out, err := exec.Command("nsenter", nsArgs...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w, output: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// FindMount returns the mount of the mount point, the last one wins if the mount point is mounted more than once
+func FindMount(mounts []MountInfo, mountPoint string) *MountInfo {
+	mountPoint = filepath.Clean(mountPoint)
+	var found *MountInfo
+	for i := range mounts {
+		if mounts[i].MountPoint == mountPoint {
+			found = &mounts[i]
+		}
+	}
+	return found
+}
@@ -0,0 +1,116 @@
+package mounter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MountInfo is an entry of the mountinfo file, see proc(5)
+type MountInfo struct {
+	ID           int
+	ParentID     int
+	Root         string
+	MountPoint   string
+	Options      string
+	FSType       string
+	Source       string
+	SuperOptions string
+}
+
+// IsNFS returns whether the mount is a NFS mount
+func (m *MountInfo) IsNFS() bool {
+	return m.FSType == "nfs" || m.FSType == "nfs4"
+}
+
+// NFSServer returns the server address of the NFS mount, the resolved "addr" option is preferred over the source
+// because the source may be a hostname
+func (m *MountInfo) NFSServer() string {
+	for _, opt := range strings.Split(m.SuperOptions, ",") {
+		if addr, found := strings.CutPrefix(opt, "addr="); found {
+			return addr
+		}
+	}
+	server, _, _ := strings.Cut(m.Source, ":/")
+	return strings.TrimSuffix(strings.TrimPrefix(server, "["), "]")
+}
+
+// NFSPath returns the exported path of the NFS mount
+func (m *MountInfo) NFSPath() string {
+	if i := strings.Index(m.Source, ":/"); i >= 0 {
+		return m.Source[i+1:]
+	}
+	return ""
+}
+
+// ParseMountInfo parses the mountinfo file, e.g.
+// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - nfs4 10.0.0.1:/pvc-1 rw,vers=4.1,addr=10.0.0.1
+func ParseMountInfo(r io.Reader) ([]MountInfo, error) {
+	var mounts []MountInfo
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		// the optional fields are terminated by a single hyphen
+		separator := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				separator = i
+				break
+			}
+		}
+		if separator < 0 || len(fields) < separator+3 {
+			return nil, fmt.Errorf("malformed mountinfo line: %q", line)
+		}
+
+		id, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("malformed mount ID in mountinfo line %q: %w", line, err)
+		}
+		parentID, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("malformed parent ID in mountinfo line %q: %w", line, err)
+		}
+		mount := MountInfo{
+			ID:         id,
+			ParentID:   parentID,
+			Root:       unescape(fields[3]),
+			MountPoint: unescape(fields[4]),
+			Options:    fields[5],
+			FSType:     fields[separator+1],
+			Source:     unescape(fields[separator+2]),
+		}
+		if len(fields) > separator+3 {
+			mount.SuperOptions = fields[separator+3]
+		}
+		mounts = append(mounts, mount)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return mounts, nil
+}
+
+// unescape decodes the octal escapes (e.g. \040 for the space) of the mountinfo fields
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,131 @@
+package mounter
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMountInfo(t *testing.T) {
+	f, err := os.Open("testdata/mountinfo")
+	if err != nil {
+		t.Fatalf("failed to open the mountinfo fixture: %v", err)
+	}
+	defer f.Close()
+
+	mounts, err := ParseMountInfo(f)
+	if err != nil {
+		t.Fatalf("failed to parse the mountinfo fixture: %v", err)
+	}
+	if len(mounts) != 7 {
+		t.Fatalf("expected 7 mounts, got %d", len(mounts))
+	}
+
+	want := MountInfo{
+		ID:           613,
+		ParentID:     25,
+		Root:         "/",
+		MountPoint:   "/mnt/read only",
+		Options:      "ro,relatime",
+		FSType:       "nfs4",
+		Source:       "nfs.example.com:/pvc-1234/data",
+		SuperOptions: "ro,vers=4.2,hard,proto=tcp,addr=10.53.0.11",
+	}
+	if !reflect.DeepEqual(mounts[4], want) {
+		t.Errorf("expected mount %+v, got %+v", want, mounts[4])
+	}
+	if mounts[1].SuperOptions != "rw" || mounts[1].MountPoint != "/" {
+		t.Errorf("unexpected root mount %+v", mounts[1])
+	}
+}
+
+func TestParseMountInfoMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		wantErr string
+	}{
+		{
+			name:    "no separator",
+			line:    "25 1 253:1 / / rw,relatime shared:1 ext4 /dev/vda1 rw",
+			wantErr: "malformed mountinfo line",
+		},
+		{
+			name:    "no source after the separator",
+			line:    "25 1 253:1 / / rw,relatime shared:1 - ext4",
+			wantErr: "malformed mountinfo line",
+		},
+		{
+			name:    "mount ID is not a number",
+			line:    "x 1 253:1 / / rw,relatime - ext4 /dev/vda1 rw",
+			wantErr: "malformed mount ID",
+		},
+		{
+			name:    "parent ID is not a number",
+			line:    "25 x 253:1 / / rw,relatime - ext4 /dev/vda1 rw",
+			wantErr: "malformed parent ID",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseMountInfo(strings.NewReader(tt.line + "\n"))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestMountInfoNFS(t *testing.T) {
+	f, err := os.Open("testdata/mountinfo")
+	if err != nil {
+		t.Fatalf("failed to open the mountinfo fixture: %v", err)
+	}
+	defer f.Close()
+	mounts, err := ParseMountInfo(f)
+	if err != nil {
+		t.Fatalf("failed to parse the mountinfo fixture: %v", err)
+	}
+
+	tests := []struct {
+		mountPoint string
+		wantNFS    bool
+		wantServer string
+		wantPath   string
+	}{
+		{mountPoint: "/run"},
+		{mountPoint: "/mnt/share", wantNFS: true, wantServer: "10.53.0.10", wantPath: "/pvc-1234"},
+		// the resolved address is preferred over the hostname of the source
+		{mountPoint: "/mnt/read only", wantNFS: true, wantServer: "10.53.0.11", wantPath: "/pvc-1234/data"},
+		// NFSv3 has no addr option, the brackets of the IPv6 source are dropped
+		{mountPoint: "/mnt/legacy", wantNFS: true, wantServer: "fd00::10", wantPath: "/exports/home"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mountPoint, func(t *testing.T) {
+			var m *MountInfo
+			for i := range mounts {
+				if mounts[i].MountPoint == tt.mountPoint {
+					m = &mounts[i]
+				}
+			}
+			if m == nil {
+				t.Fatalf("mount %s is not in the fixture", tt.mountPoint)
+			}
+			if m.IsNFS() != tt.wantNFS {
+				t.Fatalf("expected IsNFS %v, got %v", tt.wantNFS, m.IsNFS())
+			}
+			if !tt.wantNFS {
+				return
+			}
+			if server := m.NFSServer(); server != tt.wantServer {
+				t.Errorf("expected server %q, got %q", tt.wantServer, server)
+			}
+			if path := m.NFSPath(); path != tt.wantPath {
+				t.Errorf("expected path %q, got %q", tt.wantPath, path)
+			}
+		})
+	}
+}
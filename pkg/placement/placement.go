@@ -0,0 +1,272 @@
+package placement
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	longhornv2 "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	ctlcorev1 "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/backend"
+	ctllonghornv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/generated/controllers/longhorn.io/v1beta2"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+const (
+	placementNodeHandlerName   = "harvester-network-filesystem-placement-node-handler"
+	placementLHNodeHandlerName = "harvester-network-filesystem-placement-lhnode-handler"
+)
+
+// Placer chooses the node which hosts the export of the networkFS among its candidate nodes
+type Placer struct {
+	// keepDraining keeps the running export on the draining node, it is migrated off the node by the drain controller
+	keepDraining bool
+
+	Nodes       ctlcorev1.NodeController
+	NodeCache   ctlcorev1.NodeCache
+	LHNodes     ctllonghornv1.NodeController
+	LHNodeCache ctllonghornv1.NodeCache
+}
+
+// New creates the placer which evaluates the candidates against the nodes and the Longhorn nodes,
+// the running exports stay on the draining node when the drain controller is enabled
+func New(nodes ctlcorev1.NodeController, lhNodes ctllonghornv1.NodeController, opt *utils.Option) *Placer {
+	return &Placer{
+		keepDraining: opt.DrainMaxMigrations > 0,
+		Nodes:        nodes,
+		NodeCache:    nodes.Cache(),
+		LHNodes:      lhNodes,
+		LHNodeCache:  lhNodes.Cache(),
+	}
+}
+
+// Watch calls enqueue with the name of the node whenever its eligibility may change
+func (p *Placer) Watch(ctx context.Context, enqueue func(node string)) {
+	p.Nodes.OnChange(ctx, placementNodeHandlerName, func(_ string, node *corev1.Node) (*corev1.Node, error) {
+		if node != nil {
+			enqueue(node.Name)
+		}
+		return nil, nil
+	})
+	p.LHNodes.OnChange(ctx, placementLHNodeHandlerName, func(_ string, node *longhornv2.Node) (*longhornv2.Node, error) {
+		if node != nil {
+			enqueue(node.Name)
+		}
+		return nil, nil
+	})
+}
+
+// HasRules returns whether the node of the networkFS is chosen by the manager, the backend picks it otherwise
+func HasRules(networkFS *networkfsv1.NetworkFilesystem) bool {
+	return networkFS.Spec.TargetNode != "" || len(CandidateNodes(networkFS)) > 0 || len(networkFS.Spec.NodeSelector) > 0
+}
+
+// CandidateNodes returns the ordered candidate nodes named in the spec,
+// the deprecated preferred node is the only candidate when the candidateNodes is empty.
+func CandidateNodes(networkFS *networkfsv1.NetworkFilesystem) []string {
+	if len(networkFS.Spec.CandidateNodes) > 0 {
+		return networkFS.Spec.CandidateNodes
+	}
+	if networkFS.Spec.PreferredNode != "" {
+		return []string{networkFS.Spec.PreferredNode}
+	}
+	return nil
+}
+
+// Select returns the node which should host the export, it is empty when the networkFS has no placement rule.
+// The eligible targetNode always wins, otherwise the current node is kept while it is eligible, so a recovered
+// candidate does not take the export back.
+func (p *Placer) Select(networkFS *networkfsv1.NetworkFilesystem) (string, error) {
+	if !HasRules(networkFS) {
+		return "", nil
+	}
+	if target := networkFS.Spec.TargetNode; target != "" {
+		err := p.Eligible(networkFS, target)
+		if err == nil {
+			return target, nil
+		}
+		if target == networkFS.Status.Node && p.canKeep(networkFS, target) == nil {
+			return target, nil
+		}
+		if len(CandidateNodes(networkFS)) == 0 && len(networkFS.Spec.NodeSelector) == 0 {
+			return "", fmt.Errorf("target node is not eligible: %w", err)
+		}
+	}
+	candidates, err := p.candidates(networkFS)
+	if err != nil {
+		return "", err
+	}
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no node matches the nodeSelector")
+	}
+
+	if current := networkFS.Status.Node; current != "" && slices.Contains(candidates, current) {
+		if err := p.canKeep(networkFS, current); err == nil {
+			return current, nil
+		}
+	}
+	var reasons []string
+	for _, name := range candidates {
+		err := p.Eligible(networkFS, name)
+		if err == nil {
+			return name, nil
+		}
+		reasons = append(reasons, err.Error())
+	}
+	return "", fmt.Errorf("no eligible candidate node: %s", strings.Join(reasons, "; "))
+}
+
+// Evacuate returns the node to which the export is migrated off the draining node. The candidates are chosen in
+// order for the networkFS with the placement rules, any eligible node is chosen otherwise.
+func (p *Placer) Evacuate(networkFS *networkfsv1.NetworkFilesystem, from string) (string, error) {
+	// the targetNode pinned the export to the draining node, the other rules decide where it goes
+	networkFSCpy := networkFS.DeepCopy()
+	networkFSCpy.Spec.TargetNode = ""
+	networkFSCpy.Status.Node = ""
+	if HasRules(networkFSCpy) {
+		return p.Select(networkFSCpy)
+	}
+
+	nodes, err := p.NodeCache.List(labels.Everything())
+	if err != nil {
+		return "", fmt.Errorf("failed to list nodes: %w", err)
+	}
+	names := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		if node.Name != from {
+			names = append(names, node.Name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := p.Eligible(networkFS, name); err == nil {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("no eligible node other than %s", from)
+}
+
+// Eligible returns why the node can not host the export of the networkFS, it is nil when the node can
+func (p *Placer) Eligible(networkFS *networkfsv1.NetworkFilesystem, name string) error {
+	return p.eligible(networkFS, name, false)
+}
+
+// canKeep returns why the node can not keep the running export, the draining node keeps it when keepDraining is set
+func (p *Placer) canKeep(networkFS *networkfsv1.NetworkFilesystem, name string) error {
+	return p.eligible(networkFS, name, p.keepDraining)
+}
+
+func (p *Placer) eligible(networkFS *networkfsv1.NetworkFilesystem, name string, keepDraining bool) error {
+	node, err := p.NodeCache.Get(name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("node %s is not found", name)
+		}
+		return fmt.Errorf("failed to get node %s: %w", name, err)
+	}
+	if !labels.SelectorFromSet(networkFS.Spec.NodeSelector).Matches(labels.Set(node.Labels)) {
+		return fmt.Errorf("node %s does not match the nodeSelector", name)
+	}
+	if !keepDraining {
+		if node.Spec.Unschedulable {
+			return fmt.Errorf("node %s is cordoned", name)
+		}
+		if status := node.Annotations[utils.AnnotationMaintainStatus]; status != "" {
+			return fmt.Errorf("node %s is in maintenance mode (%s)", name, status)
+		}
+	}
+	if !isNodeReady(node) {
+		return fmt.Errorf("node %s is not ready", name)
+	}
+	if backend.TypeOf(networkFS) != networkfsv1.ExportBackendLonghorn {
+		return nil
+	}
+
+	// the share manager needs the Longhorn engine on the node to attach the volume
+	lhNode, err := p.LHNodeCache.Get(utils.LHNameSpace, name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("node %s is not a longhorn node", name)
+		}
+		return fmt.Errorf("failed to get longhorn node %s: %w", name, err)
+	}
+	if !isConditionTrue(lhNode.Status.Conditions, longhornv2.NodeConditionTypeReady) {
+		return fmt.Errorf("longhorn node %s is not ready", name)
+	}
+	if keepDraining {
+		// the volume is already attached, the draining Longhorn node may refuse the new replicas
+		return nil
+	}
+	if !utils.IsLHNodeSchedulable(lhNode) {
+		return fmt.Errorf("longhorn node %s is not schedulable", name)
+	}
+	if !hasHealthyDisk(lhNode) {
+		return fmt.Errorf("longhorn node %s has no healthy disk", name)
+	}
+	return nil
+}
+
+// candidates returns the ordered candidate nodes, the nodes matched by the nodeSelector are ordered by name
+func (p *Placer) candidates(networkFS *networkfsv1.NetworkFilesystem) ([]string, error) {
+	if names := CandidateNodes(networkFS); len(names) > 0 {
+		// the nodeSelector is checked by Eligible, so the reason is reported for each candidate
+		return names, nil
+	}
+	nodes, err := p.NodeCache.List(labels.SelectorFromSet(networkFS.Spec.NodeSelector))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	names := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		names = append(names, node.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// IsDraining returns whether the node is cordoned or in the Harvester maintenance mode, the exports on it are evacuated
+func IsDraining(node *corev1.Node) bool {
+	return node.Spec.Unschedulable || node.Annotations[utils.AnnotationMaintainStatus] != ""
+}
+
+func isNodeReady(node *corev1.Node) bool {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// hasHealthyDisk checks the Longhorn node has a disk which is ready and schedulable
+func hasHealthyDisk(node *longhornv2.Node) bool {
+	for name, disk := range node.Spec.Disks {
+		if !disk.AllowScheduling || disk.EvictionRequested {
+			continue
+		}
+		status, found := node.Status.DiskStatus[name]
+		if !found || status == nil {
+			continue
+		}
+		if isConditionTrue(status.Conditions, longhornv2.DiskConditionTypeReady) && isConditionTrue(status.Conditions, longhornv2.DiskConditionTypeSchedulable) {
+			return true
+		}
+	}
+	return false
+}
+
+func isConditionTrue(conds []longhornv2.Condition, condType string) bool {
+	for _, cond := range conds {
+		if cond.Type == condType {
+			return cond.Status == longhornv2.ConditionStatusTrue
+		}
+	}
+	return false
+}
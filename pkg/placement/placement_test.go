@@ -0,0 +1,359 @@
+package placement
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	longhornv2 "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	ctlcorev1 "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	ctllonghornv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/generated/controllers/longhorn.io/v1beta2"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+// testNodeCache serves the nodes of the test, the placer only gets and lists them
+type testNodeCache struct {
+	ctlcorev1.NodeCache
+	nodes map[string]*corev1.Node
+}
+
+func (c *testNodeCache) Get(name string) (*corev1.Node, error) {
+	if node, found := c.nodes[name]; found {
+		return node, nil
+	}
+	return nil, apierrors.NewNotFound(corev1.Resource("nodes"), name)
+}
+
+func (c *testNodeCache) List(selector labels.Selector) ([]*corev1.Node, error) {
+	var nodes []*corev1.Node
+	for _, node := range c.nodes {
+		if selector.Matches(labels.Set(node.Labels)) {
+			nodes = append(nodes, node)
+		}
+	}
+	// the order of the cache is random, the placer must not depend on it
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name > nodes[j].Name })
+	return nodes, nil
+}
+
+// testLHNodeCache serves the Longhorn nodes of the test
+type testLHNodeCache struct {
+	ctllonghornv1.NodeCache
+	nodes map[string]*longhornv2.Node
+}
+
+func (c *testLHNodeCache) Get(namespace, name string) (*longhornv2.Node, error) {
+	if node, found := c.nodes[namespace+"/"+name]; found {
+		return node, nil
+	}
+	return nil, apierrors.NewNotFound(longhornv2.Resource("nodes"), name)
+}
+
+func testNode(name string, modify func(*corev1.Node)) *corev1.Node {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+		},
+	}
+	if modify != nil {
+		modify(node)
+	}
+	return node
+}
+
+func testLHNode(name string, modify func(*longhornv2.Node)) *longhornv2.Node {
+	ready := []longhornv2.Condition{
+		{Type: longhornv2.DiskConditionTypeReady, Status: longhornv2.ConditionStatusTrue},
+		{Type: longhornv2.DiskConditionTypeSchedulable, Status: longhornv2.ConditionStatusTrue},
+	}
+	node := &longhornv2.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: utils.LHNameSpace},
+		Spec: longhornv2.NodeSpec{
+			AllowScheduling: true,
+			Disks:           map[string]longhornv2.DiskSpec{"disk-1": {AllowScheduling: true}},
+		},
+		Status: longhornv2.NodeStatus{
+			Conditions: []longhornv2.Condition{
+				{Type: longhornv2.NodeConditionTypeReady, Status: longhornv2.ConditionStatusTrue},
+				{Type: longhornv2.NodeConditionTypeSchedulable, Status: longhornv2.ConditionStatusTrue},
+			},
+			DiskStatus: map[string]*longhornv2.DiskStatus{"disk-1": {Conditions: ready}},
+		},
+	}
+	if modify != nil {
+		modify(node)
+	}
+	return node
+}
+
+func testPlacer(keepDraining bool) *Placer {
+	zoneA := func(node *corev1.Node) { node.Labels = map[string]string{"zone": "a"} }
+	nodes := []*corev1.Node{
+		testNode("node-1", nil),
+		testNode("node-2", zoneA),
+		testNode("node-3", zoneA),
+		testNode("node-cordoned", func(node *corev1.Node) { node.Spec.Unschedulable = true }),
+		testNode("node-maintained", func(node *corev1.Node) {
+			node.Annotations = map[string]string{utils.AnnotationMaintainStatus: "running"}
+		}),
+		testNode("node-not-ready", func(node *corev1.Node) { node.Status.Conditions[0].Status = corev1.ConditionFalse }),
+		testNode("node-without-longhorn", nil),
+		testNode("node-without-disk", nil),
+	}
+	lhNodes := []*longhornv2.Node{
+		testLHNode("node-1", nil),
+		testLHNode("node-2", nil),
+		testLHNode("node-3", nil),
+		testLHNode("node-cordoned", nil),
+		testLHNode("node-maintained", nil),
+		testLHNode("node-not-ready", nil),
+		testLHNode("node-without-disk", func(node *longhornv2.Node) { node.Spec.Disks["disk-1"] = longhornv2.DiskSpec{EvictionRequested: true} }),
+	}
+
+	p := &Placer{
+		keepDraining: keepDraining,
+		NodeCache:    &testNodeCache{nodes: map[string]*corev1.Node{}},
+		LHNodeCache:  &testLHNodeCache{nodes: map[string]*longhornv2.Node{}},
+	}
+	for _, node := range nodes {
+		p.NodeCache.(*testNodeCache).nodes[node.Name] = node
+	}
+	for _, node := range lhNodes {
+		p.LHNodeCache.(*testLHNodeCache).nodes[node.Namespace+"/"+node.Name] = node
+	}
+	return p
+}
+
+func testNetworkFS(modify func(*networkfsv1.NetworkFilesystem)) *networkfsv1.NetworkFilesystem {
+	networkFS := &networkfsv1.NetworkFilesystem{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc-1234", Namespace: "harvester-system"},
+		Spec:       networkfsv1.NetworkFSSpec{NetworkFSName: "pvc-1234"},
+	}
+	if modify != nil {
+		modify(networkFS)
+	}
+	return networkFS
+}
+
+func TestSelect(t *testing.T) {
+	tests := []struct {
+		name         string
+		networkFS    *networkfsv1.NetworkFilesystem
+		keepDraining bool
+		want         string
+		wantErr      string
+	}{
+		{
+			name:      "no placement rule",
+			networkFS: testNetworkFS(nil),
+		},
+		{
+			name: "eligible target node",
+			networkFS: testNetworkFS(func(networkFS *networkfsv1.NetworkFilesystem) {
+				networkFS.Spec.TargetNode = "node-2"
+				networkFS.Spec.CandidateNodes = []string{"node-1"}
+				networkFS.Status.Node = "node-1"
+			}),
+			want: "node-2",
+		},
+		{
+			name: "target node which is not eligible without the other rules",
+			networkFS: testNetworkFS(func(networkFS *networkfsv1.NetworkFilesystem) {
+				networkFS.Spec.TargetNode = "node-not-ready"
+			}),
+			wantErr: "target node is not eligible: node node-not-ready is not ready",
+		},
+		{
+			name: "target node which is not eligible falls back to the candidates",
+			networkFS: testNetworkFS(func(networkFS *networkfsv1.NetworkFilesystem) {
+				networkFS.Spec.TargetNode = "node-cordoned"
+				networkFS.Spec.CandidateNodes = []string{"node-1", "node-2"}
+			}),
+			want: "node-1",
+		},
+		{
+			name: "draining target node keeps the running export",
+			networkFS: testNetworkFS(func(networkFS *networkfsv1.NetworkFilesystem) {
+				networkFS.Spec.TargetNode = "node-cordoned"
+				networkFS.Status.Node = "node-cordoned"
+			}),
+			keepDraining: true,
+			want:         "node-cordoned",
+		},
+		{
+			name: "first eligible candidate",
+			networkFS: testNetworkFS(func(networkFS *networkfsv1.NetworkFilesystem) {
+				networkFS.Spec.CandidateNodes = []string{"node-not-ready", "node-without-longhorn", "node-3", "node-1"}
+			}),
+			want: "node-3",
+		},
+		{
+			name: "deprecated preferred node",
+			networkFS: testNetworkFS(func(networkFS *networkfsv1.NetworkFilesystem) {
+				networkFS.Spec.PreferredNode = "node-2"
+			}),
+			want: "node-2",
+		},
+		{
+			name: "current node is kept over the recovered candidate",
+			networkFS: testNetworkFS(func(networkFS *networkfsv1.NetworkFilesystem) {
+				networkFS.Spec.CandidateNodes = []string{"node-1", "node-2"}
+				networkFS.Status.Node = "node-2"
+			}),
+			want: "node-2",
+		},
+		{
+			name: "cordoned current node fails over",
+			networkFS: testNetworkFS(func(networkFS *networkfsv1.NetworkFilesystem) {
+				networkFS.Spec.CandidateNodes = []string{"node-cordoned", "node-2"}
+				networkFS.Status.Node = "node-cordoned"
+			}),
+			want: "node-2",
+		},
+		{
+			name: "candidate in maintenance mode",
+			networkFS: testNetworkFS(func(networkFS *networkfsv1.NetworkFilesystem) {
+				networkFS.Spec.CandidateNodes = []string{"node-maintained", "node-1"}
+			}),
+			want: "node-1",
+		},
+		{
+			name: "draining current node keeps the running export",
+			networkFS: testNetworkFS(func(networkFS *networkfsv1.NetworkFilesystem) {
+				networkFS.Spec.CandidateNodes = []string{"node-1", "node-maintained"}
+				networkFS.Status.Node = "node-maintained"
+			}),
+			keepDraining: true,
+			want:         "node-maintained",
+		},
+		{
+			name: "nodes of the nodeSelector are ordered by name",
+			networkFS: testNetworkFS(func(networkFS *networkfsv1.NetworkFilesystem) {
+				networkFS.Spec.NodeSelector = map[string]string{"zone": "a"}
+			}),
+			want: "node-2",
+		},
+		{
+			name: "candidate which does not match the nodeSelector",
+			networkFS: testNetworkFS(func(networkFS *networkfsv1.NetworkFilesystem) {
+				networkFS.Spec.CandidateNodes = []string{"node-1", "node-3"}
+				networkFS.Spec.NodeSelector = map[string]string{"zone": "a"}
+			}),
+			want: "node-3",
+		},
+		{
+			name: "nodeSelector which matches no node",
+			networkFS: testNetworkFS(func(networkFS *networkfsv1.NetworkFilesystem) {
+				networkFS.Spec.NodeSelector = map[string]string{"zone": "b"}
+			}),
+			wantErr: "no node matches the nodeSelector",
+		},
+		{
+			name: "no eligible candidate",
+			networkFS: testNetworkFS(func(networkFS *networkfsv1.NetworkFilesystem) {
+				networkFS.Spec.CandidateNodes = []string{"node-missing", "node-not-ready", "node-without-longhorn", "node-without-disk"}
+			}),
+			wantErr: "no eligible candidate node: node node-missing is not found; node node-not-ready is not ready; " +
+				"node node-without-longhorn is not a longhorn node; longhorn node node-without-disk has no healthy disk",
+		},
+		{
+			name: "Ganesha backend does not need the Longhorn node",
+			networkFS: testNetworkFS(func(networkFS *networkfsv1.NetworkFilesystem) {
+				networkFS.Spec.ExportBackend = networkfsv1.ExportBackendGanesha
+				networkFS.Spec.CandidateNodes = []string{"node-without-longhorn", "node-1"}
+			}),
+			want: "node-without-longhorn",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := testPlacer(tt.keepDraining).Select(tt.networkFS)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected node %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEvacuate(t *testing.T) {
+	tests := []struct {
+		name      string
+		networkFS *networkfsv1.NetworkFilesystem
+		from      string
+		want      string
+		wantErr   string
+	}{
+		{
+			name: "any eligible node without the placement rules",
+			networkFS: testNetworkFS(func(networkFS *networkfsv1.NetworkFilesystem) {
+				networkFS.Status.Node = "node-1"
+			}),
+			from: "node-1",
+			want: "node-2",
+		},
+		{
+			name: "target node is ignored",
+			networkFS: testNetworkFS(func(networkFS *networkfsv1.NetworkFilesystem) {
+				networkFS.Spec.TargetNode = "node-cordoned"
+				networkFS.Status.Node = "node-cordoned"
+			}),
+			from: "node-cordoned",
+			want: "node-1",
+		},
+		{
+			name: "candidates are chosen in order",
+			networkFS: testNetworkFS(func(networkFS *networkfsv1.NetworkFilesystem) {
+				networkFS.Spec.TargetNode = "node-maintained"
+				networkFS.Spec.CandidateNodes = []string{"node-maintained", "node-3", "node-2"}
+				networkFS.Status.Node = "node-maintained"
+			}),
+			from: "node-maintained",
+			want: "node-3",
+		},
+		{
+			name: "no eligible candidate",
+			networkFS: testNetworkFS(func(networkFS *networkfsv1.NetworkFilesystem) {
+				networkFS.Spec.CandidateNodes = []string{"node-cordoned", "node-not-ready"}
+				networkFS.Status.Node = "node-cordoned"
+			}),
+			from:    "node-cordoned",
+			wantErr: "no eligible candidate node",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the draining node keeps the running export, it must not be chosen to evacuate to
+			got, err := testPlacer(true).Evacuate(tt.networkFS, tt.from)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected node %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
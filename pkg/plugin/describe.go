@@ -0,0 +1,174 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/backend"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/placement"
+)
+
+func (s *session) describe(ctx context.Context, name string) error {
+	networkFS, err := s.client.HarvesterhciV1beta1().NetworkFilesystems(s.namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get network filesystem %s: %w", name, err)
+	}
+	// the events are optional, e.g. the user is not allowed to list them
+	events, err := s.kube.CoreV1().Events(networkFS.Namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.Set{
+			"involvedObject.kind": "NetworkFilesystem",
+			"involvedObject.uid":  string(networkFS.UID),
+		}.String(),
+	})
+	if err != nil {
+		events = nil
+	}
+
+	p := s.printer
+	p.field(0, "Name", networkFS.Name, "")
+	p.field(0, "Namespace", networkFS.Namespace, "")
+	p.field(0, "Labels", joinMap(networkFS.Labels), "")
+	p.field(0, "Created", fmt.Sprintf("%s (%s ago)", networkFS.CreationTimestamp.UTC().Format("2006-01-02T15:04:05Z"), age(networkFS.CreationTimestamp)), "")
+
+	fmt.Fprintln(p.out, "Spec:")
+	p.field(1, "Desired State", string(networkFS.Spec.DesiredState), "")
+	p.field(1, "Source", networkFS.Spec.NetworkFSName, "")
+	p.field(1, "Export Backend", string(backend.TypeOf(networkFS)), "")
+	p.field(1, "Protocol", backend.ProtocolOf(networkFS), "")
+	p.field(1, "Candidate Nodes", strings.Join(placement.CandidateNodes(networkFS), ", "), "")
+	p.field(1, "Node Selector", joinMap(networkFS.Spec.NodeSelector), "")
+	p.field(1, "Target Node", networkFS.Spec.TargetNode, "")
+	p.field(1, "Service", serviceOf(networkFS.Spec.Service), "")
+	p.field(1, "Access Rules", accessRules(networkFS.Spec.AccessRules), "")
+
+	fmt.Fprintln(p.out, "Status:")
+	p.field(1, "State", string(networkFS.Status.State), stateColor(networkFS.Status.State))
+	p.field(1, "Endpoint Status", string(networkFS.Status.Status), statusColor(networkFS.Status.Status))
+	p.field(1, "Type", networkFS.Status.Type, "")
+	p.field(1, "Endpoint", networkFS.Status.Endpoint, "")
+	p.field(1, "Server Address", networkFS.Status.ServerAddress, "")
+	p.field(1, "Node", networkFS.Status.Node, "")
+	p.field(1, "Export Path", networkFS.Status.ExportPath, "")
+	if networkFS.Status.UNCPath != "" {
+		p.field(1, "UNC Path", networkFS.Status.UNCPath, "")
+	}
+	p.field(1, "Mount Options", networkFS.Status.MountOpts, "")
+	p.field(1, "Observed Generation", fmt.Sprintf("%d (generation %d)", networkFS.Status.ObservedGeneration, networkFS.Generation), "")
+	p.field(1, "Recovery Attempts", fmt.Sprintf("%d", networkFS.Status.RecoveryAttempts), "")
+	if health := networkFS.Status.Health; health != nil && health.LastSuccessTime != nil {
+		p.field(1, "Health", fmt.Sprintf("%dms, succeeded %s ago", health.LatencyMilliseconds, age(*health.LastSuccessTime)), "")
+	}
+	if migration := networkFS.Status.Migration; migration != nil {
+		p.field(1, "Migration", fmt.Sprintf("%s, %s -> %s: %s", migration.Phase, valueOrNone(migration.SourceNode), migration.TargetNode, migration.Message), "")
+	}
+
+	// only the latest condition of each type is kept, the history is ordered by the transition time
+	fmt.Fprintln(p.out, "Conditions:")
+	conds := append([]networkfsv1.NetworkFSCondition(nil), networkFS.Status.NetworkFSConds...)
+	sort.SliceStable(conds, func(i, j int) bool {
+		return conds[i].LastTransitionTime.Before(&conds[j].LastTransitionTime)
+	})
+	if len(conds) == 0 {
+		fmt.Fprintln(p.out, "  <none>")
+	} else {
+		rows := make([][]cell, 0, len(conds))
+		for _, cond := range conds {
+			rows = append(rows, []cell{
+				{text: "  " + string(cond.Type)},
+				{text: string(cond.Status), color: conditionColor(cond.Status)},
+				{text: age(cond.LastTransitionTime)},
+				{text: valueOrNone(cond.Reason)},
+				{text: cond.Message},
+			})
+		}
+		p.table([]string{"  TYPE", "STATUS", "AGE", "REASON", "MESSAGE"}, rows)
+	}
+
+	fmt.Fprintln(p.out, "Events:")
+	if events == nil || len(events.Items) == 0 {
+		fmt.Fprintln(p.out, "  <none>")
+		return nil
+	}
+	sort.SliceStable(events.Items, func(i, j int) bool {
+		return eventTime(&events.Items[i]).Before(eventTime(&events.Items[j]))
+	})
+	rows := make([][]cell, 0, len(events.Items))
+	for i := range events.Items {
+		event := &events.Items[i]
+		color := ""
+		if event.Type == corev1.EventTypeWarning {
+			color = colorYellow
+		}
+		rows = append(rows, []cell{
+			{text: "  " + event.Type, color: color},
+			{text: event.Reason},
+			{text: age(*eventTime(event))},
+			{text: event.Source.Component},
+			{text: event.Message},
+		})
+	}
+	p.table([]string{"  TYPE", "REASON", "AGE", "FROM", "MESSAGE"}, rows)
+	return nil
+}
+
+// field prints the field of the describe view, the empty value is printed as <none>
+func (p *printer) field(indent int, name, value, color string) {
+	key := strings.Repeat("  ", indent) + name + ":"
+	fmt.Fprintf(p.out, "%-24s%s\n", key, p.colorize(valueOrNone(value), color))
+}
+
+func eventTime(event *corev1.Event) *metav1.Time {
+	if !event.LastTimestamp.IsZero() {
+		return &event.LastTimestamp
+	}
+	if !event.EventTime.IsZero() {
+		return &metav1.Time{Time: event.EventTime.Time}
+	}
+	return &event.CreationTimestamp
+}
+
+func serviceOf(service networkfsv1.ExportService) string {
+	serviceType := string(service.Type)
+	if serviceType == "" {
+		serviceType = string(corev1.ServiceTypeClusterIP)
+	}
+	if service.LoadBalancerIP != "" {
+		return serviceType + " " + service.LoadBalancerIP
+	}
+	return serviceType
+}
+
+func accessRules(rules []networkfsv1.AccessRule) string {
+	var ret []string
+	for _, rule := range rules {
+		access, squash := rule.Access, rule.Squash
+		if access == "" {
+			access = networkfsv1.AccessReadWrite
+		}
+		if squash == "" {
+			squash = networkfsv1.SquashNone
+		}
+		ret = append(ret, fmt.Sprintf("%s (%s, %s)", strings.Join(rule.Clients, ","), access, squash))
+	}
+	return strings.Join(ret, "; ")
+}
+
+func joinMap(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var ret []string
+	for _, k := range keys {
+		ret = append(ret, k+"="+m[k])
+	}
+	return strings.Join(ret, ",")
+}
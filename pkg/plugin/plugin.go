@@ -0,0 +1,232 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rancher/wrangler/v3/pkg/kubeconfig"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/yaml"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/generated/clientset/versioned"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+type options struct {
+	kubeConfig    string
+	namespace     string
+	allNamespaces bool
+	output        string
+	noColor       bool
+}
+
+// session holds the clients and the resolved namespace of a single invocation
+type session struct {
+	namespace string
+	client    versioned.Interface
+	kube      kubernetes.Interface
+	printer   *printer
+}
+
+// NewApp creates the kubectl plugin "kubectl netfs"
+func NewApp() *cli.App {
+	var opt options
+	app := cli.NewApp()
+	app.Name = "kubectl-netfs"
+	app.Version = utils.FriendlyVersion()
+	app.Usage = "Inspect the network filesystems of the networkFS-manager"
+	app.Flags = []cli.Flag{
+		&cli.StringFlag{
+			Name:        "kubeconfig",
+			EnvVars:     []string{"KUBECONFIG"},
+			Destination: &opt.kubeConfig,
+			Usage:       "Kube config for accessing k8s cluster",
+		},
+		&cli.StringFlag{
+			Name:        "namespace",
+			Aliases:     []string{"n"},
+			Destination: &opt.namespace,
+			Usage:       "Namespace of the network filesystems, default to the namespace of the current context",
+		},
+		&cli.BoolFlag{
+			Name:        "no-color",
+			EnvVars:     []string{"NO_COLOR"},
+			Destination: &opt.noColor,
+			Usage:       "Disable the colored output",
+		},
+	}
+	outputFlag := &cli.StringFlag{
+		Name:        "output",
+		Aliases:     []string{"o"},
+		Destination: &opt.output,
+		Usage:       "Output format, options are \"json\" or \"yaml\", the table is printed by default",
+	}
+	allNamespacesFlag := &cli.BoolFlag{
+		Name:        "all-namespaces",
+		Aliases:     []string{"A"},
+		Destination: &opt.allNamespaces,
+		Usage:       "List the network filesystems of all namespaces",
+	}
+
+	app.Commands = []*cli.Command{
+		{
+			Name:      "get",
+			Usage:     "Print the status of the network filesystems",
+			ArgsUsage: "[NAME]",
+			Flags:     []cli.Flag{outputFlag, allNamespacesFlag},
+			Action: func(c *cli.Context) error {
+				s, err := newSession(&opt)
+				if err != nil {
+					return err
+				}
+				return s.get(c.Context, c.Args().First(), opt.output)
+			},
+		},
+		{
+			Name:      "describe",
+			Usage:     "Describe the network filesystem with its condition history and events",
+			ArgsUsage: "NAME",
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					return fmt.Errorf("describe requires exactly one network filesystem name")
+				}
+				s, err := newSession(&opt)
+				if err != nil {
+					return err
+				}
+				return s.describe(c.Context, c.Args().First())
+			},
+		},
+		{
+			Name:      "watch",
+			Usage:     "Stream the state transitions of the network filesystems",
+			ArgsUsage: "[NAME]",
+			Flags:     []cli.Flag{allNamespacesFlag},
+			Action: func(c *cli.Context) error {
+				s, err := newSession(&opt)
+				if err != nil {
+					return err
+				}
+				return s.watch(c.Context, c.Args().First())
+			},
+		},
+	}
+	return app
+}
+
+func newSession(opt *options) (*session, error) {
+	clientConfig := kubeconfig.GetNonInteractiveClientConfig(opt.kubeConfig)
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find kubeconfig: %v", err)
+	}
+	client, err := versioned.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	kube, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := opt.namespace
+	if opt.allNamespaces {
+		namespace = metav1.NamespaceAll
+	} else if namespace == "" {
+		namespace = namespaceOf(clientConfig)
+	}
+	return &session{
+		namespace: namespace,
+		client:    client,
+		kube:      kube,
+		printer: &printer{
+			out:   os.Stdout,
+			color: !opt.noColor && term.IsTerminal(int(os.Stdout.Fd())),
+		},
+	}, nil
+}
+
+func namespaceOf(clientConfig clientcmd.ClientConfig) string {
+	if namespace, _, err := clientConfig.Namespace(); err == nil && namespace != "" {
+		return namespace
+	}
+	return metav1.NamespaceDefault
+}
+
+func (s *session) get(ctx context.Context, name, output string) error {
+	networkFSes := s.client.HarvesterhciV1beta1().NetworkFilesystems(s.namespace)
+	var obj interface{}
+	var items []networkfsv1.NetworkFilesystem
+	if name != "" {
+		networkFS, err := networkFSes.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get network filesystem %s: %w", name, err)
+		}
+		setTypeMeta(networkFS)
+		obj, items = networkFS, []networkfsv1.NetworkFilesystem{*networkFS}
+	} else {
+		list, err := networkFSes.List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to list network filesystems: %w", err)
+		}
+		list.APIVersion, list.Kind = "v1", "List"
+		for i := range list.Items {
+			setTypeMeta(&list.Items[i])
+		}
+		obj, items = list, list.Items
+	}
+
+	switch output {
+	case "":
+	case "json", "yaml":
+		return writeObject(s.printer.out, obj, output)
+	default:
+		return fmt.Errorf("invalid output %q, only \"json\" and \"yaml\" are allowed", output)
+	}
+
+	withNamespace := s.namespace == metav1.NamespaceAll
+	if len(items) == 0 && withNamespace {
+		fmt.Fprintln(s.printer.out, "No network filesystems found.")
+		return nil
+	}
+	if len(items) == 0 {
+		fmt.Fprintf(s.printer.out, "No network filesystems found in %s namespace.\n", s.namespace)
+		return nil
+	}
+	rows := make([][]cell, 0, len(items))
+	for i := range items {
+		rows = append(rows, networkFSRow(&items[i], withNamespace))
+	}
+	s.printer.table(networkFSHeaders(withNamespace), rows)
+	return nil
+}
+
+// setTypeMeta sets the type meta which is dropped by the client
+func setTypeMeta(networkFS *networkfsv1.NetworkFilesystem) {
+	networkFS.APIVersion = networkfsv1.SchemeGroupVersion.String()
+	networkFS.Kind = "NetworkFilesystem"
+}
+
+func writeObject(out io.Writer, obj interface{}, output string) error {
+	var data []byte
+	var err error
+	if output == "yaml" {
+		data, err = yaml.Marshal(obj)
+	} else {
+		data, err = json.MarshalIndent(obj, "", "    ")
+		data = append(data, '\n')
+	}
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(data)
+	return err
+}
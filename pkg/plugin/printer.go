@@ -0,0 +1,153 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+)
+
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorGray   = "\033[90m"
+)
+
+// cell is a table cell, the color is applied after the cell is padded so the columns are aligned
+type cell struct {
+	text  string
+	color string
+}
+
+type printer struct {
+	out   io.Writer
+	color bool
+}
+
+func (p *printer) table(headers []string, rows [][]cell) {
+	widths := make([]int, len(headers))
+	for i, header := range headers {
+		widths[i] = len(header)
+	}
+	for _, row := range rows {
+		for i, c := range row {
+			if len(c.text) > widths[i] {
+				widths[i] = len(c.text)
+			}
+		}
+	}
+
+	p.row(widths, cellsOf(headers))
+	for _, row := range rows {
+		p.row(widths, row)
+	}
+}
+
+func (p *printer) row(widths []int, cells []cell) {
+	var b strings.Builder
+	for i, c := range cells {
+		text := c.text
+		if i < len(cells)-1 {
+			text += strings.Repeat(" ", max(widths[i]-len(c.text), 0)+3)
+		}
+		b.WriteString(p.colorize(text, c.color))
+	}
+	fmt.Fprintln(p.out, strings.TrimRight(b.String(), " "))
+}
+
+func cellsOf(texts []string) []cell {
+	cells := make([]cell, len(texts))
+	for i, text := range texts {
+		cells[i] = cell{text: text}
+	}
+	return cells
+}
+
+func (p *printer) colorize(text, color string) string {
+	if !p.color || color == "" {
+		return text
+	}
+	return color + text + colorReset
+}
+
+func networkFSRow(networkFS *networkfsv1.NetworkFilesystem, withNamespace bool) []cell {
+	var row []cell
+	if withNamespace {
+		row = append(row, cell{text: networkFS.Namespace})
+	}
+	return append(row,
+		cell{text: networkFS.Name},
+		cell{text: string(networkFS.Spec.DesiredState)},
+		cell{text: valueOrNone(string(networkFS.Status.State)), color: stateColor(networkFS.Status.State)},
+		cell{text: valueOrNone(string(networkFS.Status.Status)), color: statusColor(networkFS.Status.Status)},
+		cell{text: valueOrNone(networkFS.Status.Type)},
+		cell{text: valueOrNone(networkFS.Status.Endpoint)},
+		cell{text: age(networkFS.CreationTimestamp)},
+	)
+}
+
+func networkFSHeaders(withNamespace bool) []string {
+	headers := []string{"NAME", "DESIRED", "STATE", "STATUS", "TYPE", "ENDPOINT", "AGE"}
+	if withNamespace {
+		return append([]string{"NAMESPACE"}, headers...)
+	}
+	return headers
+}
+
+func stateColor(state networkfsv1.NetworkFSState) string {
+	switch state {
+	case networkfsv1.NetworkFSStateEnabled:
+		return colorGreen
+	case networkfsv1.NetworkFSStateEnabling, networkfsv1.NetworkFSStateDisabling:
+		return colorYellow
+	case networkfsv1.NetworkFSStateDisabled:
+		return colorGray
+	case networkfsv1.NetworkFSStateUnknown:
+		return colorRed
+	}
+	return ""
+}
+
+func statusColor(status networkfsv1.EndpointStatus) string {
+	switch status {
+	case networkfsv1.EndpointStatusReady:
+		return colorGreen
+	case networkfsv1.EndpointStatusReconciling:
+		return colorYellow
+	case networkfsv1.EndpointStatusNotReady, networkfsv1.EndpointStatusUnknown:
+		return colorRed
+	}
+	return ""
+}
+
+func conditionColor(status corev1.ConditionStatus) string {
+	switch status {
+	case corev1.ConditionTrue:
+		return colorGreen
+	case corev1.ConditionFalse:
+		return colorRed
+	}
+	return colorYellow
+}
+
+func valueOrNone(value string) string {
+	if value == "" {
+		return "<none>"
+	}
+	return value
+}
+
+func age(t metav1.Time) string {
+	if t.IsZero() {
+		return "<unknown>"
+	}
+	return duration.HumanDuration(time.Since(t.Time))
+}
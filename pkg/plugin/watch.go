@@ -0,0 +1,127 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+)
+
+// the interval before the watch is re-established after it failed
+const rewatchInterval = 2 * time.Second
+
+// transition is what a watch line reports, the other changes (e.g. the probe result) are not printed
+type transition struct {
+	desired  networkfsv1.NetworkFSState
+	state    networkfsv1.NetworkFSState
+	status   networkfsv1.EndpointStatus
+	endpoint string
+}
+
+func transitionOf(networkFS *networkfsv1.NetworkFilesystem) transition {
+	return transition{
+		desired:  networkFS.Spec.DesiredState,
+		state:    networkFS.Status.State,
+		status:   networkFS.Status.Status,
+		endpoint: networkFS.Status.Endpoint,
+	}
+}
+
+// watch prints the current network filesystems and then a line for every transition until it is interrupted
+func (s *session) watch(ctx context.Context, name string) error {
+	listOpts := metav1.ListOptions{}
+	if name != "" {
+		listOpts.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+	}
+	withNamespace := s.namespace == metav1.NamespaceAll
+	headers := append([]string{"TIME", "EVENT"}, networkFSHeaders(withNamespace)...)
+	widths := make([]int, len(headers))
+	for i, header := range headers {
+		// the rows are streamed, the columns are sized for the usual values
+		widths[i] = max(len(header), 10)
+	}
+	widths[len(widths)-2] = max(widths[len(widths)-2], 15)
+	s.printer.row(widths, cellsOf(headers))
+
+	seen := map[string]transition{}
+	resourceVersion := ""
+	for {
+		if resourceVersion == "" {
+			list, err := s.client.HarvesterhciV1beta1().NetworkFilesystems(s.namespace).List(ctx, listOpts)
+			if err != nil {
+				return fmt.Errorf("failed to list network filesystems: %w", err)
+			}
+			for i := range list.Items {
+				s.printTransition(widths, seen, watch.Added, &list.Items[i], withNamespace)
+			}
+			resourceVersion = list.ResourceVersion
+		}
+
+		watchOpts := listOpts
+		watchOpts.ResourceVersion = resourceVersion
+		w, err := s.client.HarvesterhciV1beta1().NetworkFilesystems(s.namespace).Watch(ctx, watchOpts)
+		if err != nil {
+			return fmt.Errorf("failed to watch network filesystems: %w", err)
+		}
+		resourceVersion, err = s.streamTransitions(ctx, w, widths, seen, resourceVersion, withNamespace)
+		w.Stop()
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(rewatchInterval):
+		}
+	}
+}
+
+// streamTransitions prints the transitions until the watch is closed, the resource version to resume from is returned
+func (s *session) streamTransitions(ctx context.Context, w watch.Interface, widths []int, seen map[string]transition, resourceVersion string, withNamespace bool) (string, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return resourceVersion, nil
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return resourceVersion, nil
+			}
+			if event.Type == watch.Error {
+				err := apierrors.FromObject(event.Object)
+				// the resource version is too old, list again
+				if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+					return "", nil
+				}
+				return resourceVersion, err
+			}
+			networkFS, ok := event.Object.(*networkfsv1.NetworkFilesystem)
+			if !ok {
+				continue
+			}
+			s.printTransition(widths, seen, event.Type, networkFS, withNamespace)
+			resourceVersion = networkFS.ResourceVersion
+		}
+	}
+}
+
+func (s *session) printTransition(widths []int, seen map[string]transition, eventType watch.EventType, networkFS *networkfsv1.NetworkFilesystem, withNamespace bool) {
+	key := networkFS.Namespace + "/" + networkFS.Name
+	cur := transitionOf(networkFS)
+	// the relist after the watch expired reports the unchanged ones again
+	if last, found := seen[key]; found && last == cur && eventType != watch.Deleted {
+		return
+	}
+	if eventType == watch.Deleted {
+		delete(seen, key)
+	} else {
+		seen[key] = cur
+	}
+	row := append([]cell{{text: time.Now().Format("15:04:05")}, {text: string(eventType)}}, networkFSRow(networkFS, withNamespace)...)
+	s.printer.row(widths, row)
+}
@@ -0,0 +1,242 @@
+package prober
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// NFSv4.1 (RFC 8881) constants used by the probe
+const (
+	nfsProgram  = 100003
+	nfsVersion  = 4
+	nfsMinorVer = 1
+
+	procNull     = 0
+	procCompound = 1
+
+	opGetFH           = 10
+	opLookup          = 15
+	opPutRootFH       = 24
+	opExchangeID      = 42
+	opCreateSession   = 43
+	opDestroySession  = 44
+	opSequence        = 53
+	opDestroyClientID = 57
+
+	nfs4OK = 0
+
+	sessionIDSize = 16
+)
+
+// NFSProber checks the NFS server with the RPC NULL call and a lookup of the export root
+type NFSProber struct {
+	// Port is the NFS port, 2049 by default
+	Port int
+	// Dial connects the NFS server, it could be replaced to connect an in-process fake server
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// NewNFSProber creates the NFS prober with the default port and dialer
+func NewNFSProber() *NFSProber {
+	dialer := &net.Dialer{}
+	return &NFSProber{
+		Port: 2049,
+		Dial: dialer.DialContext,
+	}
+}
+
+// Probe performs the RPC NULL call against the NFS program and looks up the export path through a NFSv4.1 session,
+// the probe is bounded by the deadline of the context.
+func (p *NFSProber) Probe(ctx context.Context, address, exportPath string) error {
+	conn, err := p.Dial(ctx, "tcp", net.JoinHostPort(address, strconv.Itoa(p.Port)))
+	if err != nil {
+		return fmt.Errorf("failed to connect NFS server: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+
+	client := &rpcClient{conn: conn, prog: nfsProgram, vers: nfsVersion}
+	if _, err := client.call(procNull, nil); err != nil {
+		return fmt.Errorf("NULL call failed: %w", err)
+	}
+
+	client.authSys = true
+	clientID, sequenceID, err := exchangeID(client)
+	if err != nil {
+		return err
+	}
+	defer destroyClientID(client, clientID)
+
+	sessionID, err := createSession(client, clientID, sequenceID)
+	if err != nil {
+		return err
+	}
+	defer destroySession(client, sessionID)
+
+	return lookup(client, sessionID, exportPath)
+}
+
+func compoundHeader(w *xdrWriter, ops uint32) {
+	w.string("") // tag
+	w.uint32(nfsMinorVer)
+	w.uint32(ops)
+}
+
+// compound sends the COMPOUND and returns the reader after the status of the first operation
+func compound(client *rpcClient, name string, args []byte) (*xdrReader, error) {
+	r, err := client.call(procCompound, args)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %w", name, err)
+	}
+	status := r.uint32()
+	r.opaque() // tag
+	results := r.uint32()
+	if r.err != nil {
+		return nil, fmt.Errorf("failed to decode %s reply: %w", name, r.err)
+	}
+	if status != nfs4OK {
+		return nil, fmt.Errorf("%s failed with NFS status %d", name, status)
+	}
+	if results == 0 {
+		return nil, fmt.Errorf("%s returns no result", name)
+	}
+	r.uint32() // opcode
+	r.uint32() // status of the operation, same as the compound status
+	return r, nil
+}
+
+func exchangeID(client *rpcClient) (uint64, uint32, error) {
+	verifier := make([]byte, 8)
+	if _, err := rand.Read(verifier); err != nil {
+		return 0, 0, err
+	}
+
+	w := &xdrWriter{}
+	compoundHeader(w, 1)
+	w.uint32(opExchangeID)
+	w.fixed(verifier)
+	w.string(fmt.Sprintf("networkfs-manager-prober-%x", verifier))
+	w.uint32(0) // flags
+	w.uint32(0) // SP4_NONE
+	w.uint32(0) // no implementation id
+
+	r, err := compound(client, "EXCHANGE_ID", w.buf)
+	if err != nil {
+		return 0, 0, err
+	}
+	clientID, sequenceID := r.uint64(), r.uint32()
+	if r.err != nil {
+		return 0, 0, fmt.Errorf("failed to decode EXCHANGE_ID reply: %w", r.err)
+	}
+	return clientID, sequenceID, nil
+}
+
+func channelAttrs(w *xdrWriter) {
+	w.uint32(0)       // header pad size
+	w.uint32(1 << 16) // max request size
+	w.uint32(1 << 16) // max response size
+	w.uint32(4096)    // max response size cached
+	w.uint32(8)       // max operations
+	w.uint32(1)       // max requests
+	w.uint32(0)       // no RDMA
+}
+
+func createSession(client *rpcClient, clientID uint64, sequenceID uint32) ([]byte, error) {
+	w := &xdrWriter{}
+	compoundHeader(w, 1)
+	w.uint32(opCreateSession)
+	w.uint64(clientID)
+	w.uint32(sequenceID)
+	w.uint32(0) // flags, no back channel
+	channelAttrs(w)
+	channelAttrs(w)
+	w.uint32(0) // callback program
+	w.uint32(1) // one callback security parameter
+	w.uint32(authNone)
+
+	r, err := compound(client, "CREATE_SESSION", w.buf)
+	if err != nil {
+		return nil, err
+	}
+	sessionID := r.fixed(sessionIDSize)
+	if r.err != nil {
+		return nil, fmt.Errorf("failed to decode CREATE_SESSION reply: %w", r.err)
+	}
+	return append([]byte(nil), sessionID...), nil
+}
+
+func sequence(w *xdrWriter, sessionID []byte) {
+	w.uint32(opSequence)
+	w.fixed(sessionID)
+	w.uint32(1) // sequence id of the slot
+	w.uint32(0) // slot id
+	w.uint32(0) // highest slot id
+	w.uint32(0) // do not cache
+}
+
+// lookup resolves the export path from the root (pseudo) filesystem
+func lookup(client *rpcClient, sessionID []byte, exportPath string) error {
+	var components []string
+	for _, component := range strings.Split(exportPath, "/") {
+		if component != "" {
+			components = append(components, component)
+		}
+	}
+
+	w := &xdrWriter{}
+	compoundHeader(w, uint32(3+len(components)))
+	sequence(w, sessionID)
+	w.uint32(opPutRootFH)
+	for _, component := range components {
+		w.uint32(opLookup)
+		w.string(component)
+	}
+	w.uint32(opGetFH)
+
+	r, err := client.call(procCompound, w.buf)
+	if err != nil {
+		return fmt.Errorf("LOOKUP %s failed: %w", exportPath, err)
+	}
+	status := r.uint32()
+	if r.err != nil {
+		return fmt.Errorf("failed to decode LOOKUP reply: %w", r.err)
+	}
+	if status != nfs4OK {
+		return fmt.Errorf("LOOKUP %s failed with NFS status %d", exportPath, status)
+	}
+	return nil
+}
+
+func destroySession(client *rpcClient, sessionID []byte) {
+	w := &xdrWriter{}
+	compoundHeader(w, 1)
+	w.uint32(opDestroySession)
+	w.fixed(sessionID)
+	_, _ = client.call(procCompound, w.buf)
+}
+
+func destroyClientID(client *rpcClient, clientID uint64) {
+	w := &xdrWriter{}
+	compoundHeader(w, 1)
+	w.uint32(opDestroyClientID)
+	w.uint64(clientID)
+	_, _ = client.call(procCompound, w.buf)
+}
+
+// ProbeTCP only checks the server accepts the connection, it is used by the protocols without a deeper probe
+func ProbeTCP(ctx context.Context, address string, port int) error {
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(address, strconv.Itoa(port)))
+	if err != nil {
+		return fmt.Errorf("failed to connect server: %w", err)
+	}
+	return conn.Close()
+}
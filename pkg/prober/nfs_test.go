@@ -0,0 +1,202 @@
+package prober
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+const nfs4ErrNoEnt = 2
+
+// fakeNFSServer answers the calls of the NFS probe on one connection, it knows a single export
+type fakeNFSServer struct {
+	exportPath string
+	// acceptStat is returned for every call when it is not acceptSuccess
+	acceptStat uint32
+	// silent never replies, so the probe runs into its deadline
+	silent bool
+	// closeAfter closes the connection after the number of calls when it is not zero
+	closeAfter int
+}
+
+func (s *fakeNFSServer) serve(conn net.Conn) {
+	defer conn.Close()
+	for calls := 1; ; calls++ {
+		record, err := readRecord(conn)
+		if err != nil {
+			return
+		}
+		if s.silent {
+			continue
+		}
+		if s.closeAfter != 0 && calls > s.closeAfter {
+			return
+		}
+
+		r := &xdrReader{buf: record}
+		id := r.uint32()
+		r.uint32() // message type
+		r.uint32() // RPC version
+		prog, vers, proc := r.uint32(), r.uint32(), r.uint32()
+		r.uint32() // credential flavor
+		r.opaque() // credential body
+		r.uint32() // verifier flavor
+		r.opaque() // verifier body
+
+		w := &xdrWriter{}
+		w.uint32(id)
+		w.uint32(msgTypeReply)
+		w.uint32(replyAccepted)
+		w.uint32(authNone)
+		w.opaque(nil)
+		switch {
+		case s.acceptStat != acceptSuccess:
+			w.uint32(s.acceptStat)
+			if s.acceptStat == acceptProgMismatch {
+				w.uint32(3)
+				w.uint32(3)
+			}
+		case prog != nfsProgram || vers != nfsVersion:
+			w.uint32(acceptProgUnavail)
+		case proc == procNull:
+			w.uint32(acceptSuccess)
+		default:
+			w.uint32(acceptSuccess)
+			s.compound(r, w)
+		}
+		if err := writeRecord(conn, w.buf); err != nil {
+			return
+		}
+	}
+}
+
+// compound answers the COMPOUND by its first operation, only the operations sent by the probe are known
+func (s *fakeNFSServer) compound(r *xdrReader, w *xdrWriter) {
+	r.opaque() // tag
+	r.uint32() // minor version
+	ops := r.uint32()
+	op := r.uint32()
+
+	reply := func(status uint32, results int) {
+		w.uint32(status)
+		w.string("")
+		w.uint32(uint32(results))
+		w.uint32(op)
+		w.uint32(status)
+	}
+	switch op {
+	case opExchangeID:
+		reply(nfs4OK, 1)
+		w.uint64(42) // client id
+		w.uint32(1)  // sequence id
+	case opCreateSession:
+		reply(nfs4OK, 1)
+		w.fixed(make([]byte, sessionIDSize))
+	case opSequence:
+		r.fixed(sessionIDSize)
+		r.uint32() // sequence id
+		r.uint32() // slot id
+		r.uint32() // highest slot id
+		r.uint32() // cache this
+		r.uint32() // PUTROOTFH
+		var components []string
+		for i := uint32(0); i < ops-3; i++ {
+			r.uint32() // LOOKUP
+			components = append(components, string(r.opaque()))
+		}
+		if "/"+strings.Join(components, "/") != s.exportPath {
+			reply(nfs4ErrNoEnt, 2+len(components))
+			return
+		}
+		reply(nfs4OK, int(ops))
+	default:
+		reply(nfs4OK, 1)
+	}
+}
+
+// dialer returns the dial function which connects the probe to the fake server through an in-memory pipe
+func (s *fakeNFSServer) dialer() func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(_ context.Context, _, _ string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go s.serve(server)
+		return client, nil
+	}
+}
+
+func TestNFSProberProbe(t *testing.T) {
+	tests := []struct {
+		name       string
+		server     *fakeNFSServer
+		exportPath string
+		timeout    time.Duration
+		wantErr    string
+	}{
+		{
+			name:       "export is served",
+			server:     &fakeNFSServer{exportPath: "/pvc-1234"},
+			exportPath: "/pvc-1234",
+		},
+		{
+			name:       "nested export is served",
+			server:     &fakeNFSServer{exportPath: "/exports/pvc-1234"},
+			exportPath: "/exports/pvc-1234",
+		},
+		{
+			name:       "export is missing",
+			server:     &fakeNFSServer{exportPath: "/pvc-1234"},
+			exportPath: "/pvc-5678",
+			wantErr:    "LOOKUP /pvc-5678 failed with NFS status 2",
+		},
+		{
+			name:       "NFS program is unavailable",
+			server:     &fakeNFSServer{acceptStat: acceptProgUnavail},
+			exportPath: "/pvc-1234",
+			wantErr:    "NULL call failed: RPC program is unavailable",
+		},
+		{
+			name:       "NFSv4 is not supported",
+			server:     &fakeNFSServer{acceptStat: acceptProgMismatch},
+			exportPath: "/pvc-1234",
+			wantErr:    "supported versions 3-3",
+		},
+		{
+			name:       "server closes the connection",
+			server:     &fakeNFSServer{exportPath: "/pvc-1234", closeAfter: 1},
+			exportPath: "/pvc-1234",
+			wantErr:    "EXCHANGE_ID failed",
+		},
+		{
+			name:       "server does not answer",
+			server:     &fakeNFSServer{silent: true},
+			exportPath: "/pvc-1234",
+			timeout:    100 * time.Millisecond,
+			wantErr:    "NULL call failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeout := tt.timeout
+			if timeout == 0 {
+				timeout = 5 * time.Second
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			p := NewNFSProber()
+			p.Dial = tt.server.dialer()
+			err := p.Probe(ctx, "10.53.0.10", tt.exportPath)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package prober
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/backend"
+	ctlntefsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/generated/controllers/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+// the number of the endpoints probed at once, the probes of a large cluster are spread over the interval
+const maxConcurrentProbes = 16
+
+// Prober periodically probes the endpoints of the enabled networkFS and reports the Healthy condition
+type Prober struct {
+	interval time.Duration
+	timeout  time.Duration
+
+	nfs *NFSProber
+
+	NetworkFSCache    ctlntefsv1.NetworkFilesystemCache
+	NetworkFilsystems ctlntefsv1.NetworkFilesystemController
+}
+
+// Register starts the prober, it is disabled when the probe interval is zero
+func Register(ctx context.Context, netfilesystems ctlntefsv1.NetworkFilesystemController, opt *utils.Option) error {
+	if opt.ProbeInterval <= 0 {
+		logrus.Info("Endpoint prober is disabled")
+		return nil
+	}
+
+	p := &Prober{
+		interval:          opt.ProbeInterval,
+		timeout:           opt.ProbeTimeout,
+		nfs:               NewNFSProber(),
+		NetworkFSCache:    netfilesystems.Cache(),
+		NetworkFilsystems: netfilesystems,
+	}
+
+	go wait.UntilWithContext(ctx, p.probeAll, p.interval)
+	return nil
+}
+
+func (p *Prober) probeAll(ctx context.Context) {
+	networkFSes, err := p.NetworkFSCache.List("", labels.Everything())
+	if err != nil {
+		logrus.Errorf("Failed to list network filesystems for probing: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, maxConcurrentProbes)
+	for _, networkFS := range networkFSes {
+		if networkFS.Status.State != networkfsv1.NetworkFSStateEnabled || networkFS.Status.Endpoint == "" {
+			continue
+		}
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		}
+		wg.Add(1)
+		go func(networkFS *networkfsv1.NetworkFilesystem) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			p.probeNetworkFS(ctx, networkFS)
+		}(networkFS)
+	}
+	wg.Wait()
+}
+
+func (p *Prober) probe(ctx context.Context, networkFS *networkfsv1.NetworkFilesystem) error {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	protocol := backend.ProtocolOf(networkFS)
+	if protocol == networkfsv1.NetworkFSTypeNFS {
+		return p.nfs.Probe(ctx, networkFS.Status.Endpoint, networkFS.Status.ExportPath)
+	}
+	return ProbeTCP(ctx, networkFS.Status.Endpoint, int(backend.PortOf(protocol)))
+}
+
+func (p *Prober) probeNetworkFS(ctx context.Context, networkFS *networkfsv1.NetworkFilesystem) {
+	start := time.Now()
+	err := p.probe(ctx, networkFS)
+	latency := time.Since(start)
+
+	// the probe may take a while, update the latest one
+	networkFS, getErr := p.NetworkFSCache.Get(networkFS.Namespace, networkFS.Name)
+	if getErr != nil {
+		if !apierrors.IsNotFound(getErr) {
+			logrus.Errorf("Failed to get network filesystem %s after probing: %v", networkFS.Name, getErr)
+		}
+		return
+	}
+	if networkFS.Status.State != networkfsv1.NetworkFSStateEnabled {
+		return
+	}
+
+	networkFSCpy := networkFS.DeepCopy()
+	cond := networkfsv1.NetworkFSCondition{
+		Type:               networkfsv1.ConditionTypeHealthy,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             "Probe succeeded",
+		Message:            fmt.Sprintf("%s endpoint %s is serving", backend.ProtocolOf(networkFS), networkFS.Status.Endpoint),
+	}
+	if err != nil {
+		logrus.Warnf("Probe of network filesystem %s failed: %v", networkFS.Name, err)
+		cond.Status = corev1.ConditionFalse
+		cond.Reason = "Probe failed"
+		cond.Message = err.Error()
+	} else {
+		now := metav1.Now()
+		networkFSCpy.Status.Health = &networkfsv1.HealthStatus{
+			LatencyMilliseconds: latency.Milliseconds(),
+			LastSuccessTime:     &now,
+		}
+	}
+	networkFSCpy.Status.NetworkFSConds = utils.UpdateNetworkFSConds(networkFSCpy.Status.NetworkFSConds, cond)
+
+	if reflect.DeepEqual(networkFS, networkFSCpy) {
+		return
+	}
+	if _, err := p.NetworkFilsystems.UpdateStatus(networkFSCpy); err != nil {
+		// conflicts with the controller are expected, the next round reports it again
+		logrus.Debugf("Failed to update health of network filesystem %s: %v", networkFS.Name, err)
+	}
+}
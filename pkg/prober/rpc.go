@@ -0,0 +1,231 @@
+package prober
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync/atomic"
+)
+
+// a minimal ONC RPC (RFC 5531) client over TCP, it only supports what the NFS probe needs
+
+const (
+	rpcVersion = 2
+
+	msgTypeCall  = 0
+	msgTypeReply = 1
+
+	replyAccepted = 0
+
+	acceptSuccess      = 0
+	acceptProgUnavail  = 1
+	acceptProgMismatch = 2
+	acceptProcUnavail  = 3
+	acceptGarbageArgs  = 4
+	acceptSystemErr    = 5
+
+	authNone = 0
+	authSys  = 1
+
+	lastFragment = 0x80000000
+	maxRecord    = 1 << 20
+)
+
+var xid uint32
+
+// xdrWriter encodes the XDR (RFC 4506) data
+type xdrWriter struct {
+	buf []byte
+}
+
+func (w *xdrWriter) uint32(v uint32) {
+	w.buf = binary.BigEndian.AppendUint32(w.buf, v)
+}
+
+func (w *xdrWriter) uint64(v uint64) {
+	w.buf = binary.BigEndian.AppendUint64(w.buf, v)
+}
+
+// fixed writes the fixed-length opaque data
+func (w *xdrWriter) fixed(data []byte) {
+	w.buf = append(w.buf, data...)
+	if pad := len(data) % 4; pad != 0 {
+		w.buf = append(w.buf, make([]byte, 4-pad)...)
+	}
+}
+
+// opaque writes the variable-length opaque data
+func (w *xdrWriter) opaque(data []byte) {
+	w.uint32(uint32(len(data)))
+	w.fixed(data)
+}
+
+func (w *xdrWriter) string(s string) {
+	w.opaque([]byte(s))
+}
+
+// xdrReader decodes the XDR data, the first error is kept and the following reads return zero values
+type xdrReader struct {
+	buf []byte
+	err error
+}
+
+var errShortBuffer = errors.New("short XDR buffer")
+
+func (r *xdrReader) uint32() uint32 {
+	if r.err != nil || len(r.buf) < 4 {
+		r.err = errShortBuffer
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.buf)
+	r.buf = r.buf[4:]
+	return v
+}
+
+func (r *xdrReader) uint64() uint64 {
+	if r.err != nil || len(r.buf) < 8 {
+		r.err = errShortBuffer
+		return 0
+	}
+	v := binary.BigEndian.Uint64(r.buf)
+	r.buf = r.buf[8:]
+	return v
+}
+
+func (r *xdrReader) fixed(n int) []byte {
+	padded := (n + 3) &^ 3
+	if r.err != nil || len(r.buf) < padded {
+		r.err = errShortBuffer
+		return nil
+	}
+	data := r.buf[:n]
+	r.buf = r.buf[padded:]
+	return data
+}
+
+func (r *xdrReader) opaque() []byte {
+	n := r.uint32()
+	if n > maxRecord {
+		r.err = fmt.Errorf("XDR opaque length %d is too large", n)
+		return nil
+	}
+	return r.fixed(int(n))
+}
+
+// rpcClient sends the calls of a program/version on a connection, one call at a time
+type rpcClient struct {
+	conn    net.Conn
+	prog    uint32
+	vers    uint32
+	authSys bool
+}
+
+// call sends the procedure with the encoded args and returns the reader of the results
+func (c *rpcClient) call(proc uint32, args []byte) (*xdrReader, error) {
+	id := atomic.AddUint32(&xid, 1)
+	w := &xdrWriter{}
+	w.uint32(id)
+	w.uint32(msgTypeCall)
+	w.uint32(rpcVersion)
+	w.uint32(c.prog)
+	w.uint32(c.vers)
+	w.uint32(proc)
+	if c.authSys {
+		cred := &xdrWriter{}
+		cred.uint32(0)                   // stamp
+		cred.string("networkfs-manager") // machine name
+		cred.uint32(0)                   // uid
+		cred.uint32(0)                   // gid
+		cred.uint32(0)                   // no auxiliary gids
+		w.uint32(authSys)
+		w.opaque(cred.buf)
+	} else {
+		w.uint32(authNone)
+		w.opaque(nil)
+	}
+	w.uint32(authNone) // verifier
+	w.opaque(nil)
+	w.buf = append(w.buf, args...)
+
+	if err := writeRecord(c.conn, w.buf); err != nil {
+		return nil, fmt.Errorf("failed to send RPC call: %w", err)
+	}
+
+	for {
+		record, err := readRecord(c.conn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read RPC reply: %w", err)
+		}
+		r := &xdrReader{buf: record}
+		if r.uint32() != id {
+			// the reply of a timed out call, skip it
+			continue
+		}
+		if msgType := r.uint32(); msgType != msgTypeReply {
+			return nil, fmt.Errorf("unexpected RPC message type %d", msgType)
+		}
+		if stat := r.uint32(); stat != replyAccepted {
+			return nil, fmt.Errorf("RPC call is denied, reply state %d", stat)
+		}
+		r.uint32() // verifier flavor
+		r.opaque() // verifier body
+		acceptStat := r.uint32()
+		if r.err != nil {
+			return nil, fmt.Errorf("failed to decode RPC reply: %w", r.err)
+		}
+		if err := acceptError(acceptStat, r); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+}
+
+func acceptError(stat uint32, r *xdrReader) error {
+	switch stat {
+	case acceptSuccess:
+		return nil
+	case acceptProgUnavail:
+		return errors.New("RPC program is unavailable")
+	case acceptProgMismatch:
+		low, high := r.uint32(), r.uint32()
+		return fmt.Errorf("RPC program version mismatch, supported versions %d-%d", low, high)
+	case acceptProcUnavail:
+		return errors.New("RPC procedure is unavailable")
+	case acceptGarbageArgs:
+		return errors.New("RPC server can not decode the arguments")
+	case acceptSystemErr:
+		return errors.New("RPC server system error")
+	}
+	return fmt.Errorf("unknown RPC accept state %d", stat)
+}
+
+func writeRecord(w io.Writer, data []byte) error {
+	header := binary.BigEndian.AppendUint32(nil, lastFragment|uint32(len(data)))
+	_, err := w.Write(append(header, data...))
+	return err
+}
+
+func readRecord(r io.Reader) ([]byte, error) {
+	var record []byte
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, err
+		}
+		marker := binary.BigEndian.Uint32(header[:])
+		size := marker &^ lastFragment
+		if len(record)+int(size) > maxRecord {
+			return nil, fmt.Errorf("RPC record is larger than %d bytes", maxRecord)
+		}
+		fragment := make([]byte, size)
+		if _, err := io.ReadFull(r, fragment); err != nil {
+			return nil, err
+		}
+		record = append(record, fragment...)
+		if marker&lastFragment != 0 {
+			return record, nil
+		}
+	}
+}
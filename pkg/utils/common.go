@@ -2,18 +2,35 @@ package utils
 
 import (
 	"fmt"
+	"time"
 
+	longhornv2 "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
 	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
 )
 
 type Option struct {
-	KubeConfig  string
-	Namespace   string
-	NodeName    string
-	Debug       bool
-	Threadiness int
+	KubeConfig          string
+	Namespace           string
+	NodeName            string
+	Debug               bool
+	Threadiness         int
+	WebhookPort         int
+	WebhookName         string
+	WebhookServiceName  string
+	GaneshaImage        string
+	SambaImage          string
+	RecoveryRetryBudget int
+	TeardownTimeout     time.Duration
+	ProbeInterval       time.Duration
+	ProbeTimeout        time.Duration
+	IdleCheckInterval   time.Duration
+	DrainMaxMigrations  int
+	MetricsPort         int
+	MountAgent          bool
+	MountHostPathPrefix string
 }
 
 // These values are set via linker flags in scripts/build
@@ -23,6 +40,38 @@ var (
 	LHNameSpace = "longhorn-system"
 )
 
+const (
+	// AnnotationPVCNamespace records the namespace of the PVC backing an auto-discovered networkFS, the PVC name
+	// may exceed the length of a label value, so both are annotations
+	AnnotationPVCNamespace = "networkfs.harvesterhci.io/pvc-namespace"
+	// AnnotationPVCName records the name of the PVC backing an auto-discovered networkFS
+	AnnotationPVCName = "networkfs.harvesterhci.io/pvc-name"
+	// LabelDiscovered marks the networkFS which is created by the discovery controller
+	LabelDiscovered = "networkfs.harvesterhci.io/discovered"
+	// LabelNetworkFSNamespace records the namespace of the networkFS owning a resource in another namespace
+	LabelNetworkFSNamespace = "networkfs.harvesterhci.io/networkfs-namespace"
+	// LabelNetworkFSName records the name of the networkFS owning a resource in another namespace
+	LabelNetworkFSName = "networkfs.harvesterhci.io/networkfs-name"
+	// LabelSecretType records what the secret managed by the networkFS holds, e.g. "cloud-init" or "connection"
+	LabelSecretType = "networkfs.harvesterhci.io/secret-type"
+	// LabelExportUID records the UID of the NetworkFilesystemExport owning a resource in the guest cluster
+	LabelExportUID = "networkfs.harvesterhci.io/export-uid"
+
+	// AnnotationScheduleOverride suspends the schedule of the networkFS when it is "true", the desiredState is left to the user
+	AnnotationScheduleOverride = "networkfs.harvesterhci.io/schedule-override"
+	// AnnotationForceDelete skips the teardown of the deleting networkFS when it is "true", the export may be left running
+	AnnotationForceDelete = "networkfs.harvesterhci.io/force-delete"
+	// AnnotationMaintainStatus is set on the node by Harvester while it enters or is in the maintenance mode
+	AnnotationMaintainStatus = "harvesterhci.io/maintain-status"
+	// AnnotationDrainMigration records the move of the export off the draining node, the targetNode is restored once the node is back
+	AnnotationDrainMigration = "networkfs.harvesterhci.io/drain-migration"
+
+	// NetworkFSByLHVolumeIndex indexes the networkFS exported by the Longhorn backend with the volume name
+	NetworkFSByLHVolumeIndex = "networkfs.harvesterhci.io/lh-volume"
+	// NetworkFSByNodeIndex indexes the networkFS with the node chosen to host its export
+	NetworkFSByNodeIndex = "networkfs.harvesterhci.io/node"
+)
+
 func FriendlyVersion() string {
 	return fmt.Sprintf("%s (%s)", Version, GitCommit)
 }
@@ -40,6 +89,10 @@ func UpdateNetworkFSConds(curConds []networkfsv1.NetworkFSCondition, c networkfs
 	}
 
 	if found {
+		// keep the transition time if nothing changed, so the status is not updated in every round
+		if cur := curConds[pod]; cur.Status == c.Status && cur.Reason == c.Reason && cur.Message == c.Message {
+			return curConds
+		}
 		curConds[pod] = c
 	} else {
 		curConds = append(curConds, c)
@@ -47,3 +100,60 @@ func UpdateNetworkFSConds(curConds []networkfsv1.NetworkFSCondition, c networkfs
 	return curConds
 
 }
+
+// IsLHNodeSchedulable checks the Longhorn node allows scheduling and reports the Schedulable condition
+func IsLHNodeSchedulable(node *longhornv2.Node) bool {
+	if !node.Spec.AllowScheduling {
+		return false
+	}
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == longhornv2.NodeConditionTypeSchedulable {
+			return cond.Status == longhornv2.ConditionStatusTrue
+		}
+	}
+	return false
+}
+
+// IndexNetworkFSByLHVolume returns the Longhorn volume name of the networkFS using the Longhorn backend
+func IndexNetworkFSByLHVolume(networkFS *networkfsv1.NetworkFilesystem) ([]string, error) {
+	if networkFS.Spec.ExportBackend != "" && networkFS.Spec.ExportBackend != networkfsv1.ExportBackendLonghorn {
+		return nil, nil
+	}
+	return []string{networkFS.Spec.NetworkFSName}, nil
+}
+
+// IndexNetworkFSByNode returns the node chosen to host the export of the networkFS
+func IndexNetworkFSByNode(networkFS *networkfsv1.NetworkFilesystem) ([]string, error) {
+	if networkFS.Status.Node == "" {
+		return nil, nil
+	}
+	return []string{networkFS.Status.Node}, nil
+}
+
+// CloudInitSecretName returns the name of the secret which holds the cloud-init snippets of the networkFS
+func CloudInitSecretName(networkFS *networkfsv1.NetworkFilesystem) string {
+	return fmt.Sprintf("netfs-%s-cloudinit", networkFS.Name)
+}
+
+// KubeconfigKey returns the key of the guest kubeconfig in the secret of the export
+func KubeconfigKey(export *networkfsv1.NetworkFilesystemExport) string {
+	if export.Spec.KubeconfigKey == "" {
+		return "kubeconfig"
+	}
+	return export.Spec.KubeconfigKey
+}
+
+// NetworkFSOwnerReferences returns the owner references of the resources managed for the networkFS,
+// the resources must be in the same namespace as the networkFS.
+func NetworkFSOwnerReferences(networkFS *networkfsv1.NetworkFilesystem) []metav1.OwnerReference {
+	controller := true
+	return []metav1.OwnerReference{
+		{
+			APIVersion: networkfsv1.SchemeGroupVersion.String(),
+			Kind:       "NetworkFilesystem",
+			Name:       networkFS.Name,
+			UID:        networkFS.UID,
+			Controller: &controller,
+		},
+	}
+}
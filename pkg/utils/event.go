@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/generated/clientset/versioned/scheme"
+)
+
+const (
+	eventComponent = "harvester-network-fs-manager"
+
+	// EventReasonEnableRequested is recorded when the manager starts to enable the networkFS
+	EventReasonEnableRequested = "EnableRequested"
+	// EventReasonDisableRequested is recorded when the manager starts to disable the networkFS
+	EventReasonDisableRequested = "DisableRequested"
+	// EventReasonDisabled is recorded when the export of the networkFS is stopped
+	EventReasonDisabled = "Disabled"
+	// EventReasonEndpointReady is recorded when the endpoint of the networkFS becomes ready
+	EventReasonEndpointReady = "EndpointReady"
+	// EventReasonEndpointNotReady is recorded when the endpoint of the enabled networkFS has no ready address
+	EventReasonEndpointNotReady = "EndpointNotReady"
+	// EventReasonEndpointChanged is recorded when the endpoint address of the networkFS is changed
+	EventReasonEndpointChanged = "EndpointChanged"
+	// EventReasonShareManagerStopped is recorded when the Longhorn share manager of the networkFS is stopped
+	EventReasonShareManagerStopped = "ShareManagerStopped"
+	// EventReasonShareManagerError is recorded when the Longhorn share manager of the networkFS is in error state
+	EventReasonShareManagerError = "ShareManagerError"
+	// EventReasonAttachmentUpdateFailed is recorded when the Longhorn volume attachment could not be updated
+	EventReasonAttachmentUpdateFailed = "AttachmentUpdateFailed"
+	// EventReasonExportUpdateFailed is recorded when the export of the other backends could not be updated
+	EventReasonExportUpdateFailed = "ExportUpdateFailed"
+	// EventReasonRecovering is recorded when the failed export is re-driven
+	EventReasonRecovering = "Recovering"
+	// EventReasonRecoveryExhausted is recorded when the recovery retry budget is exhausted
+	EventReasonRecoveryExhausted = "RecoveryExhausted"
+	// EventReasonTeardownTimeout is recorded when the export of the deleting networkFS did not stop in time
+	EventReasonTeardownTimeout = "TeardownTimeout"
+	// EventReasonForceDeleted is recorded when the teardown of the deleting networkFS is skipped by the annotation
+	EventReasonForceDeleted = "ForceDeleted"
+	// EventReasonNodeFailover is recorded when the export is moved to the next candidate node
+	EventReasonNodeFailover = "NodeFailover"
+	// EventReasonNoEligibleNode is recorded when none of the candidate nodes can host the export
+	EventReasonNoEligibleNode = "NoEligibleNode"
+	// EventReasonMigrationStarted is recorded when the export starts to migrate to the target node
+	EventReasonMigrationStarted = "MigrationStarted"
+	// EventReasonMigrationPending is recorded when the target node is not eligible to host the export yet
+	EventReasonMigrationPending = "MigrationPending"
+	// EventReasonMigrationCompleted is recorded when the export is served from the target node
+	EventReasonMigrationCompleted = "MigrationCompleted"
+	// EventReasonMigrationFailed is recorded when the export could not be moved to the target node
+	EventReasonMigrationFailed = "MigrationFailed"
+	// EventReasonDrainMigration is recorded when the export is migrated off the cordoned or maintained node
+	EventReasonDrainMigration = "DrainMigration"
+	// EventReasonDrainBlocked is recorded when the export on the draining node has nowhere to go
+	EventReasonDrainBlocked = "DrainBlocked"
+
+	// EventReasonMounted is recorded when the node agent mounts the networkFS onto the host path
+	EventReasonMounted = "Mounted"
+	// EventReasonRemounted is recorded when the node agent remounts the networkFS from the new source
+	EventReasonRemounted = "Remounted"
+	// EventReasonUnmounted is recorded when the node agent unmounts the networkFS from the host path
+	EventReasonUnmounted = "Unmounted"
+	// EventReasonMountFailed is recorded when the node agent could not mount or unmount the networkFS
+	EventReasonMountFailed = "MountFailed"
+	// EventReasonStaleMountRemounted is recorded when the node agent remounts the mounts of the stale endpoint
+	EventReasonStaleMountRemounted = "StaleMountRemounted"
+	// EventReasonStaleMountRemountFailed is recorded when the node agent could not remount the mounts of the stale endpoint
+	EventReasonStaleMountRemountFailed = "StaleMountRemountFailed"
+
+	// EventReasonGuestVolumeProvisioned is recorded when the PV is created in the guest cluster
+	EventReasonGuestVolumeProvisioned = "GuestVolumeProvisioned"
+	// EventReasonGuestVolumeRecreating is recorded when the PV in the guest cluster is recreated for the new endpoint
+	EventReasonGuestVolumeRecreating = "GuestVolumeRecreating"
+	// EventReasonGuestVolumeInUse is recorded when the PV in the guest cluster is kept for the pods using it
+	EventReasonGuestVolumeInUse = "GuestVolumeInUse"
+	// EventReasonGuestVolumeFailed is recorded when the PV could not be provisioned to the guest cluster
+	EventReasonGuestVolumeFailed = "GuestVolumeFailed"
+
+	// EventReasonScheduled is recorded when the schedule flips the desiredState of the networkFS
+	EventReasonScheduled = "Scheduled"
+	// EventReasonScheduleOverridden is recorded when the scheduled transition is skipped by the manual override
+	EventReasonScheduleOverridden = "ScheduleOverridden"
+
+	// EventReasonIdleDisabled is recorded when the networkFS is disabled after it had no client for the idleTimeout
+	EventReasonIdleDisabled = "IdleDisabled"
+)
+
+// NewEventRecorder creates the recorder of the events on the networkFS, the events are sent until the context is done
+func NewEventRecorder(ctx context.Context, client kubernetes.Interface, nodeName string) record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartLogging(logrus.Debugf)
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	go func() {
+		<-ctx.Done()
+		broadcaster.Shutdown()
+	}()
+	return broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: eventComponent, Host: nodeName})
+}
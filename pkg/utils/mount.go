@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// DefaultMountHostPathPrefix is the directory below which the NetworkFilesystemMount may mount the networkFS
+const DefaultMountHostPathPrefix = "/mnt"
+
+// allowedNFSMountOptions are the NFS mount options which can be set by the NetworkFilesystemMount, the options which
+// weaken the host (e.g. suid and dev) are not allowed, nosuid and nodev are always set
+var allowedNFSMountOptions = map[string]bool{
+	"vers": true, "nfsvers": true, "minorversion": true, "proto": true, "port": true,
+	"hard": true, "soft": true, "softreval": true, "timeo": true, "retrans": true,
+	"rsize": true, "wsize": true, "nconnect": true, "max_connect": true, "sec": true,
+	"ac": true, "noac": true, "actimeo": true, "acregmin": true, "acregmax": true, "acdirmin": true, "acdirmax": true,
+	"lookupcache": true, "cto": true, "nocto": true, "lock": true, "nolock": true, "local_lock": true,
+	"intr": true, "nointr": true, "resvport": true, "noresvport": true, "fsc": true, "nofsc": true,
+	"sync": true, "async": true, "atime": true, "noatime": true, "relatime": true, "diratime": true, "nodiratime": true,
+	"rw": true, "ro": true, "noexec": true, "nosuid": true, "nodev": true,
+}
+
+// NFSMountOptions splits the comma separated mount options, the spaces around them and the empty ones are dropped
+func NFSMountOptions(opts string) []string {
+	var options []string
+	for _, opt := range strings.Split(opts, ",") {
+		if opt = strings.TrimSpace(opt); opt != "" {
+			options = append(options, opt)
+		}
+	}
+	return options
+}
+
+// ValidateMountHostPath checks the host path is a clean absolute path below the prefix
+func ValidateMountHostPath(hostPath, prefix string) error {
+	if !path.IsAbs(hostPath) {
+		return fmt.Errorf("hostPath %q must be an absolute path", hostPath)
+	}
+	for _, elem := range strings.Split(hostPath, "/") {
+		if elem == ".." {
+			return fmt.Errorf("hostPath %q must not contain \"..\"", hostPath)
+		}
+	}
+	if path.Clean(hostPath) != hostPath {
+		return fmt.Errorf("hostPath %q must be a clean path, e.g. %s", hostPath, path.Clean(hostPath))
+	}
+	prefix = path.Clean(prefix)
+	if hostPath == prefix || !strings.HasPrefix(hostPath, strings.TrimSuffix(prefix, "/")+"/") {
+		return fmt.Errorf("hostPath %q must be below %s", hostPath, prefix)
+	}
+	return nil
+}
+
+// ValidateNFSMountOptions checks every comma separated mount option is allowed
+func ValidateNFSMountOptions(opts string) error {
+	for _, opt := range NFSMountOptions(opts) {
+		if !isAllowedNFSMountOption(opt) {
+			return fmt.Errorf("mount option %q is not allowed", opt)
+		}
+	}
+	return nil
+}
+
+// RestrictNFSMountOptions drops the mount options which are not allowed, nosuid and nodev are always appended
+func RestrictNFSMountOptions(options []string) []string {
+	var ret []string
+	for _, opt := range options {
+		if isAllowedNFSMountOption(opt) && opt != "nosuid" && opt != "nodev" {
+			ret = append(ret, opt)
+		}
+	}
+	return append(ret, "nosuid", "nodev")
+}
+
+func isAllowedNFSMountOption(opt string) bool {
+	key, _, _ := strings.Cut(opt, "=")
+	return allowedNFSMountOptions[key]
+}
@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNFSMountOptions(t *testing.T) {
+	want := []string{"vers=4.1", "hard", "timeo=600"}
+	if got := NFSMountOptions(" vers=4.1,hard,, timeo=600,"); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected options %v, got %v", want, got)
+	}
+	if got := NFSMountOptions(""); got != nil {
+		t.Errorf("expected no options, got %v", got)
+	}
+}
+
+func TestValidateMountHostPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		hostPath string
+		prefix   string
+		wantErr  string
+	}{
+		{
+			name:     "path below the prefix",
+			hostPath: "/mnt/data",
+			prefix:   "/mnt",
+		},
+		{
+			name:     "prefix with the trailing slash",
+			hostPath: "/mnt/data/pvc-1234",
+			prefix:   "/mnt/",
+		},
+		{
+			name:     "any path below the root prefix",
+			hostPath: "/var/lib/data",
+			prefix:   "/",
+		},
+		{
+			name:     "relative path",
+			hostPath: "mnt/data",
+			prefix:   "/mnt",
+			wantErr:  "must be an absolute path",
+		},
+		{
+			name:     "parent directory",
+			hostPath: "/mnt/../etc",
+			prefix:   "/mnt",
+			wantErr:  `must not contain ".."`,
+		},
+		{
+			name:     "unclean path",
+			hostPath: "/mnt//data/",
+			prefix:   "/mnt",
+			wantErr:  "must be a clean path",
+		},
+		{
+			name:     "prefix itself",
+			hostPath: "/mnt",
+			prefix:   "/mnt",
+			wantErr:  "must be below /mnt",
+		},
+		{
+			name:     "sibling sharing the prefix",
+			hostPath: "/mnt2/data",
+			prefix:   "/mnt",
+			wantErr:  "must be below /mnt",
+		},
+		{
+			name:     "root",
+			hostPath: "/",
+			prefix:   "/",
+			wantErr:  "must be below /",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateMountHostPath(tt.hostPath, tt.prefix)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestNFSMountOptionsRestriction(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "allowed options",
+			opts: "vers=4.1,hard,timeo=600,noatime,ro",
+			want: []string{"vers=4.1", "hard", "timeo=600", "noatime", "ro", "nosuid", "nodev"},
+		},
+		{
+			name: "nosuid and nodev are not duplicated",
+			opts: "nodev,vers=4.2,nosuid",
+			want: []string{"vers=4.2", "nosuid", "nodev"},
+		},
+		{
+			name:    "suid is dropped",
+			opts:    "vers=4.1,suid",
+			want:    []string{"vers=4.1", "nosuid", "nodev"},
+			wantErr: `mount option "suid" is not allowed`,
+		},
+		{
+			name:    "unknown option is dropped",
+			opts:    "context=system_u:object_r:etc_t:s0,hard",
+			want:    []string{"hard", "nosuid", "nodev"},
+			wantErr: `mount option "context=system_u:object_r:etc_t:s0" is not allowed`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RestrictNFSMountOptions(NFSMountOptions(tt.opts)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected options %v, got %v", tt.want, got)
+			}
+			err := ValidateNFSMountOptions(tt.opts)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
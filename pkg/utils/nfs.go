@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"strings"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+)
+
+// NFSExportPath returns the NFS path of the export, the Longhorn share manager exports the volume as /<volume name>
+// before the path is observed
+func NFSExportPath(networkFS *networkfsv1.NetworkFilesystem) string {
+	if networkFS.Status.ExportPath != "" {
+		return networkFS.Status.ExportPath
+	}
+	return "/" + networkFS.Spec.NetworkFSName
+}
+
+// NFSSource returns the NFS source of the export on the address, the IPv6 address is enclosed in brackets
+func NFSSource(address, exportPath string) string {
+	if strings.Contains(address, ":") {
+		address = "[" + address + "]"
+	}
+	return address + ":" + exportPath
+}
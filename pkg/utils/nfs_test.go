@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"testing"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+)
+
+func TestNFSSource(t *testing.T) {
+	tests := []struct {
+		name      string
+		networkFS *networkfsv1.NetworkFilesystem
+		want      string
+	}{
+		{
+			name: "observed export path",
+			networkFS: &networkfsv1.NetworkFilesystem{
+				Spec:   networkfsv1.NetworkFSSpec{NetworkFSName: "pvc-1234"},
+				Status: networkfsv1.NetworkFSStatus{Endpoint: "10.53.0.10", ExportPath: "/pvc-1234/data"},
+			},
+			want: "10.53.0.10:/pvc-1234/data",
+		},
+		{
+			name: "export path of the volume name before it is observed",
+			networkFS: &networkfsv1.NetworkFilesystem{
+				Spec:   networkfsv1.NetworkFSSpec{NetworkFSName: "pvc-1234"},
+				Status: networkfsv1.NetworkFSStatus{Endpoint: "10.53.0.10"},
+			},
+			want: "10.53.0.10:/pvc-1234",
+		},
+		{
+			name: "IPv6 endpoint",
+			networkFS: &networkfsv1.NetworkFilesystem{
+				Spec:   networkfsv1.NetworkFSSpec{NetworkFSName: "pvc-1234"},
+				Status: networkfsv1.NetworkFSStatus{Endpoint: "fd00::10"},
+			},
+			want: "[fd00::10]:/pvc-1234",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NFSSource(tt.networkFS.Status.Endpoint, NFSExportPath(tt.networkFS)); got != tt.want {
+				t.Errorf("expected source %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
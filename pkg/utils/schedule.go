@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	// the manager image may not ship the zoneinfo
+	_ "time/tzdata"
+
+	"github.com/robfig/cron/v3"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+)
+
+// the transitions are replayed up to this limit when the manager was down for a while
+const maxCatchUpTransitions = 10000
+
+// Schedule is the parsed schedule of the networkFS
+type Schedule struct {
+	enable  cron.Schedule
+	disable cron.Schedule
+}
+
+// ParseSchedule parses the cron expressions of the schedule in its time zone
+func ParseSchedule(spec *networkfsv1.ExportSchedule) (*Schedule, error) {
+	if spec.Enable == "" && spec.Disable == "" {
+		return nil, fmt.Errorf("at least one of enable and disable is required")
+	}
+	if _, err := time.LoadLocation(spec.TimeZone); err != nil {
+		return nil, fmt.Errorf("invalid timeZone %q: %w", spec.TimeZone, err)
+	}
+
+	s := &Schedule{}
+	var err error
+	if s.enable, err = parseCron(spec.Enable, spec.TimeZone); err != nil {
+		return nil, fmt.Errorf("invalid enable: %w", err)
+	}
+	if s.disable, err = parseCron(spec.Disable, spec.TimeZone); err != nil {
+		return nil, fmt.Errorf("invalid disable: %w", err)
+	}
+	if spec.Enable != "" && spec.Enable == spec.Disable {
+		return nil, fmt.Errorf("enable and disable can not be the same")
+	}
+	return s, nil
+}
+
+func parseCron(expr, timeZone string) (cron.Schedule, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(expr, "TZ=") || strings.HasPrefix(expr, "CRON_TZ=") {
+		return nil, fmt.Errorf("%q should not specify the time zone, use timeZone instead", expr)
+	}
+	// the time zone of the parser is the local one of the manager by default
+	if timeZone == "" {
+		timeZone = "UTC"
+	}
+	return cron.ParseStandard(fmt.Sprintf("CRON_TZ=%s %s", timeZone, expr))
+}
+
+// Next returns the first transition after the time, the zero time is returned if there is none
+func (s *Schedule) Next(t time.Time) (networkfsv1.NetworkFSState, time.Time) {
+	var state networkfsv1.NetworkFSState
+	var next time.Time
+	if s.enable != nil {
+		state, next = networkfsv1.NetworkFSStateEnabled, s.enable.Next(t)
+	}
+	if s.disable != nil {
+		// the disable wins if both are at the same time
+		if disable := s.disable.Next(t); !disable.IsZero() && (next.IsZero() || !disable.After(next)) {
+			state, next = networkfsv1.NetworkFSStateDisabled, disable
+		}
+	}
+	if next.IsZero() {
+		return "", next
+	}
+	return state, next
+}
+
+// LastDue returns the latest transition which is due at the time, starting from the planned one
+func (s *Schedule) LastDue(state networkfsv1.NetworkFSState, planned, now time.Time) (networkfsv1.NetworkFSState, time.Time) {
+	for i := 0; i < maxCatchUpTransitions; i++ {
+		nextState, next := s.Next(planned)
+		if next.IsZero() || next.After(now) {
+			break
+		}
+		state, planned = nextState, next
+	}
+	return state, planned
+}
@@ -0,0 +1,203 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+)
+
+// the office hours of the weekdays, 2024-01-01 is a Monday
+var testOfficeHours = &networkfsv1.ExportSchedule{Enable: "0 8 * * 1-5", Disable: "0 18 * * 1-5"}
+
+func testTime(t *testing.T, value string) time.Time {
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatalf("failed to parse time %s: %v", value, err)
+	}
+	return at
+}
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    *networkfsv1.ExportSchedule
+		wantErr string
+	}{
+		{
+			name: "office hours",
+			spec: testOfficeHours,
+		},
+		{
+			name: "enable only in the time zone",
+			spec: &networkfsv1.ExportSchedule{Enable: "30 7 * * *", TimeZone: "Asia/Taipei"},
+		},
+		{
+			name:    "neither enable nor disable",
+			spec:    &networkfsv1.ExportSchedule{TimeZone: "UTC"},
+			wantErr: "at least one of enable and disable is required",
+		},
+		{
+			name:    "unknown time zone",
+			spec:    &networkfsv1.ExportSchedule{Enable: "0 8 * * *", TimeZone: "Mars/Olympus"},
+			wantErr: `invalid timeZone "Mars/Olympus"`,
+		},
+		{
+			name:    "invalid enable",
+			spec:    &networkfsv1.ExportSchedule{Enable: "0 25 * * *"},
+			wantErr: "invalid enable",
+		},
+		{
+			name:    "invalid disable",
+			spec:    &networkfsv1.ExportSchedule{Enable: "0 8 * * *", Disable: "every day"},
+			wantErr: "invalid disable",
+		},
+		{
+			name:    "time zone in the expression",
+			spec:    &networkfsv1.ExportSchedule{Enable: "CRON_TZ=Asia/Taipei 0 8 * * *"},
+			wantErr: "should not specify the time zone, use timeZone instead",
+		},
+		{
+			name:    "same enable and disable",
+			spec:    &networkfsv1.ExportSchedule{Enable: "0 8 * * *", Disable: "0 8 * * *"},
+			wantErr: "enable and disable can not be the same",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSchedule(tt.spec)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	tests := []struct {
+		name      string
+		spec      *networkfsv1.ExportSchedule
+		now       string
+		wantState networkfsv1.NetworkFSState
+		wantTime  string
+	}{
+		{
+			name:      "enabled in the morning",
+			spec:      testOfficeHours,
+			now:       "2024-01-01T07:00:00Z",
+			wantState: networkfsv1.NetworkFSStateEnabled,
+			wantTime:  "2024-01-01T08:00:00Z",
+		},
+		{
+			name:      "disabled in the evening",
+			spec:      testOfficeHours,
+			now:       "2024-01-01T08:00:00Z",
+			wantState: networkfsv1.NetworkFSStateDisabled,
+			wantTime:  "2024-01-01T18:00:00Z",
+		},
+		{
+			name:      "enabled after the weekend",
+			spec:      testOfficeHours,
+			now:       "2024-01-05T19:00:00Z",
+			wantState: networkfsv1.NetworkFSStateEnabled,
+			wantTime:  "2024-01-08T08:00:00Z",
+		},
+		{
+			name:      "disable wins at the same time",
+			spec:      &networkfsv1.ExportSchedule{Enable: "0 * * * *", Disable: "0 0 * * *"},
+			now:       "2024-01-01T23:30:00Z",
+			wantState: networkfsv1.NetworkFSStateDisabled,
+			wantTime:  "2024-01-02T00:00:00Z",
+		},
+		{
+			name:      "disable only",
+			spec:      &networkfsv1.ExportSchedule{Disable: "0 18 * * *"},
+			now:       "2024-01-01T19:00:00Z",
+			wantState: networkfsv1.NetworkFSStateDisabled,
+			wantTime:  "2024-01-02T18:00:00Z",
+		},
+		{
+			name:      "time zone of the schedule",
+			spec:      &networkfsv1.ExportSchedule{Enable: "0 8 * * *", TimeZone: "Asia/Taipei"},
+			now:       "2024-01-01T01:00:00Z",
+			wantState: networkfsv1.NetworkFSStateEnabled,
+			wantTime:  "2024-01-02T00:00:00Z",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.spec)
+			if err != nil {
+				t.Fatalf("failed to parse the schedule: %v", err)
+			}
+			state, next := schedule.Next(testTime(t, tt.now))
+			if state != tt.wantState {
+				t.Errorf("expected state %s, got %s", tt.wantState, state)
+			}
+			if want := testTime(t, tt.wantTime); !next.Equal(want) {
+				t.Errorf("expected time %s, got %s", want, next)
+			}
+		})
+	}
+}
+
+func TestScheduleLastDue(t *testing.T) {
+	tests := []struct {
+		name      string
+		state     networkfsv1.NetworkFSState
+		planned   string
+		now       string
+		wantState networkfsv1.NetworkFSState
+		wantTime  string
+	}{
+		{
+			name:      "planned transition is due",
+			state:     networkfsv1.NetworkFSStateEnabled,
+			planned:   "2024-01-01T08:00:00Z",
+			now:       "2024-01-01T09:00:00Z",
+			wantState: networkfsv1.NetworkFSStateEnabled,
+			wantTime:  "2024-01-01T08:00:00Z",
+		},
+		{
+			name:      "latest transition after the manager was down",
+			state:     networkfsv1.NetworkFSStateEnabled,
+			planned:   "2024-01-01T08:00:00Z",
+			now:       "2024-01-03T19:00:00Z",
+			wantState: networkfsv1.NetworkFSStateDisabled,
+			wantTime:  "2024-01-03T18:00:00Z",
+		},
+		{
+			name:      "weekend is skipped",
+			state:     networkfsv1.NetworkFSStateDisabled,
+			planned:   "2024-01-05T18:00:00Z",
+			now:       "2024-01-08T08:30:00Z",
+			wantState: networkfsv1.NetworkFSStateEnabled,
+			wantTime:  "2024-01-08T08:00:00Z",
+		},
+	}
+
+	schedule, err := ParseSchedule(testOfficeHours)
+	if err != nil {
+		t.Fatalf("failed to parse the schedule: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, at := schedule.LastDue(tt.state, testTime(t, tt.planned), testTime(t, tt.now))
+			if state != tt.wantState {
+				t.Errorf("expected state %s, got %s", tt.wantState, state)
+			}
+			if want := testTime(t, tt.wantTime); !at.Equal(want) {
+				t.Errorf("expected time %s, got %s", want, at)
+			}
+		})
+	}
+}
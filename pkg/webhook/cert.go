@@ -0,0 +1,157 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	certValidity = 10 * 365 * 24 * time.Hour
+	// renew the serving certificate when it expires in less than certRenewBefore
+	certRenewBefore = 30 * 24 * time.Hour
+
+	secretKeyCACert = "ca.crt"
+)
+
+// ensureCertificate loads the self-signed serving certificate from the TLS secret,
+// (re)generating it when it is missing, broken or about to expire.
+// Every replica shares the same secret, so the first one wins and the others reuse it.
+func ensureCertificate(ctx context.Context, client kubernetes.Interface, namespace, secretName, serviceName string) (*tls.Certificate, []byte, error) {
+	secrets := client.CoreV1().Secrets(namespace)
+	secret, err := secrets.Get(ctx, secretName, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, nil, fmt.Errorf("failed to get webhook secret %s: %w", secretName, err)
+	}
+
+	if err == nil {
+		if cert, caPEM, ok := loadCertificate(secret); ok {
+			return cert, caPEM, nil
+		}
+		logrus.Infof("Webhook certificate in secret %s is invalid or expiring, regenerate it", secretName)
+	}
+
+	caPEM, certPEM, keyPEM, genErr := generateCertificate(serviceName, namespace)
+	if genErr != nil {
+		return nil, nil, fmt.Errorf("failed to generate webhook certificate: %w", genErr)
+	}
+	data := map[string][]byte{
+		secretKeyCACert:         caPEM,
+		corev1.TLSCertKey:       certPEM,
+		corev1.TLSPrivateKeyKey: keyPEM,
+	}
+
+	if apierrors.IsNotFound(err) {
+		newSecret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      secretName,
+				Namespace: namespace,
+			},
+			Type: corev1.SecretTypeTLS,
+			Data: data,
+		}
+		if _, err := secrets.Create(ctx, newSecret, metav1.CreateOptions{}); err != nil {
+			if apierrors.IsAlreadyExists(err) {
+				// another replica won the race, reuse its certificate
+				return ensureCertificate(ctx, client, namespace, secretName, serviceName)
+			}
+			return nil, nil, fmt.Errorf("failed to create webhook secret %s: %w", secretName, err)
+		}
+	} else {
+		secretCpy := secret.DeepCopy()
+		secretCpy.Data = data
+		if _, err := secrets.Update(ctx, secretCpy, metav1.UpdateOptions{}); err != nil {
+			return nil, nil, fmt.Errorf("failed to update webhook secret %s: %w", secretName, err)
+		}
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &cert, caPEM, nil
+}
+
+func loadCertificate(secret *corev1.Secret) (*tls.Certificate, []byte, bool) {
+	caPEM := secret.Data[secretKeyCACert]
+	cert, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil || len(caPEM) == 0 {
+		return nil, nil, false
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil || time.Now().Add(certRenewBefore).After(leaf.NotAfter) {
+		return nil, nil, false
+	}
+	return &cert, caPEM, true
+}
+
+func generateCertificate(serviceName, namespace string) (caPEM, certPEM, keyPEM []byte, err error) {
+	now := time.Now()
+
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(now.UnixNano()),
+		Subject:               pkix.Name{CommonName: fmt.Sprintf("%s-ca@%d", serviceName, now.Unix())},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(certValidity),
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(now.UnixNano() + 1),
+		Subject:      pkix.Name{CommonName: fmt.Sprintf("%s.%s.svc", serviceName, namespace)},
+		DNSNames: []string{
+			serviceName,
+			fmt.Sprintf("%s.%s", serviceName, namespace),
+			fmt.Sprintf("%s.%s.svc", serviceName, namespace),
+			fmt.Sprintf("%s.%s.svc.cluster.local", serviceName, namespace),
+		},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.Add(certValidity),
+		KeyUsage:    x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return encodePEM("CERTIFICATE", caDER), encodePEM("CERTIFICATE", der), encodePEM("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key)), nil
+}
+
+func encodePEM(blockType string, der []byte) []byte {
+	buf := &bytes.Buffer{}
+	_ = pem.Encode(buf, &pem.Block{Type: blockType, Bytes: der})
+	return buf.Bytes()
+}
@@ -0,0 +1,114 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	networkfsv2 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta2"
+)
+
+const (
+	// ConversionPath is the path of the CRD conversion webhook
+	ConversionPath = "/v1/webhook/conversion"
+
+	networkFSCRDName = "networkfilesystems.harvesterhci.io"
+)
+
+func (s *Server) serveConversion(w http.ResponseWriter, r *http.Request) {
+	review := &apiextensionsv1.ConversionReview{}
+	if err := decodeBody(r, review); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if review.Request == nil {
+		http.Error(w, "conversion review contains no request", http.StatusBadRequest)
+		return
+	}
+
+	response := &apiextensionsv1.ConversionResponse{
+		UID:    review.Request.UID,
+		Result: metav1.Status{Status: metav1.StatusSuccess},
+	}
+	for _, obj := range review.Request.Objects {
+		converted, err := convertNetworkFS(obj.Raw, review.Request.DesiredAPIVersion)
+		if err != nil {
+			logrus.Errorf("Failed to convert networkfilesystem to %s: %v", review.Request.DesiredAPIVersion, err)
+			response.ConvertedObjects = nil
+			response.Result = metav1.Status{
+				Status:  metav1.StatusFailure,
+				Message: err.Error(),
+			}
+			break
+		}
+		response.ConvertedObjects = append(response.ConvertedObjects, runtime.RawExtension{Raw: converted})
+	}
+
+	review.Request = nil
+	review.Response = response
+	writeResponse(w, review)
+}
+
+// convertNetworkFS converts the raw NetworkFilesystem between v1beta1 and v1beta2
+func convertNetworkFS(raw []byte, desiredAPIVersion string) ([]byte, error) {
+	typeMeta := &metav1.TypeMeta{}
+	if err := json.Unmarshal(raw, typeMeta); err != nil {
+		return nil, fmt.Errorf("failed to decode type meta: %w", err)
+	}
+	if typeMeta.APIVersion == desiredAPIVersion {
+		return raw, nil
+	}
+
+	switch {
+	case typeMeta.APIVersion == networkfsv1.SchemeGroupVersion.String() && desiredAPIVersion == networkfsv2.SchemeGroupVersion.String():
+		src := &networkfsv1.NetworkFilesystem{}
+		if err := json.Unmarshal(raw, src); err != nil {
+			return nil, err
+		}
+		return json.Marshal(networkfsv2.ConvertFromV1beta1(src))
+	case typeMeta.APIVersion == networkfsv2.SchemeGroupVersion.String() && desiredAPIVersion == networkfsv1.SchemeGroupVersion.String():
+		src := &networkfsv2.NetworkFilesystem{}
+		if err := json.Unmarshal(raw, src); err != nil {
+			return nil, err
+		}
+		return json.Marshal(networkfsv2.ConvertToV1beta1(src))
+	}
+	return nil, fmt.Errorf("unsupported conversion from %s to %s", typeMeta.APIVersion, desiredAPIVersion)
+}
+
+// injectConversionCABundle makes the apiserver trust our self-signed certificate for CRD conversion
+func (s *Server) injectConversionCABundle(ctx context.Context, caPEM []byte) error {
+	crds := s.crdClient.ApiextensionsV1().CustomResourceDefinitions()
+	crd, err := crds.Get(ctx, networkFSCRDName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			logrus.Warnf("CRD %s is not found, skip injecting the conversion CA bundle", networkFSCRDName)
+			return nil
+		}
+		return fmt.Errorf("failed to get CRD %s: %w", networkFSCRDName, err)
+	}
+
+	conversion := crd.Spec.Conversion
+	if conversion == nil || conversion.Strategy != apiextensionsv1.WebhookConverter || conversion.Webhook == nil || conversion.Webhook.ClientConfig == nil {
+		logrus.Warnf("CRD %s does not use the conversion webhook, skip injecting the CA bundle", networkFSCRDName)
+		return nil
+	}
+	if string(conversion.Webhook.ClientConfig.CABundle) == string(caPEM) {
+		return nil
+	}
+
+	crdCpy := crd.DeepCopy()
+	crdCpy.Spec.Conversion.Webhook.ClientConfig.CABundle = caPEM
+	if _, err := crds.Update(ctx, crdCpy, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update CRD %s: %w", networkFSCRDName, err)
+	}
+	return nil
+}
@@ -0,0 +1,342 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"reflect"
+	"slices"
+	"strings"
+
+	lhclientset "github.com/longhorn/longhorn-manager/k8s/pkg/client/clientset/versioned"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/backend"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/backend/ganesha"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+type networkFSValidator struct {
+	client   kubernetes.Interface
+	lhClient lhclientset.Interface
+}
+
+// NewNetworkFSValidator creates the validator of the NetworkFilesystem
+func NewNetworkFSValidator(client kubernetes.Interface, lhClient lhclientset.Interface) Validator {
+	return &networkFSValidator{
+		client:   client,
+		lhClient: lhClient,
+	}
+}
+
+func (v *networkFSValidator) Kind() string {
+	return "NetworkFilesystem"
+}
+
+func (v *networkFSValidator) Create(request *admissionv1.AdmissionRequest) error {
+	networkFS := &networkfsv1.NetworkFilesystem{}
+	if err := json.Unmarshal(request.Object.Raw, networkFS); err != nil {
+		return fmt.Errorf("failed to decode networkfilesystem: %w", err)
+	}
+
+	return v.validateSpec(nil, networkFS)
+}
+
+func (v *networkFSValidator) Update(request *admissionv1.AdmissionRequest) error {
+	oldNetworkFS := &networkfsv1.NetworkFilesystem{}
+	if err := json.Unmarshal(request.OldObject.Raw, oldNetworkFS); err != nil {
+		return fmt.Errorf("failed to decode old networkfilesystem: %w", err)
+	}
+	networkFS := &networkfsv1.NetworkFilesystem{}
+	if err := json.Unmarshal(request.Object.Raw, networkFS); err != nil {
+		return fmt.Errorf("failed to decode networkfilesystem: %w", err)
+	}
+
+	// status and metadata updates are always allowed
+	if reflect.DeepEqual(oldNetworkFS.Spec, networkFS.Spec) {
+		return nil
+	}
+
+	// the controller is driving the volume attachment, changing the spec now would race with it. Disabling is always
+	// allowed, so the export which never gets ready can be stopped.
+	if state := oldNetworkFS.Status.State; (state == networkfsv1.NetworkFSStateEnabling || state == networkfsv1.NetworkFSStateDisabling) && !isDisableOnly(oldNetworkFS, networkFS) {
+		return fmt.Errorf("networkfilesystem %s is %s, spec can not be changed until the transition is finished, only desiredState can be set to %s", networkFS.Name, state, networkfsv1.NetworkFSStateDisabled)
+	}
+
+	return v.validateSpec(oldNetworkFS, networkFS)
+}
+
+// isDisableOnly returns whether the update only sets the desiredState to Disabled
+func isDisableOnly(oldNetworkFS, networkFS *networkfsv1.NetworkFilesystem) bool {
+	if networkFS.Spec.DesiredState != networkfsv1.NetworkFSStateDisabled {
+		return false
+	}
+	spec := oldNetworkFS.Spec.DeepCopy()
+	spec.DesiredState = networkFS.Spec.DesiredState
+	return reflect.DeepEqual(*spec, networkFS.Spec)
+}
+
+func (v *networkFSValidator) validateSpec(oldNetworkFS, networkFS *networkfsv1.NetworkFilesystem) error {
+	switch networkFS.Spec.DesiredState {
+	case networkfsv1.NetworkFSStateEnabled, networkfsv1.NetworkFSStateDisabled:
+	default:
+		return fmt.Errorf("invalid desiredState %q, only %q and %q are allowed", networkFS.Spec.DesiredState, networkfsv1.NetworkFSStateEnabled, networkfsv1.NetworkFSStateDisabled)
+	}
+
+	backendType := backend.TypeOf(networkFS)
+	switch backendType {
+	case networkfsv1.ExportBackendLonghorn, networkfsv1.ExportBackendGanesha:
+	default:
+		return fmt.Errorf("invalid exportBackend %q", backendType)
+	}
+	backendChanged := oldNetworkFS != nil && backend.TypeOf(oldNetworkFS) != backendType
+	if backendChanged && oldNetworkFS.Status.State != networkfsv1.NetworkFSStateDisabled {
+		return fmt.Errorf("exportBackend can only be changed when networkfilesystem %s is disabled", networkFS.Name)
+	}
+
+	protocol := backend.ProtocolOf(networkFS)
+	switch protocol {
+	case networkfsv1.NetworkFSTypeNFS, networkfsv1.NetworkFSTypeSMB:
+	default:
+		return fmt.Errorf("invalid protocol %q", protocol)
+	}
+	if !backend.SupportsProtocol(backendType, protocol) {
+		return fmt.Errorf("exportBackend %s does not support protocol %s", backendType, protocol)
+	}
+	if oldNetworkFS != nil && backend.ProtocolOf(oldNetworkFS) != protocol && oldNetworkFS.Status.State != networkfsv1.NetworkFSStateDisabled {
+		return fmt.Errorf("protocol can only be changed when networkfilesystem %s is disabled", networkFS.Name)
+	}
+	if protocol == networkfsv1.NetworkFSTypeSMB && (oldNetworkFS == nil || !reflect.DeepEqual(oldNetworkFS.Spec.SMBCredentialsSecretRef, networkFS.Spec.SMBCredentialsSecretRef) || backend.ProtocolOf(oldNetworkFS) != protocol) {
+		if err := v.validateSMBCredentials(networkFS); err != nil {
+			return err
+		}
+	}
+
+	if err := validateAccessRules(backendType, protocol, networkFS.Spec.AccessRules); err != nil {
+		return err
+	}
+	if err := validateService(networkFS.Spec.Service); err != nil {
+		return err
+	}
+	if err := validateConnectionSecretRef(networkFS); err != nil {
+		return err
+	}
+	if networkFS.Spec.Schedule != nil {
+		if _, err := utils.ParseSchedule(networkFS.Spec.Schedule); err != nil {
+			return fmt.Errorf("invalid schedule: %w", err)
+		}
+	}
+	if networkFS.Spec.IdleTimeout != nil && networkFS.Spec.IdleTimeout.Duration <= 0 {
+		return fmt.Errorf("invalid idleTimeout %s, it should be positive", networkFS.Spec.IdleTimeout.Duration)
+	}
+
+	if oldNetworkFS == nil || backendChanged || oldNetworkFS.Spec.NetworkFSName != networkFS.Spec.NetworkFSName {
+		if err := v.validateSource(networkFS); err != nil {
+			return err
+		}
+	}
+
+	if networkFS.Spec.PreferredNode != "" && (oldNetworkFS == nil || backendChanged || oldNetworkFS.Spec.PreferredNode != networkFS.Spec.PreferredNode) {
+		if err := v.validateNode(backendType, networkFS.Spec.PreferredNode, true); err != nil {
+			return err
+		}
+	}
+	return v.validatePlacement(oldNetworkFS, networkFS, backendType, backendChanged)
+}
+
+// validatePlacement checks the candidate nodes, the nodeSelector and the targetNode, the nodes only have to exist
+// because their readiness is evaluated when the node is chosen.
+func (v *networkFSValidator) validatePlacement(oldNetworkFS, networkFS *networkfsv1.NetworkFilesystem, backendType networkfsv1.ExportBackendType, backendChanged bool) error {
+	if len(networkFS.Spec.CandidateNodes) > 0 && networkFS.Spec.PreferredNode != "" {
+		return fmt.Errorf("perferredNodes is deprecated, it can not be set together with candidateNodes")
+	}
+	if _, err := labels.ValidatedSelectorFromSet(networkFS.Spec.NodeSelector); err != nil {
+		return fmt.Errorf("invalid nodeSelector: %w", err)
+	}
+
+	seen := map[string]struct{}{}
+	for i, name := range networkFS.Spec.CandidateNodes {
+		if name == "" {
+			return fmt.Errorf("candidateNodes[%d] can not be empty", i)
+		}
+		if _, found := seen[name]; found {
+			return fmt.Errorf("candidateNodes[%d]: node %s is duplicated", i, name)
+		}
+		seen[name] = struct{}{}
+		if oldNetworkFS != nil && !backendChanged && slices.Contains(oldNetworkFS.Spec.CandidateNodes, name) {
+			continue
+		}
+		if err := v.validateNode(backendType, name, false); err != nil {
+			return fmt.Errorf("candidateNodes[%d]: %w", i, err)
+		}
+	}
+
+	target := networkFS.Spec.TargetNode
+	if target != "" && (oldNetworkFS == nil || backendChanged || oldNetworkFS.Spec.TargetNode != target) {
+		if err := v.validateNode(backendType, target, false); err != nil {
+			return fmt.Errorf("targetNode: %w", err)
+		}
+	}
+	return nil
+}
+
+// validateSource checks the volume to be exported exists
+func (v *networkFSValidator) validateSource(networkFS *networkfsv1.NetworkFilesystem) error {
+	name := networkFS.Spec.NetworkFSName
+	if name == "" {
+		return fmt.Errorf("networkFSName can not be empty")
+	}
+	if backend.TypeOf(networkFS) == networkfsv1.ExportBackendGanesha {
+		if _, err := v.client.CoreV1().PersistentVolumeClaims(networkFS.Namespace).Get(context.TODO(), name, metav1.GetOptions{}); err != nil {
+			if apierrors.IsNotFound(err) {
+				return fmt.Errorf("persistent volume claim %s/%s is not found", networkFS.Namespace, name)
+			}
+			return fmt.Errorf("failed to get persistent volume claim %s/%s: %w", networkFS.Namespace, name, err)
+		}
+		return nil
+	}
+	return v.validateVolume(name)
+}
+
+// validateAccessRules checks the access rules are valid and could be enforced by the backend
+func validateAccessRules(backendType networkfsv1.ExportBackendType, protocol string, rules []networkfsv1.AccessRule) error {
+	for i, rule := range rules {
+		if len(rule.Clients) == 0 {
+			return fmt.Errorf("accessRules[%d] has no clients", i)
+		}
+		for _, client := range rule.Clients {
+			if _, err := backend.ClientCIDR(client); err != nil {
+				return fmt.Errorf("accessRules[%d]: %w", i, err)
+			}
+		}
+
+		access, squash := backend.AccessOf(rule), backend.SquashOf(rule)
+		switch access {
+		case networkfsv1.AccessReadWrite, networkfsv1.AccessReadOnly:
+		default:
+			return fmt.Errorf("accessRules[%d] has invalid access %q", i, access)
+		}
+		switch squash {
+		case networkfsv1.SquashNone, networkfsv1.SquashRoot, networkfsv1.SquashAll:
+		default:
+			return fmt.Errorf("accessRules[%d] has invalid squash %q", i, squash)
+		}
+
+		// the Longhorn share manager always exports read-write without squash, only the clients are enforced
+		if backendType == networkfsv1.ExportBackendLonghorn && (access != networkfsv1.AccessReadWrite || squash != networkfsv1.SquashNone) {
+			return fmt.Errorf("accessRules[%d]: exportBackend %s only supports access %s and squash %s", i, backendType, networkfsv1.AccessReadWrite, networkfsv1.SquashNone)
+		}
+		if protocol == networkfsv1.NetworkFSTypeSMB {
+			if squash != networkfsv1.SquashNone {
+				return fmt.Errorf("accessRules[%d]: squash is not supported by protocol %s", i, protocol)
+			}
+			if access != backend.AccessOf(rules[0]) {
+				return fmt.Errorf("accessRules[%d]: all rules must have the same access for protocol %s", i, protocol)
+			}
+		}
+	}
+	return nil
+}
+
+// validateService checks the managed service of the export
+func validateService(service networkfsv1.ExportService) error {
+	switch service.Type {
+	case "", corev1.ServiceTypeClusterIP:
+		if service.LoadBalancerIP != "" {
+			return fmt.Errorf("service.loadBalancerIP is only allowed by the service type %s", corev1.ServiceTypeLoadBalancer)
+		}
+	case corev1.ServiceTypeLoadBalancer:
+		if service.LoadBalancerIP != "" && net.ParseIP(service.LoadBalancerIP) == nil {
+			return fmt.Errorf("invalid service.loadBalancerIP %q", service.LoadBalancerIP)
+		}
+	default:
+		return fmt.Errorf("invalid service.type %q, only %q and %q are allowed", service.Type, corev1.ServiceTypeClusterIP, corev1.ServiceTypeLoadBalancer)
+	}
+	return nil
+}
+
+// validateConnectionSecretRef checks the connection secret does not collide with the other secrets of the networkFS
+func validateConnectionSecretRef(networkFS *networkfsv1.NetworkFilesystem) error {
+	ref := networkFS.Spec.ConnectionSecretRef
+	if ref == nil {
+		return nil
+	}
+	if errs := validation.IsDNS1123Subdomain(ref.Name); len(errs) > 0 {
+		return fmt.Errorf("invalid connectionSecretRef.name %q: %s", ref.Name, strings.Join(errs, ", "))
+	}
+	if ref.Name == utils.CloudInitSecretName(networkFS) {
+		return fmt.Errorf("connectionSecretRef.name %q is reserved by the cloud-init secret", ref.Name)
+	}
+	if smbRef := networkFS.Spec.SMBCredentialsSecretRef; smbRef != nil && smbRef.Name == ref.Name {
+		return fmt.Errorf("connectionSecretRef.name %q can not be the smb credentials secret", ref.Name)
+	}
+	return nil
+}
+
+// validateSMBCredentials checks the SMB credentials secret exists and contains the username and password
+func (v *networkFSValidator) validateSMBCredentials(networkFS *networkfsv1.NetworkFilesystem) error {
+	ref := networkFS.Spec.SMBCredentialsSecretRef
+	if ref == nil || ref.Name == "" {
+		return fmt.Errorf("smbCredentialsSecretRef is required by the SMB protocol")
+	}
+	secret, err := v.client.CoreV1().Secrets(networkFS.Namespace).Get(context.TODO(), ref.Name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("smb credentials secret %s/%s is not found", networkFS.Namespace, ref.Name)
+		}
+		return fmt.Errorf("failed to get smb credentials secret %s/%s: %w", networkFS.Namespace, ref.Name, err)
+	}
+	for _, key := range []string{ganesha.SMBUsernameKey, ganesha.SMBPasswordKey} {
+		if len(secret.Data[key]) == 0 {
+			return fmt.Errorf("smb credentials secret %s/%s does not contain %q", networkFS.Namespace, ref.Name, key)
+		}
+	}
+	return nil
+}
+
+func (v *networkFSValidator) validateVolume(name string) error {
+	if _, err := v.lhClient.LonghornV1beta2().Volumes(utils.LHNameSpace).Get(context.TODO(), name, metav1.GetOptions{}); err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("longhorn volume %s is not found", name)
+		}
+		return fmt.Errorf("failed to get longhorn volume %s: %w", name, err)
+	}
+	return nil
+}
+
+// validateNode checks the node exists (as a Longhorn node for the Longhorn backend), and is schedulable when requireSchedulable is set
+func (v *networkFSValidator) validateNode(backendType networkfsv1.ExportBackendType, name string, requireSchedulable bool) error {
+	if backendType == networkfsv1.ExportBackendGanesha {
+		node, err := v.client.CoreV1().Nodes().Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return fmt.Errorf("node %s is not found", name)
+			}
+			return fmt.Errorf("failed to get node %s: %w", name, err)
+		}
+		if requireSchedulable && node.Spec.Unschedulable {
+			return fmt.Errorf("node %s is not schedulable", name)
+		}
+		return nil
+	}
+
+	node, err := v.lhClient.LonghornV1beta2().Nodes(utils.LHNameSpace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("node %s is not a longhorn node", name)
+		}
+		return fmt.Errorf("failed to get longhorn node %s: %w", name, err)
+	}
+	if requireSchedulable && !utils.IsLHNodeSchedulable(node) {
+		return fmt.Errorf("node %s is not schedulable", name)
+	}
+	return nil
+}
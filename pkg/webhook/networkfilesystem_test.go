@@ -0,0 +1,156 @@
+package webhook
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	longhornv2 "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	lhfake "github.com/longhorn/longhorn-manager/k8s/pkg/client/clientset/versioned/fake"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+func rawExtension(t *testing.T, obj interface{}) runtime.RawExtension {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatalf("failed to encode %T: %v", obj, err)
+	}
+	return runtime.RawExtension{Raw: raw}
+}
+
+func testNetworkFS(state, desiredState networkfsv1.NetworkFSState) *networkfsv1.NetworkFilesystem {
+	return &networkfsv1.NetworkFilesystem{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc-1234", Namespace: "harvester-system"},
+		Spec: networkfsv1.NetworkFSSpec{
+			NetworkFSName: "pvc-1234",
+			DesiredState:  desiredState,
+		},
+		Status: networkfsv1.NetworkFSStatus{State: state},
+	}
+}
+
+func testLHNode(name string, schedulable bool) *longhornv2.Node {
+	status := longhornv2.ConditionStatusFalse
+	if schedulable {
+		status = longhornv2.ConditionStatusTrue
+	}
+	return &longhornv2.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: utils.LHNameSpace},
+		Spec:       longhornv2.NodeSpec{AllowScheduling: true},
+		Status: longhornv2.NodeStatus{
+			Conditions: []longhornv2.Condition{{Type: longhornv2.NodeConditionTypeSchedulable, Status: status}},
+		},
+	}
+}
+
+func TestNetworkFSValidator(t *testing.T) {
+	tests := []struct {
+		name      string
+		old       *networkfsv1.NetworkFilesystem
+		networkFS *networkfsv1.NetworkFilesystem
+		wantErr   string
+	}{
+		{
+			name:      "valid network filesystem",
+			networkFS: testNetworkFS("", networkfsv1.NetworkFSStateEnabled),
+		},
+		{
+			name:      "invalid desiredState",
+			networkFS: testNetworkFS("", "Running"),
+			wantErr:   `invalid desiredState "Running"`,
+		},
+		{
+			name: "volume is not found",
+			networkFS: func() *networkfsv1.NetworkFilesystem {
+				networkFS := testNetworkFS("", networkfsv1.NetworkFSStateEnabled)
+				networkFS.Spec.NetworkFSName = "pvc-missing"
+				return networkFS
+			}(),
+			wantErr: "longhorn volume pvc-missing is not found",
+		},
+		{
+			name: "preferred node is not a longhorn node",
+			networkFS: func() *networkfsv1.NetworkFilesystem {
+				networkFS := testNetworkFS("", networkfsv1.NetworkFSStateEnabled)
+				networkFS.Spec.PreferredNode = "node-missing"
+				return networkFS
+			}(),
+			wantErr: "node-missing is not a longhorn node",
+		},
+		{
+			name: "preferred node is not schedulable",
+			networkFS: func() *networkfsv1.NetworkFilesystem {
+				networkFS := testNetworkFS("", networkfsv1.NetworkFSStateEnabled)
+				networkFS.Spec.PreferredNode = "node-2"
+				return networkFS
+			}(),
+			wantErr: "node-2 is not schedulable",
+		},
+		{
+			name: "status update during the transition",
+			old:  testNetworkFS(networkfsv1.NetworkFSStateEnabling, networkfsv1.NetworkFSStateEnabled),
+			networkFS: func() *networkfsv1.NetworkFilesystem {
+				networkFS := testNetworkFS(networkfsv1.NetworkFSStateEnabled, networkfsv1.NetworkFSStateEnabled)
+				networkFS.Status.Endpoint = "10.53.0.10"
+				return networkFS
+			}(),
+		},
+		{
+			name: "spec update during the transition",
+			old:  testNetworkFS(networkfsv1.NetworkFSStateEnabling, networkfsv1.NetworkFSStateEnabled),
+			networkFS: func() *networkfsv1.NetworkFilesystem {
+				networkFS := testNetworkFS(networkfsv1.NetworkFSStateEnabling, networkfsv1.NetworkFSStateEnabled)
+				networkFS.Spec.PreferredNode = "node-1"
+				return networkFS
+			}(),
+			wantErr: "spec can not be changed until the transition is finished",
+		},
+		{
+			name:      "disable during the transition",
+			old:       testNetworkFS(networkfsv1.NetworkFSStateEnabling, networkfsv1.NetworkFSStateEnabled),
+			networkFS: testNetworkFS(networkfsv1.NetworkFSStateEnabling, networkfsv1.NetworkFSStateDisabled),
+		},
+		{
+			name: "preferred node update of the disabled network filesystem",
+			old:  testNetworkFS(networkfsv1.NetworkFSStateDisabled, networkfsv1.NetworkFSStateDisabled),
+			networkFS: func() *networkfsv1.NetworkFilesystem {
+				networkFS := testNetworkFS(networkfsv1.NetworkFSStateDisabled, networkfsv1.NetworkFSStateDisabled)
+				networkFS.Spec.PreferredNode = "node-1"
+				return networkFS
+			}(),
+		},
+	}
+
+	lhClient := lhfake.NewSimpleClientset(
+		&longhornv2.Volume{ObjectMeta: metav1.ObjectMeta{Name: "pvc-1234", Namespace: utils.LHNameSpace}},
+		testLHNode("node-1", true),
+		testLHNode("node-2", false),
+	)
+	v := &networkFSValidator{lhClient: lhClient}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := &admissionv1.AdmissionRequest{Object: rawExtension(t, tt.networkFS)}
+			var err error
+			if tt.old == nil {
+				err = v.Create(request)
+			} else {
+				request.OldObject = rawExtension(t, tt.old)
+				err = v.Update(request)
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+type networkFSExportValidator struct {
+	client kubernetes.Interface
+}
+
+// NewNetworkFSExportValidator creates the validator of the NetworkFilesystemExport
+func NewNetworkFSExportValidator(client kubernetes.Interface) Validator {
+	return &networkFSExportValidator{
+		client: client,
+	}
+}
+
+func (v *networkFSExportValidator) Kind() string {
+	return "NetworkFilesystemExport"
+}
+
+func (v *networkFSExportValidator) Create(request *admissionv1.AdmissionRequest) error {
+	export := &networkfsv1.NetworkFilesystemExport{}
+	if err := json.Unmarshal(request.Object.Raw, export); err != nil {
+		return fmt.Errorf("failed to decode networkfilesystemexport: %w", err)
+	}
+
+	return v.validateSpec(nil, export)
+}
+
+func (v *networkFSExportValidator) Update(request *admissionv1.AdmissionRequest) error {
+	oldExport := &networkfsv1.NetworkFilesystemExport{}
+	if err := json.Unmarshal(request.OldObject.Raw, oldExport); err != nil {
+		return fmt.Errorf("failed to decode old networkfilesystemexport: %w", err)
+	}
+	export := &networkfsv1.NetworkFilesystemExport{}
+	if err := json.Unmarshal(request.Object.Raw, export); err != nil {
+		return fmt.Errorf("failed to decode networkfilesystemexport: %w", err)
+	}
+
+	// status and metadata updates are always allowed, e.g. the finalizer is removed once the guest volume is released
+	if reflect.DeepEqual(oldExport.Spec, export.Spec) {
+		return nil
+	}
+
+	return v.validateSpec(oldExport, export)
+}
+
+func (v *networkFSExportValidator) validateSpec(oldExport, export *networkfsv1.NetworkFilesystemExport) error {
+	if export.Spec.NetworkFilesystemName == "" {
+		return fmt.Errorf("networkFilesystemName can not be empty")
+	}
+	if err := validatePersistentVolume(export.Spec.PersistentVolume); err != nil {
+		return err
+	}
+	if claim := export.Spec.PersistentVolumeClaim; claim != nil {
+		if errs := validation.IsDNS1123Label(claim.Namespace); len(errs) > 0 {
+			return fmt.Errorf("invalid persistentVolumeClaim.namespace %q: %s", claim.Namespace, strings.Join(errs, ", "))
+		}
+		if errs := validation.IsDNS1123Subdomain(claim.Name); len(errs) > 0 {
+			return fmt.Errorf("invalid persistentVolumeClaim.name %q: %s", claim.Name, strings.Join(errs, ", "))
+		}
+	}
+
+	if oldExport == nil || !reflect.DeepEqual(oldExport.Spec.KubeconfigSecretRef, export.Spec.KubeconfigSecretRef) || oldExport.Spec.KubeconfigKey != export.Spec.KubeconfigKey {
+		return v.validateKubeconfigSecret(export)
+	}
+	return nil
+}
+
+// validatePersistentVolume checks the PV could be created in the guest cluster
+func validatePersistentVolume(pv networkfsv1.GuestPersistentVolume) error {
+	if errs := validation.IsDNS1123Subdomain(pv.Name); len(errs) > 0 {
+		return fmt.Errorf("invalid persistentVolume.name %q: %s", pv.Name, strings.Join(errs, ", "))
+	}
+	if pv.Capacity.Sign() <= 0 {
+		return fmt.Errorf("invalid persistentVolume.capacity %s, it should be positive", pv.Capacity.String())
+	}
+	for i, mode := range pv.AccessModes {
+		switch mode {
+		case corev1.ReadWriteOnce, corev1.ReadOnlyMany, corev1.ReadWriteMany, corev1.ReadWriteOncePod:
+		default:
+			return fmt.Errorf("persistentVolume.accessModes[%d] has invalid access mode %q", i, mode)
+		}
+	}
+	if pv.StorageClassName != "" {
+		if errs := validation.IsDNS1123Subdomain(pv.StorageClassName); len(errs) > 0 {
+			return fmt.Errorf("invalid persistentVolume.storageClassName %q: %s", pv.StorageClassName, strings.Join(errs, ", "))
+		}
+	}
+	if err := utils.ValidateNFSMountOptions(pv.MountOptions); err != nil {
+		return fmt.Errorf("invalid persistentVolume.mountOptions: %w", err)
+	}
+	return nil
+}
+
+// validateKubeconfigSecret checks the kubeconfig secret exists and contains the kubeconfig
+func (v *networkFSExportValidator) validateKubeconfigSecret(export *networkfsv1.NetworkFilesystemExport) error {
+	ref := export.Spec.KubeconfigSecretRef
+	if ref.Name == "" {
+		return fmt.Errorf("kubeconfigSecretRef.name can not be empty")
+	}
+	secret, err := v.client.CoreV1().Secrets(export.Namespace).Get(context.TODO(), ref.Name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("kubeconfig secret %s/%s is not found", export.Namespace, ref.Name)
+		}
+		return fmt.Errorf("failed to get kubeconfig secret %s/%s: %w", export.Namespace, ref.Name, err)
+	}
+	key := utils.KubeconfigKey(export)
+	if len(secret.Data[key]) == 0 {
+		return fmt.Errorf("kubeconfig secret %s/%s does not contain %q", export.Namespace, ref.Name, key)
+	}
+	return nil
+}
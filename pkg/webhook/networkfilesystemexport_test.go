@@ -0,0 +1,144 @@
+package webhook
+
+import (
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+)
+
+func testExport(modify func(*networkfsv1.NetworkFilesystemExport)) *networkfsv1.NetworkFilesystemExport {
+	export := &networkfsv1.NetworkFilesystemExport{
+		ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "default"},
+		Spec: networkfsv1.NetworkFSExportSpec{
+			NetworkFilesystemName: "pvc-1234",
+			KubeconfigSecretRef:   corev1.LocalObjectReference{Name: "guest-kubeconfig"},
+			PersistentVolume: networkfsv1.GuestPersistentVolume{
+				Name:        "pvc-1234",
+				Capacity:    resource.MustParse("10Gi"),
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+			},
+			PersistentVolumeClaim: &networkfsv1.GuestPersistentVolumeClaim{Namespace: "default", Name: "data"},
+		},
+	}
+	if modify != nil {
+		modify(export)
+	}
+	return export
+}
+
+func TestNetworkFSExportValidator(t *testing.T) {
+	tests := []struct {
+		name    string
+		old     *networkfsv1.NetworkFilesystemExport
+		export  *networkfsv1.NetworkFilesystemExport
+		wantErr string
+	}{
+		{
+			name:   "valid export",
+			export: testExport(nil),
+		},
+		{
+			name: "kubeconfig of another key",
+			export: testExport(func(export *networkfsv1.NetworkFilesystemExport) {
+				export.Spec.KubeconfigKey = "value"
+			}),
+		},
+		{
+			name: "kubeconfig secret is not found",
+			export: testExport(func(export *networkfsv1.NetworkFilesystemExport) {
+				export.Spec.KubeconfigSecretRef.Name = "missing"
+			}),
+			wantErr: "kubeconfig secret default/missing is not found",
+		},
+		{
+			name: "kubeconfig key is not found",
+			export: testExport(func(export *networkfsv1.NetworkFilesystemExport) {
+				export.Spec.KubeconfigKey = "config"
+			}),
+			wantErr: `kubeconfig secret default/guest-kubeconfig does not contain "config"`,
+		},
+		{
+			name: "invalid PV name",
+			export: testExport(func(export *networkfsv1.NetworkFilesystemExport) {
+				export.Spec.PersistentVolume.Name = "PVC_1234"
+			}),
+			wantErr: `invalid persistentVolume.name "PVC_1234"`,
+		},
+		{
+			name: "zero capacity",
+			export: testExport(func(export *networkfsv1.NetworkFilesystemExport) {
+				export.Spec.PersistentVolume.Capacity = resource.MustParse("0")
+			}),
+			wantErr: "invalid persistentVolume.capacity 0, it should be positive",
+		},
+		{
+			name: "invalid access mode",
+			export: testExport(func(export *networkfsv1.NetworkFilesystemExport) {
+				export.Spec.PersistentVolume.AccessModes = []corev1.PersistentVolumeAccessMode{"ReadWriteAll"}
+			}),
+			wantErr: `persistentVolume.accessModes[0] has invalid access mode "ReadWriteAll"`,
+		},
+		{
+			name: "mount option which is not allowed",
+			export: testExport(func(export *networkfsv1.NetworkFilesystemExport) {
+				export.Spec.PersistentVolume.MountOptions = "vers=4.1,dev"
+			}),
+			wantErr: `invalid persistentVolume.mountOptions: mount option "dev" is not allowed`,
+		},
+		{
+			name: "invalid PVC namespace",
+			export: testExport(func(export *networkfsv1.NetworkFilesystemExport) {
+				export.Spec.PersistentVolumeClaim.Namespace = "kube.system"
+			}),
+			wantErr: `invalid persistentVolumeClaim.namespace "kube.system"`,
+		},
+		{
+			// the kubeconfig secret is only checked when it changes, so the export can be released after it is removed
+			name: "update without the kubeconfig secret",
+			old: testExport(func(export *networkfsv1.NetworkFilesystemExport) {
+				export.Spec.KubeconfigSecretRef.Name = "removed"
+			}),
+			export: testExport(func(export *networkfsv1.NetworkFilesystemExport) {
+				export.Spec.KubeconfigSecretRef.Name = "removed"
+				export.Spec.PersistentVolume.MountOptions = "vers=4.2"
+			}),
+		},
+	}
+
+	client := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "guest-kubeconfig", Namespace: "default"},
+		Data: map[string][]byte{
+			"kubeconfig": []byte("apiVersion: v1\nkind: Config\n"),
+			"value":      []byte("apiVersion: v1\nkind: Config\n"),
+		},
+	})
+	v := NewNetworkFSExportValidator(client)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := &admissionv1.AdmissionRequest{Object: rawExtension(t, tt.export)}
+			var err error
+			if tt.old == nil {
+				err = v.Create(request)
+			} else {
+				request.OldObject = rawExtension(t, tt.old)
+				err = v.Update(request)
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
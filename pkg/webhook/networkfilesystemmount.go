@@ -0,0 +1,75 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+type networkFSMountValidator struct {
+	hostPathPrefix string
+}
+
+// NewNetworkFSMountValidator creates the validator of the NetworkFilesystemMount, the host paths must be below the prefix
+func NewNetworkFSMountValidator(hostPathPrefix string) Validator {
+	return &networkFSMountValidator{
+		hostPathPrefix: hostPathPrefix,
+	}
+}
+
+func (v *networkFSMountValidator) Kind() string {
+	return "NetworkFilesystemMount"
+}
+
+func (v *networkFSMountValidator) Create(request *admissionv1.AdmissionRequest) error {
+	mount := &networkfsv1.NetworkFilesystemMount{}
+	if err := json.Unmarshal(request.Object.Raw, mount); err != nil {
+		return fmt.Errorf("failed to decode networkfilesystemmount: %w", err)
+	}
+
+	return v.validateSpec(mount)
+}
+
+func (v *networkFSMountValidator) Update(request *admissionv1.AdmissionRequest) error {
+	oldMount := &networkfsv1.NetworkFilesystemMount{}
+	if err := json.Unmarshal(request.OldObject.Raw, oldMount); err != nil {
+		return fmt.Errorf("failed to decode old networkfilesystemmount: %w", err)
+	}
+	mount := &networkfsv1.NetworkFilesystemMount{}
+	if err := json.Unmarshal(request.Object.Raw, mount); err != nil {
+		return fmt.Errorf("failed to decode networkfilesystemmount: %w", err)
+	}
+
+	// status and metadata updates are always allowed, e.g. the agents remove their finalizers
+	if reflect.DeepEqual(oldMount.Spec, mount.Spec) {
+		return nil
+	}
+	// the agents only know the current host path, the networkFS would stay mounted on the previous one
+	if oldMount.Spec.HostPath != mount.Spec.HostPath {
+		return fmt.Errorf("hostPath of networkfilesystemmount %s can not be changed, create another networkfilesystemmount instead", mount.Name)
+	}
+
+	return v.validateSpec(mount)
+}
+
+func (v *networkFSMountValidator) validateSpec(mount *networkfsv1.NetworkFilesystemMount) error {
+	if mount.Spec.NetworkFilesystemName == "" {
+		return fmt.Errorf("networkFilesystemName can not be empty")
+	}
+	if err := utils.ValidateMountHostPath(mount.Spec.HostPath, v.hostPathPrefix); err != nil {
+		return err
+	}
+	if err := utils.ValidateNFSMountOptions(mount.Spec.MountOptions); err != nil {
+		return fmt.Errorf("invalid mountOptions: %w", err)
+	}
+	if _, err := labels.ValidatedSelectorFromSet(mount.Spec.NodeSelector); err != nil {
+		return fmt.Errorf("invalid nodeSelector: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package webhook
+
+import (
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networkfsv1 "github.com/Vicente-Cheng/networkfs-manager/pkg/apis/harvesterhci.io/v1beta1"
+)
+
+func testMount(hostPath, mountOptions string) *networkfsv1.NetworkFilesystemMount {
+	return &networkfsv1.NetworkFilesystemMount{
+		ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "default"},
+		Spec: networkfsv1.NetworkFSMountSpec{
+			NetworkFilesystemName: "pvc-1234",
+			HostPath:              hostPath,
+			MountOptions:          mountOptions,
+		},
+	}
+}
+
+func TestNetworkFSMountValidator(t *testing.T) {
+	tests := []struct {
+		name    string
+		old     *networkfsv1.NetworkFilesystemMount
+		mount   *networkfsv1.NetworkFilesystemMount
+		wantErr string
+	}{
+		{
+			name:  "valid mount",
+			mount: testMount("/mnt/data", "vers=4.1,hard,nosuid"),
+		},
+		{
+			name:    "host path outside the prefix",
+			mount:   testMount("/etc/data", ""),
+			wantErr: `hostPath "/etc/data" must be below /mnt`,
+		},
+		{
+			name:    "host path escaping the prefix",
+			mount:   testMount("/mnt/../etc", ""),
+			wantErr: `must not contain ".."`,
+		},
+		{
+			name:    "mount option which is not allowed",
+			mount:   testMount("/mnt/data", "vers=4.1,suid"),
+			wantErr: `invalid mountOptions: mount option "suid" is not allowed`,
+		},
+		{
+			name:    "empty networkFilesystemName",
+			mount:   &networkfsv1.NetworkFilesystemMount{Spec: networkfsv1.NetworkFSMountSpec{HostPath: "/mnt/data"}},
+			wantErr: "networkFilesystemName can not be empty",
+		},
+		{
+			name:  "update of the mount options",
+			old:   testMount("/mnt/data", ""),
+			mount: testMount("/mnt/data", "vers=4.2"),
+		},
+		{
+			name:    "update of the host path",
+			old:     testMount("/mnt/data", ""),
+			mount:   testMount("/mnt/other", ""),
+			wantErr: "hostPath of networkfilesystemmount data can not be changed",
+		},
+		{
+			// the mount created before the prefix changed can still be released by the agents
+			name: "status update of the mount outside the prefix",
+			old:  testMount("/data", ""),
+			mount: func() *networkfsv1.NetworkFilesystemMount {
+				mount := testMount("/data", "")
+				mount.Finalizers = []string{"networkfs.harvesterhci.io/node-node-1"}
+				return mount
+			}(),
+		},
+	}
+
+	v := NewNetworkFSMountValidator("/mnt")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := &admissionv1.AdmissionRequest{Object: rawExtension(t, tt.mount)}
+			var err error
+			if tt.old == nil {
+				err = v.Create(request)
+			} else {
+				request.OldObject = rawExtension(t, tt.old)
+				err = v.Update(request)
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
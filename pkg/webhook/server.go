@@ -0,0 +1,215 @@
+package webhook
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	lhclientset "github.com/longhorn/longhorn-manager/k8s/pkg/client/clientset/versioned"
+	"github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/Vicente-Cheng/networkfs-manager/pkg/utils"
+)
+
+const (
+	// ValidationPath is the path of the validating admission webhook
+	ValidationPath = "/v1/webhook/validation"
+
+	maxRequestBodyBytes  = 3 * 1024 * 1024
+	caBundleResyncPeriod = 5 * time.Minute
+)
+
+// Validator validates the admission request of one kind of resource
+type Validator interface {
+	// Kind returns the kind of the resource handled by the validator
+	Kind() string
+	Create(request *admissionv1.AdmissionRequest) error
+	Update(request *admissionv1.AdmissionRequest) error
+}
+
+type Server struct {
+	namespace   string
+	webhookName string
+	serviceName string
+	port        int
+
+	client     kubernetes.Interface
+	crdClient  apiextensionsclient.Interface
+	validators map[string]Validator
+}
+
+// NewServer creates the admission webhook server
+func NewServer(client kubernetes.Interface, crdClient apiextensionsclient.Interface, lhClient lhclientset.Interface, opt *utils.Option) *Server {
+	s := &Server{
+		namespace:   opt.Namespace,
+		webhookName: opt.WebhookName,
+		serviceName: opt.WebhookServiceName,
+		port:        opt.WebhookPort,
+		client:      client,
+		crdClient:   crdClient,
+		validators:  map[string]Validator{},
+	}
+	s.register(NewNetworkFSValidator(client, lhClient))
+	s.register(NewNetworkFSMountValidator(opt.MountHostPathPrefix))
+	s.register(NewNetworkFSExportValidator(client))
+	return s
+}
+
+func (s *Server) register(v Validator) {
+	s.validators[v.Kind()] = v
+}
+
+// ListenAndServe bootstraps the certificate and serves the webhooks until the context is done
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	cert, caPEM, err := ensureCertificate(ctx, s.client, s.namespace, s.serviceName+"-tls", s.serviceName)
+	if err != nil {
+		return err
+	}
+	if err := s.injectCABundles(ctx, caPEM); err != nil {
+		return err
+	}
+	// chart upgrades re-apply the webhook configuration and CRD without the CA bundle
+	go wait.Until(func() {
+		if err := s.injectCABundles(ctx, caPEM); err != nil {
+			logrus.Warnf("Failed to re-inject webhook CA bundle: %v", err)
+		}
+	}, caBundleResyncPeriod, ctx.Done())
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(ValidationPath, s.serveValidation)
+	mux.HandleFunc(ConversionPath, s.serveConversion)
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", s.port),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig: &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{*cert},
+		},
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	logrus.Infof("Webhook server is listening on %s", srv.Addr)
+	if err := srv.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) injectCABundles(ctx context.Context, caPEM []byte) error {
+	if err := s.injectCABundle(ctx, caPEM); err != nil {
+		return err
+	}
+	return s.injectConversionCABundle(ctx, caPEM)
+}
+
+// injectCABundle makes the apiserver trust our self-signed certificate
+func (s *Server) injectCABundle(ctx context.Context, caPEM []byte) error {
+	configs := s.client.AdmissionregistrationV1().ValidatingWebhookConfigurations()
+	config, err := configs.Get(ctx, s.webhookName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			logrus.Warnf("ValidatingWebhookConfiguration %s is not found, skip injecting the CA bundle", s.webhookName)
+			return nil
+		}
+		return fmt.Errorf("failed to get ValidatingWebhookConfiguration %s: %w", s.webhookName, err)
+	}
+
+	configCpy := config.DeepCopy()
+	changed := false
+	for i := range configCpy.Webhooks {
+		if string(configCpy.Webhooks[i].ClientConfig.CABundle) != string(caPEM) {
+			configCpy.Webhooks[i].ClientConfig.CABundle = caPEM
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	if _, err := configs.Update(ctx, configCpy, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update ValidatingWebhookConfiguration %s: %w", s.webhookName, err)
+	}
+	return nil
+}
+
+func (s *Server) serveValidation(w http.ResponseWriter, r *http.Request) {
+	review := &admissionv1.AdmissionReview{}
+	if err := decodeBody(r, review); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if review.Request == nil {
+		http.Error(w, "admission review contains no request", http.StatusBadRequest)
+		return
+	}
+
+	review.Response = s.validate(review.Request)
+	review.Response.UID = review.Request.UID
+	review.Request = nil
+	writeResponse(w, review)
+}
+
+func (s *Server) validate(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	validator, found := s.validators[request.Kind.Kind]
+	if !found {
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+
+	var err error
+	switch request.Operation {
+	case admissionv1.Create:
+		err = validator.Create(request)
+	case admissionv1.Update:
+		err = validator.Update(request)
+	}
+	if err != nil {
+		logrus.Infof("Reject %s %s %s/%s: %v", request.Operation, request.Kind.Kind, request.Namespace, request.Name, err)
+		return &admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result: &metav1.Status{
+				Status:  metav1.StatusFailure,
+				Code:    http.StatusUnprocessableEntity,
+				Reason:  metav1.StatusReasonInvalid,
+				Message: err.Error(),
+			},
+		}
+	}
+	return &admissionv1.AdmissionResponse{Allowed: true}
+}
+
+func decodeBody(r *http.Request, into interface{}) error {
+	if r.Method != http.MethodPost {
+		return fmt.Errorf("unsupported method %s", r.Method)
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBodyBytes))
+	if err != nil {
+		return fmt.Errorf("failed to read request body: %w", err)
+	}
+	if err := json.Unmarshal(body, into); err != nil {
+		return fmt.Errorf("failed to decode request body: %w", err)
+	}
+	return nil
+}
+
+func writeResponse(w http.ResponseWriter, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(obj); err != nil {
+		logrus.Errorf("Failed to write webhook response: %v", err)
+	}
+}